@@ -0,0 +1,168 @@
+package apitokenservice
+
+import (
+	"context"
+	"errors"
+	"filmPackager/internal/domain/apitoken"
+	"filmPackager/internal/domain/membership"
+	"filmPackager/internal/domain/project"
+	"filmPackager/internal/domain/user"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// the expiry choices in days offered on the settings page, 0 never expires
+var ExpiryOptions = []int{7, 30, 90, 365, 0}
+
+type APITokenService struct {
+	tokenRepo  apitoken.TokenRepository
+	userRepo   user.UserRepository
+	memberRepo membership.MembershipRepository
+	projRepo   project.ProjectRepository
+}
+
+func NewAPITokenService(tokenRepo apitoken.TokenRepository, userRepo user.UserRepository, memberRepo membership.MembershipRepository, projRepo project.ProjectRepository) *APITokenService {
+	return &APITokenService{tokenRepo: tokenRepo, userRepo: userRepo, memberRepo: memberRepo, projRepo: projRepo}
+}
+
+type TokenOverview struct {
+	Token        apitoken.Token
+	ProjectNames []string
+	Expired      bool
+}
+
+type GetTokensResponse struct {
+	Tokens []TokenOverview
+	// the projects a new token can be limited to
+	Projects      []project.Project
+	Scopes        []string
+	ExpiryOptions []int
+	// only set right after a token is created so it can be copied
+	NewToken string
+}
+
+// Authenticate returns the owner of a plain token, expired and unknown tokens are rejected
+func (s *APITokenService) Authenticate(ctx context.Context, plain string) (*user.User, *apitoken.Token, error) {
+	if !apitoken.LooksLikeToken(plain) {
+		return nil, nil, apitoken.ErrTokenNotFound
+	}
+
+	t, err := s.tokenRepo.GetByHash(ctx, apitoken.Hash(plain))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	if t.IsExpired(now) {
+		return nil, nil, apitoken.ErrTokenExpired
+	}
+
+	u, err := s.userRepo.GetUserById(ctx, t.UserID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting user: %v", err)
+	}
+
+	// a failed timestamp shouldn't fail the request
+	err = s.tokenRepo.UpdateLastUsed(ctx, t.ID, now)
+	if err != nil {
+		log.Printf("error updating api token last used: %v", err)
+	}
+	t.LastUsedAt = &now
+
+	return u, t, nil
+}
+
+func (s *APITokenService) GetTokens(ctx context.Context, userID uuid.UUID) (*GetTokensResponse, error) {
+	tokens, err := s.tokenRepo.GetUserTokens(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting api tokens: %v", err)
+	}
+
+	projects, err := s.getAcceptedProjects(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// see api_token_utils.go
+	return &GetTokensResponse{
+		Tokens:        buildTokenOverviews(tokens, projects, time.Now()),
+		Projects:      projects,
+		Scopes:        apitoken.Scopes,
+		ExpiryOptions: ExpiryOptions,
+	}, nil
+}
+
+func (s *APITokenService) CreateToken(ctx context.Context, userID uuid.UUID, name, scope string, projectIDs []uuid.UUID, expiresInDays int) (*GetTokensResponse, error) {
+	// see api_token_utils.go
+	err := validateToken(name, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	projects, err := s.getAcceptedProjects(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = validateTokenProjects(projectIDs, projects)
+	if err != nil {
+		return nil, err
+	}
+
+	var expiresAt *time.Time
+	if expiresInDays > 0 {
+		at := time.Now().AddDate(0, 0, expiresInDays)
+		expiresAt = &at
+	}
+
+	t, plain := apitoken.NewToken(userID, name, scope, projectIDs, expiresAt)
+
+	err = s.tokenRepo.CreateToken(ctx, t)
+	if err != nil {
+		return nil, fmt.Errorf("error creating api token: %v", err)
+	}
+
+	rv, err := s.GetTokens(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	rv.NewToken = plain
+
+	return rv, nil
+}
+
+func (s *APITokenService) RevokeToken(ctx context.Context, userID, tokenID uuid.UUID) (*GetTokensResponse, error) {
+	err := s.tokenRepo.DeleteToken(ctx, userID, tokenID)
+	if err != nil {
+		if errors.Is(err, apitoken.ErrTokenNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("error revoking api token: %v", err)
+	}
+
+	return s.GetTokens(ctx, userID)
+}
+
+func (s *APITokenService) getAcceptedProjects(ctx context.Context, userID uuid.UUID) ([]project.Project, error) {
+	memberships, err := s.memberRepo.GetAllUserMemberships(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting user memberships: %v", err)
+	}
+
+	projIDs := []uuid.UUID{}
+	for _, m := range memberships {
+		if m.InviteStatus == "accepted" {
+			projIDs = append(projIDs, m.ProjectID)
+		}
+	}
+
+	projects, err := s.projRepo.GetProjectsByMembershipIDs(ctx, projIDs)
+	if err != nil {
+		return nil, fmt.Errorf("error getting projects: %v", err)
+	}
+
+	return projects, nil
+}
@@ -0,0 +1,61 @@
+package apitokenservice
+
+import (
+	"filmPackager/internal/domain/apitoken"
+	"filmPackager/internal/domain/project"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func validateToken(name, scope string) error {
+	if strings.TrimSpace(name) == "" {
+		return apitoken.ErrInvalidName
+	}
+
+	if !apitoken.IsValidScope(scope) {
+		return apitoken.ErrInvalidScope
+	}
+
+	return nil
+}
+
+// a token can only be limited to projects the user has accepted
+func validateTokenProjects(projectIDs []uuid.UUID, projects []project.Project) error {
+	allowed := make(map[uuid.UUID]bool)
+	for _, p := range projects {
+		allowed[p.ID] = true
+	}
+
+	for _, id := range projectIDs {
+		if !allowed[id] {
+			return apitoken.ErrInvalidProject
+		}
+	}
+
+	return nil
+}
+
+func buildTokenOverviews(tokens []apitoken.Token, projects []project.Project, now time.Time) []TokenOverview {
+	projMap := make(map[uuid.UUID]string)
+	for _, p := range projects {
+		projMap[p.ID] = p.Name
+	}
+
+	rv := []TokenOverview{}
+	for _, t := range tokens {
+		o := TokenOverview{Token: t, Expired: t.IsExpired(now)}
+		for _, id := range t.ProjectIDs {
+			// projects the user has since left show up as removed
+			name, ok := projMap[id]
+			if !ok {
+				name = "(removed project)"
+			}
+			o.ProjectNames = append(o.ProjectNames, name)
+		}
+		rv = append(rv, o)
+	}
+
+	return rv
+}
@@ -3,25 +3,51 @@ package authservice
 import (
 	"context"
 	"errors"
+	"filmPackager/internal/application/middleware/auth/signer"
+	"filmPackager/internal/domain/lockout"
+	"filmPackager/internal/domain/session"
+	"filmPackager/internal/domain/twofactor"
 	"filmPackager/internal/domain/user"
+	"log"
+	"slices"
+	"strings"
 	"time"
 
 	"fmt"
-	"os"
 
 	"github.com/golang-jwt/jwt"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// Sender delivers a plain text email, see email.SendEmail
+type Sender func(to, subject, body string) error
+
 type AuthService struct {
-	UserRepo user.UserRepository
+	UserRepo    user.UserRepository
+	AttemptRepo lockout.AttemptRepository
+	SessionRepo session.SessionRepository
+	// the users' authenticator apps, see twofactor.TwoFactor
+	TwoFactorRepo twofactor.TwoFactorRepository
+	// signs and checks the session tokens
+	Signer    *signer.Signer
+	SendEmail Sender
+	Policy    lockout.Policy
+	// the site admins who can unlock accounts, matched case insensitively
+	AdminEmails []string
 }
 
-func NewAuthService(userRepo user.UserRepository) *AuthService {
-	return &AuthService{UserRepo: userRepo}
+func NewAuthService(userRepo user.UserRepository, attemptRepo lockout.AttemptRepository, sessionRepo session.SessionRepository, twoFactorRepo twofactor.TwoFactorRepository, tokenSigner *signer.Signer, sendEmail Sender, adminEmails []string) *AuthService {
+	return &AuthService{UserRepo: userRepo, AttemptRepo: attemptRepo, SessionRepo: sessionRepo, TwoFactorRepo: twoFactorRepo, Signer: tokenSigner, SendEmail: sendEmail, Policy: lockout.DefaultPolicy, AdminEmails: adminEmails}
 }
 
+// the time between the password and the code from the authenticator app
+const challengeDuration = 5 * time.Minute
+
+// the subject of the tokens that carry a login from the password to the code, they
+// have no session so they're never accepted as a login themselves
+const challengeSubject = "two-factor"
+
 func HashPassword(password string) (string, error) {
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
@@ -31,28 +57,170 @@ func HashPassword(password string) (string, error) {
 	return hashedStr, nil
 }
 
-// Potential issue here in not reading the env first??
-var jwtKey = []byte(os.Getenv("JWT_SECRET_KEY"))
-
+// the token's Id (jti) is the session it belongs to
 type Claims struct {
 	UserID uuid.UUID
 	jwt.StandardClaims
 }
 
-func (s *AuthService) CreateLoginToken(ctx context.Context, email, password string) (string, error) {
+// LoginResponse is the result of a correct password, one of the tokens is set
+type LoginResponse struct {
+	// the login is done, the token goes in the session cookie
+	SessionToken string
+	// the user has two-factor authentication on, the token goes back with the code from their app
+	ChallengeToken string
+}
+
+// TwoFactorResponse is the user's two-factor authentication as shown on its settings page
+type TwoFactorResponse struct {
+	Enabled           bool
+	RecoveryCodesLeft int
+	// set while the user is setting it up
+	Setup *TwoFactorSetup
+}
+
+// TwoFactorSetup is what the user needs to add the account to their authenticator app
+type TwoFactorSetup struct {
+	Secret string
+	// the otpauth:// link, opened on a phone or turned into a QR code
+	URI string
+}
+
+// SessionResponse is one of the user's logins as shown on the sessions page
+type SessionResponse struct {
+	Session session.Session
+	Device  string
+	// the session the page is being viewed from
+	Current bool
+}
+
+// CreateLoginToken checks the password, slowing down and then locking out the account and the
+// address after repeated failures. Unknown emails and wrong passwords both return user.ErrInvalidPassword
+// so the response doesn't give away which emails have accounts. Users with two-factor authentication
+// on get a challenge token to send back with their code instead of a session
+func (s *AuthService) CreateLoginToken(ctx context.Context, email, password string, client session.Client) (*LoginResponse, error) {
+	if email == "" || password == "" {
+		return nil, user.ErrMissingLoginField
+	}
+
+	now := time.Now()
+
+	account, address, err := s.checkThrottle(ctx, email, client.IP, now)
+	if err != nil {
+		return nil, err
+	}
+
 	// get the user info from the token
 	u, err := s.UserRepo.GetUserByEmail(ctx, email)
+	if err != nil && !errors.Is(err, user.ErrUserNotFound) {
+		return nil, fmt.Errorf("error getting user by email: %v", err)
+	}
+
+	// compare the password
+	if u == nil || bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password)) != nil {
+		err = s.recordFailure(ctx, u, account, address, client.IP, now)
+		if err != nil {
+			return nil, err
+		}
+		return nil, user.ErrInvalidPassword
+	}
+
+	tf, err := s.getTwoFactor(ctx, u.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	// the failures are only cleared once the code is right too
+	if tf.IsEnabled() {
+		token, err := s.Signer.Sign(&Claims{
+			UserID: u.Id,
+			StandardClaims: jwt.StandardClaims{
+				Subject:   challengeSubject,
+				ExpiresAt: now.Add(challengeDuration).Unix(),
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error generating JWT: %v", err)
+		}
+		return &LoginResponse{ChallengeToken: token}, nil
+	}
+
+	// the address keeps its count, one good login shouldn't clear failures against other accounts
+	err = s.AttemptRepo.DeleteAttempts(ctx, account.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := s.startSession(ctx, u.Id, client)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoginResponse{SessionToken: token}, nil
+}
+
+// VerifyLoginCode finishes a login with the code from the user's authenticator app or one of their
+// recovery codes, wrong codes count towards locking out the account the same as wrong passwords
+func (s *AuthService) VerifyLoginCode(ctx context.Context, challengeToken, code string, client session.Client) (string, error) {
+	claims := &Claims{}
+	err := s.Signer.Parse(challengeToken, claims)
+	if err != nil || claims.Subject != challengeSubject {
+		return "", twofactor.ErrChallengeExpired
+	}
+
+	u, err := s.UserRepo.GetUserById(ctx, claims.UserID)
 	if err != nil {
 		return "", fmt.Errorf("error getting user by id: %v", err)
 	}
 
-	// compare the password
-	err = bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
+	now := time.Now()
+
+	account, address, err := s.checkThrottle(ctx, u.Email, client.IP, now)
+	if err != nil {
+		return "", err
+	}
+
+	tf, err := s.getTwoFactor(ctx, u.Id)
+	if err != nil {
+		return "", err
+	}
+
+	err = tf.Verify(code, now)
+	if errors.Is(err, twofactor.ErrInvalidCode) {
+		err = s.recordFailure(ctx, u, account, address, client.IP, now)
+		if err != nil {
+			return "", err
+		}
+		return "", twofactor.ErrInvalidCode
+	}
 	if err != nil {
-		return "", fmt.Errorf("invalid password: %v", err)
+		return "", err
 	}
 
-	token, err := GenerateJWT(u.Id)
+	// the used code or recovery code can't be used again
+	err = s.TwoFactorRepo.SaveTwoFactor(ctx, tf)
+	if err != nil {
+		return "", fmt.Errorf("error saving two-factor settings: %v", err)
+	}
+
+	err = s.AttemptRepo.DeleteAttempts(ctx, account.Key)
+	if err != nil {
+		return "", err
+	}
+
+	return s.startSession(ctx, u.Id, client)
+}
+
+// startSession records the login and returns the token for its cookie
+func (s *AuthService) startSession(ctx context.Context, userID uuid.UUID, client session.Client) (string, error) {
+	sess := session.NewSession(userID, client, time.Now())
+
+	err := s.SessionRepo.CreateSession(ctx, sess)
+	if err != nil {
+		return "", fmt.Errorf("error creating session: %v", err)
+	}
+
+	token, err := s.generateJWT(userID, sess.ID, sess.ExpiresAt)
 	if err != nil {
 		return "", fmt.Errorf("error generating JWT: %v", err)
 	}
@@ -60,36 +228,97 @@ func (s *AuthService) CreateLoginToken(ctx context.Context, email, password stri
 	return token, nil
 }
 
-func GenerateJWT(userID uuid.UUID) (string, error) {
-	expirationTime := time.Now().Add(48 * time.Hour) // valid for 48 hours
+func (s *AuthService) generateJWT(userID, sessionID uuid.UUID, expiresAt time.Time) (string, error) {
 	claims := &Claims{
 		UserID: userID,
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: expirationTime.Unix(),
+			Id:        sessionID.String(),
+			ExpiresAt: expiresAt.Unix(),
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(jwtKey)
+	return s.Signer.Sign(claims)
+}
+
+// Authenticate returns the user and the session a token belongs to, tokens from before sessions
+// were kept have no session and are turned away, as are the ones that have been logged out
+func (s *AuthService) Authenticate(ctx context.Context, tokenString string, client session.Client) (*user.User, *session.Session, error) {
+	claims := &Claims{}
+	err := s.Signer.Parse(tokenString, claims)
 	if err != nil {
-		return "", err
+		return nil, nil, err
+	}
+
+	sessionID, err := uuid.Parse(claims.Id)
+	if err != nil {
+		return nil, nil, session.ErrSessionNotFound
+	}
+
+	sess, err := s.SessionRepo.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, nil, err
 	}
-	return tokenString, nil
+
+	now := time.Now()
+	if !sess.IsActive(now) || sess.UserID != claims.UserID {
+		return nil, nil, session.ErrSessionExpired
+	}
+
+	u, err := s.UserRepo.GetUserById(ctx, sess.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// last seen is for the sessions page, so failing to update it doesn't fail the request
+	if sess.NeedsTouch(now, client.IP) {
+		err = s.SessionRepo.TouchSession(ctx, sess.ID, client.IP, now)
+		if err != nil {
+			log.Printf("error updating session %s: %v", sess.ID, err)
+		}
+		sess.LastSeenAt, sess.IP = now, client.IP
+	}
+
+	return u, sess, nil
 }
 
-func (s *AuthService) VerifyToken(tokenString string) error {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return jwtKey, nil
-	})
+// GetSessions returns the user's active logins, most recently used first
+func (s *AuthService) GetSessions(ctx context.Context, userID, currentID uuid.UUID) ([]SessionResponse, error) {
+	sessions, err := s.SessionRepo.GetActiveSessions(ctx, userID, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("error getting sessions: %v", err)
+	}
+
+	rv := []SessionResponse{}
+	for _, sess := range sessions {
+		rv = append(rv, SessionResponse{Session: sess, Device: sess.Device(), Current: sess.ID == currentID})
+	}
+
+	return rv, nil
+}
+
+// RevokeSession logs out one of the user's sessions, used for logging out too
+func (s *AuthService) RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) error {
+	err := s.SessionRepo.RevokeSession(ctx, userID, sessionID, time.Now())
 	if err != nil {
-		return err
+		if errors.Is(err, session.ErrSessionNotFound) {
+			return err
+		}
+		return fmt.Errorf("error revoking session: %v", err)
 	}
-	if !token.Valid {
-		return fmt.Errorf("invalid token")
+
+	return nil
+}
+
+// RevokeAllSessions logs the user out everywhere, including the session asking
+func (s *AuthService) RevokeAllSessions(ctx context.Context, userID uuid.UUID) error {
+	err := s.SessionRepo.RevokeUserSessions(ctx, userID, uuid.Nil, time.Now())
+	if err != nil {
+		return fmt.Errorf("error revoking sessions: %v", err)
 	}
+
 	return nil
 }
 
-func (s *AuthService) CreateNewUser(ctx context.Context, firstName, lastName, email, password, secondPassword string) (string, error) {
+func (s *AuthService) CreateNewUser(ctx context.Context, firstName, lastName, email, password, secondPassword string, client session.Client) (string, error) {
 	err := verifyCreateAccountFields(firstName, lastName, email, password, secondPassword)
 	if err != nil {
 		return "", err
@@ -118,10 +347,222 @@ func (s *AuthService) CreateNewUser(ctx context.Context, firstName, lastName, em
 		return "", fmt.Errorf("error creating user: %v", err)
 	}
 
-	tokenString, err := GenerateJWT(newUser.Id)
+	return s.startSession(ctx, newUser.Id, client)
+}
+
+// IsAdmin reports whether the user can unlock accounts and addresses
+func (s *AuthService) IsAdmin(u *user.User) bool {
+	return u != nil && slices.ContainsFunc(s.AdminEmails, func(e string) bool {
+		return strings.EqualFold(e, u.Email)
+	})
+}
+
+// GetLockouts returns the accounts and addresses that are locked out
+func (s *AuthService) GetLockouts(ctx context.Context) ([]lockout.Attempts, error) {
+	locked, err := s.AttemptRepo.GetLocked(ctx, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("error getting lockouts: %v", err)
+	}
+
+	return locked, nil
+}
+
+// Unlock clears the failed logins of an account or address so it can log in straight away
+func (s *AuthService) Unlock(ctx context.Context, admin *user.User, key string) error {
+	if !s.IsAdmin(admin) {
+		return lockout.ErrNotAdmin
+	}
+
+	err := s.AttemptRepo.DeleteAttempts(ctx, key)
 	if err != nil {
-		return "", fmt.Errorf("error generating JWT: %v", err)
+		return fmt.Errorf("error unlocking %s: %v", key, err)
+	}
+
+	log.Printf("%s unlocked %s", admin.Email, key)
+
+	return nil
+}
+
+// GetTwoFactor returns the user's two-factor authentication, with the secret to add to their app while they're setting it up
+func (s *AuthService) GetTwoFactor(ctx context.Context, u *user.User) (*TwoFactorResponse, error) {
+	tf, err := s.getTwoFactor(ctx, u.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	rv := &TwoFactorResponse{}
+	switch {
+	case tf.IsEnabled():
+		rv.Enabled = true
+		rv.RecoveryCodesLeft = len(tf.RecoveryHashes)
+	case tf != nil:
+		rv.Setup = &TwoFactorSetup{Secret: tf.Secret, URI: twofactor.ProvisioningURI(twofactor.Issuer, u.Email, tf.Secret)}
+	}
+
+	return rv, nil
+}
+
+// StartTwoFactor creates a new secret for the user to add to their authenticator app, it isn't
+// needed to log in until EnableTwoFactor confirms the app has it
+func (s *AuthService) StartTwoFactor(ctx context.Context, u *user.User) (*TwoFactorResponse, error) {
+	tf, err := s.getTwoFactor(ctx, u.Id)
+	if err != nil {
+		return nil, err
+	}
+	if tf.IsEnabled() {
+		return nil, twofactor.ErrAlreadyEnabled
+	}
+
+	err = s.TwoFactorRepo.SaveTwoFactor(ctx, twofactor.NewEnrollment(u.Id, time.Now()))
+	if err != nil {
+		return nil, fmt.Errorf("error saving two-factor settings: %v", err)
+	}
+
+	return s.GetTwoFactor(ctx, u)
+}
+
+// EnableTwoFactor turns two-factor authentication on with the first code from the user's app,
+// returning the recovery codes to show them the once
+func (s *AuthService) EnableTwoFactor(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	tf, err := s.getTwoFactor(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if tf == nil {
+		return nil, twofactor.ErrNotEnrolled
+	}
+
+	codes, err := tf.Enable(code, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.TwoFactorRepo.SaveTwoFactor(ctx, tf)
+	if err != nil {
+		return nil, fmt.Errorf("error saving two-factor settings: %v", err)
+	}
+
+	return codes, nil
+}
+
+// NewRecoveryCodes replaces the user's recovery codes, it takes a code from their app so a
+// forgotten logged in browser can't be used to get them
+func (s *AuthService) NewRecoveryCodes(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	tf, err := s.getTwoFactor(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !tf.IsEnabled() {
+		return nil, twofactor.ErrNotEnrolled
+	}
+
+	err = tf.Verify(code, time.Now())
+	if err != nil {
+		return nil, err
 	}
 
-	return tokenString, nil
+	codes := tf.NewRecoveryCodes()
+
+	err = s.TwoFactorRepo.SaveTwoFactor(ctx, tf)
+	if err != nil {
+		return nil, fmt.Errorf("error saving two-factor settings: %v", err)
+	}
+
+	return codes, nil
+}
+
+// DisableTwoFactor turns two-factor authentication off, or abandons setting it up, it takes the
+// user's password for the same reason as NewRecoveryCodes takes a code
+func (s *AuthService) DisableTwoFactor(ctx context.Context, userID uuid.UUID, password string) error {
+	u, err := s.UserRepo.GetUserById(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("error getting user by id: %v", err)
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password)) != nil {
+		return user.ErrInvalidPassword
+	}
+
+	err = s.TwoFactorRepo.DeleteTwoFactor(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("error deleting two-factor settings: %v", err)
+	}
+
+	return nil
+}
+
+// returns nil when the user hasn't started setting up two-factor authentication
+func (s *AuthService) getTwoFactor(ctx context.Context, userID uuid.UUID) (*twofactor.TwoFactor, error) {
+	tf, err := s.TwoFactorRepo.GetTwoFactor(ctx, userID)
+	if errors.Is(err, twofactor.ErrNotEnrolled) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting two-factor settings: %v", err)
+	}
+
+	return tf, nil
+}
+
+// returns the failed logins for the account and the address, or a lockout.ThrottledError if either has to wait
+func (s *AuthService) checkThrottle(ctx context.Context, email, ip string, now time.Time) (*lockout.Attempts, *lockout.Attempts, error) {
+	account, err := s.getAttempts(ctx, lockout.AccountKey(email))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	address, err := s.getAttempts(ctx, lockout.IPKey(ip))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// the longer wait of the two applies
+	for _, a := range []*lockout.Attempts{account, address} {
+		wait := a.RetryAfter(now, s.Policy)
+		if wait > 0 {
+			return nil, nil, &lockout.ThrottledError{RetryAfter: wait, Locked: a.IsLocked(now)}
+		}
+	}
+
+	return account, address, nil
+}
+
+func (s *AuthService) getAttempts(ctx context.Context, key string) (*lockout.Attempts, error) {
+	a, err := s.AttemptRepo.GetAttempts(ctx, key)
+	if errors.Is(err, lockout.ErrAttemptsNotFound) {
+		return lockout.NewAttempts(key), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting login attempts: %v", err)
+	}
+
+	return a, nil
+}
+
+// counts the failure against the account and the address, emailing the account's owner if it locks
+func (s *AuthService) recordFailure(ctx context.Context, u *user.User, account, address *lockout.Attempts, ip string, now time.Time) error {
+	locked := account.Fail(now, s.Policy)
+	err := s.AttemptRepo.SaveAttempts(ctx, account)
+	if err != nil {
+		return fmt.Errorf("error saving login attempts: %v", err)
+	}
+
+	if address.Fail(now, s.Policy) {
+		log.Printf("locked logins from %s after %d failures", ip, address.Failures)
+	}
+	err = s.AttemptRepo.SaveAttempts(ctx, address)
+	if err != nil {
+		return fmt.Errorf("error saving login attempts: %v", err)
+	}
+
+	// the email is a side effect of the lock, so a failure to send is logged rather than returned
+	if locked && u != nil {
+		subject, body := buildLockoutEmail(u, account, ip)
+		err = s.SendEmail(u.Email, subject, body)
+		if err != nil {
+			log.Printf("error sending lockout email to %s: %v", u.Email, err)
+		}
+	}
+
+	return nil
 }
@@ -2,7 +2,10 @@ package authservice
 
 import (
 	"errors"
+	"filmPackager/internal/domain/lockout"
 	"filmPackager/internal/domain/user"
+	"fmt"
+	"strings"
 )
 
 func verifyCreateAccountFields(firstName, lastName, email, password, secondPassword string) error {
@@ -23,3 +26,15 @@ func verifyCreateAccountFields(firstName, lastName, email, password, secondPassw
 	}
 	return nil
 }
+
+func buildLockoutEmail(u *user.User, a *lockout.Attempts, ip string) (string, string) {
+	subject := "Film Packager: your account has been locked"
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Hi %s,\r\n\r\n", u.Name)
+	fmt.Fprintf(&b, "There were %d failed attempts to log in to your account, the last from %s, so logging in has been locked until %s.\r\n\r\n", a.Failures, ip, a.LockedUntil.Format("01-02-2006 15:04 MST"))
+	b.WriteString("If this was you, you can try again once the lock runs out.\r\n")
+	b.WriteString("If it wasn't, change your password once you're logged in, and let us know if it keeps happening.\r\n")
+
+	return subject, b.String()
+}
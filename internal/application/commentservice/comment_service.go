@@ -2,32 +2,118 @@ package commentservice
 
 import (
 	"context"
+	"filmPackager/internal/application/eventbroker"
+	"filmPackager/internal/domain/activity"
 	"filmPackager/internal/domain/comment"
+	"filmPackager/internal/domain/document"
+	"filmPackager/internal/domain/membership"
+	"filmPackager/internal/domain/notification"
 	"filmPackager/internal/domain/user"
 	"fmt"
+	"html/template"
+	"log"
+	"slices"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 type CommentService struct {
-	CommentRepo comment.CommentRepository
-	UserRepo    user.UserRepository
+	CommentRepo      comment.CommentRepository
+	UserRepo         user.UserRepository
+	DocRepo          document.DocumentRepository
+	MemberRepo       membership.MembershipRepository
+	ActivityRepo     activity.ActivityRepository
+	NotificationRepo notification.NotificationRepository
+	Broker           *eventbroker.Broker
+	Webhooks         eventbroker.Recorder
 }
 
-func NewCommentService(commentRepo comment.CommentRepository, userRepo user.UserRepository) *CommentService {
-	return &CommentService{CommentRepo: commentRepo, UserRepo: userRepo}
+func NewCommentService(commentRepo comment.CommentRepository, userRepo user.UserRepository, docRepo document.DocumentRepository, memberRepo membership.MembershipRepository, activityRepo activity.ActivityRepository, notificationRepo notification.NotificationRepository, broker *eventbroker.Broker, webhooks eventbroker.Recorder) *CommentService {
+	return &CommentService{CommentRepo: commentRepo, UserRepo: userRepo, DocRepo: docRepo, MemberRepo: memberRepo, ActivityRepo: activityRepo, NotificationRepo: notificationRepo, Broker: broker, Webhooks: webhooks}
 }
 
 type CommentResponse struct {
-	ID        uuid.UUID
-	DocID     uuid.UUID
-	Text      string
+	ID    uuid.UUID
+	DocID uuid.UUID
+	// the text as written, Markdown and all
+	Text string
+	// the text rendered from Markdown with the mentions highlighted, see renderComment
+	HTML      template.HTML
 	Author    user.User
 	CreatedAt string
+	// nil for the comment that starts a thread
+	ParentID *uuid.UUID
+	// the rest of the thread, oldest first, only set on the comment that starts it
+	Replies    []CommentResponse
+	Resolved   bool
+	ResolvedBy user.User
+	ResolvedAt string
+	// the page and region the thread is pinned to, nil for comments on the whole document
+	Anchor   *comment.Anchor
+	Edited   bool
+	EditedAt string
+	// what the user viewing the comment can do with it
+	CanEdit   bool
+	CanDelete bool
+	// the version the thread was written on, 0 unless it was carried forward
+	CarriedFrom int
 }
 
+// VersionComments are the threads left on an earlier version of a document
+type VersionComments struct {
+	DocID    uuid.UUID
+	Version  int
+	FileName string
+	Threads  []CommentResponse
+	// the open threads that can be carried forward
+	OpenCount int
+}
+
+type GetPriorVersionCommentsResponse struct {
+	// the document's own version, 0 for documents uploaded before versions were kept
+	Version int
+	// newest version first
+	PriorVersions []VersionComments
+}
+
+// ThreadID returns the ID of the comment that started the thread
+func (c CommentResponse) ThreadID() uuid.UUID {
+	if c.ParentID != nil {
+		return *c.ParentID
+	}
+	return c.ID
+}
+
+type RevisionResponse struct {
+	Text string
+	// when the text was replaced
+	ReplacedAt string
+}
+
+type GetRevisionsResponse struct {
+	Comment CommentResponse
+	// the earlier versions of the text, newest first
+	Revisions []RevisionResponse
+}
+
+// PageMarker is a page of the PDF preview with the threads anchored to it
+type PageMarker struct {
+	Page    int
+	Threads []CommentResponse
+}
+
+// how many members are suggested while typing a mention
+const mentionSuggestionLimit = 5
+
 type GetDocCommentsResponse struct {
-	Comments []CommentResponse
+	// the threads matching the filter, newest first
+	Comments      []CommentResponse
+	Filter        string
+	OpenCount     int
+	ResolvedCount int
+	// the anchored threads matching the filter grouped by page, first page first
+	Markers []PageMarker
 }
 
 type DeleteDocCommentResponse struct {
@@ -35,58 +121,233 @@ type DeleteDocCommentResponse struct {
 	DocID    uuid.UUID
 }
 
-func (s *CommentService) GetDocComments(ctx context.Context, docID uuid.UUID) (*GetDocCommentsResponse, error) {
-	rv := &GetDocCommentsResponse{}
+// GetDocComments returns the document's threads as seen by the user, filter is one of comment.Filters and defaults to all
+func (s *CommentService) GetDocComments(ctx context.Context, docID uuid.UUID, userID uuid.UUID, filter string) (*GetDocCommentsResponse, error) {
+	if !comment.IsValidFilter(filter) {
+		filter = comment.FilterAll
+	}
 
-	comments, err := s.CommentRepo.GetDocComments(ctx, docID)
+	comments, userMap, err := s.loadDocComments(ctx, docID)
 	if err != nil {
-		return nil, fmt.Errorf("error getting comments: %v", err)
+		return nil, err
 	}
 
-	uIDs := []uuid.UUID{}
+	isOwner, err := s.isProjectOwner(ctx, docID, userID)
+	if err != nil {
+		return nil, err
+	}
 
-	for _, c := range comments {
-		rv.Comments = append(rv.Comments, CommentResponse{
-			ID:        c.ID,
-			DocID:     c.DocID,
-			Text:      c.Content,
-			CreatedAt: c.CreatedAt.Format("01-02-2006 15:04"),
+	// see comment_utils.go
+	threads, open, resolved := buildThreads(comments, userMap, filter)
+	setPermissions(threads, userID, isOwner)
+
+	return &GetDocCommentsResponse{Comments: threads, Filter: filter, OpenCount: open, ResolvedCount: resolved, Markers: buildPageMarkers(threads)}, nil
+}
+
+// GetPriorVersionComments returns the threads left on the earlier versions of the document, filtered like its own threads
+func (s *CommentService) GetPriorVersionComments(ctx context.Context, docID uuid.UUID, filter string) (*GetPriorVersionCommentsResponse, error) {
+	if !comment.IsValidFilter(filter) {
+		filter = comment.FilterAll
+	}
+
+	current, prior, err := s.getVersions(ctx, docID)
+	if err != nil {
+		return nil, err
+	}
+
+	rv := &GetPriorVersionCommentsResponse{PriorVersions: []VersionComments{}}
+	if current != nil {
+		rv.Version = current.Number
+	}
+
+	for i := len(prior) - 1; i >= 0; i-- {
+		v := prior[i]
+
+		comments, userMap, err := s.loadDocComments(ctx, v.DocID)
+		if err != nil {
+			return nil, err
+		}
+
+		// versions nobody commented on are left out
+		threads, open, _ := buildThreads(comments, userMap, filter)
+		if len(threads) == 0 {
+			continue
+		}
+
+		rv.PriorVersions = append(rv.PriorVersions, VersionComments{
+			DocID:     v.DocID,
+			Version:   v.Number,
+			FileName:  v.FileName,
+			Threads:   threads,
+			OpenCount: open,
 		})
-		uIDs = append(uIDs, c.AuthorID)
 	}
 
-	users, err := s.UserRepo.GetUsersByIDs(ctx, uIDs)
+	return rv, nil
+}
+
+// CarryForward moves the open threads of an earlier version onto the document, labelled with the version they were written on
+func (s *CommentService) CarryForward(ctx context.Context, docID, fromDocID uuid.UUID, userID uuid.UUID) error {
+	doc, err := s.DocRepo.GetDocumentDetails(ctx, docID)
 	if err != nil {
-		return nil, fmt.Errorf("error getting users: %v", err)
+		return fmt.Errorf("error getting document details: %v", err)
 	}
 
-	userMap := map[uuid.UUID]user.User{}
+	m, err := s.MemberRepo.GetMembership(ctx, doc.OrganizationID, userID)
+	if err != nil || m.InviteStatus != "accepted" {
+		return comment.ErrNotMember
+	}
 
-	for _, u := range users {
-		userMap[u.Id] = u
+	// both documents have to be versions of the same file in the same project
+	fromDoc, err := s.DocRepo.GetDocumentDetails(ctx, fromDocID)
+	if err != nil || fromDoc.OrganizationID != doc.OrganizationID || fromDoc.FileType != doc.FileType {
+		return comment.ErrNotAnEarlierVersion
 	}
 
-	for i, c := range comments {
-		rv.Comments[i].Author = userMap[c.AuthorID]
+	_, prior, err := s.getVersions(ctx, docID)
+	if err != nil {
+		return err
 	}
 
-	return rv, nil
+	from := document.FindVersion(prior, fromDocID)
+	if from == nil {
+		return comment.ErrNotAnEarlierVersion
+	}
+
+	comments, err := s.CommentRepo.GetDocComments(ctx, fromDocID)
+	if err != nil {
+		return fmt.Errorf("error getting comments: %v", err)
+	}
+
+	moved := 0
+	for _, c := range comments {
+		if !c.CanCarryForward() {
+			continue
+		}
+
+		err = s.CommentRepo.MoveThread(ctx, c.ID, docID, from.Number)
+		if err != nil {
+			return fmt.Errorf("error carrying thread forward: %v", err)
+		}
+		moved++
+	}
+
+	if moved > 0 {
+		eventbroker.Emit(ctx, s.Webhooks, s.Broker, eventbroker.NewDocumentEvent(eventbroker.CommentCreated, doc.OrganizationID, userID, docID))
+	}
+
+	return nil
+}
+
+// returns the document's version and the versions of its file type before it, oldest first
+func (s *CommentService) getVersions(ctx context.Context, docID uuid.UUID) (*document.Version, []document.Version, error) {
+	doc, err := s.DocRepo.GetDocumentDetails(ctx, docID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting document details: %v", err)
+	}
+
+	versions, err := s.DocRepo.GetVersions(ctx, doc.OrganizationID, doc.FileType)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting document versions: %v", err)
+	}
+
+	current := document.FindVersion(versions, docID)
+
+	prior := []document.Version{}
+	for _, v := range versions {
+		if v.DocID != docID && (current == nil || v.Number < current.Number) {
+			prior = append(prior, v)
+		}
+	}
+
+	return current, prior, nil
+}
+
+func (s *CommentService) GetComment(ctx context.Context, commentID uuid.UUID) (*comment.Comment, error) {
+	c, err := s.CommentRepo.GetDocComment(ctx, commentID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting comment: %v", err)
+	}
+
+	return c, nil
+}
+
+// GetThread returns the thread the comment belongs to as seen by the user, with all of its replies
+func (s *CommentService) GetThread(ctx context.Context, commentID uuid.UUID, userID uuid.UUID) (*CommentResponse, error) {
+	c, err := s.CommentRepo.GetDocComment(ctx, commentID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting comment: %v", err)
+	}
+
+	comments, userMap, err := s.loadDocComments(ctx, c.DocID)
+	if err != nil {
+		return nil, err
+	}
+
+	isOwner, err := s.isProjectOwner(ctx, c.DocID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	threads, _, _ := buildThreads(comments, userMap, comment.FilterAll)
+	setPermissions(threads, userID, isOwner)
+	for _, t := range threads {
+		if t.ID == c.ThreadID() {
+			return &t, nil
+		}
+	}
+
+	return nil, comment.ErrCommentNotFound
 }
 
-func (s *CommentService) CreateComment(ctx context.Context, text string, userID uuid.UUID, docID uuid.UUID) (*CommentResponse, error) {
+// CreateComment starts a thread on the document, anchor is nil for a comment on the whole document
+func (s *CommentService) CreateComment(ctx context.Context, text string, userID uuid.UUID, docID uuid.UUID, anchor *comment.Anchor) (*CommentResponse, error) {
+	err := comment.CheckLength(text)
+	if err != nil {
+		return nil, err
+	}
+
 	c := comment.CreateNewComment(docID, userID, text)
+	if anchor != nil {
+		err := c.AnchorTo(anchor)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	doc, err := s.DocRepo.GetDocumentDetails(ctx, docID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting document details: %v", err)
+	}
+
+	members, err := s.mentionableMembers(ctx, doc.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+	if !isMentionable(members, userID) {
+		return nil, comment.ErrNotMember
+	}
+	// only the accepted members are matched, so nobody outside the project is notified
+	c.Mentions = comment.FindMentions(c.Content, members)
+
 	rv := &CommentResponse{
 		DocID:     docID,
 		ID:        c.ID,
 		Text:      c.Content,
+		HTML:      renderComment(c.Content, members),
 		CreatedAt: c.CreatedAt.Format("01-02-2006 15:04"),
+		Anchor:    c.Anchor,
+		CanEdit:   true,
+		CanDelete: true,
 	}
 
-	err := s.CommentRepo.CreateDocComment(ctx, c)
+	err = s.CommentRepo.CreateDocComment(ctx, c)
 	if err != nil {
 		return nil, fmt.Errorf("error creating comment: %v", err)
 	}
 
+	s.notifyMentions(ctx, c, doc.OrganizationID, c.Mentions)
+
 	u, err := s.UserRepo.GetUserById(ctx, userID)
 	if err != nil {
 		return nil, fmt.Errorf("error getting user: %v", err)
@@ -94,10 +355,191 @@ func (s *CommentService) CreateComment(ctx context.Context, text string, userID
 
 	rv.Author = *u
 
+	err = s.recordCommentActivity(ctx, docID, userID, activity.KindComment, eventbroker.CommentCreated)
+	if err != nil {
+		return nil, err
+	}
+
 	return rv, nil
 }
 
-func (s *CommentService) DeleteComment(ctx context.Context, commentID uuid.UUID) (*DeleteDocCommentResponse, error) {
+// CreateReply adds a reply to the comment's thread and returns the whole thread
+func (s *CommentService) CreateReply(ctx context.Context, text string, userID uuid.UUID, parentID uuid.UUID) (*CommentResponse, error) {
+	err := comment.CheckLength(text)
+	if err != nil {
+		return nil, err
+	}
+
+	parent, err := s.CommentRepo.GetDocComment(ctx, parentID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting comment: %v", err)
+	}
+
+	c := comment.CreateNewReply(parent, userID, text)
+
+	doc, err := s.DocRepo.GetDocumentDetails(ctx, c.DocID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting document details: %v", err)
+	}
+
+	members, err := s.mentionableMembers(ctx, doc.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+	if !isMentionable(members, userID) {
+		return nil, comment.ErrNotMember
+	}
+	c.Mentions = comment.FindMentions(c.Content, members)
+
+	err = s.CommentRepo.CreateDocComment(ctx, c)
+	if err != nil {
+		return nil, fmt.Errorf("error creating reply: %v", err)
+	}
+
+	s.notifyMentions(ctx, c, doc.OrganizationID, c.Mentions)
+
+	err = s.recordCommentActivity(ctx, c.DocID, userID, activity.KindComment, eventbroker.CommentCreated)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetThread(ctx, c.ThreadID(), userID)
+}
+
+func (s *CommentService) ResolveThread(ctx context.Context, commentID uuid.UUID, userID uuid.UUID) (*CommentResponse, error) {
+	c, err := s.CommentRepo.GetDocComment(ctx, commentID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting comment: %v", err)
+	}
+
+	err = c.Resolve(userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.CommentRepo.UpdateDocComment(ctx, c)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving thread: %v", err)
+	}
+
+	err = s.recordCommentActivity(ctx, c.DocID, userID, activity.KindResolve, eventbroker.CommentResolved)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetThread(ctx, c.ID, userID)
+}
+
+func (s *CommentService) ReopenThread(ctx context.Context, commentID uuid.UUID, userID uuid.UUID) (*CommentResponse, error) {
+	c, err := s.CommentRepo.GetDocComment(ctx, commentID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting comment: %v", err)
+	}
+
+	err = c.Reopen()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.CommentRepo.UpdateDocComment(ctx, c)
+	if err != nil {
+		return nil, fmt.Errorf("error reopening thread: %v", err)
+	}
+
+	err = s.recordCommentActivity(ctx, c.DocID, userID, activity.KindReopen, eventbroker.CommentReopened)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetThread(ctx, c.ID, userID)
+}
+
+// EditComment changes the text of the user's own comment, keeping the old text as a revision, and returns the whole thread
+func (s *CommentService) EditComment(ctx context.Context, commentID uuid.UUID, userID uuid.UUID, text string) (*CommentResponse, error) {
+	c, err := s.CommentRepo.GetDocComment(ctx, commentID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting comment: %v", err)
+	}
+
+	rev, err := c.Edit(userID, text, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	// nothing changed
+	if rev == nil {
+		return s.GetThread(ctx, c.ID, userID)
+	}
+
+	doc, err := s.DocRepo.GetDocumentDetails(ctx, c.DocID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting document details: %v", err)
+	}
+
+	members, err := s.mentionableMembers(ctx, doc.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	// only the members the edit adds a mention of are notified
+	previous := c.Mentions
+	c.Mentions = comment.FindMentions(c.Content, members)
+
+	err = s.CommentRepo.CreateRevision(ctx, rev)
+	if err != nil {
+		return nil, fmt.Errorf("error saving revision: %v", err)
+	}
+
+	err = s.CommentRepo.UpdateDocComment(ctx, c)
+	if err != nil {
+		return nil, fmt.Errorf("error editing comment: %v", err)
+	}
+
+	s.notifyMentions(ctx, c, doc.OrganizationID, newMentions(previous, c.Mentions))
+
+	eventbroker.Emit(ctx, s.Webhooks, s.Broker, eventbroker.NewDocumentEvent(eventbroker.CommentEdited, doc.OrganizationID, userID, c.DocID))
+
+	return s.GetThread(ctx, c.ID, userID)
+}
+
+// GetRevisions returns the comment as it is now along with its earlier versions
+func (s *CommentService) GetRevisions(ctx context.Context, commentID uuid.UUID, userID uuid.UUID) (*GetRevisionsResponse, error) {
+	c, err := s.CommentRepo.GetDocComment(ctx, commentID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting comment: %v", err)
+	}
+
+	// checked first so the history isn't loaded for someone outside the project
+	isOwner, err := s.isProjectOwner(ctx, c.DocID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions, err := s.CommentRepo.GetRevisions(ctx, commentID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting revisions: %v", err)
+	}
+
+	u, err := s.UserRepo.GetUserById(ctx, c.AuthorID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting user: %v", err)
+	}
+
+	rv := &GetRevisionsResponse{Comment: newCommentResponse(*c, map[uuid.UUID]user.User{u.Id: *u})}
+	setCommentPermissions(&rv.Comment, userID, isOwner)
+
+	for i := len(revisions) - 1; i >= 0; i-- {
+		rv.Revisions = append(rv.Revisions, RevisionResponse{
+			Text:       revisions[i].Content,
+			ReplacedAt: revisions[i].CreatedAt.Format("01-02-2006 15:04"),
+		})
+	}
+
+	return rv, nil
+}
+
+// DeleteComment deletes the comment, and its replies when it starts a thread, if the user wrote it or owns the project
+func (s *CommentService) DeleteComment(ctx context.Context, commentID uuid.UUID, userID uuid.UUID) (*DeleteDocCommentResponse, error) {
 	rv := &DeleteDocCommentResponse{}
 
 	c, err := s.CommentRepo.GetDocComment(ctx, commentID)
@@ -106,42 +548,172 @@ func (s *CommentService) DeleteComment(ctx context.Context, commentID uuid.UUID)
 	}
 	rv.DocID = c.DocID
 
+	isOwner, err := s.isProjectOwner(ctx, c.DocID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !c.CanDelete(userID, isOwner) {
+		return nil, comment.ErrCannotDelete
+	}
+
 	err = s.CommentRepo.DeleteDocComment(ctx, commentID)
 	if err != nil {
 		return nil, fmt.Errorf("error deleting comment: %v", err)
 	}
 
-	comments, err := s.CommentRepo.GetDocComments(ctx, c.DocID)
+	doc, err := s.DocRepo.GetDocumentDetails(ctx, c.DocID)
 	if err != nil {
-		return nil, fmt.Errorf("error getting comments: %v", err)
+		return nil, fmt.Errorf("error getting document details: %v", err)
 	}
 
-	uIDs := []uuid.UUID{}
+	eventbroker.Emit(ctx, s.Webhooks, s.Broker, eventbroker.NewDocumentEvent(eventbroker.CommentDeleted, doc.OrganizationID, userID, c.DocID))
+
+	comments, userMap, err := s.loadDocComments(ctx, c.DocID)
+	if err != nil {
+		return nil, err
+	}
+
+	rv.Comments, _, _ = buildThreads(comments, userMap, comment.FilterAll)
+	setPermissions(rv.Comments, userID, isOwner)
+
+	return rv, nil
+}
+
+// SuggestMentions returns the members matching the @name being typed at the end of the text
+func (s *CommentService) SuggestMentions(ctx context.Context, docID uuid.UUID, text string) ([]comment.Mentionable, error) {
+	query, ok := comment.MentionQuery(text)
+	if !ok {
+		return []comment.Mentionable{}, nil
+	}
+
+	doc, err := s.DocRepo.GetDocumentDetails(ctx, docID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting document details: %v", err)
+	}
+
+	members, err := s.mentionableMembers(ctx, doc.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	return comment.SuggestMentions(query, members, mentionSuggestionLimit), nil
+}
+
+// PreviewComment renders the text as it would show once posted on the document
+func (s *CommentService) PreviewComment(ctx context.Context, docID uuid.UUID, text string) (template.HTML, error) {
+	err := comment.CheckLength(text)
+	if err != nil {
+		return "", err
+	}
+
+	doc, err := s.DocRepo.GetDocumentDetails(ctx, docID)
+	if err != nil {
+		return "", fmt.Errorf("error getting document details: %v", err)
+	}
+
+	members, err := s.mentionableMembers(ctx, doc.OrganizationID)
+	if err != nil {
+		return "", err
+	}
+
+	return renderComment(text, members), nil
+}
 
+// the accepted members of the project, pending invites can't be mentioned
+func (s *CommentService) mentionableMembers(ctx context.Context, projectID uuid.UUID) ([]comment.Mentionable, error) {
+	memberships, err := s.MemberRepo.GetProjectMemberships(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting project memberships: %v", err)
+	}
+
+	members := []comment.Mentionable{}
+	for _, m := range memberships {
+		if m.InviteStatus == "accepted" {
+			members = append(members, comment.Mentionable{UserID: m.UserID, Name: m.UserName})
+		}
+	}
+
+	return members, nil
+}
+
+// the members that can be mentioned are the ones who accepted their invite
+func isMentionable(members []comment.Mentionable, userID uuid.UUID) bool {
+	return slices.ContainsFunc(members, func(m comment.Mentionable) bool { return m.UserID == userID })
+}
+
+// notifications are a side effect of commenting, so failures are logged rather than returned
+func (s *CommentService) notifyMentions(ctx context.Context, c *comment.Comment, projectID uuid.UUID, userIDs []uuid.UUID) {
+	for _, id := range userIDs {
+		// mentioning yourself doesn't need a notification
+		if id == c.AuthorID {
+			continue
+		}
+
+		err := s.NotificationRepo.CreateNotification(ctx, notification.NewMention(id, c.AuthorID, projectID, c.DocID, c.ID, c.Content))
+		if err != nil {
+			log.Printf("error creating mention notification: %v", err)
+		}
+	}
+}
+
+// owners of the document's project can moderate its comments, anyone who isn't a member gets comment.ErrNotMember
+func (s *CommentService) isProjectOwner(ctx context.Context, docID, userID uuid.UUID) (bool, error) {
+	doc, err := s.DocRepo.GetDocumentDetails(ctx, docID)
+	if err != nil {
+		return false, fmt.Errorf("error getting document details: %v", err)
+	}
+
+	m, err := s.MemberRepo.GetMembership(ctx, doc.OrganizationID, userID)
+	if err != nil || m.InviteStatus != "accepted" {
+		return false, comment.ErrNotMember
+	}
+
+	return m.IsOwner(), nil
+}
+
+// loads the document's comments along with their authors and resolvers
+func (s *CommentService) loadDocComments(ctx context.Context, docID uuid.UUID) ([]comment.Comment, map[uuid.UUID]user.User, error) {
+	comments, err := s.CommentRepo.GetDocComments(ctx, docID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting comments: %v", err)
+	}
+
+	uIDs := []uuid.UUID{}
 	for _, c := range comments {
-		rv.Comments = append(rv.Comments, CommentResponse{
-			ID:        c.ID,
-			DocID:     c.DocID,
-			Text:      c.Content,
-			CreatedAt: c.CreatedAt.Format("01-02-2006 15:04"),
-		})
 		uIDs = append(uIDs, c.AuthorID)
+		if c.ResolvedBy != nil {
+			uIDs = append(uIDs, *c.ResolvedBy)
+		}
+		uIDs = append(uIDs, c.Mentions...)
 	}
 
 	users, err := s.UserRepo.GetUsersByIDs(ctx, uIDs)
 	if err != nil {
-		return nil, fmt.Errorf("error getting users: %v", err)
+		return nil, nil, fmt.Errorf("error getting users: %v", err)
 	}
 
 	userMap := map[uuid.UUID]user.User{}
-
 	for _, u := range users {
 		userMap[u.Id] = u
 	}
 
-	for i, c := range comments {
-		rv.Comments[i].Author = userMap[c.AuthorID]
+	return comments, userMap, nil
+}
+
+// records the change against the document's project for the activity digests and lets the project page know
+func (s *CommentService) recordCommentActivity(ctx context.Context, docID, userID uuid.UUID, kind, eventKind string) error {
+	doc, err := s.DocRepo.GetDocumentDetails(ctx, docID)
+	if err != nil {
+		return fmt.Errorf("error getting document details: %v", err)
 	}
 
-	return rv, nil
+	err = s.ActivityRepo.Record(ctx, activity.NewActivity(doc.OrganizationID, userID, kind, doc.FileType))
+	if err != nil {
+		log.Printf("error recording %s activity: %v", kind, err)
+	}
+
+	eventbroker.Emit(ctx, s.Webhooks, s.Broker, eventbroker.NewDocumentEvent(eventKind, doc.OrganizationID, userID, docID))
+
+	return nil
 }
@@ -0,0 +1,144 @@
+package commentservice
+
+import (
+	"filmPackager/internal/domain/comment"
+	"filmPackager/internal/domain/user"
+	"html/template"
+	"slices"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+// the renderer escapes everything it doesn't write itself, which is what makes it safe to mark as HTML
+func renderComment(text string, members []comment.Mentionable) template.HTML {
+	return template.HTML(comment.RenderMarkdown(text, members))
+}
+
+func newCommentResponse(c comment.Comment, userMap map[uuid.UUID]user.User) CommentResponse {
+	rv := CommentResponse{
+		ID:        c.ID,
+		DocID:     c.DocID,
+		Text:      c.Content,
+		HTML:      renderComment(c.Content, mentionedMembers(c.Mentions, userMap)),
+		Author:    userMap[c.AuthorID],
+		CreatedAt: c.CreatedAt.Format("01-02-2006 15:04"),
+		ParentID:  c.ParentID,
+		Resolved:  c.IsResolved(),
+		Anchor:    c.Anchor,
+		Edited:    c.IsEdited(),
+	}
+
+	if c.IsEdited() {
+		rv.EditedAt = c.EditedAt.Format("01-02-2006 15:04")
+	}
+
+	if c.CarriedFrom != nil {
+		rv.CarriedFrom = *c.CarriedFrom
+	}
+
+	if c.IsResolved() {
+		rv.ResolvedAt = c.ResolvedAt.Format("01-02-2006 15:04")
+		if c.ResolvedBy != nil {
+			rv.ResolvedBy = userMap[*c.ResolvedBy]
+		}
+	}
+
+	return rv
+}
+
+// groups the comments (oldest first) into threads, returning the threads matching
+// the filter newest first along with the open and resolved counts
+func buildThreads(comments []comment.Comment, userMap map[uuid.UUID]user.User, filter string) ([]CommentResponse, int, int) {
+	replies := make(map[uuid.UUID][]CommentResponse)
+	for _, c := range comments {
+		if c.IsReply() {
+			replies[*c.ParentID] = append(replies[*c.ParentID], newCommentResponse(c, userMap))
+		}
+	}
+
+	threads := []CommentResponse{}
+	open, resolved := 0, 0
+	for i := len(comments) - 1; i >= 0; i-- {
+		c := comments[i]
+		if c.IsReply() {
+			continue
+		}
+
+		if c.IsResolved() {
+			resolved++
+		} else {
+			open++
+		}
+
+		if !c.MatchesFilter(filter) {
+			continue
+		}
+
+		t := newCommentResponse(c, userMap)
+		t.Replies = replies[c.ID]
+		threads = append(threads, t)
+	}
+
+	return threads, open, resolved
+}
+
+// groups the anchored threads by page, keeping the threads' order within a page
+func buildPageMarkers(threads []CommentResponse) []PageMarker {
+	byPage := make(map[int][]CommentResponse)
+	for _, t := range threads {
+		if t.Anchor != nil {
+			byPage[t.Anchor.Page] = append(byPage[t.Anchor.Page], t)
+		}
+	}
+
+	markers := []PageMarker{}
+	for page, pageThreads := range byPage {
+		markers = append(markers, PageMarker{Page: page, Threads: pageThreads})
+	}
+
+	sort.Slice(markers, func(i, j int) bool {
+		return markers[i].Page < markers[j].Page
+	})
+
+	return markers
+}
+
+// marks what the user can do with each comment in the threads, see comment.CanEdit and comment.CanDelete
+func setPermissions(threads []CommentResponse, userID uuid.UUID, isOwner bool) {
+	for i := range threads {
+		setCommentPermissions(&threads[i], userID, isOwner)
+		for j := range threads[i].Replies {
+			setCommentPermissions(&threads[i].Replies[j], userID, isOwner)
+		}
+	}
+}
+
+func setCommentPermissions(rv *CommentResponse, userID uuid.UUID, isOwner bool) {
+	c := comment.Comment{AuthorID: rv.Author.Id}
+	rv.CanEdit = c.CanEdit(userID)
+	rv.CanDelete = c.CanDelete(userID, isOwner)
+}
+
+// the mentioned users as they're named now, a mention of someone who has since
+// been renamed is left as plain text
+func mentionedMembers(userIDs []uuid.UUID, userMap map[uuid.UUID]user.User) []comment.Mentionable {
+	members := []comment.Mentionable{}
+	for _, id := range userIDs {
+		if u, ok := userMap[id]; ok {
+			members = append(members, comment.Mentionable{UserID: u.Id, Name: u.Name})
+		}
+	}
+	return members
+}
+
+// the mentions in current that weren't in previous
+func newMentions(previous, current []uuid.UUID) []uuid.UUID {
+	added := []uuid.UUID{}
+	for _, id := range current {
+		if !slices.Contains(previous, id) {
+			added = append(added, id)
+		}
+	}
+	return added
+}
@@ -0,0 +1,213 @@
+package digestservice
+
+import (
+	"context"
+	"errors"
+	"filmPackager/internal/domain/activity"
+	"filmPackager/internal/domain/digest"
+	"filmPackager/internal/domain/membership"
+	"filmPackager/internal/domain/project"
+	"filmPackager/internal/domain/user"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Sender delivers a plain text email, see email.SendEmail
+type Sender func(to, subject, body string) error
+
+type DigestService struct {
+	digestRepo   digest.DigestRepository
+	activityRepo activity.ActivityRepository
+	memberRepo   membership.MembershipRepository
+	projRepo     project.ProjectRepository
+	userRepo     user.UserRepository
+	send         Sender
+	// used to build the unsubscribe link in the email
+	baseURL string
+}
+
+func NewDigestService(digestRepo digest.DigestRepository, activityRepo activity.ActivityRepository, memberRepo membership.MembershipRepository, projRepo project.ProjectRepository, userRepo user.UserRepository, send Sender, baseURL string) *DigestService {
+	return &DigestService{
+		digestRepo:   digestRepo,
+		activityRepo: activityRepo,
+		memberRepo:   memberRepo,
+		projRepo:     projRepo,
+		userRepo:     userRepo,
+		send:         send,
+		baseURL:      baseURL,
+	}
+}
+
+type GetDigestSettingsResponse struct {
+	Frequency   string
+	Frequencies []string
+}
+
+// Start sends the due digests every interval until the context is cancelled
+func (s *DigestService) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			err := s.SendDueDigests(ctx, now)
+			if err != nil {
+				log.Printf("error sending digests: %v", err)
+			}
+		}
+	}
+}
+
+func (s *DigestService) SendDueDigests(ctx context.Context, now time.Time) error {
+	subs, err := s.digestRepo.GetAllUserSubscriptions(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting subscriptions: %v", err)
+	}
+
+	for _, sub := range subs {
+		if !sub.IsDue(now) {
+			continue
+		}
+
+		// one user's failure shouldn't hold up everyone else's digest
+		err := s.sendDigest(ctx, &sub, now)
+		if err != nil {
+			log.Printf("error sending digest to user %s: %v", sub.UserID, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *DigestService) sendDigest(ctx context.Context, sub *digest.Subscription, now time.Time) error {
+	u, err := s.userRepo.GetUserById(ctx, sub.UserID)
+	if err != nil {
+		return fmt.Errorf("error getting user: %v", err)
+	}
+
+	memberships, err := s.memberRepo.GetAllUserMemberships(ctx, sub.UserID)
+	if err != nil {
+		return fmt.Errorf("error getting user memberships: %v", err)
+	}
+
+	// only accepted projects are included in the digest
+	projIDs := []uuid.UUID{}
+	for _, m := range memberships {
+		if m.InviteStatus == "accepted" {
+			projIDs = append(projIDs, m.ProjectID)
+		}
+	}
+
+	since := sub.Since(now)
+
+	activities, err := s.activityRepo.GetForProjectsSince(ctx, projIDs, since)
+	if err != nil {
+		return fmt.Errorf("error getting activity: %v", err)
+	}
+
+	// users without saved settings don't have a token until their first digest
+	if sub.UnsubscribeToken == "" {
+		sub.UnsubscribeToken = digest.NewUnsubscribeToken()
+	}
+
+	// see digest_utils.go
+	activities = excludeOwnActivity(activities, sub.UserID)
+	if len(activities) > 0 {
+		projects, err := s.projRepo.GetProjectsByMembershipIDs(ctx, projIDs)
+		if err != nil {
+			return fmt.Errorf("error getting projects: %v", err)
+		}
+
+		actorIDs := []uuid.UUID{}
+		for _, a := range activities {
+			actorIDs = append(actorIDs, a.ActorID)
+		}
+
+		actors, err := s.userRepo.GetUsersByIDs(ctx, actorIDs)
+		if err != nil {
+			return fmt.Errorf("error getting users: %v", err)
+		}
+
+		unsubscribeURL := fmt.Sprintf("%s/unsubscribe/%s", s.baseURL, sub.UnsubscribeToken)
+		subject, body := buildDigestEmail(sub.Frequency, since, activities, projects, actors, unsubscribeURL)
+
+		err = s.send(u.Email, subject, body)
+		if err != nil {
+			return fmt.Errorf("error sending email: %v", err)
+		}
+	}
+
+	// move the window forward even when there was nothing to send
+	sub.LastSentAt = &now
+	err = s.digestRepo.Save(ctx, sub)
+	if err != nil {
+		return fmt.Errorf("error saving subscription: %v", err)
+	}
+
+	return nil
+}
+
+func (s *DigestService) GetDigestSettings(ctx context.Context, userID uuid.UUID) (*GetDigestSettingsResponse, error) {
+	sub, err := s.getSubscription(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GetDigestSettingsResponse{Frequency: sub.Frequency, Frequencies: digest.Frequencies}, nil
+}
+
+func (s *DigestService) UpdateFrequency(ctx context.Context, userID uuid.UUID, frequency string) (*GetDigestSettingsResponse, error) {
+	if !digest.IsValidFrequency(frequency) {
+		return nil, digest.ErrInvalidFrequency
+	}
+
+	sub, err := s.getSubscription(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sub.Frequency = frequency
+
+	err = s.digestRepo.Save(ctx, sub)
+	if err != nil {
+		return nil, fmt.Errorf("error saving subscription: %v", err)
+	}
+
+	return &GetDigestSettingsResponse{Frequency: sub.Frequency, Frequencies: digest.Frequencies}, nil
+}
+
+// Unsubscribe turns the digest off for the owner of the token, no login required
+func (s *DigestService) Unsubscribe(ctx context.Context, token string) error {
+	sub, err := s.digestRepo.GetByUnsubscribeToken(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	sub.Frequency = digest.FrequencyOff
+
+	err = s.digestRepo.Save(ctx, sub)
+	if err != nil {
+		return fmt.Errorf("error saving subscription: %v", err)
+	}
+
+	return nil
+}
+
+// returns the saved subscription or a new one with the defaults
+func (s *DigestService) getSubscription(ctx context.Context, userID uuid.UUID) (*digest.Subscription, error) {
+	sub, err := s.digestRepo.GetByUserID(ctx, userID)
+	if errors.Is(err, digest.ErrSubscriptionNotFound) {
+		return digest.NewSubscription(userID), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting subscription: %v", err)
+	}
+
+	return sub, nil
+}
@@ -0,0 +1,79 @@
+package digestservice
+
+import (
+	"filmPackager/internal/domain/activity"
+	"filmPackager/internal/domain/project"
+	"filmPackager/internal/domain/user"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func excludeOwnActivity(activities []activity.Activity, userID uuid.UUID) []activity.Activity {
+	rv := []activity.Activity{}
+	for _, a := range activities {
+		if a.ActorID != userID {
+			rv = append(rv, a)
+		}
+	}
+	return rv
+}
+
+func describeActivity(a activity.Activity, actorName string) string {
+	switch a.Kind {
+	case activity.KindUpload:
+		return fmt.Sprintf("%s staged a new %s", actorName, a.Detail)
+	case activity.KindLock:
+		return fmt.Sprintf("%s locked the staged documents", actorName)
+	case activity.KindComment:
+		return fmt.Sprintf("%s commented on the %s", actorName, a.Detail)
+	case activity.KindResolve:
+		return fmt.Sprintf("%s resolved a thread on the %s", actorName, a.Detail)
+	case activity.KindReopen:
+		return fmt.Sprintf("%s reopened a thread on the %s", actorName, a.Detail)
+	case activity.KindInvite:
+		return fmt.Sprintf("%s invited %s to the project", actorName, a.Detail)
+	default:
+		return fmt.Sprintf("%s: %s %s", actorName, a.Kind, a.Detail)
+	}
+}
+
+// builds the subject and plain text body of the digest, grouped by project
+func buildDigestEmail(frequency string, since time.Time, activities []activity.Activity, projects []project.Project, actors []user.User, unsubscribeURL string) (string, string) {
+	projMap := make(map[uuid.UUID]string)
+	for _, p := range projects {
+		projMap[p.ID] = p.Name
+	}
+
+	actorMap := make(map[uuid.UUID]string)
+	for _, u := range actors {
+		actorMap[u.Id] = u.Name
+	}
+
+	// keep the projects in the order of their first activity
+	order := []uuid.UUID{}
+	grouped := make(map[uuid.UUID][]string)
+	for _, a := range activities {
+		if _, ok := grouped[a.ProjectID]; !ok {
+			order = append(order, a.ProjectID)
+		}
+		line := fmt.Sprintf("  - %s (%s)", describeActivity(a, actorMap[a.ActorID]), a.CreatedAt.Format("01-02-2006 15:04"))
+		grouped[a.ProjectID] = append(grouped[a.ProjectID], line)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Here's what happened on Film Packager since %s:\r\n", since.Format("01-02-2006 15:04"))
+	for _, pID := range order {
+		fmt.Fprintf(&b, "\r\n%s\r\n", projMap[pID])
+		for _, line := range grouped[pID] {
+			b.WriteString(line + "\r\n")
+		}
+	}
+	fmt.Fprintf(&b, "\r\nYou're receiving the %s digest. To stop receiving these emails, visit:\r\n%s\r\n", frequency, unsubscribeURL)
+
+	subject := fmt.Sprintf("Your %s Film Packager digest", frequency)
+
+	return subject, b.String()
+}
@@ -2,41 +2,100 @@ package documentservice
 
 import (
 	"context"
+	"filmPackager/internal/application/eventbroker"
+	"filmPackager/internal/domain/activity"
 	"filmPackager/internal/domain/comment"
 	"filmPackager/internal/domain/document"
 	"filmPackager/internal/domain/membership"
 	"filmPackager/internal/domain/project"
 	"filmPackager/internal/domain/user"
+	"filmPackager/internal/domain/watermark"
 	"fmt"
-	"path/filepath"
+	"io"
+	"log"
+	"mime/multipart"
+	"os"
 	"slices"
+	"sort"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/google/uuid"
 )
 
 type DocumentService struct {
-	docRepo     document.DocumentRepository
-	s3Repo      document.S3Repository
-	userRepo    user.UserRepository
-	memberRepo  membership.MembershipRepository
-	projRepo    project.ProjectRepository
-	commentRepo comment.CommentRepository
+	docRepo      document.DocumentRepository
+	s3Repo       document.S3Repository
+	blobRepo     document.BlobRepository
+	uploadRepo   document.UploadRepository
+	uploadStore  document.UploadStore
+	linker       document.FileLinker
+	scanner      document.Scanner
+	userRepo     user.UserRepository
+	memberRepo   membership.MembershipRepository
+	projRepo     project.ProjectRepository
+	commentRepo  comment.CommentRepository
+	activityRepo activity.ActivityRepository
+	broker       *eventbroker.Broker
+	webhooks     eventbroker.Recorder
+	// which roles get watermarked copies of which document types
+	watermarks watermark.Policy
+	// uploaded PDFs waiting for Start to render their first page
+	thumbnails chan *document.Document
 }
 
-func NewDocumentService(docRepo document.DocumentRepository, s3Repo document.S3Repository, userRepo user.UserRepository, memberRepo membership.MembershipRepository, projRepo project.ProjectRepository, commRepo comment.CommentRepository) *DocumentService {
-	return &DocumentService{docRepo: docRepo, s3Repo: s3Repo, userRepo: userRepo, memberRepo: memberRepo, projRepo: projRepo, commentRepo: commRepo}
+func NewDocumentService(docRepo document.DocumentRepository, s3Repo document.S3Repository, blobRepo document.BlobRepository, uploadRepo document.UploadRepository, uploadStore document.UploadStore, linker document.FileLinker, scanner document.Scanner, userRepo user.UserRepository, memberRepo membership.MembershipRepository, projRepo project.ProjectRepository, commRepo comment.CommentRepository, activityRepo activity.ActivityRepository, broker *eventbroker.Broker, webhooks eventbroker.Recorder, watermarks watermark.Policy) *DocumentService {
+	return &DocumentService{docRepo: docRepo, s3Repo: s3Repo, blobRepo: blobRepo, uploadRepo: uploadRepo, uploadStore: uploadStore, linker: linker, scanner: scanner, userRepo: userRepo, memberRepo: memberRepo, projRepo: projRepo, commentRepo: commRepo, activityRepo: activityRepo, broker: broker, webhooks: webhooks, watermarks: watermarks, thumbnails: make(chan *document.Document, thumbnailQueue)}
 }
 
 type UploadDocumentResponse struct {
-	ID   uuid.UUID
-	Date string
+	ID         uuid.UUID
+	Date       string
+	ScanStatus string
+}
+
+type UploadPartURL struct {
+	Number int32
+	URL    string
+}
+
+// UploadSessionResponse is what the browser needs to send, or carry on sending, a file straight to the bucket
+type UploadSessionResponse struct {
+	ID       uuid.UUID
+	PartSize int64
+	// the parts still to send, their URLs last for partURLExpiry
+	Parts []UploadPartURL
+	// the parts already sent
+	Done []int32
+}
+
+type CompleteUploadResponse struct {
+	FileType string
+	// all the staged documents, the same as UploadDocument returns
+	Staged map[string]UploadDocumentResponse
+}
+
+type DownloadDocumentRequest struct {
+	// who's downloading, their role decides whether the copy is watermarked
+	UserID uuid.UUID
+	// shown in the browser rather than saved, only PDFs are
+	Inline bool
+	// nil for the whole file
+	Range *document.ByteRange
 }
 
 type DownloadDocumentResponse struct {
-	DocStream *s3.GetObjectOutput
-	FileName  string
+	// set when the file is fetched straight from the bucket, the rest is left empty
+	URL  string
+	Body io.ReadCloser
+	// -1 when it isn't known up front
+	Size int64
+	// set when Body only has the requested range
+	ContentRange string
+	FileName     string
+	ContentType  string
+	Disposition  string
+	// stamped for the recipient, a fresh copy each time so it can't be fetched in ranges
+	Watermarked bool
 }
 
 type GetDocumentDetailsResponse struct {
@@ -47,6 +106,8 @@ type GetDocumentDetailsResponse struct {
 	UploadDate   string
 	DocType      string
 	Status       string
+	ScanStatus   string
+	Checksum     string
 	IsPDF        bool
 }
 
@@ -61,18 +122,26 @@ var accessTiers = map[string][]string{
 }
 
 // standardize the file naming on upload - ProjectName-FileType-Date
-func (s *DocumentService) UploadDocument(ctx context.Context, orgID, userID uuid.UUID, fileName, fileType string, fileBody interface{}) (map[string]UploadDocumentResponse, error) {
-	m, err := s.memberRepo.GetMembership(ctx, orgID, userID)
+func (s *DocumentService) UploadDocument(ctx context.Context, orgID, userID uuid.UUID, fileName, fileType string, file multipart.File, size int64) (map[string]UploadDocumentResponse, error) {
+	err := s.checkUploadAccess(ctx, orgID, userID, fileType)
+	if err != nil {
+		return nil, err
+	}
+
+	// check the file is one of the formats allowed for the type, going by its content and not just the name
+	rule, err := document.RuleFor(fileType)
 	if err != nil {
-		return nil, fmt.Errorf("error getting membership: %v", err)
+		return nil, err
 	}
 
-	// checks the users highest role against their access tier
-	if !slices.Contains(accessTiers[m.Roles[0]], fileType) {
-		// return custom error for template
-		return nil, document.ErrAccessDenied
+	format, err := rule.Validate(fileType, fileName, file, size)
+	if err != nil {
+		return nil, err
 	}
 
+	// an infected file is still stored so the uploader can see what happened, but it can't be downloaded
+	scanStatus := s.scan(ctx, io.NewSectionReader(file, 0, size), fileName)
+
 	// create a return value
 	rv := make(map[string]UploadDocumentResponse)
 
@@ -82,11 +151,8 @@ func (s *DocumentService) UploadDocument(ctx context.Context, orgID, userID uuid
 		return nil, fmt.Errorf("error getting project: %v", err)
 	}
 
-	// gets the extension of the file - later used to conditionally render a preview
-	ext := filepath.Ext(fileName)
-
-	// build the file name with extension
-	fileName = fmt.Sprintf("%s_%s_%s%s", project.Name, fileType, time.Now().Format("01-02-2006"), ext)
+	// build the file name with the detected format's extension
+	fileName = fmt.Sprintf("%s_%s_%s%s", project.Name, fileType, time.Now().Format("01-02-2006"), format.Extensions[0])
 
 	// create a new document object
 	now := time.Now()
@@ -99,6 +165,20 @@ func (s *DocumentService) UploadDocument(ctx context.Context, orgID, userID uuid
 		Date:           &now,
 		Status:         "staged",
 		Color:          "black",
+		ContentType:    format.ContentType,
+		ScanStatus:     scanStatus,
+	}
+
+	// the file is stored under its checksum, so the same content uploaded again shares the stored file
+	d.Checksum, err = document.Checksum(io.NewSectionReader(file, 0, size))
+	if err != nil {
+		return nil, fmt.Errorf("error hashing file: %v", err)
+	}
+
+	// the documents before this upload, used to fill in the version history
+	existing, err := s.docRepo.GetAllByOrgId(ctx, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting all documents: %v", err)
 	}
 
 	// check if there is a document with the same type for the org
@@ -106,40 +186,29 @@ func (s *DocumentService) UploadDocument(ctx context.Context, orgID, userID uuid
 	switch err {
 	// if there is an existing document, update the values
 	case nil:
-		// delete the file from the s3 bucket
-		err := s.s3Repo.DeleteFile(ctx, oldDoc)
-		if err != nil {
-			return nil, fmt.Errorf("error deleting file: %v", err)
-		}
-
-		// upload the file to s3
-		_, err = s.s3Repo.UploadFile(ctx, d, fileBody)
+		// store the new file first, it may be the same one the old document has
+		err := s.storeFile(ctx, d, file, size)
 		if err != nil {
-			return nil, fmt.Errorf("error uploading file: %v", err)
+			return nil, err
 		}
 
-		// delete the old document comments from the db
-		err = s.commentRepo.DeleteDocComments(ctx, oldDoc.ID)
+		// the old document is kept as an earlier version with its file and comments, see recordVersion
+		err = s.docRepo.ReplaceStaged(ctx, oldDoc, d)
 		if err != nil {
-			return nil, fmt.Errorf("error deleting comments: %v", err)
-		}
-
-		// update the document in the PG database
-		err = s.docRepo.UpdateDocument(ctx, d)
-		if err != nil {
-			return nil, fmt.Errorf("error updating document: %v", err)
+			s.dropFile(ctx, d)
+			return nil, fmt.Errorf("error replacing document: %v", err)
 		}
 	// if there is no existing document, save the new document
 	case document.ErrDocumentNotFound:
-		// upload the file to s3
-		_, err := s.s3Repo.UploadFile(ctx, d, fileBody)
+		err := s.storeFile(ctx, d, file, size)
 		if err != nil {
-			return nil, fmt.Errorf("error uploading file: %v", err)
+			return nil, err
 		}
 
 		// save to the PG database
 		err = s.docRepo.Save(ctx, d)
 		if err != nil {
+			s.dropFile(ctx, d)
 			return nil, fmt.Errorf("error saving document: %v", err)
 		}
 
@@ -148,6 +217,23 @@ func (s *DocumentService) UploadDocument(ctx context.Context, orgID, userID uuid
 		return nil, fmt.Errorf("error finding staged document: %v", err)
 	}
 
+	err = s.recordVersion(ctx, d, existing)
+	if err != nil {
+		return nil, err
+	}
+
+	// the thumbnail is made in the background, the project page shows an icon until it's ready
+	s.queueThumbnail(d)
+
+	// record the upload for the activity digests
+	err = s.activityRepo.Record(ctx, activity.NewActivity(orgID, userID, activity.KindUpload, fileType))
+	if err != nil {
+		log.Printf("error recording upload activity: %v", err)
+	}
+
+	// let everyone on the project page know there is a new staged document
+	eventbroker.Emit(ctx, s.webhooks, s.broker, eventbroker.NewDocumentEvent(eventbroker.DocumentUploaded, orgID, userID, d.ID))
+
 	docs, err := s.docRepo.GetAllByOrgId(ctx, orgID)
 	if err != nil {
 		return nil, fmt.Errorf("error getting all documents: %v", err)
@@ -157,8 +243,9 @@ func (s *DocumentService) UploadDocument(ctx context.Context, orgID, userID uuid
 		// we only need to return the staged documents
 		if doc.IsStaged() {
 			docResp := &UploadDocumentResponse{
-				ID:   doc.ID,
-				Date: doc.Date.Format("01-02-2006"),
+				ID:         doc.ID,
+				Date:       doc.Date.Format("01-02-2006"),
+				ScanStatus: doc.ScanStatus,
 			}
 			rv[doc.FileType] = *docResp
 		}
@@ -168,6 +255,111 @@ func (s *DocumentService) UploadDocument(ctx context.Context, orgID, userID uuid
 	return rv, nil
 }
 
+// StartUpload begins sending a file straight to the bucket, checking what it can before any of it arrives
+func (s *DocumentService) StartUpload(ctx context.Context, projectID, userID uuid.UUID, fileName, fileType string, size int64) (*UploadSessionResponse, error) {
+	err := s.checkUploadAccess(ctx, projectID, userID, fileType)
+	if err != nil {
+		return nil, err
+	}
+
+	if size <= 0 {
+		return nil, document.ErrEmptyUpload
+	}
+
+	rule, err := document.RuleFor(fileType)
+	if err != nil {
+		return nil, err
+	}
+
+	err = rule.Check(fileType, fileName, size)
+	if err != nil {
+		return nil, err
+	}
+
+	u := document.NewUpload(projectID, userID, fileName, fileType, size, time.Now())
+	u.StoreID, err = s.uploadStore.CreateUpload(ctx, u)
+	if err != nil {
+		return nil, fmt.Errorf("error starting upload: %v", err)
+	}
+
+	err = s.uploadRepo.SaveUpload(ctx, u)
+	if err != nil {
+		return nil, fmt.Errorf("error saving upload: %v", err)
+	}
+
+	return s.uploadSession(ctx, u, nil)
+}
+
+// ResumeUpload returns fresh URLs for the parts that haven't been sent yet
+func (s *DocumentService) ResumeUpload(ctx context.Context, projectID, uploadID, userID uuid.UUID) (*UploadSessionResponse, error) {
+	u, err := s.getUpload(ctx, projectID, uploadID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	parts, err := s.uploadStore.ListParts(ctx, u)
+	if err != nil {
+		return nil, fmt.Errorf("error listing uploaded parts: %v", err)
+	}
+
+	return s.uploadSession(ctx, u, parts)
+}
+
+// CompleteUpload puts the parts together and stages the file the same as UploadDocument,
+// which checks and scans it before storing it
+func (s *DocumentService) CompleteUpload(ctx context.Context, projectID, uploadID, userID uuid.UUID) (*CompleteUploadResponse, error) {
+	u, err := s.getUpload(ctx, projectID, uploadID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	parts, err := s.uploadStore.ListParts(ctx, u)
+	if err != nil {
+		return nil, fmt.Errorf("error listing uploaded parts: %v", err)
+	}
+
+	if !u.IsComplete(parts) {
+		return nil, document.ErrIncompleteUpload
+	}
+
+	err = s.uploadStore.CompleteUpload(ctx, u, parts)
+	if err != nil {
+		return nil, fmt.Errorf("error completing upload: %v", err)
+	}
+
+	// finished or not, the parts are gone now so the upload can't be resumed
+	defer s.removeUpload(ctx, u)
+
+	file, err := s.downloadUpload(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	staged, err := s.UploadDocument(ctx, u.ProjectID, userID, u.FileName, u.FileType, file, u.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CompleteUploadResponse{FileType: u.FileType, Staged: staged}, nil
+}
+
+// CancelUpload throws away the parts sent so far
+func (s *DocumentService) CancelUpload(ctx context.Context, projectID, uploadID, userID uuid.UUID) error {
+	u, err := s.getUpload(ctx, projectID, uploadID, userID)
+	if err != nil {
+		return err
+	}
+
+	err = s.uploadStore.AbortUpload(ctx, u)
+	if err != nil {
+		return fmt.Errorf("error aborting upload: %v", err)
+	}
+
+	return s.uploadRepo.DeleteUpload(ctx, u.ID)
+}
+
 func (s *DocumentService) GetDocumentDetails(ctx context.Context, docID uuid.UUID) (*GetDocumentDetailsResponse, error) {
 	if s.docRepo == nil {
 		return nil, fmt.Errorf("nil repository")
@@ -191,10 +383,12 @@ func (s *DocumentService) GetDocumentDetails(ctx context.Context, docID uuid.UUI
 		UploadDate:   doc.Date.Format("01-02-2006, 15:04"),
 		DocType:      doc.FileType,
 		Status:       doc.Status,
+		ScanStatus:   doc.ScanStatus,
+		Checksum:     doc.Checksum,
 	}
 
-	ext := filepath.Ext(doc.FileName)
-	if ext == ".pdf" {
+	// only PDFs can be shown in the browser's viewer, and only once they've passed the scan
+	if document.ContentTypeFor(doc) == document.PDF.ContentType && doc.CheckScan() == nil {
 		rv.IsPDF = true
 	}
 
@@ -224,7 +418,15 @@ func (s *DocumentService) LockDocuments(ctx context.Context, pID uuid.UUID, uID
 		return fmt.Errorf("error getting staged documents: %v", err)
 	}
 
-	// I only want to delete the files that are both locked and staged
+	// nothing is locked until every staged file has passed the scan
+	for _, doc := range stagedDocs {
+		err = doc.CheckScan()
+		if err != nil {
+			return err
+		}
+	}
+
+	// I only want to replace the documents that are both locked and staged
 	// so I will create a map of the staged documents for simpler access
 	stagedMap := make(map[string]*document.Document)
 	for _, doc := range stagedDocs {
@@ -232,28 +434,17 @@ func (s *DocumentService) LockDocuments(ctx context.Context, pID uuid.UUID, uID
 	}
 
 	// create a list of the locked documents that are also staged
-	// keys for the s3 bucket and IDs for the PG database
-	keysToDelete := []string{}
-	IDsToDelete := []uuid.UUID{}
+	replacedIDs := []uuid.UUID{}
 	for _, doc := range lockedDocs {
 		if _, ok := stagedMap[doc.FileType]; ok {
-			// format the key for the s3 bucket
-			key := fmt.Sprintf("%s=%s", doc.FileName, doc.ID)
-			keysToDelete = append(keysToDelete, key)
-			IDsToDelete = append(IDsToDelete, doc.ID)
+			replacedIDs = append(replacedIDs, doc.ID)
 		}
 	}
 
-	// delete the previous locked files from the s3 bucket
-	err = s.s3Repo.DeleteAllOrgFiles(ctx, keysToDelete)
+	// the replaced locked documents are kept as earlier versions with their files and comments
+	err = s.docRepo.SupersedeDocuments(ctx, replacedIDs)
 	if err != nil {
-		return fmt.Errorf("error deleting files: %v", err)
-	}
-
-	// delete the locked documents from the PG database only when there is a replacement available
-	err = s.docRepo.DeleteSelectedDocuments(ctx, IDsToDelete)
-	if err != nil {
-		return fmt.Errorf("error deleting documents: %v", err)
+		return fmt.Errorf("error superseding documents: %v", err)
 	}
 
 	// go through all staged docs and update "staged" to "locked" in PG
@@ -262,12 +453,21 @@ func (s *DocumentService) LockDocuments(ctx context.Context, pID uuid.UUID, uID
 		return fmt.Errorf("error updating staged to locked: %v", err)
 	}
 
+	// record the lock for the activity digests
+	err = s.activityRepo.Record(ctx, activity.NewActivity(pID, uID, activity.KindLock, ""))
+	if err != nil {
+		log.Printf("error recording lock activity: %v", err)
+	}
+
+	eventbroker.Emit(ctx, s.webhooks, s.broker, eventbroker.NewEvent(eventbroker.DocumentsLocked, pID, uID))
+
 	// only returning an error bc it would need to do so much work, get docs-membmerships-p details, etc
 	return nil
 }
 
-// need to document and further understand
-func (s *DocumentService) DownloadDocument(ctx context.Context, docID uuid.UUID) (DownloadDocumentResponse, error) {
+// DownloadDocument links to the file in the bucket when the storage can, otherwise the file
+// is sent through the app. Access to the document is checked by the caller
+func (s *DocumentService) DownloadDocument(ctx context.Context, docID uuid.UUID, req DownloadDocumentRequest) (DownloadDocumentResponse, error) {
 	rv := DownloadDocumentResponse{}
 
 	if s.s3Repo == nil {
@@ -280,18 +480,98 @@ func (s *DocumentService) DownloadDocument(ctx context.Context, docID uuid.UUID)
 		return rv, fmt.Errorf("error getting document details: %v", err)
 	}
 
+	// pending and quarantined files never leave the bucket
+	err = doc.CheckScan()
+	if err != nil {
+		return rv, err
+	}
+
+	rv.FileName = doc.FileName
+	rv.ContentType = document.ContentTypeFor(doc)
+	rv.Disposition = disposition(rv.ContentType, doc.FileName, req.Inline)
+
+	// a watermarked copy is made for each download, so it's always whole and never straight from the bucket
+	stamp, err := s.watermarkFor(ctx, doc, req.UserID)
+	if err != nil {
+		return rv, err
+	}
+	if stamp != nil {
+		rv.Watermarked = true
+		rv.Body, rv.Size, err = s.watermarked(ctx, doc, *stamp)
+		return rv, err
+	}
+
+	if s.linker != nil {
+		rv.URL, err = s.linker.LinkFile(ctx, doc, rv.Disposition, linkExpiry)
+		if err != nil {
+			return rv, fmt.Errorf("error linking to file: %v", err)
+		}
+		return rv, nil
+	}
+
 	// download the file from the s3 bucket
-	stream, err := s.s3Repo.DownloadFile(ctx, doc.FileName, doc.ID)
+	stream, err := s.s3Repo.DownloadFile(ctx, doc, req.Range)
+	if err == document.ErrRangeNotSatisfiable {
+		return rv, err
+	}
 	if err != nil {
 		return rv, fmt.Errorf("error downloading file: %v", err)
 	}
 
-	rv.DocStream = stream
-	rv.FileName = doc.FileName
+	rv.Body = stream.Body
+	// a whole file is checked against the checksum it was uploaded with as it's sent
+	if req.Range == nil && doc.Checksum != "" {
+		rv.Body = document.NewVerifier(stream.Body, doc.Checksum)
+	}
+	rv.Size = -1
+	if stream.ContentLength != nil {
+		rv.Size = *stream.ContentLength
+	}
+	if stream.ContentRange != nil {
+		rv.ContentRange = *stream.ContentRange
+	}
 	return rv, nil
 }
 
-func (s *DocumentService) DeleteDocument(ctx context.Context, docID uuid.UUID) (uuid.UUID, error) {
+// GetThumbnail is the PNG of the first page of a PDF that's passed the scan, ErrNoThumbnail for anything
+// else so it's shown with an icon. Members whose copies are watermarked only get the icon, the
+// thumbnail is of the file as it was uploaded
+func (s *DocumentService) GetThumbnail(ctx context.Context, docID, userID uuid.UUID) ([]byte, error) {
+	doc, err := s.docRepo.GetDocumentDetails(ctx, docID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting document details: %v", err)
+	}
+
+	if document.ContentTypeFor(doc) != document.PDF.ContentType || doc.CheckScan() != nil {
+		return nil, document.ErrNoThumbnail
+	}
+
+	stamp, err := s.watermarkFor(ctx, doc, userID)
+	if err != nil {
+		return nil, err
+	}
+	if stamp != nil {
+		return nil, document.ErrNoThumbnail
+	}
+
+	png, err := s.s3Repo.DownloadThumbnail(ctx, doc)
+	if err == document.ErrNoThumbnail {
+		// uploaded before thumbnails were made, or dropped from a full queue
+		s.queueThumbnail(doc)
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error downloading thumbnail: %v", err)
+	}
+
+	// the file couldn't be rendered
+	if len(png) == 0 {
+		return nil, document.ErrNoThumbnail
+	}
+	return png, nil
+}
+
+func (s *DocumentService) DeleteDocument(ctx context.Context, docID uuid.UUID, userID uuid.UUID) (uuid.UUID, error) {
 	pID := uuid.UUID{}
 
 	// get the doc from the PG database
@@ -306,8 +586,8 @@ func (s *DocumentService) DeleteDocument(ctx context.Context, docID uuid.UUID) (
 		return pID, fmt.Errorf("error deleting comments: %v", err)
 	}
 
-	// delete the file from the s3 bucket
-	err = s.s3Repo.DeleteFile(ctx, doc)
+	// delete the file from the s3 bucket once no other version uses it
+	err = s.releaseFile(ctx, doc)
 	if err != nil {
 		return pID, fmt.Errorf("error deleting file: %v", err)
 	}
@@ -318,6 +598,43 @@ func (s *DocumentService) DeleteDocument(ctx context.Context, docID uuid.UUID) (
 		return pID, fmt.Errorf("error deleting document: %v", err)
 	}
 
+	// a deleted draft has no comments left, so it drops out of the version history
+	err = s.docRepo.DeleteVersion(ctx, docID)
+	if err != nil {
+		return pID, fmt.Errorf("error deleting document version: %v", err)
+	}
+
+	eventbroker.Emit(ctx, s.webhooks, s.broker, eventbroker.NewDocumentEvent(eventbroker.DocumentDeleted, doc.OrganizationID, userID, doc.ID))
+
 	// return the project ID to redirect to the project page
 	return doc.OrganizationID, nil
 }
+
+// recordVersion numbers the new document after the earlier versions of its file type, documents
+// uploaded before versions were kept are numbered first so their comments stay in the history
+func (s *DocumentService) recordVersion(ctx context.Context, d *document.Document, existing []*document.Document) error {
+	versions, err := s.docRepo.GetVersions(ctx, d.OrganizationID, d.FileType)
+	if err != nil {
+		return fmt.Errorf("error getting document versions: %v", err)
+	}
+
+	// the locked document was uploaded before the staged one it could be replaced by
+	sort.SliceStable(existing, func(i, j int) bool {
+		return existing[i].Date.Before(*existing[j].Date)
+	})
+
+	for _, e := range existing {
+		if e.FileType != d.FileType || e.ID == d.ID || document.FindVersion(versions, e.ID) != nil {
+			continue
+		}
+
+		v := document.NewVersion(e, versions)
+		err = s.docRepo.SaveVersion(ctx, v)
+		if err != nil {
+			return err
+		}
+		versions = append(versions, *v)
+	}
+
+	return s.docRepo.SaveVersion(ctx, document.NewVersion(d, versions))
+}
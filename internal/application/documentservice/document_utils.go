@@ -0,0 +1,413 @@
+package documentservice
+
+import (
+	"context"
+	"errors"
+	"filmPackager/internal/domain/document"
+	"filmPackager/internal/domain/thumbnail"
+	"filmPackager/internal/domain/watermark"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// how many pending documents are rescanned each run
+const rescanBatch = 20
+
+// how long the URLs for sending the parts of an upload last, ResumeUpload hands out new ones
+const partURLExpiry = time.Hour
+
+// how long a download link lasts, long enough to start the download but not to pass around
+const linkExpiry = 5 * time.Minute
+
+// how many documents can wait for a thumbnail, past that they're made when the thumbnail is first asked for
+const thumbnailQueue = 100
+
+// only a PDF is shown inline, anything else is downloaded rather than rendered by the browser
+func disposition(contentType, fileName string, inline bool) string {
+	if inline && contentType == document.PDF.ContentType {
+		return fmt.Sprintf("inline; filename=%q", fileName)
+	}
+	return fmt.Sprintf("attachment; filename=%q", fileName)
+}
+
+// checks the user's highest role against their access tier
+func (s *DocumentService) checkUploadAccess(ctx context.Context, projectID, userID uuid.UUID, fileType string) error {
+	m, err := s.memberRepo.GetMembership(ctx, projectID, userID)
+	if err != nil {
+		return fmt.Errorf("error getting membership: %v", err)
+	}
+
+	if !slices.Contains(accessTiers[m.Roles[0]], fileType) {
+		// return custom error for template
+		return document.ErrAccessDenied
+	}
+
+	return nil
+}
+
+// an upload can only be carried on by whoever started it, and not once it's been abandoned
+func (s *DocumentService) getUpload(ctx context.Context, projectID, uploadID, userID uuid.UUID) (*document.Upload, error) {
+	u, err := s.uploadRepo.GetUpload(ctx, uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.ProjectID != projectID || u.UserID != userID || u.IsExpired(time.Now()) {
+		return nil, document.ErrUploadNotFound
+	}
+
+	return u, nil
+}
+
+func (s *DocumentService) uploadSession(ctx context.Context, u *document.Upload, parts []document.Part) (*UploadSessionResponse, error) {
+	rv := &UploadSessionResponse{ID: u.ID, PartSize: document.PartSize, Parts: []UploadPartURL{}, Done: []int32{}}
+
+	sent := make(map[int32]bool)
+	for _, p := range parts {
+		sent[p.Number] = true
+	}
+
+	for n := int32(1); n <= u.PartCount(); n++ {
+		if sent[n] {
+			rv.Done = append(rv.Done, n)
+			continue
+		}
+
+		url, err := s.uploadStore.PresignPart(ctx, u, n, partURLExpiry)
+		if err != nil {
+			return nil, fmt.Errorf("error presigning part %d: %v", n, err)
+		}
+		rv.Parts = append(rv.Parts, UploadPartURL{Number: n, URL: url})
+	}
+
+	return rv, nil
+}
+
+// downloadUpload copies the finished upload to a temp file, which the caller removes
+func (s *DocumentService) downloadUpload(ctx context.Context, u *document.Upload) (*os.File, error) {
+	body, err := s.uploadStore.GetUploaded(ctx, u)
+	if err != nil {
+		return nil, fmt.Errorf("error getting upload: %v", err)
+	}
+	defer body.Close()
+
+	file, err := os.CreateTemp("", "filmpackager-upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("error creating temp file: %v", err)
+	}
+
+	n, err := io.Copy(file, body)
+	if err == nil && n != u.Size {
+		err = document.ErrIncompleteUpload
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, fmt.Errorf("error copying upload: %v", err)
+	}
+
+	return file, nil
+}
+
+// watermarkFor is the stamp for the member's copy, nil when their highest role gets the document as it was uploaded.
+// Only PDFs are stamped, anything else is sent unchanged
+func (s *DocumentService) watermarkFor(ctx context.Context, doc *document.Document, userID uuid.UUID) (*watermark.Stamp, error) {
+	if document.ContentTypeFor(doc) != document.PDF.ContentType {
+		return nil, nil
+	}
+
+	m, err := s.memberRepo.GetMembership(ctx, doc.OrganizationID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting membership: %v", err)
+	}
+	// an invite that hasn't been accepted doesn't get a copy at all
+	if m.InviteStatus != "accepted" {
+		return nil, document.ErrAccessDenied
+	}
+	if len(m.Roles) == 0 || !s.watermarks.Applies(m.Roles[0], doc.FileType) {
+		return nil, nil
+	}
+
+	project, err := s.projRepo.GetProjectByID(ctx, doc.OrganizationID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting project: %v", err)
+	}
+
+	return &watermark.Stamp{Name: m.UserName, Email: m.UserEmail, Project: project.Name, Time: time.Now()}, nil
+}
+
+// downloadFile copies the document's file to a temp file, checked against its checksum on the way.
+// The caller closes and removes the file
+func (s *DocumentService) downloadFile(ctx context.Context, doc *document.Document, pattern string) (*os.File, int64, error) {
+	stream, err := s.s3Repo.DownloadFile(ctx, doc, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error downloading file: %v", err)
+	}
+	body := stream.Body
+	if doc.Checksum != "" {
+		body = document.NewVerifier(body, doc.Checksum)
+	}
+	defer body.Close()
+
+	file, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error creating temp file: %v", err)
+	}
+
+	size, err := io.Copy(file, body)
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, 0, fmt.Errorf("error downloading file: %v", err)
+	}
+
+	return file, size, nil
+}
+
+// watermarked copies the PDF to a temp file and writes the stamped copy to another, which is sent.
+// A file that can't be stamped isn't sent at all, the stamped copy is removed once the body is closed
+func (s *DocumentService) watermarked(ctx context.Context, doc *document.Document, stamp watermark.Stamp) (io.ReadCloser, int64, error) {
+	file, size, err := s.downloadFile(ctx, doc, "filmpackager-watermark-*")
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() {
+		file.Close()
+		os.Remove(file.Name())
+	}()
+
+	out, err := os.CreateTemp("", "filmpackager-watermarked-*")
+	if err != nil {
+		return nil, 0, fmt.Errorf("error creating temp file: %v", err)
+	}
+
+	n, err := watermark.StampPDF(file, size, stamp, out)
+	if err == nil {
+		_, err = out.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		if errors.Is(err, watermark.ErrEncryptedPDF) {
+			return nil, 0, watermark.ErrEncryptedPDF
+		}
+		if errors.Is(err, watermark.ErrUnreadablePDF) {
+			log.Printf("error watermarking %s: %v", doc.ID, err)
+			return nil, 0, watermark.ErrUnreadablePDF
+		}
+		return nil, 0, fmt.Errorf("error writing watermarked file: %v", err)
+	}
+
+	return &tempBody{Reader: out, file: out}, n, nil
+}
+
+// tempBody is read from a temp file that goes once it's closed
+type tempBody struct {
+	io.Reader
+	file *os.File
+}
+
+func (b *tempBody) Close() error {
+	b.file.Close()
+	return os.Remove(b.file.Name())
+}
+
+// removeUpload clears away a finished or abandoned upload, failures are only logged
+func (s *DocumentService) removeUpload(ctx context.Context, u *document.Upload) {
+	err := s.uploadStore.DeleteUploaded(ctx, u)
+	if err != nil {
+		log.Printf("error deleting upload %s from the bucket: %v", u.ID, err)
+	}
+
+	err = s.uploadRepo.DeleteUpload(ctx, u.ID)
+	if err != nil {
+		log.Printf("error deleting upload %s: %v", u.ID, err)
+	}
+}
+
+// scan runs the file through the scanner, a failed scan leaves the document pending rather than letting it through
+func (s *DocumentService) scan(ctx context.Context, file io.Reader, name string) string {
+	signature, err := s.scanner.Scan(ctx, file)
+	if err != nil {
+		log.Printf("error scanning %s, it stays pending: %v", name, err)
+	}
+	if signature != "" {
+		log.Printf("quarantined %s: %s", name, signature)
+	}
+
+	return document.ScanState(signature, err)
+}
+
+// Start retries the documents the scanner couldn't check on upload, clears away abandoned uploads
+// and makes the thumbnails of uploaded PDFs
+func (s *DocumentService) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case doc := <-s.thumbnails:
+			s.makeThumbnail(ctx, doc)
+		case now := <-ticker.C:
+			err := s.RescanPending(ctx)
+			if err != nil {
+				log.Printf("error rescanning documents: %v", err)
+			}
+
+			err = s.AbortStaleUploads(ctx, now)
+			if err != nil {
+				log.Printf("error clearing abandoned uploads: %v", err)
+			}
+		}
+	}
+}
+
+// RescanPending scans the pending documents again from the bucket
+func (s *DocumentService) RescanPending(ctx context.Context) error {
+	pending, err := s.docRepo.FindPendingScan(ctx, rescanBatch)
+	if err != nil {
+		return fmt.Errorf("error getting pending documents: %v", err)
+	}
+
+	for _, doc := range pending {
+		stream, err := s.s3Repo.DownloadFile(ctx, doc, nil)
+		if err != nil {
+			log.Printf("error downloading %s to rescan: %v", doc.ID, err)
+			continue
+		}
+
+		status := s.scan(ctx, stream.Body, doc.FileName)
+		stream.Body.Close()
+
+		// still pending, the scanner is likely down so the rest can wait for the next run
+		if status == document.ScanPending {
+			return nil
+		}
+
+		err = s.docRepo.UpdateScanStatus(ctx, doc.ID, status)
+		if err != nil {
+			log.Printf("error saving the scan of %s: %v", doc.ID, err)
+			continue
+		}
+
+		doc.ScanStatus = status
+		s.queueThumbnail(doc)
+	}
+
+	return nil
+}
+
+// AbortStaleUploads throws away the parts of uploads that were never finished
+func (s *DocumentService) AbortStaleUploads(ctx context.Context, now time.Time) error {
+	stale, err := s.uploadRepo.GetUploadsStartedBefore(ctx, now.Add(-document.UploadTTL))
+	if err != nil {
+		return fmt.Errorf("error getting abandoned uploads: %v", err)
+	}
+
+	for _, u := range stale {
+		err := s.uploadStore.AbortUpload(ctx, u)
+		if err != nil {
+			// already completed or aborted in the bucket, the row still goes
+			log.Printf("error aborting upload %s: %v", u.ID, err)
+		}
+		s.removeUpload(ctx, u)
+	}
+
+	return nil
+}
+
+// storeFile puts the document's file in the bucket, unless the project already has the same file stored
+func (s *DocumentService) storeFile(ctx context.Context, d *document.Document, file io.ReaderAt, size int64) error {
+	return s.blobRepo.AddBlobRef(ctx, d.OrganizationID, d.Checksum, size, func() error {
+		_, err := s.s3Repo.UploadFile(ctx, d, io.NewSectionReader(file, 0, size))
+		if err != nil {
+			return fmt.Errorf("error uploading file: %v", err)
+		}
+		return nil
+	})
+}
+
+// dropFile gives back the reference storeFile took for a document that didn't end up saved,
+// the upload has already failed so an error here is only logged
+func (s *DocumentService) dropFile(ctx context.Context, d *document.Document) {
+	err := s.releaseFile(ctx, d)
+	if err != nil {
+		log.Printf("error releasing the blob of %s: %v", d.ID, err)
+	}
+}
+
+// releaseFile deletes the document's file from the bucket once no other document uses it
+func (s *DocumentService) releaseFile(ctx context.Context, d *document.Document) error {
+	// uploaded before files were content addressed, so it's the document's own
+	if d.Checksum == "" {
+		return s.deleteFile(ctx, d)
+	}
+
+	return s.blobRepo.ReleaseBlobRef(ctx, d.OrganizationID, d.Checksum, func() error {
+		return s.deleteFile(ctx, d)
+	})
+}
+
+// deleteFile deletes the file and its thumbnail, a thumbnail left behind is only logged
+func (s *DocumentService) deleteFile(ctx context.Context, d *document.Document) error {
+	err := s.s3Repo.DeleteFile(ctx, d)
+	if err != nil {
+		return err
+	}
+
+	err = s.s3Repo.DeleteThumbnail(ctx, d)
+	if err != nil {
+		log.Printf("error deleting the thumbnail of %s: %v", d.ID, err)
+	}
+	return nil
+}
+
+// queueThumbnail hands the document to Start to make its thumbnail. Only PDFs get one, and a full
+// queue drops it, GetThumbnail queues it again when it's next asked for
+func (s *DocumentService) queueThumbnail(d *document.Document) {
+	if document.ContentTypeFor(d) != document.PDF.ContentType || d.CheckScan() != nil {
+		return
+	}
+
+	select {
+	case s.thumbnails <- d:
+	default:
+	}
+}
+
+// makeThumbnail renders the first page of the document's file. A file that can't be rendered gets an
+// empty thumbnail, so it's shown with an icon rather than tried again
+func (s *DocumentService) makeThumbnail(ctx context.Context, d *document.Document) {
+	// queued more than once, or another document with the same file already has it
+	_, err := s.s3Repo.DownloadThumbnail(ctx, d)
+	if err == nil {
+		return
+	}
+
+	file, size, err := s.downloadFile(ctx, d, "filmpackager-thumbnail-*")
+	if err != nil {
+		log.Printf("error getting %s for its thumbnail: %v", d.ID, err)
+		return
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	png, err := thumbnail.Render(file, size)
+	if err != nil {
+		log.Printf("error rendering the thumbnail of %s: %v", d.ID, err)
+		png = []byte{}
+	}
+
+	err = s.s3Repo.UploadThumbnail(ctx, d, png)
+	if err != nil {
+		log.Printf("error storing the thumbnail of %s: %v", d.ID, err)
+	}
+}
@@ -0,0 +1,102 @@
+package eventbroker
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// the kinds of events published by the application services
+const (
+	DocumentUploaded   = "document.uploaded"
+	DocumentDeleted    = "document.deleted"
+	DocumentsLocked    = "documents.locked"
+	CommentCreated     = "comment.created"
+	CommentDeleted     = "comment.deleted"
+	CommentResolved    = "comment.resolved"
+	CommentReopened    = "comment.reopened"
+	CommentEdited      = "comment.edited"
+	MemberInvited      = "membership.invited"
+	MemberJoined       = "membership.joined"
+	MemberRolesUpdated = "membership.updated"
+)
+
+// how many events a slow subscriber can fall behind before events are dropped
+const subscriberBuffer = 16
+
+type Event struct {
+	Kind      string
+	ProjectID uuid.UUID
+	ActorID   uuid.UUID
+	// only set for document and comment events
+	DocID     uuid.UUID
+	CreatedAt time.Time
+}
+
+func NewEvent(kind string, projectID, actorID uuid.UUID) Event {
+	return Event{
+		Kind:      kind,
+		ProjectID: projectID,
+		ActorID:   actorID,
+		CreatedAt: time.Now(),
+	}
+}
+
+func NewDocumentEvent(kind string, projectID, actorID, docID uuid.UUID) Event {
+	e := NewEvent(kind, projectID, actorID)
+	e.DocID = docID
+	return e
+}
+
+// Broker fans out project events to the subscribers of that project within this process
+type Broker struct {
+	mu   sync.RWMutex
+	subs map[uuid.UUID]map[chan Event]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		subs: make(map[uuid.UUID]map[chan Event]struct{}),
+	}
+}
+
+// Subscribe returns a channel of the project's events and a func to stop receiving them
+func (b *Broker) Subscribe(projectID uuid.UUID) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	b.mu.Lock()
+	if b.subs[projectID] == nil {
+		b.subs[projectID] = make(map[chan Event]struct{})
+	}
+	b.subs[projectID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs[projectID], ch)
+			if len(b.subs[projectID]) == 0 {
+				delete(b.subs, projectID)
+			}
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+
+	return ch, unsubscribe
+}
+
+// Publish never blocks the caller, a subscriber with a full buffer misses the event
+func (b *Broker) Publish(e Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for ch := range b.subs[e.ProjectID] {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
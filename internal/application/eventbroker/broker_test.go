@@ -0,0 +1,32 @@
+package eventbroker_test
+
+import (
+	"filmPackager/internal/application/eventbroker"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBrokerDeliversOnlyToProjectSubscribers(t *testing.T) {
+	b := eventbroker.NewBroker()
+	projectA, projectB := uuid.New(), uuid.New()
+
+	eventsA, unsubscribeA := b.Subscribe(projectA)
+	eventsB, unsubscribeB := b.Subscribe(projectB)
+	defer unsubscribeB()
+
+	b.Publish(eventbroker.NewEvent(eventbroker.DocumentsLocked, projectA, uuid.New()))
+
+	e := <-eventsA
+	assert.Equal(t, eventbroker.DocumentsLocked, e.Kind)
+	assert.Equal(t, projectA, e.ProjectID)
+	assert.Empty(t, eventsB)
+
+	// the channel is closed and publishing afterwards doesn't panic
+	unsubscribeA()
+	unsubscribeA()
+	_, ok := <-eventsA
+	assert.False(t, ok)
+	b.Publish(eventbroker.NewEvent(eventbroker.DocumentsLocked, projectA, uuid.New()))
+}
@@ -0,0 +1,24 @@
+package eventbroker
+
+import (
+	"context"
+	"log"
+)
+
+// Recorder keeps a lasting record of an event. The broker only reaches the pages open at the
+// time and drops events for subscribers that fall behind, so anything owed for every event,
+// like the webhook deliveries, is recorded instead
+type Recorder interface {
+	RecordEvent(ctx context.Context, e Event) error
+}
+
+// Emit records the event before publishing it to the open pages. What the event is about has
+// already happened, so failing to record it is logged rather than returned
+func Emit(ctx context.Context, r Recorder, b *Broker, e Event) {
+	err := r.RecordEvent(ctx, e)
+	if err != nil {
+		log.Printf("error recording %s event: %v", e.Kind, err)
+	}
+
+	b.Publish(e)
+}
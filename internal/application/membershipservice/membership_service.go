@@ -2,9 +2,15 @@ package membershipservice
 
 import (
 	"context"
+	"errors"
+	"filmPackager/internal/application/eventbroker"
+	"filmPackager/internal/domain/activity"
 	"filmPackager/internal/domain/membership"
+	"filmPackager/internal/domain/project"
+	"filmPackager/internal/domain/twofactor"
 	"filmPackager/internal/domain/user"
 	"fmt"
+	"log"
 	"slices"
 	"strings"
 
@@ -12,8 +18,13 @@ import (
 )
 
 type MembershipService struct {
-	memberRepo membership.MembershipRepository
-	userRepo   user.UserRepository
+	memberRepo    membership.MembershipRepository
+	userRepo      user.UserRepository
+	activityRepo  activity.ActivityRepository
+	projRepo      project.ProjectRepository
+	twoFactorRepo twofactor.TwoFactorRepository
+	broker        *eventbroker.Broker
+	webhooks      eventbroker.Recorder
 }
 
 type GetMembershipResponse struct {
@@ -21,8 +32,8 @@ type GetMembershipResponse struct {
 	AvailableRoles []string
 }
 
-func NewMembershipService(memberRepo membership.MembershipRepository, userRepo user.UserRepository) *MembershipService {
-	return &MembershipService{memberRepo: memberRepo, userRepo: userRepo}
+func NewMembershipService(memberRepo membership.MembershipRepository, userRepo user.UserRepository, activityRepo activity.ActivityRepository, projRepo project.ProjectRepository, twoFactorRepo twofactor.TwoFactorRepository, broker *eventbroker.Broker, webhooks eventbroker.Recorder) *MembershipService {
+	return &MembershipService{memberRepo: memberRepo, userRepo: userRepo, activityRepo: activityRepo, projRepo: projRepo, twoFactorRepo: twoFactorRepo, broker: broker, webhooks: webhooks}
 }
 
 type GetProjectMembershipsResponse struct {
@@ -67,7 +78,7 @@ func (s *MembershipService) SearchForNewMembersByName(ctx context.Context, name
 }
 
 // invite a user to a project
-func (s *MembershipService) InviteUserToProject(ctx context.Context, userID, projectID uuid.UUID) ([]membership.Membership, error) {
+func (s *MembershipService) InviteUserToProject(ctx context.Context, inviterID, userID, projectID uuid.UUID) ([]membership.Membership, error) {
 	// get the user by id
 	u, err := s.userRepo.GetUserById(ctx, userID)
 	if err != nil {
@@ -75,7 +86,7 @@ func (s *MembershipService) InviteUserToProject(ctx context.Context, userID, pro
 	}
 
 	// check if the user is already a member of the project
-	_, err = s.memberRepo.GetMembership(ctx, userID, projectID)
+	_, err = s.memberRepo.GetMembership(ctx, projectID, userID)
 	if err == nil {
 		return nil, membership.ErrUserAlreadyMember
 	}
@@ -96,6 +107,14 @@ func (s *MembershipService) InviteUserToProject(ctx context.Context, userID, pro
 		return nil, fmt.Errorf("error creating new membership: %v", err)
 	}
 
+	// record the invite for the activity digests
+	err = s.activityRepo.Record(ctx, activity.NewActivity(projectID, inviterID, activity.KindInvite, u.Name))
+	if err != nil {
+		log.Printf("error recording invite activity: %v", err)
+	}
+
+	eventbroker.Emit(ctx, s.webhooks, s.broker, eventbroker.NewEvent(eventbroker.MemberInvited, projectID, inviterID))
+
 	// get all memberships for the project
 	memberships, err := s.memberRepo.GetProjectMemberships(ctx, projectID)
 
@@ -130,6 +149,28 @@ func (s *MembershipService) InviteUserToProject(ctx context.Context, userID, pro
 	return invited, nil
 }
 
+// CheckTwoFactor returns project.ErrTwoFactorRequired if the project requires two-factor
+// authentication and the user hasn't turned it on
+func (s *MembershipService) CheckTwoFactor(ctx context.Context, projectID, userID uuid.UUID) error {
+	p, err := s.projRepo.GetProjectByID(ctx, projectID)
+	if err != nil {
+		return fmt.Errorf("error getting project: %v", err)
+	}
+	if !p.RequireTwoFactor {
+		return nil
+	}
+
+	tf, err := s.twoFactorRepo.GetTwoFactor(ctx, userID)
+	if err != nil && !errors.Is(err, twofactor.ErrNotEnrolled) {
+		return fmt.Errorf("error getting two-factor settings: %v", err)
+	}
+	if !tf.IsEnabled() {
+		return project.ErrTwoFactorRequired
+	}
+
+	return nil
+}
+
 // get a user's memberships for a project
 func (s *MembershipService) GetMembership(ctx context.Context, projectID, userID uuid.UUID) (*GetMembershipResponse, error) {
 	m, err := s.memberRepo.GetMembership(ctx, projectID, userID)
@@ -165,7 +206,7 @@ func (s *MembershipService) GetMembership(ctx context.Context, projectID, userID
 	return rv, nil
 }
 
-func (s *MembershipService) UpdateMemberRoles(ctx context.Context, projectID, userID uuid.UUID, role string) (*membership.Membership, error) {
+func (s *MembershipService) UpdateMemberRoles(ctx context.Context, updaterID, projectID, userID uuid.UUID, role string) (*membership.Membership, error) {
 	// get the membership
 	m, err := s.memberRepo.GetMembership(ctx, projectID, userID)
 	if err != nil {
@@ -196,6 +237,8 @@ func (s *MembershipService) UpdateMemberRoles(ctx context.Context, projectID, us
 		return nil, fmt.Errorf("error updating membership: %v", err)
 	}
 
+	eventbroker.Emit(ctx, s.webhooks, s.broker, eventbroker.NewEvent(eventbroker.MemberRolesUpdated, projectID, updaterID))
+
 	return m, nil
 }
 
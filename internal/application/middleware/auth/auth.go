@@ -1,21 +1,26 @@
 package auth
 
 import (
+	"filmPackager/internal/application/apitokenservice"
 	"filmPackager/internal/application/authservice"
+	"filmPackager/internal/domain/apitoken"
+	"filmPackager/internal/domain/session"
 	"filmPackager/internal/domain/user"
+	"strings"
 
 	"fmt"
-	"os"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt"
-	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type contextKey int
 
-const userKey contextKey = iota
+const (
+	userKey contextKey = iota
+	tokenKey
+	sessionKey
+)
 
 func HashPassword(password string) (string, error) {
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -26,41 +31,37 @@ func HashPassword(password string) (string, error) {
 	return hashedStr, nil
 }
 
-var jwtKey = []byte(os.Getenv("JWT_SECRET_KEY"))
-
-type Claims struct {
-	UserID uuid.UUID
-	jwt.StandardClaims
-}
-
-func New(svc *authservice.AuthService) fiber.Handler {
+func New(svc *authservice.AuthService, tokenSvc *apitokenservice.APITokenService) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		tokenString := c.Cookies("filmpackager")
-		if tokenString == "" {
-			return c.Next()
-		}
+		// personal API tokens are only accepted by the JSON API, the HTML routes need the session cookie
+		header := c.Get(fiber.HeaderAuthorization)
+		if strings.HasPrefix(c.Path(), "/api/") && strings.HasPrefix(header, "Bearer ") {
+			u, t, err := tokenSvc.Authenticate(c.Context(), strings.TrimPrefix(header, "Bearer "))
+			if err != nil {
+				return c.Next()
+			}
 
-		tokenString = tokenString[len("Bearer "):]
+			c.Locals(userKey, u)
+			c.Locals(tokenKey, t)
 
-		// get user id from token
-		claims := &Claims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return jwtKey, nil
-		})
-		if err != nil {
 			return c.Next()
 		}
-		if !token.Valid {
+
+		tokenString := c.Cookies(CookieName)
+		if tokenString == "" {
 			return c.Next()
 		}
 
-		u, err := svc.UserRepo.GetUserById(c.Context(), claims.UserID)
+		tokenString = tokenString[len("Bearer "):]
 
+		// the session is checked on every request so a revoked one stops working straight away
+		u, sess, err := svc.Authenticate(c.Context(), tokenString, ClientFromContext(c))
 		if err != nil {
 			return c.Next()
 		}
 
 		c.Locals(userKey, u)
+		c.Locals(sessionKey, sess)
 
 		return c.Next()
 	}
@@ -74,3 +75,28 @@ func GetUserFromContext(c *fiber.Ctx) *user.User {
 
 	return u
 }
+
+// GetTokenFromContext returns the API token the request was made with, nil for session requests
+func GetTokenFromContext(c *fiber.Ctx) *apitoken.Token {
+	t, ok := c.Locals(tokenKey).(*apitoken.Token)
+	if !ok {
+		return nil
+	}
+
+	return t
+}
+
+// GetSessionFromContext returns the session the request was made with, nil for API token requests
+func GetSessionFromContext(c *fiber.Ctx) *session.Session {
+	sess, ok := c.Locals(sessionKey).(*session.Session)
+	if !ok {
+		return nil
+	}
+
+	return sess
+}
+
+// ClientFromContext describes where the request came from, for the sessions page
+func ClientFromContext(c *fiber.Ctx) session.Client {
+	return session.Client{IP: c.IP(), UserAgent: c.Get(fiber.HeaderUserAgent)}
+}
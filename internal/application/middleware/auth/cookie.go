@@ -0,0 +1,26 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// CookieName is the session cookie, it holds "Bearer <token>"
+const CookieName = "filmpackager"
+
+// NewCookie returns a cookie with the flags every cookie the app sets shares: HttpOnly, only
+// sent over https (browsers allow localhost over http) and only sent with requests from other
+// sites when following a link, so links in emails still arrive logged in. A zero expiry makes
+// it last until the browser closes, one in the past deletes it
+func NewCookie(name, value string, expires time.Time) *fiber.Cookie {
+	return &fiber.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Expires:  expires,
+		HTTPOnly: true,
+		Secure:   true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	}
+}
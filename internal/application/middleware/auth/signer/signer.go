@@ -0,0 +1,149 @@
+package signer
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// tokens signed before key IDs were added have no kid, they're checked with the key of this ID
+const LegacyKeyID = "default"
+
+// shorter secrets are accepted but warned about, HS256 wants at least 256 bits
+const minSecretLength = 32
+
+var (
+	ErrNoKeys        = errors.New("no JWT signing keys, set JWT_SIGNING_KEYS or JWT_SECRET_KEY")
+	ErrEmptySecret   = errors.New("JWT signing key has an empty secret")
+	ErrDuplicateKey  = errors.New("JWT signing key ID is used twice")
+	ErrUnknownKey    = errors.New("token was signed with an unknown key")
+	ErrInvalidMethod = errors.New("token was not signed with HS256")
+	ErrInvalidToken  = errors.New("invalid token")
+)
+
+type Key struct {
+	ID     string
+	Secret []byte
+}
+
+// Signer signs tokens with the current key and checks them against every key it
+// holds, so a key can be rotated without logging everyone out: add the new key
+// first, and drop the old one once the tokens it signed have expired
+type Signer struct {
+	current string
+	keys    map[string][]byte
+}
+
+// New returns a signer that signs with the first key
+func New(keys []Key) (*Signer, error) {
+	if len(keys) == 0 {
+		return nil, ErrNoKeys
+	}
+
+	s := &Signer{current: keys[0].ID, keys: make(map[string][]byte)}
+	for _, k := range keys {
+		if len(k.Secret) == 0 {
+			return nil, fmt.Errorf("%w: %q", ErrEmptySecret, k.ID)
+		}
+		if _, ok := s.keys[k.ID]; ok {
+			return nil, fmt.Errorf("%w: %q", ErrDuplicateKey, k.ID)
+		}
+		if len(k.Secret) < minSecretLength {
+			log.Printf("JWT signing key %q is shorter than %d bytes", k.ID, minSecretLength)
+		}
+		s.keys[k.ID] = k.Secret
+	}
+
+	return s, nil
+}
+
+// FromEnv reads the keys from JWT_SIGNING_KEYS as comma separated id:secret pairs,
+// newest first, falling back to JWT_SECRET_KEY as the legacy key
+func FromEnv() (*Signer, error) {
+	keys, err := ParseKeys(os.Getenv("JWT_SIGNING_KEYS"))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(keys) == 0 {
+		if secret := os.Getenv("JWT_SECRET_KEY"); secret != "" {
+			keys = append(keys, Key{ID: LegacyKeyID, Secret: []byte(secret)})
+		}
+	}
+
+	return New(keys)
+}
+
+// ParseKeys parses id:secret pairs separated by commas, the secrets can't contain commas
+func ParseKeys(env string) ([]Key, error) {
+	keys := []Key{}
+	for _, pair := range strings.Split(env, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		id, secret, ok := strings.Cut(pair, ":")
+		if !ok || strings.TrimSpace(id) == "" {
+			return nil, errors.New("JWT signing keys should be id:secret pairs, got one without an id")
+		}
+
+		keys = append(keys, Key{ID: strings.TrimSpace(id), Secret: []byte(secret)})
+	}
+
+	return keys, nil
+}
+
+// CurrentKeyID is the kid new tokens are signed with
+func (s *Signer) CurrentKeyID() string {
+	return s.current
+}
+
+func (s *Signer) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = s.current
+
+	return token.SignedString(s.keys[s.current])
+}
+
+// Parse checks the token's signature and expiry and fills in the claims. Only HS256 is
+// accepted whatever the token's header says, so a token can't pick a weaker algorithm
+func (s *Signer) Parse(tokenString string, claims jwt.Claims) error {
+	parser := &jwt.Parser{ValidMethods: []string{jwt.SigningMethodHS256.Alg()}}
+
+	token, err := parser.ParseWithClaims(tokenString, claims, s.keyFor)
+	if err != nil {
+		var validationErr *jwt.ValidationError
+		if errors.As(err, &validationErr) && validationErr.Inner != nil {
+			return validationErr.Inner
+		}
+		return err
+	}
+	if !token.Valid {
+		return ErrInvalidToken
+	}
+
+	return nil
+}
+
+func (s *Signer) keyFor(token *jwt.Token) (interface{}, error) {
+	if token.Method != jwt.SigningMethodHS256 {
+		return nil, ErrInvalidMethod
+	}
+
+	kid, ok := token.Header["kid"].(string)
+	if !ok {
+		kid = LegacyKeyID
+	}
+
+	secret, ok := s.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	return secret, nil
+}
@@ -0,0 +1,87 @@
+package signer_test
+
+import (
+	"filmPackager/internal/application/middleware/auth/signer"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	oldKey = signer.Key{ID: "2024-01", Secret: []byte("an old secret that is at least 32 bytes")}
+	newKey = signer.Key{ID: "2024-06", Secret: []byte("a new secret that is also at least 32 bytes")}
+)
+
+func claims() *jwt.StandardClaims {
+	return &jwt.StandardClaims{Subject: "user", ExpiresAt: time.Now().Add(time.Hour).Unix()}
+}
+
+func TestNewSigner(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := signer.New(nil)
+	assert.ErrorIs(err, signer.ErrNoKeys)
+
+	_, err = signer.New([]signer.Key{{ID: "empty"}})
+	assert.ErrorIs(err, signer.ErrEmptySecret)
+
+	_, err = signer.New([]signer.Key{oldKey, oldKey})
+	assert.ErrorIs(err, signer.ErrDuplicateKey)
+
+	keys, err := signer.ParseKeys("2024-06:abc, 2024-01:d:ef")
+	assert.NoError(err)
+	assert.Equal([]signer.Key{{ID: "2024-06", Secret: []byte("abc")}, {ID: "2024-01", Secret: []byte("d:ef")}}, keys)
+
+	_, err = signer.ParseKeys("no-id")
+	assert.Error(err)
+}
+
+func TestRotateKeys(t *testing.T) {
+	assert := assert.New(t)
+
+	before, err := signer.New([]signer.Key{oldKey})
+	assert.NoError(err)
+	token, err := before.Sign(claims())
+	assert.NoError(err)
+
+	// the new key signs while the old one still checks the tokens it signed
+	during, err := signer.New([]signer.Key{newKey, oldKey})
+	assert.NoError(err)
+	assert.Equal(newKey.ID, during.CurrentKeyID())
+	assert.NoError(during.Parse(token, &jwt.StandardClaims{}))
+
+	fresh, err := during.Sign(claims())
+	assert.NoError(err)
+
+	// once the old key is dropped its tokens stop working
+	after, err := signer.New([]signer.Key{newKey})
+	assert.NoError(err)
+	assert.NoError(after.Parse(fresh, &jwt.StandardClaims{}))
+	assert.ErrorIs(after.Parse(token, &jwt.StandardClaims{}), signer.ErrUnknownKey)
+}
+
+func TestParseRejectsOtherMethods(t *testing.T) {
+	assert := assert.New(t)
+
+	s, err := signer.New([]signer.Key{newKey})
+	assert.NoError(err)
+
+	none := jwt.NewWithClaims(jwt.SigningMethodNone, claims())
+	none.Header["kid"] = newKey.ID
+	unsigned, err := none.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	assert.NoError(err)
+	assert.Error(s.Parse(unsigned, &jwt.StandardClaims{}))
+
+	hs512 := jwt.NewWithClaims(jwt.SigningMethodHS512, claims())
+	hs512.Header["kid"] = newKey.ID
+	signed, err := hs512.SignedString(newKey.Secret)
+	assert.NoError(err)
+	assert.Error(s.Parse(signed, &jwt.StandardClaims{}))
+
+	expired := &jwt.StandardClaims{ExpiresAt: time.Now().Add(-time.Minute).Unix()}
+	old, err := s.Sign(expired)
+	assert.NoError(err)
+	assert.Error(s.Parse(old, &jwt.StandardClaims{}))
+}
@@ -0,0 +1,178 @@
+package csrf
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"filmPackager/internal/application/middleware/auth"
+	"filmPackager/internal/application/middleware/auth/signer"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt"
+)
+
+const (
+	// the cookie isn't HttpOnly, static/js/csrf.js reads it and sends it back in the header
+	CookieName = "filmpackager_csrf"
+	HeaderName = "X-CSRF-Token"
+	// forms posted without htmx send the token in this field instead
+	FormField = "_csrf"
+
+	subject = "csrf"
+)
+
+var (
+	ErrMissingToken = errors.New("missing or invalid CSRF token, reload the page and try again")
+	ErrCrossSite    = errors.New("cross-site requests aren't allowed")
+)
+
+type contextKey int
+
+const (
+	tokenKey contextKey = iota
+	validKey
+)
+
+// the token is tied to the session it was issued for, so one issued to an attacker's own
+// browser can't be planted in someone else's
+type claims struct {
+	Session string `json:"sid,omitempty"`
+	jwt.StandardClaims
+}
+
+// New issues every browser a signed token in a cookie and rejects state-changing requests that
+// don't send it back or that come from another site. Requests made with an API token don't
+// carry cookies so they aren't checked, the JSON API checks session requests itself with Check
+// so it can answer in its own format. It runs after the auth middleware so the session is known.
+func New(s *signer.Signer) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if auth.GetTokenFromContext(c) != nil {
+			return c.Next()
+		}
+
+		binding := ""
+		if sess := auth.GetSessionFromContext(c); sess != nil {
+			binding = sess.ID.String()
+		}
+
+		token := c.Cookies(CookieName)
+		valid := isValid(s, token, binding)
+		if !valid {
+			var err error
+			token, err = issue(s, binding)
+			if err != nil {
+				return err
+			}
+
+			// a browser session cookie, it's replaced whenever it's missing or stale
+			cookie := auth.NewCookie(CookieName, token, time.Time{})
+			cookie.HTTPOnly = false
+			c.Cookie(cookie)
+		}
+
+		c.Locals(tokenKey, token)
+		c.Locals(validKey, valid)
+
+		if !strings.HasPrefix(c.Path(), "/api/") {
+			err := Check(c)
+			if err != nil {
+				return fiber.NewError(fiber.StatusForbidden, err.Error())
+			}
+		}
+
+		return c.Next()
+	}
+}
+
+// Check returns an error if a state-changing request didn't come from one of the app's own pages
+func Check(c *fiber.Ctx) error {
+	if isSafeMethod(c.Method()) {
+		return nil
+	}
+
+	if !isSameOrigin(c) {
+		return ErrCrossSite
+	}
+
+	token, _ := c.Locals(tokenKey).(string)
+	valid, _ := c.Locals(validKey).(bool)
+	if !valid || token == "" {
+		return ErrMissingToken
+	}
+
+	sent := c.Get(HeaderName)
+	if sent == "" {
+		sent = c.FormValue(FormField)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+		return ErrMissingToken
+	}
+
+	return nil
+}
+
+// Token returns the request's token for pages that post forms without htmx
+func Token(c *fiber.Ctx) string {
+	token, _ := c.Locals(tokenKey).(string)
+	return token
+}
+
+func issue(s *signer.Signer, binding string) (string, error) {
+	nonce := make([]byte, 16)
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+
+	return s.Sign(&claims{
+		Session: binding,
+		StandardClaims: jwt.StandardClaims{
+			Subject: subject,
+			Id:      base64.RawURLEncoding.EncodeToString(nonce),
+		},
+	})
+}
+
+func isValid(s *signer.Signer, token, binding string) bool {
+	if token == "" {
+		return false
+	}
+
+	cl := &claims{}
+	err := s.Parse(token, cl)
+
+	return err == nil && cl.Subject == subject && cl.Session == binding
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions, fiber.MethodTrace:
+		return true
+	default:
+		return false
+	}
+}
+
+// browsers say where a request came from in Sec-Fetch-Site and Origin, older ones may send neither
+func isSameOrigin(c *fiber.Ctx) bool {
+	if site := c.Get("Sec-Fetch-Site"); site == "cross-site" || site == "same-site" {
+		return false
+	}
+
+	origin := c.Get(fiber.HeaderOrigin)
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(u.Host, c.Hostname())
+}
@@ -0,0 +1,79 @@
+package csrf_test
+
+import (
+	"filmPackager/internal/application/middleware/auth/signer"
+	"filmPackager/internal/application/middleware/csrf"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func newApp(t *testing.T, secret string) *fiber.App {
+	s, err := signer.New([]signer.Key{{ID: "test", Secret: []byte(secret)}})
+	assert.NoError(t, err)
+
+	app := fiber.New()
+	app.Use(csrf.New(s))
+	app.Get("/", func(c *fiber.Ctx) error { return c.SendString("ok") })
+	app.Post("/", func(c *fiber.Ctx) error { return c.SendString("ok") })
+
+	return app
+}
+
+// gets a page to be issued the token cookie
+func getToken(t *testing.T, app *fiber.App) string {
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	for _, c := range resp.Cookies() {
+		if c.Name == csrf.CookieName {
+			return c.Value
+		}
+	}
+	t.Fatal("no csrf cookie was set")
+	return ""
+}
+
+func post(app *fiber.App, cookie, header, origin string) int {
+	req := httptest.NewRequest(http.MethodPost, "http://example.com/", nil)
+	if cookie != "" {
+		req.AddCookie(&http.Cookie{Name: csrf.CookieName, Value: cookie})
+	}
+	if header != "" {
+		req.Header.Set(csrf.HeaderName, header)
+	}
+	if origin != "" {
+		req.Header.Set(fiber.HeaderOrigin, origin)
+	}
+
+	resp, err := app.Test(req)
+	if err != nil {
+		return 0
+	}
+	return resp.StatusCode
+}
+
+func TestCSRF(t *testing.T) {
+	assert := assert.New(t)
+	app := newApp(t, "a test secret that is at least 32 bytes")
+	token := getToken(t, app)
+
+	assert.Equal(fiber.StatusOK, post(app, token, token, ""))
+	assert.Equal(fiber.StatusOK, post(app, token, token, "http://example.com"))
+
+	// the cookie alone is what a cross-site form would send
+	assert.Equal(fiber.StatusForbidden, post(app, token, "", ""))
+	assert.Equal(fiber.StatusForbidden, post(app, "", token, ""))
+	assert.Equal(fiber.StatusForbidden, post(app, token, token, "https://evil.example"))
+
+	// a made up token that matches its cookie isn't signed
+	assert.Equal(fiber.StatusForbidden, post(app, "forged", "forged", ""))
+
+	// tokens signed with another key don't count either
+	other := getToken(t, newApp(t, "another secret that is at least 32 bytes"))
+	assert.Equal(fiber.StatusForbidden, post(app, other, other, ""))
+}
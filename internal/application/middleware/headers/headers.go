@@ -0,0 +1,127 @@
+package headers
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Policy is a Content-Security-Policy as its directives and their sources
+type Policy map[string][]string
+
+// DefaultPolicy allows what the pages load: htmx and its SSE extension from unpkg, the Kanit
+// font from Google Fonts and the PDF preview, which is framed from this origin. The inline
+// <script> blocks in the templates and htmx's hx-on handlers need 'unsafe-inline' and
+// 'unsafe-eval' until they move to static files, the rest of the policy still stops other
+// hosts' scripts, plugins, <base> tags, forms posting elsewhere and framing by other sites
+var DefaultPolicy = Policy{
+	"default-src":     {"'self'"},
+	"script-src":      {"'self'", "'unsafe-inline'", "'unsafe-eval'", "https://unpkg.com"},
+	"style-src":       {"'self'", "'unsafe-inline'", "https://fonts.googleapis.com"},
+	"font-src":        {"'self'", "https://fonts.gstatic.com"},
+	"img-src":         {"'self'", "data:"},
+	"connect-src":     {"'self'"},
+	"frame-src":       {"'self'"},
+	"frame-ancestors": {"'self'"},
+	"object-src":      {"'none'"},
+	"base-uri":        {"'self'"},
+	"form-action":     {"'self'"},
+}
+
+// With returns a copy of the policy with the sources added to the directive
+func (p Policy) With(directive string, sources ...string) Policy {
+	rv := Policy{}
+	for d, s := range p {
+		rv[d] = slices.Clone(s)
+	}
+	for _, s := range sources {
+		if !slices.Contains(rv[directive], s) {
+			rv[directive] = append(rv[directive], s)
+		}
+	}
+	return rv
+}
+
+// String formats the policy for the header, directives in alphabetical order
+func (p Policy) String() string {
+	directives := make([]string, 0, len(p))
+	for d := range p {
+		directives = append(directives, d)
+	}
+	sort.Strings(directives)
+
+	parts := make([]string, 0, len(directives))
+	for _, d := range directives {
+		parts = append(parts, strings.TrimSpace(d+" "+strings.Join(p[d], " ")))
+	}
+	return strings.Join(parts, "; ")
+}
+
+type Config struct {
+	Policy Policy
+	// reports violations to the console without blocking anything, for trying out a stricter policy
+	ReportOnly bool
+	// how long browsers should only use https, 0 leaves HSTS off. Only sent over https
+	HSTSMaxAge int
+	// what the Referer header carries to other sites, unsubscribe links have tokens in their paths
+	ReferrerPolicy string
+}
+
+var DefaultConfig = Config{
+	Policy:         DefaultPolicy,
+	HSTSMaxAge:     365 * 24 * 60 * 60,
+	ReferrerPolicy: "same-origin",
+}
+
+// FromEnv is the default config with HSTS_MAX_AGE (seconds) and CSP_REPORT_ONLY from the environment
+func FromEnv() Config {
+	cfg := DefaultConfig
+
+	if v := os.Getenv("HSTS_MAX_AGE"); v != "" {
+		maxAge, err := strconv.Atoi(v)
+		if err != nil || maxAge < 0 {
+			log.Printf("ignoring HSTS_MAX_AGE %q, it should be a number of seconds", v)
+		} else {
+			cfg.HSTSMaxAge = maxAge
+		}
+	}
+
+	cfg.ReportOnly = os.Getenv("CSP_REPORT_ONLY") == "true"
+
+	return cfg
+}
+
+// New sets the security headers on every response. The CSP is only sent with HTML, it's
+// the pages that run scripts, and a policy on the PDF preview can stop the browser's viewer
+func New(cfg Config) fiber.Handler {
+	policy := cfg.Policy.String()
+	policyHeader := fiber.HeaderContentSecurityPolicy
+	if cfg.ReportOnly {
+		policyHeader = fiber.HeaderContentSecurityPolicyReportOnly
+	}
+
+	return func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+		c.Set(fiber.HeaderXFrameOptions, "SAMEORIGIN")
+		c.Set(fiber.HeaderReferrerPolicy, cfg.ReferrerPolicy)
+		c.Set("Cross-Origin-Opener-Policy", "same-origin")
+
+		if cfg.HSTSMaxAge > 0 && c.Protocol() == "https" {
+			c.Set(fiber.HeaderStrictTransportSecurity, fmt.Sprintf("max-age=%d; includeSubDomains", cfg.HSTSMaxAge))
+		}
+
+		err := c.Next()
+
+		if strings.HasPrefix(string(c.Response().Header.ContentType()), fiber.MIMETextHTML) {
+			c.Set(policyHeader, policy)
+		}
+
+		return err
+	}
+}
@@ -0,0 +1,59 @@
+package headers_test
+
+import (
+	"filmPackager/internal/application/middleware/headers"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicy(t *testing.T) {
+	assert := assert.New(t)
+
+	p := headers.Policy{"script-src": {"'self'"}, "default-src": {"'none'"}, "upgrade-insecure-requests": nil}
+	assert.Equal("default-src 'none'; script-src 'self'; upgrade-insecure-requests", p.String())
+
+	// With copies the policy rather than changing it
+	withS3 := p.With("connect-src", "https://bucket.s3.amazonaws.com").With("script-src", "'self'")
+	assert.Equal([]string{"https://bucket.s3.amazonaws.com"}, withS3["connect-src"])
+	assert.Equal([]string{"'self'"}, withS3["script-src"])
+	assert.NotContains(p, "connect-src")
+}
+
+func TestNew(t *testing.T) {
+	assert := assert.New(t)
+
+	app := fiber.New()
+	app.Use(headers.New(headers.DefaultConfig))
+	app.Get("/page", func(c *fiber.Ctx) error {
+		c.Type("html")
+		return c.SendString("<p>hi</p>")
+	})
+	app.Get("/doc.pdf", func(c *fiber.Ctx) error {
+		c.Type("pdf")
+		return c.SendString("%PDF")
+	})
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/page", nil))
+	assert.NoError(err)
+	assert.Equal("nosniff", resp.Header.Get("X-Content-Type-Options"))
+	assert.Equal("SAMEORIGIN", resp.Header.Get("X-Frame-Options"))
+	assert.Equal("same-origin", resp.Header.Get("Referrer-Policy"))
+	assert.Contains(resp.Header.Get("Content-Security-Policy"), "frame-ancestors 'self'")
+	// plain http doesn't get HSTS, browsers ignore it there anyway
+	assert.Empty(resp.Header.Get("Strict-Transport-Security"))
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/doc.pdf", nil))
+	assert.NoError(err)
+	assert.Equal("nosniff", resp.Header.Get("X-Content-Type-Options"))
+	assert.Empty(resp.Header.Get("Content-Security-Policy"))
+
+	req := httptest.NewRequest(http.MethodGet, "/page", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	resp, err = app.Test(req)
+	assert.NoError(err)
+	assert.Equal("max-age=31536000; includeSubDomains", resp.Header.Get("Strict-Transport-Security"))
+}
@@ -0,0 +1,230 @@
+package notificationservice
+
+import (
+	"context"
+	"filmPackager/internal/domain/document"
+	"filmPackager/internal/domain/notification"
+	"filmPackager/internal/domain/project"
+	"filmPackager/internal/domain/user"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// how many notifications are listed, and emailed per run of the worker
+const (
+	listLimit  = 50
+	emailBatch = 100
+)
+
+// Sender delivers a plain text email, see email.SendEmail
+type Sender func(to, subject, body string) error
+
+type NotificationService struct {
+	notificationRepo notification.NotificationRepository
+	userRepo         user.UserRepository
+	projRepo         project.ProjectRepository
+	docRepo          document.DocumentRepository
+	send             Sender
+	// used to link back to the app from the email
+	baseURL string
+}
+
+func NewNotificationService(notificationRepo notification.NotificationRepository, userRepo user.UserRepository, projRepo project.ProjectRepository, docRepo document.DocumentRepository, send Sender, baseURL string) *NotificationService {
+	return &NotificationService{
+		notificationRepo: notificationRepo,
+		userRepo:         userRepo,
+		projRepo:         projRepo,
+		docRepo:          docRepo,
+		send:             send,
+		baseURL:          baseURL,
+	}
+}
+
+type NotificationResponse struct {
+	ID          uuid.UUID
+	Kind        string
+	ActorName   string
+	ProjectID   uuid.UUID
+	ProjectName string
+	DocID       uuid.UUID
+	DocType     string
+	Excerpt     string
+	CreatedAt   string
+	Read        bool
+}
+
+type GetNotificationsResponse struct {
+	Notifications []NotificationResponse
+	Unread        int
+}
+
+// Start emails the new notifications every interval until the context is cancelled
+func (s *NotificationService) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			err := s.SendPendingEmails(ctx, now)
+			if err != nil {
+				log.Printf("error sending notification emails: %v", err)
+			}
+		}
+	}
+}
+
+// SendPendingEmails emails each notification that hasn't been emailed yet
+func (s *NotificationService) SendPendingEmails(ctx context.Context, now time.Time) error {
+	pending, err := s.notificationRepo.GetUnemailed(ctx, emailBatch)
+	if err != nil {
+		return fmt.Errorf("error getting notifications: %v", err)
+	}
+
+	for _, n := range pending {
+		err := s.sendEmail(ctx, n)
+		if err != nil {
+			// move on to the rest, the failed one is retried next run
+			log.Printf("error emailing notification %s: %v", n.ID, err)
+			continue
+		}
+
+		err = s.notificationRepo.MarkEmailed(ctx, n.ID, now)
+		if err != nil {
+			log.Printf("error marking notification %s emailed: %v", n.ID, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *NotificationService) sendEmail(ctx context.Context, n notification.Notification) error {
+	u, err := s.userRepo.GetUserById(ctx, n.UserID)
+	if err != nil {
+		return fmt.Errorf("error getting user: %v", err)
+	}
+
+	rv, err := s.newNotificationResponses(ctx, []notification.Notification{n})
+	if err != nil {
+		return err
+	}
+
+	// see notification_utils.go
+	subject, body := buildNotificationEmail(rv[0], s.baseURL)
+
+	return s.send(u.Email, subject, body)
+}
+
+func (s *NotificationService) GetNotifications(ctx context.Context, userID uuid.UUID) (*GetNotificationsResponse, error) {
+	notifications, err := s.notificationRepo.GetUserNotifications(ctx, userID, listLimit)
+	if err != nil {
+		return nil, fmt.Errorf("error getting notifications: %v", err)
+	}
+
+	unread, err := s.notificationRepo.CountUnread(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error counting notifications: %v", err)
+	}
+
+	rv, err := s.newNotificationResponses(ctx, notifications)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GetNotificationsResponse{Notifications: rv, Unread: unread}, nil
+}
+
+func (s *NotificationService) CountUnread(ctx context.Context, userID uuid.UUID) (int, error) {
+	count, err := s.notificationRepo.CountUnread(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("error counting notifications: %v", err)
+	}
+
+	return count, nil
+}
+
+// OpenNotification marks the user's notification read and returns it so they can be taken to the project
+func (s *NotificationService) OpenNotification(ctx context.Context, notificationID, userID uuid.UUID) (*notification.Notification, error) {
+	n, err := s.notificationRepo.GetNotification(ctx, notificationID)
+	if err != nil {
+		return nil, err
+	}
+
+	// other users' notifications don't exist as far as this user is concerned
+	if n.UserID != userID {
+		return nil, notification.ErrNotificationNotFound
+	}
+
+	err = s.notificationRepo.MarkRead(ctx, notificationID, userID, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("error marking notification read: %v", err)
+	}
+
+	return n, nil
+}
+
+func (s *NotificationService) MarkAllRead(ctx context.Context, userID uuid.UUID) (*GetNotificationsResponse, error) {
+	err := s.notificationRepo.MarkAllRead(ctx, userID, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("error marking notifications read: %v", err)
+	}
+
+	return s.GetNotifications(ctx, userID)
+}
+
+// looks up the actors, projects and documents the notifications refer to
+func (s *NotificationService) newNotificationResponses(ctx context.Context, notifications []notification.Notification) ([]NotificationResponse, error) {
+	actorIDs := []uuid.UUID{}
+	projIDs := []uuid.UUID{}
+	for _, n := range notifications {
+		actorIDs = append(actorIDs, n.ActorID)
+		projIDs = append(projIDs, n.ProjectID)
+	}
+
+	actors, err := s.userRepo.GetUsersByIDs(ctx, actorIDs)
+	if err != nil {
+		return nil, fmt.Errorf("error getting users: %v", err)
+	}
+	actorNames := map[uuid.UUID]string{}
+	for _, a := range actors {
+		actorNames[a.Id] = a.Name
+	}
+
+	projects, err := s.projRepo.GetProjectsByMembershipIDs(ctx, projIDs)
+	if err != nil {
+		return nil, fmt.Errorf("error getting projects: %v", err)
+	}
+	projNames := map[uuid.UUID]string{}
+	for _, p := range projects {
+		projNames[p.ID] = p.Name
+	}
+
+	rv := []NotificationResponse{}
+	for _, n := range notifications {
+		docType := "a deleted document"
+		doc, err := s.docRepo.GetDocumentDetails(ctx, n.DocID)
+		if err == nil {
+			docType = doc.FileType
+		}
+
+		rv = append(rv, NotificationResponse{
+			ID:          n.ID,
+			Kind:        n.Kind,
+			ActorName:   actorNames[n.ActorID],
+			ProjectID:   n.ProjectID,
+			ProjectName: projNames[n.ProjectID],
+			DocID:       n.DocID,
+			DocType:     docType,
+			Excerpt:     n.Excerpt,
+			CreatedAt:   n.CreatedAt.Format("01-02-2006 15:04"),
+			Read:        n.IsRead(),
+		})
+	}
+
+	return rv, nil
+}
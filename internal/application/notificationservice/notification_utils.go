@@ -0,0 +1,18 @@
+package notificationservice
+
+import (
+	"fmt"
+	"strings"
+)
+
+func buildNotificationEmail(n NotificationResponse, baseURL string) (string, string) {
+	subject := fmt.Sprintf("%s mentioned you on %s", n.ActorName, n.ProjectName)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s mentioned you in a comment on the %s of %s:\r\n\r\n", n.ActorName, n.DocType, n.ProjectName)
+	fmt.Fprintf(&b, "  %s\r\n\r\n", n.Excerpt)
+	// the project pages are loaded into the app, so link to the app itself
+	fmt.Fprintf(&b, "Sign in to reply: %s/\r\n", baseURL)
+
+	return subject, b.String()
+}
@@ -2,11 +2,14 @@ package projectservice
 
 import (
 	"context"
+	"filmPackager/internal/application/eventbroker"
 	"filmPackager/internal/domain/comment"
 	"filmPackager/internal/domain/document"
 	"filmPackager/internal/domain/membership"
 	"filmPackager/internal/domain/project"
+	"filmPackager/internal/domain/twofactor"
 	"filmPackager/internal/domain/user"
+	"slices"
 	"time"
 
 	"fmt"
@@ -15,22 +18,30 @@ import (
 )
 
 type ProjectService struct {
-	projRepo    project.ProjectRepository
-	docRepo     document.DocumentRepository
-	s3Repo      document.S3Repository
-	userRepo    user.UserRepository
-	memberRepo  membership.MembershipRepository
-	commentRepo comment.CommentRepository
+	projRepo      project.ProjectRepository
+	docRepo       document.DocumentRepository
+	s3Repo        document.S3Repository
+	blobRepo      document.BlobRepository
+	userRepo      user.UserRepository
+	memberRepo    membership.MembershipRepository
+	commentRepo   comment.CommentRepository
+	twoFactorRepo twofactor.TwoFactorRepository
+	broker        *eventbroker.Broker
+	webhooks      eventbroker.Recorder
 }
 
-func NewProjectService(projRepo project.ProjectRepository, docRepo document.DocumentRepository, s3Repo document.S3Repository, userRepo user.UserRepository, memberRepo membership.MembershipRepository, commentRepo comment.CommentRepository) *ProjectService {
+func NewProjectService(projRepo project.ProjectRepository, docRepo document.DocumentRepository, s3Repo document.S3Repository, blobRepo document.BlobRepository, userRepo user.UserRepository, memberRepo membership.MembershipRepository, commentRepo comment.CommentRepository, twoFactorRepo twofactor.TwoFactorRepository, broker *eventbroker.Broker, webhooks eventbroker.Recorder) *ProjectService {
 	return &ProjectService{
-		projRepo:    projRepo,
-		docRepo:     docRepo,
-		s3Repo:      s3Repo,
-		userRepo:    userRepo,
-		memberRepo:  memberRepo,
-		commentRepo: commentRepo,
+		projRepo:      projRepo,
+		docRepo:       docRepo,
+		s3Repo:        s3Repo,
+		blobRepo:      blobRepo,
+		userRepo:      userRepo,
+		memberRepo:    memberRepo,
+		commentRepo:   commentRepo,
+		twoFactorRepo: twoFactorRepo,
+		broker:        broker,
+		webhooks:      webhooks,
 	}
 }
 
@@ -46,11 +57,15 @@ type GetProjectDetailsResponse struct {
 	UploadStatus bool
 	HasLocked    bool
 	HasStaged    bool
+	IsOwner      bool
+	// whether the owner has two-factor authentication on, they need it to require it of the members
+	HasTwoFactor bool
 }
 
 type DocOverview struct {
-	ID   uuid.UUID
-	Date string
+	ID         uuid.UUID
+	Date       string
+	ScanStatus string
 }
 
 type ProjectOverview struct {
@@ -185,27 +200,54 @@ func (s *ProjectService) DeleteProject(ctx context.Context, projectId uuid.UUID,
 		return nil, fmt.Errorf("error getting project documents from db: %v", err)
 	}
 
-	// put all project IDs in a slice
+	// put all the file and thumbnail keys in a slice, documents with the same content share them
 	keys := []string{}
 	for _, d := range docs {
-		k := fmt.Sprintf("%s=%s", d.FileName, d.ID)
-		keys = append(keys, k)
+		if !slices.Contains(keys, d.Key()) {
+			keys = append(keys, d.Key(), d.ThumbnailKey())
+		}
+	}
+
+	// delete all the comments for the docs, including the ones left on earlier versions
+	versions, err := s.docRepo.GetProjectVersions(ctx, projectId)
+	if err != nil {
+		return nil, fmt.Errorf("error getting document versions from db: %v", err)
 	}
 
-	// delete all the comments for the docs
+	docIDs := []uuid.UUID{}
 	for _, d := range docs {
-		err = s.commentRepo.DeleteDocComments(ctx, d.ID)
+		docIDs = append(docIDs, d.ID)
+	}
+	for _, v := range versions {
+		if !slices.Contains(docIDs, v.DocID) {
+			docIDs = append(docIDs, v.DocID)
+		}
+	}
+
+	for _, id := range docIDs {
+		err = s.commentRepo.DeleteDocComments(ctx, id)
 		if err != nil {
 			return nil, fmt.Errorf("error deleting comments from db: %v", err)
 		}
 	}
 
+	err = s.docRepo.DeleteProjectVersions(ctx, projectId)
+	if err != nil {
+		return nil, fmt.Errorf("error deleting document versions from db: %v", err)
+	}
+
 	// delete all the project files from s3
 	err = s.s3Repo.DeleteAllOrgFiles(ctx, keys)
 	if err != nil {
 		return nil, fmt.Errorf("error deleting project files from s3: %v", err)
 	}
 
+	// the files are gone, so nothing is left to count
+	err = s.blobRepo.DeleteProjectBlobs(ctx, projectId)
+	if err != nil {
+		return nil, fmt.Errorf("error deleting project blobs from db: %v", err)
+	}
+
 	// delete the project from the db
 	err = s.projRepo.DeleteProject(ctx, projectId)
 	if err != nil {
@@ -252,6 +294,12 @@ func (s *ProjectService) GetProjectDetails(ctx context.Context, projectId uuid.U
 		return nil, fmt.Errorf("error getting project from db: %v", err)
 	}
 
+	// members of a project that requires two-factor authentication can't open it without it on
+	err = s.checkTwoFactor(ctx, p, userID)
+	if err != nil {
+		return nil, err
+	}
+
 	// assign the project to the response
 	rv.Project = p
 
@@ -292,6 +340,13 @@ func (s *ProjectService) GetProjectDetails(ctx context.Context, projectId uuid.U
 	// see project_utils.go for the sortMembers function
 	rv.sortMembersByPendingAccepted(members, users, userID)
 
+	if rv.IsOwner {
+		rv.HasTwoFactor, err = s.hasTwoFactor(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return rv, nil
 }
 
@@ -329,6 +384,8 @@ func (s *ProjectService) JoinProject(ctx context.Context, projectId uuid.UUID, u
 		return fmt.Errorf("error joining project: %v", err)
 	}
 
+	eventbroker.Emit(ctx, s.webhooks, s.broker, eventbroker.NewEvent(eventbroker.MemberJoined, projectId, userId))
+
 	return nil
 }
 
@@ -353,3 +410,37 @@ func (s *ProjectService) UpdateProjectName(ctx context.Context, projectId uuid.U
 
 	return updatedP, nil
 }
+
+// SetRequireTwoFactor turns the two-factor requirement for the project's members on or off, the owner
+// has to have two-factor authentication on themselves to require it so they can't lock themselves out
+func (s *ProjectService) SetRequireTwoFactor(ctx context.Context, projectId, userID uuid.UUID, require bool) (*project.Project, error) {
+	m, err := s.memberRepo.GetMembership(ctx, projectId, userID)
+	if err != nil || !m.IsOwner() {
+		return nil, project.ErrNotOwner
+	}
+
+	p, err := s.projRepo.GetProjectByID(ctx, projectId)
+	if err != nil {
+		return nil, fmt.Errorf("error getting project: %v", err)
+	}
+
+	if require {
+		enabled, err := s.hasTwoFactor(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !enabled {
+			return nil, project.ErrOwnerNeedsTwoFactor
+		}
+	}
+
+	p.RequireTwoFactor = require
+	p.LastUpdateAt = time.Now()
+
+	err = s.projRepo.UpdateProject(ctx, p)
+	if err != nil {
+		return nil, fmt.Errorf("error updating project: %v", err)
+	}
+
+	return p, nil
+}
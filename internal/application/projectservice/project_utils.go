@@ -1,10 +1,14 @@
 package projectservice
 
 import (
+	"context"
+	"errors"
 	"filmPackager/internal/domain/document"
 	"filmPackager/internal/domain/membership"
 	"filmPackager/internal/domain/project"
+	"filmPackager/internal/domain/twofactor"
 	"filmPackager/internal/domain/user"
+	"fmt"
 
 	"github.com/google/uuid"
 )
@@ -18,15 +22,16 @@ func (rv *GetProjectDetailsResponse) sortStagedLockedDocs(documents []*document.
 	for _, d := range documents {
 		// format the document date
 		dOverview := &DocOverview{
-			ID:   d.ID,
-			Date: d.Date.Format("01-02-2006"),
+			ID:         d.ID,
+			Date:       d.Date.Format("01-02-2006"),
+			ScanStatus: d.ScanStatus,
 		}
 		if d.Status == "staged" {
 			// set the bool for staged if there is one
 			rv.HasStaged = true
 			// assign the document to the map based on the fileType
 			stagedMap[d.FileType] = *dOverview
-		} else {
+		} else if d.Status == "locked" {
 			// set the bool for locked if there is one
 			rv.HasLocked = true
 			// assign the document to the map based on the fileType
@@ -65,6 +70,11 @@ func (rv *GetProjectDetailsResponse) sortMembersByPendingAccepted(members []memb
 			rv.UploadStatus = true
 		}
 
+		// only owners can manage the project's webhooks
+		if m.IsOwner() && m.UserID == userID {
+			rv.IsOwner = true
+		}
+
 		// sort the members based on invite status
 		if m.InviteStatus == "pending" {
 			rv.Invited = append(rv.Invited, m)
@@ -97,3 +107,30 @@ func (rv *GetUsersProjectsResponse) sortProjectsByPendingAccepted(projects []pro
 		}
 	}
 }
+
+// whether the user has two-factor authentication on, setting it up doesn't count until it's enabled
+func (s *ProjectService) hasTwoFactor(ctx context.Context, userID uuid.UUID) (bool, error) {
+	tf, err := s.twoFactorRepo.GetTwoFactor(ctx, userID)
+	if err != nil && !errors.Is(err, twofactor.ErrNotEnrolled) {
+		return false, fmt.Errorf("error getting two-factor settings: %v", err)
+	}
+
+	return tf.IsEnabled(), nil
+}
+
+// returns project.ErrTwoFactorRequired if the project requires two-factor authentication and the user hasn't turned it on
+func (s *ProjectService) checkTwoFactor(ctx context.Context, p *project.Project, userID uuid.UUID) error {
+	if !p.RequireTwoFactor {
+		return nil
+	}
+
+	enabled, err := s.hasTwoFactor(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return project.ErrTwoFactorRequired
+	}
+
+	return nil
+}
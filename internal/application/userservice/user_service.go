@@ -4,20 +4,23 @@ import (
 	"context"
 
 	"filmPackager/internal/domain/project"
+	"filmPackager/internal/domain/session"
 	"filmPackager/internal/domain/user"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type UserService struct {
-	userRepo user.UserRepository
-	projRepo project.ProjectRepository
+	userRepo    user.UserRepository
+	projRepo    project.ProjectRepository
+	sessionRepo session.SessionRepository
 }
 
-func NewUserService(userRepo user.UserRepository, projRepo project.ProjectRepository) *UserService {
-	return &UserService{userRepo: userRepo, projRepo: projRepo}
+func NewUserService(userRepo user.UserRepository, projRepo project.ProjectRepository, sessionRepo session.SessionRepository) *UserService {
+	return &UserService{userRepo: userRepo, projRepo: projRepo, sessionRepo: sessionRepo}
 }
 
 func (s *UserService) VerifyOldPassword(ctx context.Context, userID uuid.UUID, pw1, pw2 string) error {
@@ -40,7 +43,8 @@ func (s *UserService) VerifyOldPassword(ctx context.Context, userID uuid.UUID, p
 	return nil
 }
 
-func (s *UserService) SetNewPassword(ctx context.Context, userID uuid.UUID, pw1, pw2 string) error {
+// SetNewPassword changes the password and logs out every other session, keeping the one it was changed from
+func (s *UserService) SetNewPassword(ctx context.Context, userID, keepSessionID uuid.UUID, pw1, pw2 string) error {
 	// see user_utils.go
 	err := verifyFirstAndSecondPasswords(pw1, pw2)
 	if err != nil {
@@ -66,5 +70,10 @@ func (s *UserService) SetNewPassword(ctx context.Context, userID uuid.UUID, pw1,
 		return fmt.Errorf("error setting new password: %v", err)
 	}
 
+	err = s.sessionRepo.RevokeUserSessions(ctx, userID, keepSessionID, time.Now())
+	if err != nil {
+		return fmt.Errorf("error logging out other sessions: %v", err)
+	}
+
 	return nil
 }
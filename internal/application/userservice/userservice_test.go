@@ -5,8 +5,10 @@ import (
 	"errors"
 	"filmPackager/internal/application/userservice"
 	"filmPackager/internal/domain/project"
+	"filmPackager/internal/domain/session"
 	"filmPackager/internal/domain/user"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
@@ -138,6 +140,46 @@ func (m *MockProjectRepository) UpdateMemberRoles(ctx context.Context, pID uuid.
 	return args.Error(0)
 }
 
+type MockSessionRepository struct {
+	mock.Mock
+}
+
+func (m *MockSessionRepository) CreateSession(ctx context.Context, s *session.Session) error {
+	args := m.Called(ctx, s)
+	return args.Error(0)
+}
+
+func (m *MockSessionRepository) GetSession(ctx context.Context, id uuid.UUID) (*session.Session, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*session.Session), args.Error(1)
+}
+
+func (m *MockSessionRepository) TouchSession(ctx context.Context, id uuid.UUID, ip string, at time.Time) error {
+	args := m.Called(ctx, id, ip, at)
+	return args.Error(0)
+}
+
+func (m *MockSessionRepository) GetActiveSessions(ctx context.Context, userID uuid.UUID, now time.Time) ([]session.Session, error) {
+	args := m.Called(ctx, userID, now)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]session.Session), args.Error(1)
+}
+
+func (m *MockSessionRepository) RevokeSession(ctx context.Context, userID, id uuid.UUID, at time.Time) error {
+	args := m.Called(ctx, userID, id, at)
+	return args.Error(0)
+}
+
+func (m *MockSessionRepository) RevokeUserSessions(ctx context.Context, userID, keep uuid.UUID, at time.Time) error {
+	args := m.Called(ctx, userID, keep, at)
+	return args.Error(0)
+}
+
 // Helper function to create a test user with a password
 func createTestUserWithPassword(password string) *user.User {
 	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -149,8 +191,9 @@ func createTestUserWithPassword(password string) *user.User {
 func TestVerifyOldPassword(t *testing.T) {
 	mockUserRepo := new(MockUserRepository)
 	mockProjRepo := new(MockProjectRepository)
+	mockSessionRepo := new(MockSessionRepository)
 
-	service := userservice.NewUserService(mockUserRepo, mockProjRepo)
+	service := userservice.NewUserService(mockUserRepo, mockProjRepo, mockSessionRepo)
 
 	t.Run("passwords don't match", func(t *testing.T) {
 		userID := uuid.New()
@@ -206,13 +249,14 @@ func TestVerifyOldPassword(t *testing.T) {
 func TestSetNewPassword(t *testing.T) {
 	mockUserRepo := new(MockUserRepository)
 	mockProjRepo := new(MockProjectRepository)
+	mockSessionRepo := new(MockSessionRepository)
 
-	service := userservice.NewUserService(mockUserRepo, mockProjRepo)
+	service := userservice.NewUserService(mockUserRepo, mockProjRepo, mockSessionRepo)
 
 	t.Run("passwords don't match", func(t *testing.T) {
 		userID := uuid.New()
 
-		err := service.SetNewPassword(context.Background(), userID, "newpassword1", "newpassword2")
+		err := service.SetNewPassword(context.Background(), userID, uuid.Nil, "newpassword1", "newpassword2")
 
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "passwords do not match")
@@ -226,7 +270,7 @@ func TestSetNewPassword(t *testing.T) {
 		mockUserRepo.On("GetUserById", mock.Anything, userID).
 			Return(nil, errors.New("user not found")).Once()
 
-		err := service.SetNewPassword(context.Background(), userID, "newpassword", "newpassword")
+		err := service.SetNewPassword(context.Background(), userID, uuid.Nil, "newpassword", "newpassword")
 
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "error getting user")
@@ -247,7 +291,7 @@ func TestSetNewPassword(t *testing.T) {
 			return u.Id == testUser.Id
 		})).Return(errors.New("database error")).Once()
 
-		err := service.SetNewPassword(context.Background(), testUser.Id, "newpassword", "newpassword")
+		err := service.SetNewPassword(context.Background(), testUser.Id, uuid.Nil, "newpassword", "newpassword")
 
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "error setting new password")
@@ -266,9 +310,14 @@ func TestSetNewPassword(t *testing.T) {
 			return u.Id == testUser.Id && err == nil
 		})).Return(nil).Once()
 
-		err := service.SetNewPassword(context.Background(), userID, "newpassword", "newpassword")
+		// every other session is logged out, the current one stays
+		currentID := uuid.New()
+		mockSessionRepo.On("RevokeUserSessions", mock.Anything, userID, currentID, mock.Anything).Return(nil).Once()
+
+		err := service.SetNewPassword(context.Background(), userID, currentID, "newpassword", "newpassword")
 
 		assert.NoError(t, err)
 		mockUserRepo.AssertExpectations(t)
+		mockSessionRepo.AssertExpectations(t)
 	})
 }
@@ -0,0 +1,328 @@
+package webhookservice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"filmPackager/internal/application/eventbroker"
+	"filmPackager/internal/domain/membership"
+	"filmPackager/internal/domain/webhook"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// the events a webhook can subscribe to
+var EventTypes = []string{
+	eventbroker.DocumentUploaded,
+	eventbroker.DocumentDeleted,
+	eventbroker.DocumentsLocked,
+	eventbroker.CommentCreated,
+	eventbroker.CommentDeleted,
+	eventbroker.CommentResolved,
+	eventbroker.CommentReopened,
+	eventbroker.CommentEdited,
+	eventbroker.MemberInvited,
+	eventbroker.MemberJoined,
+	eventbroker.MemberRolesUpdated,
+}
+
+const (
+	// how many due deliveries the worker sends per tick
+	deliveryBatchSize = 50
+	// how many past deliveries are shown in the log
+	deliveryLogSize = 50
+)
+
+type WebhookService struct {
+	webhookRepo webhook.WebhookRepository
+	memberRepo  membership.MembershipRepository
+	client      *http.Client
+}
+
+func NewWebhookService(webhookRepo webhook.WebhookRepository, memberRepo membership.MembershipRepository, client *http.Client) *WebhookService {
+	return &WebhookService{webhookRepo: webhookRepo, memberRepo: memberRepo, client: client}
+}
+
+type GetProjectWebhooksResponse struct {
+	ProjectID  uuid.UUID
+	Webhooks   []webhook.Webhook
+	EventTypes []string
+	// only set right after a webhook is created so the secret can be copied
+	NewWebhook *webhook.Webhook
+}
+
+type GetWebhookDeliveriesResponse struct {
+	Webhook    *webhook.Webhook
+	Deliveries []webhook.Delivery
+}
+
+// Payload is the JSON body sent to the webhook URL
+type Payload struct {
+	DeliveryID uuid.UUID  `json:"delivery_id"`
+	Event      string     `json:"event"`
+	ProjectID  uuid.UUID  `json:"project_id"`
+	ActorID    uuid.UUID  `json:"actor_id"`
+	DocumentID *uuid.UUID `json:"document_id,omitempty"`
+	OccurredAt time.Time  `json:"occurred_at"`
+}
+
+// StartWorker sends the due deliveries every interval until the context is cancelled
+func (s *WebhookService) StartWorker(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			err := s.SendDueDeliveries(ctx, now)
+			if err != nil {
+				log.Printf("error sending webhook deliveries: %v", err)
+			}
+		}
+	}
+}
+
+// RecordEvent queues a delivery for every webhook of the project subscribed to the event. The services
+// call it as the event happens, see eventbroker.Emit, so the delivery is saved before the worker sends it
+func (s *WebhookService) RecordEvent(ctx context.Context, e eventbroker.Event) error {
+	hooks, err := s.webhookRepo.GetProjectWebhooks(ctx, e.ProjectID)
+	if err != nil {
+		return fmt.Errorf("error getting project webhooks: %v", err)
+	}
+
+	for _, w := range hooks {
+		if !w.Subscribes(e.Kind) {
+			continue
+		}
+
+		d := webhook.NewDelivery(w.ID, e.Kind, nil)
+
+		p := Payload{
+			DeliveryID: d.ID,
+			Event:      e.Kind,
+			ProjectID:  e.ProjectID,
+			ActorID:    e.ActorID,
+			OccurredAt: e.CreatedAt,
+		}
+		if e.DocID != uuid.Nil {
+			p.DocumentID = &e.DocID
+		}
+
+		d.Payload, err = json.Marshal(p)
+		if err != nil {
+			return fmt.Errorf("error encoding payload: %v", err)
+		}
+
+		err = s.webhookRepo.CreateDelivery(ctx, d)
+		if err != nil {
+			return fmt.Errorf("error creating delivery: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *WebhookService) SendDueDeliveries(ctx context.Context, now time.Time) error {
+	deliveries, err := s.webhookRepo.ClaimDueDeliveries(ctx, now, deliveryBatchSize)
+	if err != nil {
+		return fmt.Errorf("error claiming due deliveries: %v", err)
+	}
+
+	for _, d := range deliveries {
+		err := s.deliver(ctx, &d)
+		if err != nil {
+			log.Printf("error delivering webhook %s: %v", d.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// deliver makes one attempt and records the outcome on the delivery
+func (s *WebhookService) deliver(ctx context.Context, d *webhook.Delivery) error {
+	w, err := s.webhookRepo.GetWebhook(ctx, d.WebhookID)
+	if err != nil {
+		return fmt.Errorf("error getting webhook: %v", err)
+	}
+
+	code, err := s.post(ctx, w, d)
+	now := time.Now()
+	switch {
+	case err != nil:
+		d.RecordFailure(now, 0, err.Error())
+	case code < 200 || code >= 300:
+		d.RecordFailure(now, code, fmt.Sprintf("unexpected status %d", code))
+	default:
+		d.RecordSuccess(now, code)
+	}
+
+	err = s.webhookRepo.UpdateDelivery(ctx, d)
+	if err != nil {
+		return fmt.Errorf("error updating delivery: %v", err)
+	}
+
+	return nil
+}
+
+func (s *WebhookService) post(ctx context.Context, w *webhook.Webhook, d *webhook.Delivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Film-Packager-Webhooks")
+	req.Header.Set("X-FilmPackager-Event", d.EventType)
+	req.Header.Set("X-FilmPackager-Delivery", d.ID.String())
+	req.Header.Set("X-FilmPackager-Signature", webhook.Sign(w.Secret, d.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// drain the body so the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	return resp.StatusCode, nil
+}
+
+func (s *WebhookService) GetProjectWebhooks(ctx context.Context, userID, projectID uuid.UUID) (*GetProjectWebhooksResponse, error) {
+	err := s.checkOwner(ctx, projectID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	hooks, err := s.webhookRepo.GetProjectWebhooks(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting project webhooks: %v", err)
+	}
+
+	return &GetProjectWebhooksResponse{ProjectID: projectID, Webhooks: hooks, EventTypes: EventTypes}, nil
+}
+
+func (s *WebhookService) CreateWebhook(ctx context.Context, userID, projectID uuid.UUID, url, secret string, eventTypes []string) (*GetProjectWebhooksResponse, error) {
+	err := s.checkOwner(ctx, projectID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// see webhook_utils.go
+	err = validateWebhook(ctx, url, eventTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	w := webhook.NewWebhook(projectID, userID, url, secret, eventTypes)
+
+	err = s.webhookRepo.CreateWebhook(ctx, w)
+	if err != nil {
+		return nil, fmt.Errorf("error creating webhook: %v", err)
+	}
+
+	rv, err := s.GetProjectWebhooks(ctx, userID, projectID)
+	if err != nil {
+		return nil, err
+	}
+	rv.NewWebhook = w
+
+	return rv, nil
+}
+
+func (s *WebhookService) DeleteWebhook(ctx context.Context, userID, webhookID uuid.UUID) (*GetProjectWebhooksResponse, error) {
+	w, err := s.webhookRepo.GetWebhook(ctx, webhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.checkOwner(ctx, w.ProjectID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.webhookRepo.DeleteWebhook(ctx, webhookID)
+	if err != nil {
+		return nil, fmt.Errorf("error deleting webhook: %v", err)
+	}
+
+	return s.GetProjectWebhooks(ctx, userID, w.ProjectID)
+}
+
+func (s *WebhookService) GetWebhookDeliveries(ctx context.Context, userID, webhookID uuid.UUID) (*GetWebhookDeliveriesResponse, error) {
+	w, err := s.webhookRepo.GetWebhook(ctx, webhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.checkOwner(ctx, w.ProjectID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries, err := s.webhookRepo.GetWebhookDeliveries(ctx, webhookID, deliveryLogSize)
+	if err != nil {
+		return nil, fmt.Errorf("error getting webhook deliveries: %v", err)
+	}
+
+	return &GetWebhookDeliveriesResponse{Webhook: w, Deliveries: deliveries}, nil
+}
+
+// Redeliver queues a new delivery with the same payload, leaving the original in the log
+func (s *WebhookService) Redeliver(ctx context.Context, userID, deliveryID uuid.UUID) (*GetWebhookDeliveriesResponse, error) {
+	d, err := s.webhookRepo.GetDelivery(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := s.webhookRepo.GetWebhook(ctx, d.WebhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.checkOwner(ctx, w.ProjectID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// the payload is the event again under the new delivery's ID, so the body matches its header
+	var p Payload
+	err = json.Unmarshal(d.Payload, &p)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding payload: %v", err)
+	}
+
+	nd := webhook.NewDelivery(d.WebhookID, d.EventType, nil)
+	p.DeliveryID = nd.ID
+	nd.Payload, err = json.Marshal(p)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding payload: %v", err)
+	}
+
+	err = s.webhookRepo.CreateDelivery(ctx, nd)
+	if err != nil {
+		return nil, fmt.Errorf("error creating delivery: %v", err)
+	}
+
+	return s.GetWebhookDeliveries(ctx, userID, w.ID)
+}
+
+func (s *WebhookService) checkOwner(ctx context.Context, projectID, userID uuid.UUID) error {
+	m, err := s.memberRepo.GetMembership(ctx, projectID, userID)
+	if err != nil {
+		return webhook.ErrAccessDenied
+	}
+
+	if !m.IsOwner() {
+		return webhook.ErrAccessDenied
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package webhookservice
+
+import (
+	"context"
+	"filmPackager/internal/domain/webhook"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"syscall"
+	"time"
+)
+
+func validateWebhook(ctx context.Context, rawURL string, eventTypes []string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return webhook.ErrInvalidURL
+	}
+
+	// the deliveries are sent from the server, so the URL can't point back at it or its network.
+	// The client checks again when it dials, as the name can resolve differently by then
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil || len(addrs) == 0 {
+		return webhook.ErrInvalidURL
+	}
+	for _, a := range addrs {
+		if blockedIP(a.IP) {
+			return webhook.ErrPrivateAddress
+		}
+	}
+
+	if len(eventTypes) == 0 {
+		return webhook.ErrInvalidEventTypes
+	}
+	for _, t := range eventTypes {
+		if !slices.Contains(EventTypes, t) {
+			return webhook.ErrInvalidEventTypes
+		}
+	}
+
+	return nil
+}
+
+// blockedIP reports whether a webhook may not reach the address: the server itself, the private
+// networks around it and the link-local range the cloud metadata endpoints live on
+func blockedIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified()
+}
+
+// NewClient returns the client the deliveries are sent with. It refuses to connect to a blocked address
+// whatever the URL resolves to at the time, which also covers redirects
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		// the address here is the resolved one about to be connected to
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || blockedIP(ip) {
+				return webhook.ErrPrivateAddress
+			}
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+		},
+	}
+}
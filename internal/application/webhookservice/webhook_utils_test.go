@@ -0,0 +1,22 @@
+package webhookservice_test
+
+import (
+	"filmPackager/internal/application/webhookservice"
+	"filmPackager/internal/domain/webhook"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientRefusesLoopback(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	_, err := webhookservice.NewClient(time.Second).Get(srv.URL)
+	assert.ErrorIs(t, err, webhook.ErrPrivateAddress)
+}
@@ -0,0 +1,38 @@
+package activity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// the kinds of project activity that are recorded
+const (
+	KindUpload  = "upload"
+	KindLock    = "lock"
+	KindComment = "comment"
+	KindInvite  = "invite"
+	KindResolve = "resolve"
+	KindReopen  = "reopen"
+)
+
+type Activity struct {
+	ID        uuid.UUID
+	ProjectID uuid.UUID
+	ActorID   uuid.UUID
+	Kind      string
+	// free text describing the subject of the activity, e.g. the document type or invitee name
+	Detail    string
+	CreatedAt time.Time
+}
+
+func NewActivity(projectID, actorID uuid.UUID, kind, detail string) *Activity {
+	return &Activity{
+		ID:        uuid.New(),
+		ProjectID: projectID,
+		ActorID:   actorID,
+		Kind:      kind,
+		Detail:    detail,
+		CreatedAt: time.Now(),
+	}
+}
@@ -0,0 +1,13 @@
+package activity
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ActivityRepository interface {
+	Record(ctx context.Context, a *Activity) error
+	GetForProjectsSince(ctx context.Context, projectIDs []uuid.UUID, since time.Time) ([]Activity, error)
+}
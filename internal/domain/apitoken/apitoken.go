@@ -0,0 +1,93 @@
+package apitoken
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+)
+
+var Scopes = []string{ScopeRead, ScopeWrite}
+
+// every token starts with the prefix so it can be told apart from a session and found by secret scanners
+const Prefix = "fpk_"
+
+type Token struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+	Name   string
+	// only the sha256 of the token is stored, the token itself is shown once on creation
+	Hash  string
+	Scope string
+	// empty means the token can reach all of the user's projects
+	ProjectIDs []uuid.UUID
+	// nil means the token never expires
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	CreatedAt  time.Time
+}
+
+// NewToken returns the token to store along with the plain token to show the user
+func NewToken(userID uuid.UUID, name, scope string, projectIDs []uuid.UUID, expiresAt *time.Time) (*Token, string) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		panic("error generating api token: " + err.Error())
+	}
+	plain := Prefix + hex.EncodeToString(b)
+
+	return &Token{
+		ID:         uuid.New(),
+		UserID:     userID,
+		Name:       name,
+		Hash:       Hash(plain),
+		Scope:      scope,
+		ProjectIDs: projectIDs,
+		ExpiresAt:  expiresAt,
+		CreatedAt:  time.Now(),
+	}, plain
+}
+
+func Hash(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
+
+func LooksLikeToken(s string) bool {
+	return strings.HasPrefix(s, Prefix)
+}
+
+func IsValidScope(scope string) bool {
+	return slices.Contains(Scopes, scope)
+}
+
+func (t *Token) IsExpired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
+
+// AllowsMethod reports whether the scope covers the HTTP method, read tokens can only make safe requests
+func (t *Token) AllowsMethod(method string) bool {
+	if t.Scope == ScopeWrite {
+		return true
+	}
+	return method == "GET" || method == "HEAD"
+}
+
+func (t *Token) AllowsProject(projectID uuid.UUID) bool {
+	return len(t.ProjectIDs) == 0 || slices.Contains(t.ProjectIDs, projectID)
+}
+
+// AllowsNewProjects reports whether the token can create projects, one limited to some projects
+// couldn't reach the project it created
+func (t *Token) AllowsNewProjects() bool {
+	return len(t.ProjectIDs) == 0
+}
@@ -0,0 +1,64 @@
+package apitoken_test
+
+import (
+	"filmPackager/internal/domain/apitoken"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewToken(t *testing.T) {
+	assert := assert.New(t)
+
+	tok, plain := apitoken.NewToken(uuid.New(), "ci", apitoken.ScopeRead, nil, nil)
+	assert.True(apitoken.LooksLikeToken(plain))
+	assert.Equal(apitoken.Hash(plain), tok.Hash)
+	assert.NotContains(tok.Hash, plain)
+
+	_, other := apitoken.NewToken(uuid.New(), "ci", apitoken.ScopeRead, nil, nil)
+	assert.NotEqual(plain, other)
+}
+
+func TestTokenScope(t *testing.T) {
+	assert := assert.New(t)
+
+	tok, _ := apitoken.NewToken(uuid.New(), "ci", apitoken.ScopeRead, nil, nil)
+	assert.True(tok.AllowsMethod("GET"))
+	assert.False(tok.AllowsMethod("POST"))
+	assert.False(tok.AllowsMethod("DELETE"))
+
+	tok.Scope = apitoken.ScopeWrite
+	assert.True(tok.AllowsMethod("DELETE"))
+}
+
+func TestTokenProjects(t *testing.T) {
+	assert := assert.New(t)
+	p1, p2 := uuid.New(), uuid.New()
+
+	tok, _ := apitoken.NewToken(uuid.New(), "ci", apitoken.ScopeRead, nil, nil)
+	assert.True(tok.AllowsProject(p1))
+	assert.True(tok.AllowsNewProjects())
+
+	tok.ProjectIDs = []uuid.UUID{p1}
+	assert.True(tok.AllowsProject(p1))
+	assert.False(tok.AllowsProject(p2))
+	assert.False(tok.AllowsNewProjects())
+}
+
+func TestTokenIsExpired(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+
+	tok, _ := apitoken.NewToken(uuid.New(), "ci", apitoken.ScopeRead, nil, nil)
+	assert.False(tok.IsExpired(now))
+
+	past := now.Add(-time.Minute)
+	tok.ExpiresAt = &past
+	assert.True(tok.IsExpired(now))
+
+	future := now.Add(time.Hour)
+	tok.ExpiresAt = &future
+	assert.False(tok.IsExpired(now))
+}
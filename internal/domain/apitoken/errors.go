@@ -0,0 +1,11 @@
+package apitoken
+
+import "errors"
+
+var (
+	ErrTokenNotFound  = errors.New("api token not found")
+	ErrTokenExpired   = errors.New("api token has expired")
+	ErrInvalidName    = errors.New("please give the token a name")
+	ErrInvalidScope   = errors.New("scope must be read or write")
+	ErrInvalidProject = errors.New("tokens can only be limited to your own projects")
+)
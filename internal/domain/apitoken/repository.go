@@ -0,0 +1,17 @@
+package apitoken
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type TokenRepository interface {
+	CreateToken(ctx context.Context, t *Token) error
+	GetByHash(ctx context.Context, hash string) (*Token, error)
+	GetUserTokens(ctx context.Context, userID uuid.UUID) ([]Token, error)
+	// only deletes the token if it belongs to the user
+	DeleteToken(ctx context.Context, userID, tokenID uuid.UUID) error
+	UpdateLastUsed(ctx context.Context, tokenID uuid.UUID, at time.Time) error
+}
@@ -0,0 +1,75 @@
+package comment
+
+import "unicode/utf8"
+
+// the most of a text selection kept with an anchor
+const MaxAnchorTextLength = 500
+
+// Rect is a region of a page, as fractions of the page's width and height
+// measured from the top left corner so it holds at any zoom level
+type Rect struct {
+	X      float64
+	Y      float64
+	Width  float64
+	Height float64
+}
+
+// Anchor pins a thread to a page of a PDF, optionally to a region of it or
+// the text that was selected
+type Anchor struct {
+	// pages start at 1
+	Page int
+	Rect *Rect
+	Text string
+}
+
+func NewAnchor(page int, rect *Rect, text string) (*Anchor, error) {
+	a := &Anchor{Page: page, Rect: rect, Text: text}
+
+	err := a.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+func (a *Anchor) Validate() error {
+	if a.Page < 1 {
+		return ErrInvalidAnchorPage
+	}
+	if a.Rect != nil && !a.Rect.isValid() {
+		return ErrInvalidAnchorRect
+	}
+	if utf8.RuneCountInString(a.Text) > MaxAnchorTextLength {
+		return ErrAnchorTextTooLong
+	}
+	return nil
+}
+
+func (r *Rect) isValid() bool {
+	if r.X < 0 || r.Y < 0 || r.Width <= 0 || r.Height <= 0 {
+		return false
+	}
+	return r.X+r.Width <= 1 && r.Y+r.Height <= 1
+}
+
+// AnchorTo pins the thread to the anchor, replies follow the thread they belong to
+func (c *Comment) AnchorTo(a *Anchor) error {
+	if c.IsReply() {
+		return ErrNotAThread
+	}
+
+	err := a.Validate()
+	if err != nil {
+		return err
+	}
+
+	c.Anchor = a
+
+	return nil
+}
+
+func (c *Comment) IsAnchored() bool {
+	return c.Anchor != nil
+}
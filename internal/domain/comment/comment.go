@@ -1,11 +1,25 @@
 package comment
 
 import (
+	"slices"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 )
 
+// the filters for the comment threads on a document
+const (
+	FilterAll      = "all"
+	FilterOpen     = "open"
+	FilterResolved = "resolved"
+)
+
+var Filters = []string{FilterAll, FilterOpen, FilterResolved}
+
+// MaxLength is the most characters the comment column holds
+const MaxLength = 250
+
 type Comment struct {
 	ID       uuid.UUID
 	DocID    uuid.UUID
@@ -13,6 +27,19 @@ type Comment struct {
 	AuthorID uuid.UUID
 	// ADD A CREATED AT FIELD IN THE DB...
 	CreatedAt time.Time
+	// nil for the comment that starts a thread
+	ParentID *uuid.UUID
+	// only the comment that starts a thread can be resolved
+	ResolvedAt *time.Time
+	ResolvedBy *uuid.UUID
+	// nil for comments on the whole document, see anchor.go
+	Anchor *Anchor
+	// nil until the author changes the text, see revision.go
+	EditedAt *time.Time
+	// the members mentioned in the text, see mention.go
+	Mentions []uuid.UUID
+	// the version of the document the thread was written on, when it has been carried forward to a later one
+	CarriedFrom *int
 }
 
 func CreateNewComment(docID, authorID uuid.UUID, comment string) *Comment {
@@ -26,6 +53,103 @@ func CreateNewComment(docID, authorID uuid.UUID, comment string) *Comment {
 	}
 }
 
+// CreateNewReply adds a reply to the parent's thread, replies to replies join the same thread
+func CreateNewReply(parent *Comment, authorID uuid.UUID, comment string) *Comment {
+	c := CreateNewComment(parent.DocID, authorID, comment)
+
+	threadID := parent.ThreadID()
+	c.ParentID = &threadID
+
+	return c
+}
+
+// CheckLength turns away text the comment column can't hold, before it's rendered or saved
+func CheckLength(text string) error {
+	if utf8.RuneCountInString(text) > MaxLength {
+		return ErrCommentTooLong
+	}
+	return nil
+}
+
 func isByCurrUser(authorID, currUserID uuid.UUID) bool {
 	return authorID == currUserID
 }
+
+// only the author can change what they wrote
+func (c *Comment) CanEdit(userID uuid.UUID) bool {
+	return isByCurrUser(c.AuthorID, userID)
+}
+
+// project owners can delete any comment to moderate the discussion
+func (c *Comment) CanDelete(userID uuid.UUID, isProjectOwner bool) bool {
+	return isByCurrUser(c.AuthorID, userID) || isProjectOwner
+}
+
+func (c *Comment) IsEdited() bool {
+	return c.EditedAt != nil
+}
+
+func (c *Comment) IsReply() bool {
+	return c.ParentID != nil
+}
+
+// ThreadID returns the ID of the comment that started the thread
+func (c *Comment) ThreadID() uuid.UUID {
+	if c.IsReply() {
+		return *c.ParentID
+	}
+	return c.ID
+}
+
+func (c *Comment) IsResolved() bool {
+	return c.ResolvedAt != nil
+}
+
+// only open threads move on to the next version of the document, resolved ones stay with the version they settled
+func (c *Comment) CanCarryForward() bool {
+	return !c.IsReply() && !c.IsResolved()
+}
+
+func (c *Comment) Resolve(userID uuid.UUID, now time.Time) error {
+	if c.IsReply() {
+		return ErrNotAThread
+	}
+	if c.IsResolved() {
+		return ErrAlreadyResolved
+	}
+
+	c.ResolvedAt = &now
+	c.ResolvedBy = &userID
+
+	return nil
+}
+
+func (c *Comment) Reopen() error {
+	if c.IsReply() {
+		return ErrNotAThread
+	}
+	if !c.IsResolved() {
+		return ErrNotResolved
+	}
+
+	c.ResolvedAt = nil
+	c.ResolvedBy = nil
+
+	return nil
+}
+
+// MatchesFilter reports whether the thread started by the comment is shown under the filter
+func (c *Comment) MatchesFilter(filter string) bool {
+	switch filter {
+	case FilterOpen:
+		return !c.IsResolved()
+	case FilterResolved:
+		return c.IsResolved()
+	default:
+		return true
+	}
+}
+
+func IsValidFilter(filter string) bool {
+	return slices.Contains(Filters, filter)
+}
@@ -0,0 +1,204 @@
+package comment_test
+
+import (
+	"filmPackager/internal/domain/comment"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateNewReply(t *testing.T) {
+	assert := assert.New(t)
+
+	thread := comment.CreateNewComment(uuid.New(), uuid.New(), "page 12 runs long")
+	assert.False(thread.IsReply())
+	assert.Equal(thread.ID, thread.ThreadID())
+
+	reply := comment.CreateNewReply(thread, uuid.New(), "cut the opening")
+	assert.True(reply.IsReply())
+	assert.Equal(thread.DocID, reply.DocID)
+	assert.Equal(thread.ID, reply.ThreadID())
+
+	// replying to a reply stays in the same thread
+	nested := comment.CreateNewReply(reply, uuid.New(), "agreed")
+	assert.Equal(thread.ID, nested.ThreadID())
+}
+
+func TestResolveThread(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+	userID := uuid.New()
+
+	thread := comment.CreateNewComment(uuid.New(), uuid.New(), "page 12 runs long")
+	assert.True(thread.MatchesFilter(comment.FilterOpen))
+	assert.False(thread.MatchesFilter(comment.FilterResolved))
+
+	assert.NoError(thread.Resolve(userID, now))
+	assert.True(thread.IsResolved())
+	assert.Equal(userID, *thread.ResolvedBy)
+	assert.True(thread.MatchesFilter(comment.FilterResolved))
+	assert.True(thread.MatchesFilter(comment.FilterAll))
+	assert.ErrorIs(thread.Resolve(userID, now), comment.ErrAlreadyResolved)
+
+	assert.NoError(thread.Reopen())
+	assert.False(thread.IsResolved())
+	assert.Nil(thread.ResolvedBy)
+	assert.ErrorIs(thread.Reopen(), comment.ErrNotResolved)
+
+	reply := comment.CreateNewReply(thread, uuid.New(), "done")
+	assert.ErrorIs(reply.Resolve(userID, now), comment.ErrNotAThread)
+}
+
+func TestNewAnchor(t *testing.T) {
+	assert := assert.New(t)
+
+	a, err := comment.NewAnchor(3, &comment.Rect{X: 0.1, Y: 0.2, Width: 0.5, Height: 0.1}, "INT. KITCHEN - NIGHT")
+	assert.NoError(err)
+	assert.Equal(3, a.Page)
+
+	_, err = comment.NewAnchor(0, nil, "")
+	assert.ErrorIs(err, comment.ErrInvalidAnchorPage)
+
+	// the region has to fit on the page
+	_, err = comment.NewAnchor(1, &comment.Rect{X: 0.8, Y: 0, Width: 0.5, Height: 0.1}, "")
+	assert.ErrorIs(err, comment.ErrInvalidAnchorRect)
+
+	_, err = comment.NewAnchor(1, &comment.Rect{X: 0.1, Y: 0.1, Width: 0, Height: 0.1}, "")
+	assert.ErrorIs(err, comment.ErrInvalidAnchorRect)
+
+	_, err = comment.NewAnchor(1, nil, strings.Repeat("a", comment.MaxAnchorTextLength+1))
+	assert.ErrorIs(err, comment.ErrAnchorTextTooLong)
+}
+
+func TestAnchorTo(t *testing.T) {
+	assert := assert.New(t)
+
+	thread := comment.CreateNewComment(uuid.New(), uuid.New(), "this beat is rushed")
+	assert.False(thread.IsAnchored())
+
+	assert.NoError(thread.AnchorTo(&comment.Anchor{Page: 12}))
+	assert.True(thread.IsAnchored())
+
+	reply := comment.CreateNewReply(thread, uuid.New(), "agreed")
+	assert.ErrorIs(reply.AnchorTo(&comment.Anchor{Page: 12}), comment.ErrNotAThread)
+}
+
+func TestEditComment(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+	authorID := uuid.New()
+
+	c := comment.CreateNewComment(uuid.New(), authorID, "page 12 runs long")
+	assert.False(c.IsEdited())
+
+	_, err := c.Edit(uuid.New(), "page 12 is fine", now)
+	assert.ErrorIs(err, comment.ErrNotAuthor)
+
+	_, err = c.Edit(authorID, "   ", now)
+	assert.ErrorIs(err, comment.ErrEmptyComment)
+
+	_, err = c.Edit(authorID, strings.Repeat("é", comment.MaxLength+1), now)
+	assert.ErrorIs(err, comment.ErrCommentTooLong)
+	assert.NoError(comment.CheckLength(strings.Repeat("é", comment.MaxLength)))
+
+	// saving the same text isn't an edit
+	r, err := c.Edit(authorID, "page 12 runs long", now)
+	assert.NoError(err)
+	assert.Nil(r)
+	assert.False(c.IsEdited())
+
+	r, err = c.Edit(authorID, "pages 12 and 13 run long", now)
+	assert.NoError(err)
+	assert.Equal("page 12 runs long", r.Content)
+	assert.Equal(c.ID, r.CommentID)
+	assert.Equal("pages 12 and 13 run long", c.Content)
+	assert.True(c.IsEdited())
+}
+
+func TestCanDelete(t *testing.T) {
+	assert := assert.New(t)
+	authorID := uuid.New()
+
+	c := comment.CreateNewComment(uuid.New(), authorID, "page 12 runs long")
+	assert.True(c.CanDelete(authorID, false))
+	assert.False(c.CanDelete(uuid.New(), false))
+	assert.True(c.CanDelete(uuid.New(), true))
+}
+
+func TestFindMentions(t *testing.T) {
+	assert := assert.New(t)
+
+	sam, samLee, ana := uuid.New(), uuid.New(), uuid.New()
+	members := []comment.Mentionable{
+		{UserID: sam, Name: "Sam"},
+		{UserID: samLee, Name: "Sam Lee"},
+		{UserID: ana, Name: "Ana"},
+	}
+
+	// the longest name wins and names are matched ignoring case
+	assert.Equal([]uuid.UUID{samLee, ana}, comment.FindMentions("@sam lee can you check with @Ana?", members))
+	assert.Equal([]uuid.UUID{sam}, comment.FindMentions("@Sam, @Sam again", members))
+
+	// not a mention inside a word or with more name after it
+	assert.Empty(comment.FindMentions("mail ana@example.com", members))
+	assert.Empty(comment.FindMentions("@Samantha", members))
+
+	segments := comment.SplitMentions("ask @Ana first", members)
+	assert.Equal([]comment.Segment{
+		{Text: "ask "},
+		{Text: "@Ana", Mention: true},
+		{Text: " first"},
+	}, segments)
+}
+
+func TestMentionQuery(t *testing.T) {
+	assert := assert.New(t)
+
+	q, ok := comment.MentionQuery("thoughts @sa")
+	assert.True(ok)
+	assert.Equal("sa", q)
+
+	_, ok = comment.MentionQuery("mail ana@exa")
+	assert.False(ok)
+
+	_, ok = comment.MentionQuery("@Ana\nnew line")
+	assert.False(ok)
+
+	members := []comment.Mentionable{{Name: "Sam Lee"}, {Name: "Ana"}}
+	assert.Equal([]comment.Mentionable{{Name: "Sam Lee"}}, comment.SuggestMentions("le", members, 5))
+}
+
+func TestCanCarryForward(t *testing.T) {
+	assert := assert.New(t)
+
+	thread := comment.CreateNewComment(uuid.New(), uuid.New(), "page 12 runs long")
+	assert.True(thread.CanCarryForward())
+
+	// replies move with their thread
+	reply := comment.CreateNewReply(thread, uuid.New(), "cut the opening")
+	assert.False(reply.CanCarryForward())
+
+	assert.NoError(thread.Resolve(uuid.New(), time.Now()))
+	assert.False(thread.CanCarryForward())
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	assert := assert.New(t)
+	sam := comment.Mentionable{UserID: uuid.New(), Name: "Sam Lee"}
+
+	assert.Equal("<p><strong>cut</strong> the <em>opening</em>, see <code>scene_12</code></p>", comment.RenderMarkdown("**cut** the *opening*, see `scene_12`", nil))
+	assert.Equal("<p>file_name_v2 stays as is</p>", comment.RenderMarkdown("file_name_v2 stays as is", nil))
+	assert.Equal("<ul><li>one</li><li>two</li></ul><ol><li>three</li></ol>", comment.RenderMarkdown("- one\n* two\n1. three", nil))
+	assert.Equal("<blockquote><p>too long<br>trim it</p></blockquote><p>agreed <span class=\"mention\">@Sam Lee</span></p>", comment.RenderMarkdown("> too long\n> trim it\n\nagreed @Sam Lee", []comment.Mentionable{sam}))
+	assert.Equal(`<p><a href="https://example.com/?a=1&amp;b=2" target="_blank" rel="noopener noreferrer nofollow">notes</a></p>`, comment.RenderMarkdown("[notes](https://example.com/?a=1&b=2)", nil))
+	assert.Equal("<p>2 * 3 * 4 = 24</p>", comment.RenderMarkdown("2 * 3 * 4 = 24", nil))
+
+	// nothing from the text makes it through as markup
+	assert.Equal("<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>", comment.RenderMarkdown("<script>alert(1)</script>", nil))
+	assert.Equal("<p>[x](javascript:alert(1))</p>", comment.RenderMarkdown("[x](javascript:alert(1))", nil))
+	assert.Equal(`<p><a href="https://a.com/&#34;onmouseover=&#34;x" target="_blank" rel="noopener noreferrer nofollow">&lt;b&gt;x&lt;/b&gt;</a></p>`, comment.RenderMarkdown(`[<b>x</b>](https://a.com/"onmouseover="x)`, nil))
+	assert.Equal("<p><code>&lt;img src=x onerror=alert(1)&gt;</code></p>", comment.RenderMarkdown("`<img src=x onerror=alert(1)>`", nil))
+}
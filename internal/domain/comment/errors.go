@@ -0,0 +1,21 @@
+package comment
+
+import "errors"
+
+var (
+	ErrCommentNotFound = errors.New("comment not found")
+	ErrEmptyComment    = errors.New("comment can't be empty")
+	ErrCommentTooLong  = errors.New("comments can be at most 250 characters")
+	ErrNotAuthor       = errors.New("only the author can edit this comment")
+	ErrCannotDelete    = errors.New("only the author or the project owner can delete this comment")
+	ErrNotAThread      = errors.New("only the first comment of a thread can be resolved")
+	ErrAlreadyResolved = errors.New("thread is already resolved")
+	ErrNotResolved     = errors.New("thread is not resolved")
+
+	ErrInvalidAnchorPage = errors.New("anchor page must be 1 or more")
+	ErrInvalidAnchorRect = errors.New("anchor region must lie within the page")
+	ErrAnchorTextTooLong = errors.New("anchor text selection is too long")
+
+	ErrNotAnEarlierVersion = errors.New("threads can only be carried forward from an earlier version of the document")
+	ErrNotMember           = errors.New("only members of the project can do this")
+)
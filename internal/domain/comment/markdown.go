@@ -0,0 +1,367 @@
+package comment
+
+import (
+	"html"
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// the link schemes kept when rendering, anything else (javascript:, data:...) is shown as plain text
+var linkSchemes = []string{"http", "https", "mailto"}
+
+// RenderMarkdown renders the subset of Markdown comments support: **bold**, *italics*,
+// `inline code`, [links](https://...), > quotes and - or 1. lists, highlighting the
+// mentions of the members.
+//
+// The HTML is built here tag by tag and every piece of the text is escaped, so the
+// only markup in the result is the tags below whatever the comment contains.
+func RenderMarkdown(text string, members []Mentionable) string {
+	r := &markdownRenderer{members: members}
+	return r.blocks(strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n"))
+}
+
+type markdownRenderer struct {
+	members []Mentionable
+	// links can't be nested in links
+	inLink bool
+}
+
+type blockKind int
+
+const (
+	paragraphBlock blockKind = iota
+	quoteBlock
+	bulletBlock
+	numberedBlock
+)
+
+// groups the lines into paragraphs, quotes and lists, a blank line ends a block
+func (r *markdownRenderer) blocks(lines []string) string {
+	var b strings.Builder
+
+	kind := paragraphBlock
+	var items []string
+	flush := func() {
+		if len(items) > 0 {
+			b.WriteString(r.block(kind, items))
+		}
+		items = nil
+	}
+
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+
+		k, content := lineKind(line)
+		if k != kind {
+			flush()
+			kind = k
+		}
+		items = append(items, content)
+	}
+	flush()
+
+	return b.String()
+}
+
+func (r *markdownRenderer) block(kind blockKind, items []string) string {
+	switch kind {
+	case quoteBlock:
+		return "<blockquote><p>" + r.joinLines(items) + "</p></blockquote>"
+	case bulletBlock:
+		return "<ul>" + r.listItems(items) + "</ul>"
+	case numberedBlock:
+		return "<ol>" + r.listItems(items) + "</ol>"
+	default:
+		return "<p>" + r.joinLines(items) + "</p>"
+	}
+}
+
+// line breaks within a paragraph are kept, comments are usually written like messages
+func (r *markdownRenderer) joinLines(lines []string) string {
+	rendered := make([]string, len(lines))
+	for i, l := range lines {
+		rendered[i] = r.inline(strings.TrimSpace(l))
+	}
+	return strings.Join(rendered, "<br>")
+}
+
+func (r *markdownRenderer) listItems(items []string) string {
+	var b strings.Builder
+	for _, item := range items {
+		b.WriteString("<li>" + r.inline(strings.TrimSpace(item)) + "</li>")
+	}
+	return b.String()
+}
+
+func lineKind(line string) (blockKind, string) {
+	trimmed := strings.TrimLeft(line, " ")
+
+	if rest, ok := strings.CutPrefix(trimmed, ">"); ok {
+		return quoteBlock, rest
+	}
+
+	for _, marker := range []string{"- ", "* ", "+ "} {
+		if rest, ok := strings.CutPrefix(trimmed, marker); ok {
+			return bulletBlock, rest
+		}
+	}
+
+	digits := 0
+	for digits < len(trimmed) && trimmed[digits] >= '0' && trimmed[digits] <= '9' {
+		digits++
+	}
+	if digits > 0 && digits < len(trimmed)-1 && (trimmed[digits] == '.' || trimmed[digits] == ')') && trimmed[digits+1] == ' ' {
+		return numberedBlock, trimmed[digits+2:]
+	}
+
+	return paragraphBlock, line
+}
+
+// renders the inline markup of a line, text that isn't markup is escaped with the mentions highlighted
+func (r *markdownRenderer) inline(s string) string {
+	var b strings.Builder
+	scans := map[string]*closerScan{}
+
+	plain := 0
+	flush := func(end int) {
+		b.WriteString(r.text(s[plain:end]))
+	}
+
+	for i := 0; i < len(s); {
+		var out string
+		var next int
+		var ok bool
+
+		switch s[i] {
+		case '\\':
+			out, next, ok = escaped(s, i)
+		case '`':
+			out, next, ok = code(s, i)
+		case '*', '_':
+			out, next, ok = r.emphasis(s, i, scans)
+		case '[':
+			out, next, ok = r.link(s, i)
+		}
+
+		if !ok {
+			i++
+			continue
+		}
+
+		flush(i)
+		b.WriteString(out)
+		i, plain = next, next
+	}
+	flush(len(s))
+
+	return b.String()
+}
+
+// a backslash before punctuation shows it as is, \*not italics\*
+func escaped(s string, i int) (string, int, bool) {
+	if i+1 >= len(s) || s[i+1] >= utf8.RuneSelf || !unicode.IsPunct(rune(s[i+1])) && !unicode.IsSymbol(rune(s[i+1])) {
+		return "", 0, false
+	}
+	return html.EscapeString(s[i+1 : i+2]), i + 2, true
+}
+
+// nothing inside backticks is markup
+func code(s string, i int) (string, int, bool) {
+	end := strings.IndexByte(s[i+1:], '`')
+	if end <= 0 {
+		return "", 0, false
+	}
+	end += i + 1
+	return "<code>" + html.EscapeString(s[i+1:end]) + "</code>", end + 1, true
+}
+
+// **bold** or __bold__, *italics* or _italics_. Underscores only count at the edges of
+// words so names like file_name_v2 are left alone
+func (r *markdownRenderer) emphasis(s string, i int, scans map[string]*closerScan) (string, int, bool) {
+	delim := s[i : i+1]
+	tag := "em"
+	if strings.HasPrefix(s[i:], delim+delim) {
+		delim += delim
+		tag = "strong"
+	}
+
+	if delim[0] == '_' && i > 0 && isWordByte(s[i-1]) {
+		return "", 0, false
+	}
+
+	start := i + len(delim)
+	if start >= len(s) {
+		return "", 0, false
+	}
+	// the text can't start with a space, whichever closer is found
+	if first, _ := utf8.DecodeRuneInString(s[start:]); unicode.IsSpace(first) {
+		return "", 0, false
+	}
+
+	scan, ok := scans[delim]
+	if !ok {
+		scan = newCloserScan(s, delim)
+		scans[delim] = scan
+	}
+
+	// a closer straight after the opener would leave nothing to emphasise
+	from := start
+	if scan.next[start] == start {
+		from = scan.after(start)
+	}
+
+	end := scan.closer(from)
+	if end < 0 {
+		return "", 0, false
+	}
+
+	return "<" + tag + ">" + r.inline(s[start:end]) + "</" + tag + ">", end + len(delim), true
+}
+
+// closerScan finds the closing delimiters of a line. Whether a delimiter closes doesn't
+// depend on where its opener was, so the closer found from each position is kept and the
+// openers after it reuse it rather than scanning to the end of the line again
+type closerScan struct {
+	s     string
+	delim string
+	// the index of the first delimiter at or after each position, -1 when there isn't one
+	next []int
+	// the closer found from each position, -1 when there isn't one and -2 until it's looked for
+	found []int
+}
+
+func newCloserScan(s, delim string) *closerScan {
+	c := &closerScan{s: s, delim: delim, next: make([]int, len(s)+1), found: make([]int, len(s)+1)}
+
+	c.next[len(s)] = -1
+	for p := len(s) - 1; p >= 0; p-- {
+		c.next[p] = c.next[p+1]
+		if strings.HasPrefix(s[p:], delim) {
+			c.next[p] = p
+		}
+	}
+	for p := range c.found {
+		c.found[p] = -2
+	}
+
+	return c
+}
+
+// the first delimiter from the position that can close, skipping the ones that can't
+func (c *closerScan) closer(from int) int {
+	visited := []int{}
+	end := -1
+	for from < len(c.s) {
+		if c.found[from] != -2 {
+			end = c.found[from]
+			break
+		}
+		visited = append(visited, from)
+
+		next := c.next[from]
+		if next < 0 {
+			break
+		}
+		if c.closes(next) {
+			end = next
+			break
+		}
+		from = c.after(next)
+	}
+
+	for _, p := range visited {
+		c.found[p] = end
+	}
+	return end
+}
+
+func (c *closerScan) closes(end int) bool {
+	after := end + len(c.delim)
+	last, _ := utf8.DecodeLastRuneInString(c.s[:end])
+	closesWord := c.delim[0] != '_' || after == len(c.s) || !isWordByte(c.s[after])
+	return !unicode.IsSpace(last) && closesWord && !c.partOfDouble(end)
+}
+
+// a single * that's half of a ** belongs to the bold
+func (c *closerScan) partOfDouble(end int) bool {
+	return len(c.delim) == 1 && end+1 < len(c.s) && c.s[end+1] == c.delim[0]
+}
+
+// where the scan carries on after a delimiter that doesn't close
+func (c *closerScan) after(end int) int {
+	if c.partOfDouble(end) {
+		return end + len(c.delim) + 1
+	}
+	return end + len(c.delim)
+}
+
+// [label](url), links open in a new tab and only to the schemes in linkSchemes
+func (r *markdownRenderer) link(s string, i int) (string, int, bool) {
+	if r.inLink {
+		return "", 0, false
+	}
+
+	labelEnd := strings.Index(s[i:], "](")
+	if labelEnd < 0 {
+		return "", 0, false
+	}
+	labelEnd += i
+
+	urlEnd := strings.IndexByte(s[labelEnd+2:], ')')
+	if urlEnd < 0 {
+		return "", 0, false
+	}
+	urlEnd += labelEnd + 2
+
+	label := s[i+1 : labelEnd]
+	href := strings.TrimSpace(s[labelEnd+2 : urlEnd])
+	if label == "" || !isSafeLink(href) {
+		return "", 0, false
+	}
+
+	r.inLink = true
+	rendered := r.inline(label)
+	r.inLink = false
+
+	return `<a href="` + html.EscapeString(href) + `" target="_blank" rel="noopener noreferrer nofollow">` + rendered + "</a>", urlEnd + 1, true
+}
+
+func isSafeLink(href string) bool {
+	u, err := url.Parse(href)
+	if err != nil || strings.ContainsAny(href, " \t") {
+		return false
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	for _, s := range linkSchemes {
+		if scheme == s {
+			return u.Host != "" || u.Opaque != ""
+		}
+	}
+	return false
+}
+
+// plain text, escaped with the mentions of the members wrapped so they can be highlighted
+func (r *markdownRenderer) text(s string) string {
+	var b strings.Builder
+	for _, seg := range SplitMentions(s, r.members) {
+		if seg.Mention {
+			b.WriteString(`<span class="mention">` + html.EscapeString(seg.Text) + "</span>")
+		} else {
+			b.WriteString(html.EscapeString(seg.Text))
+		}
+	}
+	return b.String()
+}
+
+func isWordByte(c byte) bool {
+	if c >= utf8.RuneSelf {
+		return true
+	}
+	return isNameRune(rune(c))
+}
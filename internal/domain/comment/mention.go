@@ -0,0 +1,142 @@
+package comment
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// Mentionable is a project member who can be mentioned as @Name
+type Mentionable struct {
+	UserID uuid.UUID
+	Name   string
+}
+
+// Segment is a run of comment text, either plain or a mention
+type Segment struct {
+	Text    string
+	Mention bool
+}
+
+type mentionMatch struct {
+	start, end int
+	userID     uuid.UUID
+}
+
+// FindMentions returns the members mentioned in the text, in the order they're first mentioned
+func FindMentions(text string, members []Mentionable) []uuid.UUID {
+	ids := []uuid.UUID{}
+	seen := make(map[uuid.UUID]bool)
+	for _, m := range scanMentions(text, members) {
+		if !seen[m.userID] {
+			seen[m.userID] = true
+			ids = append(ids, m.userID)
+		}
+	}
+	return ids
+}
+
+// SplitMentions breaks the text up so the mentions of the members can be highlighted
+func SplitMentions(text string, members []Mentionable) []Segment {
+	segments := []Segment{}
+	last := 0
+	for _, m := range scanMentions(text, members) {
+		if m.start > last {
+			segments = append(segments, Segment{Text: text[last:m.start]})
+		}
+		segments = append(segments, Segment{Text: text[m.start:m.end], Mention: true})
+		last = m.end
+	}
+	if last < len(text) {
+		segments = append(segments, Segment{Text: text[last:]})
+	}
+	return segments
+}
+
+// MentionQuery returns what's been typed after a trailing @, for suggesting members while typing
+func MentionQuery(text string) (string, bool) {
+	i := strings.LastIndex(text, "@")
+	if i < 0 || !isMentionStart(text, i) {
+		return "", false
+	}
+
+	query := text[i+1:]
+	if strings.ContainsAny(query, "\n@") {
+		return "", false
+	}
+	return query, true
+}
+
+// SuggestMentions returns the members whose name starts with the query, or any word of it does
+func SuggestMentions(query string, members []Mentionable, limit int) []Mentionable {
+	query = strings.ToLower(strings.TrimSpace(query))
+
+	suggestions := []Mentionable{}
+	for _, m := range members {
+		if len(suggestions) == limit {
+			break
+		}
+		name := strings.ToLower(m.Name)
+		if strings.HasPrefix(name, query) || strings.Contains(name, " "+query) {
+			suggestions = append(suggestions, m)
+		}
+	}
+	return suggestions
+}
+
+// finds @Name for each member, ignoring case, preferring the longest name so
+// "@Sam Lee" isn't read as a mention of "Sam"
+func scanMentions(text string, members []Mentionable) []mentionMatch {
+	byLength := make([]Mentionable, 0, len(members))
+	for _, m := range members {
+		if strings.TrimSpace(m.Name) != "" {
+			byLength = append(byLength, m)
+		}
+	}
+	sort.SliceStable(byLength, func(i, j int) bool {
+		return len(byLength[i].Name) > len(byLength[j].Name)
+	})
+
+	matches := []mentionMatch{}
+	for i := 0; i < len(text); i++ {
+		if text[i] != '@' || !isMentionStart(text, i) {
+			continue
+		}
+
+		for _, m := range byLength {
+			end := i + 1 + len(m.Name)
+			if end > len(text) || !strings.EqualFold(text[i+1:end], m.Name) || !isMentionEnd(text, end) {
+				continue
+			}
+
+			matches = append(matches, mentionMatch{start: i, end: end, userID: m.UserID})
+			i = end - 1
+			break
+		}
+	}
+	return matches
+}
+
+// an @ inside a word, like an email address, isn't a mention
+func isMentionStart(text string, i int) bool {
+	if i == 0 {
+		return true
+	}
+	r, _ := utf8.DecodeLastRuneInString(text[:i])
+	return !isNameRune(r)
+}
+
+func isMentionEnd(text string, end int) bool {
+	if end == len(text) {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(text[end:])
+	return !isNameRune(r)
+}
+
+func isNameRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
@@ -12,4 +12,11 @@ type CommentRepository interface {
 	DeleteDocComments(ctx context.Context, docID uuid.UUID) error
 	DeleteDocComment(ctx context.Context, commentID uuid.UUID) error
 	GetDocComment(ctx context.Context, commentID uuid.UUID) (*Comment, error)
+	// saves edits and the resolution of a thread
+	UpdateDocComment(ctx context.Context, comment *Comment) error
+	// moves the thread and its replies to another version of the document
+	MoveThread(ctx context.Context, threadID uuid.UUID, docID uuid.UUID, carriedFrom int) error
+	CreateRevision(ctx context.Context, revision *Revision) error
+	// oldest first
+	GetRevisions(ctx context.Context, commentID uuid.UUID) ([]Revision, error)
 }
@@ -0,0 +1,49 @@
+package comment
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Revision keeps the text a comment had before an edit
+type Revision struct {
+	ID        uuid.UUID
+	CommentID uuid.UUID
+	Content   string
+	// when the text was replaced
+	CreatedAt time.Time
+}
+
+// Edit replaces the comment's text and returns the revision holding the old
+// text, there's no revision when the text hasn't changed
+func (c *Comment) Edit(userID uuid.UUID, text string, now time.Time) (*Revision, error) {
+	if !c.CanEdit(userID) {
+		return nil, ErrNotAuthor
+	}
+
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, ErrEmptyComment
+	}
+	err := CheckLength(text)
+	if err != nil {
+		return nil, err
+	}
+	if text == c.Content {
+		return nil, nil
+	}
+
+	r := &Revision{
+		ID:        uuid.New(),
+		CommentID: c.ID,
+		Content:   c.Content,
+		CreatedAt: now,
+	}
+
+	c.Content = text
+	c.EditedAt = &now
+
+	return r, nil
+}
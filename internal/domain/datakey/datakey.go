@@ -0,0 +1,18 @@
+package datakey
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DataKey encrypts every file of one project. Only its wrapped form is stored, sealed
+// with a master key from the configuration, so rotating the master key means
+// rewrapping these rather than re-encrypting the files
+type DataKey struct {
+	ProjectID   uuid.UUID
+	MasterKeyID string
+	Wrapped     []byte
+	CreatedAt   time.Time
+	RotatedAt   *time.Time
+}
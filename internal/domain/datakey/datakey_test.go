@@ -0,0 +1,199 @@
+package datakey_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"filmPackager/internal/domain/datakey"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func masterKey(id string) datakey.MasterKey {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return datakey.MasterKey{ID: id, Key: key}
+}
+
+func encrypt(t *testing.T, key, aad, plain []byte) []byte {
+	var sealed bytes.Buffer
+	w, err := datakey.NewEncrypter(&sealed, key, aad)
+	assert.NoError(t, err)
+	// in odd sized writes, the way an upload arrives
+	for r := bytes.NewReader(plain); ; {
+		chunk := make([]byte, 10007)
+		n, err := r.Read(chunk)
+		w.Write(chunk[:n])
+		if err == io.EOF {
+			break
+		}
+	}
+	assert.NoError(t, w.Close())
+	return sealed.Bytes()
+}
+
+func decrypt(key, aad, sealed []byte) ([]byte, error) {
+	r, err := datakey.NewDecrypter(bytes.NewReader(sealed), key, aad)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestStream(t *testing.T) {
+	assert := assert.New(t)
+
+	key := masterKey("k").Key
+	aad := []byte("project")
+
+	// empty, under a segment, exactly one and a few segments with a partial end
+	for _, size := range []int{0, 100, 64 * 1024, 3*64*1024 + 5} {
+		plain := make([]byte, size)
+		rand.Read(plain)
+
+		sealed := encrypt(t, key, aad, plain)
+		assert.True(datakey.IsEncrypted(sealed))
+
+		opened, err := decrypt(key, aad, sealed)
+		assert.NoError(err, size)
+		assert.Equal(len(plain), len(opened), size)
+		assert.True(bytes.Equal(plain, opened), size)
+	}
+
+	plain := make([]byte, 2*64*1024+10)
+	sealed := encrypt(t, key, aad, plain)
+
+	// a changed byte, a dropped last segment, the wrong key or project
+	tampered := append([]byte{}, sealed...)
+	tampered[len(tampered)-1] ^= 1
+	_, err := decrypt(key, aad, tampered)
+	assert.ErrorIs(err, datakey.ErrCorrupt)
+
+	header := len(datakey.Magic) + 7
+	_, err = decrypt(key, aad, sealed[:header+2*(64*1024+16)])
+	assert.ErrorIs(err, datakey.ErrCorrupt)
+
+	_, err = decrypt(masterKey("other").Key, aad, sealed)
+	assert.ErrorIs(err, datakey.ErrCorrupt)
+
+	_, err = decrypt(key, []byte("another project"), sealed)
+	assert.ErrorIs(err, datakey.ErrCorrupt)
+
+	// swapping the first two segments
+	seg := 64*1024 + 16
+	swapped := append([]byte{}, sealed[:header]...)
+	swapped = append(swapped, sealed[header+seg:header+2*seg]...)
+	swapped = append(swapped, sealed[header:header+seg]...)
+	swapped = append(swapped, sealed[header+2*seg:]...)
+	_, err = decrypt(key, aad, swapped)
+	assert.ErrorIs(err, datakey.ErrCorrupt)
+
+	assert.False(datakey.IsEncrypted([]byte("%PDF-1.7")))
+}
+
+func TestStreamRange(t *testing.T) {
+	assert := assert.New(t)
+
+	key := masterKey("k").Key
+	aad := []byte("project")
+
+	for _, size := range []int64{1, 64 * 1024, 3*64*1024 + 5} {
+		plain := make([]byte, size)
+		rand.Read(plain)
+		sealed := encrypt(t, key, aad, plain)
+
+		n, err := datakey.PlainSize(int64(len(sealed)))
+		assert.NoError(err)
+		assert.Equal(size, n)
+
+		// the start, across a segment boundary, the last byte
+		for _, r := range [][2]int64{{0, 0}, {size / 2, size - 1}, {size - 1, size - 1}, {10, size/3 + 64*1024}} {
+			start, end := min(r[0], size-1), min(r[1], size-1)
+			from, to := datakey.SealedRange(start, end, int64(len(sealed)))
+
+			d, err := datakey.NewDecrypterAt(sealed[:datakey.HeaderSize], bytes.NewReader(sealed[from:to+1]), key, aad, start, int64(len(sealed)))
+			assert.NoError(err)
+			got, err := io.ReadAll(io.LimitReader(d, end-start+1))
+			assert.NoError(err)
+			assert.True(bytes.Equal(plain[start:end+1], got), "%d: %d-%d", size, start, end)
+		}
+	}
+
+	_, err := datakey.PlainSize(datakey.HeaderSize + 3)
+	assert.ErrorIs(err, datakey.ErrCorrupt)
+}
+
+func TestKeyring(t *testing.T) {
+	assert := assert.New(t)
+
+	old := masterKey("2024")
+	ring, err := datakey.NewKeyring([]datakey.MasterKey{old})
+	assert.NoError(err)
+
+	project := uuid.New()
+	d, key, err := ring.NewDataKey(project, time.Now())
+	assert.NoError(err)
+	assert.Equal("2024", d.MasterKeyID)
+	assert.NotContains(string(d.Wrapped), string(key))
+
+	unwrapped, err := ring.Unwrap(d)
+	assert.NoError(err)
+	assert.Equal(key, unwrapped)
+
+	// a wrapped key moved to another project doesn't open
+	moved := *d
+	moved.ProjectID = uuid.New()
+	_, err = ring.Unwrap(&moved)
+	assert.ErrorIs(err, datakey.ErrCorrupt)
+
+	// rotating keeps the same data key under the new master key
+	rotated, err := datakey.NewKeyring([]datakey.MasterKey{masterKey("2025"), old})
+	assert.NoError(err)
+
+	changed, err := rotated.Rewrap(d, time.Now())
+	assert.NoError(err)
+	assert.True(changed)
+	assert.Equal("2025", d.MasterKeyID)
+	assert.NotNil(d.RotatedAt)
+
+	unwrapped, err = rotated.Unwrap(d)
+	assert.NoError(err)
+	assert.Equal(key, unwrapped)
+
+	changed, err = rotated.Rewrap(d, time.Now())
+	assert.NoError(err)
+	assert.False(changed)
+
+	// once the old master key is dropped, only rewrapped keys open
+	_, err = ring.Unwrap(d)
+	assert.ErrorIs(err, datakey.ErrUnknownMasterKey)
+}
+
+func TestParseMasterKeys(t *testing.T) {
+	assert := assert.New(t)
+
+	k := masterKey("x").Key
+	keys, err := datakey.ParseMasterKeys(" new:" + base64.StdEncoding.EncodeToString(k) + ", old:" + base64.StdEncoding.EncodeToString(k))
+	assert.NoError(err)
+	assert.Equal(2, len(keys))
+	assert.Equal("new", keys[0].ID)
+
+	keys, err = datakey.ParseMasterKeys("")
+	assert.NoError(err)
+	assert.Empty(keys)
+
+	_, err = datakey.ParseMasterKeys("nokey")
+	assert.Error(err)
+
+	short, err := datakey.ParseMasterKeys("short:" + base64.StdEncoding.EncodeToString([]byte("too short")))
+	assert.NoError(err)
+	_, err = datakey.NewKeyring(short)
+	assert.ErrorIs(err, datakey.ErrMasterKeyLength)
+
+	_, err = datakey.NewKeyring([]datakey.MasterKey{masterKey("a"), masterKey("a")})
+	assert.ErrorIs(err, datakey.ErrDuplicateKey)
+}
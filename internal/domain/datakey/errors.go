@@ -0,0 +1,12 @@
+package datakey
+
+import "errors"
+
+var (
+	ErrDataKeyNotFound  = errors.New("data key not found")
+	ErrUnknownMasterKey = errors.New("data key was wrapped with an unknown master key")
+	ErrMasterKeyLength  = errors.New("storage master keys have to be 32 bytes, base64 encoded")
+	ErrDuplicateKey     = errors.New("storage master key ID is used twice")
+	// the file or data key doesn't decrypt, it's been cut short, reordered or changed
+	ErrCorrupt = errors.New("encrypted data is corrupt")
+)
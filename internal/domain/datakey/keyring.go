@@ -0,0 +1,210 @@
+package datakey
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AES-256 for both the master and data keys
+const keySize = 32
+
+type MasterKey struct {
+	ID  string
+	Key []byte
+}
+
+// Keyring wraps new data keys with the current master key and unwraps them with
+// whichever key they were wrapped with, so a master key is rotated by adding the new
+// one first and dropping the old one once Rewrap has moved every data key over
+type Keyring struct {
+	current string
+	keys    map[string][]byte
+}
+
+// NewKeyring wraps with the first key
+func NewKeyring(keys []MasterKey) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no storage master keys")
+	}
+
+	k := &Keyring{current: keys[0].ID, keys: make(map[string][]byte)}
+	for _, m := range keys {
+		if len(m.Key) != keySize {
+			return nil, fmt.Errorf("%w: %q", ErrMasterKeyLength, m.ID)
+		}
+		if _, ok := k.keys[m.ID]; ok {
+			return nil, fmt.Errorf("%w: %q", ErrDuplicateKey, m.ID)
+		}
+		k.keys[m.ID] = m.Key
+	}
+
+	return k, nil
+}
+
+// KeyringFromEnv reads STORAGE_MASTER_KEYS as comma separated id:base64 pairs, newest
+// first. Nil without an error when it isn't set, the files are then stored as they are
+func KeyringFromEnv() (*Keyring, error) {
+	keys, err := ParseMasterKeys(os.Getenv("STORAGE_MASTER_KEYS"))
+	if err != nil || len(keys) == 0 {
+		return nil, err
+	}
+
+	return NewKeyring(keys)
+}
+
+func ParseMasterKeys(env string) ([]MasterKey, error) {
+	keys := []MasterKey{}
+	for _, pair := range strings.Split(env, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		id, encoded, ok := strings.Cut(pair, ":")
+		if !ok || strings.TrimSpace(id) == "" {
+			return nil, errors.New("storage master keys should be id:key pairs, got one without an id")
+		}
+
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", ErrMasterKeyLength, id)
+		}
+
+		keys = append(keys, MasterKey{ID: strings.TrimSpace(id), Key: key})
+	}
+
+	return keys, nil
+}
+
+// CurrentKeyID is the master key new data keys are wrapped with
+func (k *Keyring) CurrentKeyID() string {
+	return k.current
+}
+
+// NewDataKey makes a random data key for the project, returning it wrapped and in the clear
+func (k *Keyring) NewDataKey(projectID uuid.UUID, now time.Time) (*DataKey, []byte, error) {
+	key := make([]byte, keySize)
+	_, err := rand.Read(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	d := &DataKey{ProjectID: projectID, CreatedAt: now}
+	err = k.wrap(d, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return d, key, nil
+}
+
+// Unwrap returns the data key in the clear
+func (k *Keyring) Unwrap(d *DataKey) ([]byte, error) {
+	master, ok := k.keys[d.MasterKeyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownMasterKey, d.MasterKeyID)
+	}
+
+	aead, err := newGCM(master)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(d.Wrapped) < aead.NonceSize() {
+		return nil, ErrCorrupt
+	}
+	nonce, sealed := d.Wrapped[:aead.NonceSize()], d.Wrapped[aead.NonceSize():]
+
+	key, err := aead.Open(nil, nonce, sealed, wrapAAD(d))
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+
+	return key, nil
+}
+
+// Rewrap moves the data key to the current master key, it's the same key so nothing
+// it encrypted changes. False when it was already wrapped with the current key
+func (k *Keyring) Rewrap(d *DataKey, now time.Time) (bool, error) {
+	if d.MasterKeyID == k.current {
+		return false, nil
+	}
+
+	key, err := k.Unwrap(d)
+	if err != nil {
+		return false, err
+	}
+
+	err = k.wrap(d, key)
+	if err != nil {
+		return false, err
+	}
+	d.RotatedAt = &now
+
+	return true, nil
+}
+
+// GetOrCreate returns the project's data key in the clear, making one the first time
+func (k *Keyring) GetOrCreate(ctx context.Context, repo DataKeyRepository, projectID uuid.UUID) ([]byte, error) {
+	d, err := repo.GetDataKey(ctx, projectID)
+	if errors.Is(err, ErrDataKeyNotFound) {
+		d, _, err = k.NewDataKey(projectID, time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("error making data key: %v", err)
+		}
+
+		// two uploads can race to make the first key, only one is kept so read it back
+		err = repo.CreateDataKey(ctx, d)
+		if err != nil {
+			return nil, fmt.Errorf("error saving data key: %v", err)
+		}
+		d, err = repo.GetDataKey(ctx, projectID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting data key: %v", err)
+	}
+
+	return k.Unwrap(d)
+}
+
+func (k *Keyring) wrap(d *DataKey, key []byte) error {
+	d.MasterKeyID = k.current
+
+	aead, err := newGCM(k.keys[k.current])
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return err
+	}
+
+	d.Wrapped = aead.Seal(nonce, nonce, key, wrapAAD(d))
+	return nil
+}
+
+// a wrapped key only unwraps for its own project and master key
+func wrapAAD(d *DataKey) []byte {
+	return append(d.ProjectID[:], []byte(d.MasterKeyID)...)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
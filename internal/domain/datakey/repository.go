@@ -0,0 +1,16 @@
+package datakey
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+type DataKeyRepository interface {
+	GetDataKey(ctx context.Context, projectID uuid.UUID) (*DataKey, error)
+	// keeps the existing key if the project already has one, see GetOrCreate
+	CreateDataKey(ctx context.Context, k *DataKey) error
+	UpdateDataKey(ctx context.Context, k *DataKey) error
+	// the keys still wrapped with an older master key
+	GetDataKeysNotWrappedWith(ctx context.Context, masterKeyID string) ([]*DataKey, error)
+}
@@ -0,0 +1,252 @@
+package datakey
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+)
+
+// Files are sealed in segments so neither side has to hold the whole file: a header of
+// Magic and a random nonce prefix, then each segment of up to segmentSize bytes sealed
+// with AES-GCM. A segment's nonce is the prefix, its number and whether it's the last,
+// so segments can't be dropped, reordered or cut off the end without Open failing
+const segmentSize = 64 * 1024
+
+const (
+	prefixSize = 7
+	// Magic and the nonce prefix
+	HeaderSize = 4 + prefixSize
+	// prefix, 4 byte counter, last segment flag
+	nonceSize = prefixSize + 4 + 1
+	tagSize   = 16
+)
+
+// Magic starts every encrypted file, anything else was stored before encryption was turned on
+var Magic = []byte("FPE\x01")
+
+// IsEncrypted checks the start of a stored file
+func IsEncrypted(head []byte) bool {
+	return bytes.HasPrefix(head, Magic)
+}
+
+type encrypter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	aad     []byte
+	prefix  []byte
+	counter uint32
+	buf     []byte
+}
+
+// NewEncrypter writes the header and seals what's written to it, Close seals the last segment
+func NewEncrypter(w io.Writer, key, aad []byte) (io.WriteCloser, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := make([]byte, prefixSize)
+	_, err = rand.Read(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = w.Write(append(append([]byte{}, Magic...), prefix...))
+	if err != nil {
+		return nil, err
+	}
+
+	return &encrypter{w: w, aead: aead, aad: aad, prefix: prefix, buf: make([]byte, 0, segmentSize)}, nil
+}
+
+func (e *encrypter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		// a full segment is only sealed once there's more to come, the last one is sealed by Close
+		if len(e.buf) == segmentSize {
+			err := e.seal(false)
+			if err != nil {
+				return written, err
+			}
+		}
+
+		n := copy(e.buf[len(e.buf):segmentSize], p)
+		e.buf = e.buf[:len(e.buf)+n]
+		p = p[n:]
+		written += n
+	}
+
+	return written, nil
+}
+
+func (e *encrypter) Close() error {
+	return e.seal(true)
+}
+
+func (e *encrypter) seal(last bool) error {
+	_, err := e.w.Write(e.aead.Seal(nil, nonce(e.prefix, e.counter, last), e.buf, e.aad))
+	e.counter++
+	e.buf = e.buf[:0]
+	return err
+}
+
+type decrypter struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	aad     []byte
+	prefix  []byte
+	counter uint32
+	// the last segment's number when the sealed size is known, otherwise -1
+	last   int64
+	done   bool
+	plain  []byte
+	sealed []byte
+}
+
+// NewDecrypter reads a file written by NewEncrypter, returning ErrCorrupt from Read when it doesn't open
+func NewDecrypter(r io.Reader, key, aad []byte) (io.Reader, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, HeaderSize)
+	_, err = io.ReadFull(r, header)
+	if err != nil || !IsEncrypted(header) {
+		return nil, ErrCorrupt
+	}
+
+	return &decrypter{
+		r:      bufio.NewReaderSize(r, segmentSize+tagSize),
+		aead:   aead,
+		aad:    aad,
+		prefix: header[len(Magic):],
+		last:   -1,
+		sealed: make([]byte, segmentSize+tagSize),
+	}, nil
+}
+
+// PlainSize works out the size of the file before it was sealed from the sealed size
+func PlainSize(sealedSize int64) (int64, error) {
+	body := sealedSize - HeaderSize
+	if body < tagSize {
+		return 0, ErrCorrupt
+	}
+
+	full, rest := body/(segmentSize+tagSize), body%(segmentSize+tagSize)
+	if rest == 0 {
+		return full * segmentSize, nil
+	}
+	if rest < tagSize {
+		return 0, ErrCorrupt
+	}
+
+	return full*segmentSize + rest - tagSize, nil
+}
+
+// SealedRange is the part of a sealed file, from and to inclusive, holding the segments
+// with the plaintext bytes from start to end
+func SealedRange(start, end, sealedSize int64) (int64, int64) {
+	from := HeaderSize + start/segmentSize*(segmentSize+tagSize)
+	to := HeaderSize + (end/segmentSize+1)*(segmentSize+tagSize)
+	if to > sealedSize {
+		to = sealedSize
+	}
+	return from, to - 1
+}
+
+// NewDecrypterAt reads the plaintext from start, r being the sealed file from where
+// SealedRange says. The header is read separately as it's at the start of the file
+func NewDecrypterAt(header []byte, r io.Reader, key, aad []byte, start, sealedSize int64) (io.Reader, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(header) < HeaderSize || !IsEncrypted(header) {
+		return nil, ErrCorrupt
+	}
+
+	segments := (sealedSize - HeaderSize + segmentSize + tagSize - 1) / (segmentSize + tagSize)
+	d := &decrypter{
+		r:       bufio.NewReaderSize(r, segmentSize+tagSize),
+		aead:    aead,
+		aad:     aad,
+		prefix:  header[len(Magic):HeaderSize],
+		counter: uint32(start / segmentSize),
+		last:    segments - 1,
+		sealed:  make([]byte, segmentSize+tagSize),
+	}
+
+	// the range rarely starts on a segment
+	_, err = io.CopyN(io.Discard, d, start%segmentSize)
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+
+	return d, nil
+}
+
+func (d *decrypter) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+
+		err := d.open()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+	return n, nil
+}
+
+func (d *decrypter) open() error {
+	n, err := io.ReadFull(d.r, d.sealed)
+	switch err {
+	case nil:
+		if d.last >= 0 {
+			d.done = int64(d.counter) == d.last
+			break
+		}
+		// a full segment is the last one when nothing follows it
+		_, peekErr := d.r.Peek(1)
+		d.done = peekErr == io.EOF
+	case io.ErrUnexpectedEOF:
+		// only the last segment is short
+		if d.last >= 0 && int64(d.counter) != d.last {
+			return ErrCorrupt
+		}
+		d.done = true
+	case io.EOF:
+		// the last segment is never empty of its tag, so the file was cut short
+		return ErrCorrupt
+	default:
+		return err
+	}
+
+	plain, err := d.aead.Open(d.sealed[:0], nonce(d.prefix, d.counter, d.done), d.sealed[:n], d.aad)
+	if err != nil {
+		return ErrCorrupt
+	}
+	d.counter++
+	d.plain = plain
+
+	return nil
+}
+
+func nonce(prefix []byte, counter uint32, last bool) []byte {
+	n := make([]byte, nonceSize)
+	copy(n, prefix)
+	binary.BigEndian.PutUint32(n[prefixSize:], counter)
+	if last {
+		n[nonceSize-1] = 1
+	}
+	return n
+}
@@ -0,0 +1,81 @@
+package digest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	FrequencyOff    = "off"
+	FrequencyDaily  = "daily"
+	FrequencyWeekly = "weekly"
+)
+
+// users without saved settings receive the weekly digest
+const DefaultFrequency = FrequencyWeekly
+
+var Frequencies = []string{FrequencyOff, FrequencyDaily, FrequencyWeekly}
+
+type Subscription struct {
+	UserID    uuid.UUID
+	Frequency string
+	// lets the user unsubscribe from an email link without logging in
+	UnsubscribeToken string
+	LastSentAt       *time.Time
+}
+
+func NewSubscription(userID uuid.UUID) *Subscription {
+	return &Subscription{
+		UserID:           userID,
+		Frequency:        DefaultFrequency,
+		UnsubscribeToken: NewUnsubscribeToken(),
+	}
+}
+
+func NewUnsubscribeToken() string {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		panic("error generating unsubscribe token: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
+
+func IsValidFrequency(frequency string) bool {
+	return slices.Contains(Frequencies, frequency)
+}
+
+// Interval returns how often the digest is sent, zero when digests are off
+func (s *Subscription) Interval() time.Duration {
+	switch s.Frequency {
+	case FrequencyDaily:
+		return 24 * time.Hour
+	case FrequencyWeekly:
+		return 7 * 24 * time.Hour
+	default:
+		return 0
+	}
+}
+
+func (s *Subscription) IsDue(now time.Time) bool {
+	interval := s.Interval()
+	if interval == 0 {
+		return false
+	}
+	if s.LastSentAt == nil {
+		return true
+	}
+	return now.Sub(*s.LastSentAt) >= interval
+}
+
+// Since returns the start of the window covered by the next digest
+func (s *Subscription) Since(now time.Time) time.Time {
+	if s.LastSentAt != nil {
+		return *s.LastSentAt
+	}
+	return now.Add(-s.Interval())
+}
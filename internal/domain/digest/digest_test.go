@@ -0,0 +1,33 @@
+package digest_test
+
+import (
+	"filmPackager/internal/domain/digest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscriptionIsDue(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+
+	s := digest.NewSubscription(uuid.New())
+	assert.Equal(digest.FrequencyWeekly, s.Frequency)
+	assert.NotEmpty(s.UnsubscribeToken)
+	assert.True(s.IsDue(now))
+	assert.Equal(now.Add(-7*24*time.Hour), s.Since(now))
+
+	sixDaysAgo := now.Add(-6 * 24 * time.Hour)
+	s.LastSentAt = &sixDaysAgo
+	assert.False(s.IsDue(now))
+	assert.Equal(sixDaysAgo, s.Since(now))
+
+	s.Frequency = digest.FrequencyDaily
+	assert.True(s.IsDue(now))
+
+	s.Frequency = digest.FrequencyOff
+	assert.False(s.IsDue(now))
+	assert.False(digest.IsValidFrequency("hourly"))
+}
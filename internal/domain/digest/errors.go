@@ -0,0 +1,8 @@
+package digest
+
+import "errors"
+
+var (
+	ErrSubscriptionNotFound = errors.New("digest subscription not found")
+	ErrInvalidFrequency     = errors.New("invalid digest frequency")
+)
@@ -0,0 +1,15 @@
+package digest
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+type DigestRepository interface {
+	GetByUserID(ctx context.Context, userID uuid.UUID) (*Subscription, error)
+	GetByUnsubscribeToken(ctx context.Context, token string) (*Subscription, error)
+	// returns a subscription for every user, falling back to the defaults for users without saved settings
+	GetAllUserSubscriptions(ctx context.Context) ([]Subscription, error)
+	Save(ctx context.Context, s *Subscription) error
+}
@@ -0,0 +1,67 @@
+package document
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/google/uuid"
+)
+
+// Files are stored once per project under the SHA-256 of their content, so re-uploading a
+// version that's already there shares its file. Blobs are kept per project rather than
+// across them as each project's files are encrypted with its own key, and so one project
+// can't find out what another has stored by uploading the same file
+
+// BlobKey is where the project's file with the checksum is kept in the bucket
+func BlobKey(projectID uuid.UUID, checksum string) string {
+	return fmt.Sprintf("blobs/%s/%s", projectID, checksum)
+}
+
+// Key is where the document's file is kept in the bucket
+func (d *Document) Key() string {
+	// stored before files were content addressed, under the name it was uploaded with
+	if d.Checksum == "" {
+		return fmt.Sprintf("%s=%s", d.FileName, d.ID)
+	}
+	return BlobKey(d.OrganizationID, d.Checksum)
+}
+
+// ThumbnailKey is where the picture of the first page of the document's file is kept, next to the file
+// so documents sharing a file share it too
+func (d *Document) ThumbnailKey() string {
+	return d.Key() + ".thumbnail.png"
+}
+
+// Checksum is the hex SHA-256 of the content
+func Checksum(r io.Reader) (string, error) {
+	h := sha256.New()
+	_, err := io.Copy(h, r)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+type verifier struct {
+	io.ReadCloser
+	hash     hash.Hash
+	checksum string
+}
+
+// NewVerifier checks the whole file read through it against the checksum, Read returns
+// ErrChecksumMismatch in place of io.EOF when it doesn't match
+func NewVerifier(r io.ReadCloser, checksum string) io.ReadCloser {
+	return &verifier{ReadCloser: r, hash: sha256.New(), checksum: checksum}
+}
+
+func (v *verifier) Read(p []byte) (int, error) {
+	n, err := v.ReadCloser.Read(p)
+	v.hash.Write(p[:n])
+	if err == io.EOF && hex.EncodeToString(v.hash.Sum(nil)) != v.checksum {
+		return n, ErrChecksumMismatch
+	}
+	return n, err
+}
@@ -0,0 +1,36 @@
+package document_test
+
+import (
+	"bytes"
+	"filmPackager/internal/domain/document"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlob(t *testing.T) {
+	assert := assert.New(t)
+
+	sum, err := document.Checksum(strings.NewReader("%PDF-1.7 script"))
+	assert.NoError(err)
+	assert.Len(sum, 64)
+
+	// the same content is stored once per project, older documents keep the key they were uploaded with
+	doc := &document.Document{ID: uuid.New(), OrganizationID: uuid.New(), FileName: "Film_Script.pdf", Checksum: sum}
+	again := &document.Document{ID: uuid.New(), OrganizationID: doc.OrganizationID, FileName: "Renamed_Script.pdf", Checksum: sum}
+	assert.Equal(doc.Key(), again.Key())
+	assert.Equal("blobs/"+doc.OrganizationID.String()+"/"+sum, doc.Key())
+
+	legacy := &document.Document{ID: doc.ID, FileName: "Film_Script.pdf"}
+	assert.Equal("Film_Script.pdf="+doc.ID.String(), legacy.Key())
+
+	data, err := io.ReadAll(document.NewVerifier(io.NopCloser(strings.NewReader("%PDF-1.7 script")), sum))
+	assert.NoError(err)
+	assert.Equal("%PDF-1.7 script", string(data))
+
+	_, err = io.ReadAll(document.NewVerifier(io.NopCloser(bytes.NewReader([]byte("%PDF-1.7 scrip7"))), sum))
+	assert.ErrorIs(err, document.ErrChecksumMismatch)
+}
@@ -0,0 +1,95 @@
+package document
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ByteRange is the single range of an HTTP Range header. A suffix range, the last
+// bytes of the file whatever its size, has Start -1 and End as the length
+type ByteRange struct {
+	Start int64
+	// inclusive, -1 to read to the end of the file
+	End int64
+}
+
+// ParseRange reads a Range header, a header it can't use is ignored so the whole file is sent
+func ParseRange(header string) *ByteRange {
+	spec, ok := strings.CutPrefix(strings.TrimSpace(header), "bytes=")
+	// several ranges would need a multipart response, sending the whole file is allowed instead
+	if !ok || strings.Contains(spec, ",") {
+		return nil
+	}
+
+	first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return nil
+	}
+
+	if first == "" {
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n <= 0 {
+			return nil
+		}
+		return &ByteRange{Start: -1, End: n}
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return nil
+	}
+
+	if last == "" {
+		return &ByteRange{Start: start, End: -1}
+	}
+
+	end, err := strconv.ParseInt(last, 10, 64)
+	if err != nil || end < start {
+		return nil
+	}
+
+	return &ByteRange{Start: start, End: end}
+}
+
+// Resolve works out the first and last byte of the range in a file of the given size
+func (r ByteRange) Resolve(size int64) (int64, int64, error) {
+	if r.Start < 0 {
+		start := size - r.End
+		if start < 0 {
+			start = 0
+		}
+		if size == 0 {
+			return 0, 0, ErrRangeNotSatisfiable
+		}
+		return start, size - 1, nil
+	}
+
+	if r.Start >= size {
+		return 0, 0, ErrRangeNotSatisfiable
+	}
+
+	end := r.End
+	if end < 0 || end >= size {
+		end = size - 1
+	}
+
+	return r.Start, end, nil
+}
+
+// String is the range as a Range header
+func (r ByteRange) String() string {
+	switch {
+	case r.Start < 0:
+		return fmt.Sprintf("bytes=-%d", r.End)
+	case r.End < 0:
+		return fmt.Sprintf("bytes=%d-", r.Start)
+	default:
+		return fmt.Sprintf("bytes=%d-%d", r.Start, r.End)
+	}
+}
+
+// ContentRange is the Content-Range header for the bytes from start to end of a file of the given size
+func ContentRange(start, end, size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", start, end, size)
+}
@@ -0,0 +1,35 @@
+package document_test
+
+import (
+	"filmPackager/internal/domain/document"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRange(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(&document.ByteRange{Start: 0, End: 1023}, document.ParseRange("bytes=0-1023"))
+	assert.Equal(&document.ByteRange{Start: 500, End: -1}, document.ParseRange("bytes=500-"))
+	assert.Equal(&document.ByteRange{Start: -1, End: 200}, document.ParseRange("bytes=-200"))
+
+	// ignored, so the whole file is sent
+	for _, h := range []string{"", "bytes=0-1,5-9", "items=0-1", "bytes=9-3", "bytes=-0", "bytes=a-b"} {
+		assert.Nil(document.ParseRange(h), h)
+	}
+
+	start, end, err := document.ByteRange{Start: -1, End: 200}.Resolve(100)
+	assert.NoError(err)
+	assert.Equal([2]int64{0, 99}, [2]int64{start, end})
+
+	start, end, err = document.ByteRange{Start: 10, End: 1000}.Resolve(100)
+	assert.NoError(err)
+	assert.Equal([2]int64{10, 99}, [2]int64{start, end})
+
+	_, _, err = document.ByteRange{Start: 100, End: -1}.Resolve(100)
+	assert.ErrorIs(err, document.ErrRangeNotSatisfiable)
+
+	assert.Equal("bytes=-200", document.ByteRange{Start: -1, End: 200}.String())
+	assert.Equal("bytes 0-9/100", document.ContentRange(0, 9, 100))
+}
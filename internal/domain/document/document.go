@@ -12,9 +12,16 @@ type Document struct {
 	UserID         uuid.UUID
 	FileName       string
 	FileType       string
-	Status         string
-	Date           *time.Time
-	Color          string
+	// detected from the upload, see DetectFormat. Empty for documents uploaded before it was
+	ContentType string
+	// staged, locked, or superseded once a newer version of the file type replaced it
+	Status string
+	// see ScanClean. Documents uploaded before scanning count as clean
+	ScanStatus string
+	// the hex SHA-256 of the file, which it's stored under. Empty for documents uploaded before it was
+	Checksum string
+	Date     *time.Time
+	Color    string
 }
 
 func (d *Document) IsStaged() bool {
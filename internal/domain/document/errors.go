@@ -5,4 +5,18 @@ import "errors"
 var (
 	ErrDocumentNotFound = errors.New("document not found")
 	ErrAccessDenied     = errors.New("member access blocked")
+	ErrUnknownFileType  = errors.New("Error: unknown document type")
+	// the content isn't a format documents can be, or doesn't match the file's extension
+	ErrUnrecognisedFormat = errors.New("unrecognised file format")
+	ErrScanPending        = errors.New("Error: this file hasn't been checked for malware yet, try again shortly")
+	ErrUploadNotFound     = errors.New("upload not found")
+	ErrIncompleteUpload   = errors.New("Error: not every part of the file has been uploaded yet")
+	ErrEmptyUpload        = errors.New("Error: the file is empty")
+	ErrQuarantined        = errors.New("Error: this file was quarantined by the malware scan, delete it and upload a clean copy")
+	// the requested range starts past the end of the file
+	ErrRangeNotSatisfiable = errors.New("range not satisfiable")
+	// the stored file has changed since it was uploaded
+	ErrChecksumMismatch = errors.New("file doesn't match its checksum")
+	// the document's thumbnail hasn't been made, or couldn't be, so it's shown with an icon
+	ErrNoThumbnail = errors.New("no thumbnail")
 )
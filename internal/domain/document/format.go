@@ -0,0 +1,222 @@
+package document
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Format is a kind of file the documents can be, recognised by its content rather than its name
+type Format struct {
+	Name string
+	// the first is used when naming uploads
+	Extensions  []string
+	ContentType string
+}
+
+const MB = 1024 * 1024
+
+var (
+	PDF      = Format{Name: "PDF", Extensions: []string{".pdf"}, ContentType: "application/pdf"}
+	FDX      = Format{Name: "FDX", Extensions: []string{".fdx"}, ContentType: "application/xml"}
+	Fountain = Format{Name: "Fountain", Extensions: []string{".fountain", ".spmd"}, ContentType: "text/plain; charset=utf-8"}
+	Text     = Format{Name: "TXT", Extensions: []string{".txt"}, ContentType: "text/plain; charset=utf-8"}
+	DOCX     = Format{Name: "DOCX", Extensions: []string{".docx"}, ContentType: "application/vnd.openxmlformats-officedocument.wordprocessingml.document"}
+	XLSX     = Format{Name: "XLSX", Extensions: []string{".xlsx"}, ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"}
+	PPTX     = Format{Name: "PPTX", Extensions: []string{".pptx"}, ContentType: "application/vnd.openxmlformats-officedocument.presentationml.presentation"}
+)
+
+var formats = []Format{PDF, FDX, Fountain, Text, DOCX, XLSX, PPTX}
+
+// TypeRule is what can be uploaded as one of the document types
+type TypeRule struct {
+	Formats []Format
+	MaxSize int64
+}
+
+// uploads through the app are capped at 30MB by the server's BodyLimit, the bigger
+// pitch decks and lookbooks go straight to the bucket, see Upload
+var typeRules = map[string]TypeRule{
+	"Script":    {Formats: []Format{PDF, FDX, Fountain}, MaxSize: 20 * MB},
+	"Logline":   {Formats: []Format{PDF, Text, DOCX}, MaxSize: 2 * MB},
+	"Synopsis":  {Formats: []Format{PDF, Text, DOCX}, MaxSize: 5 * MB},
+	"PitchDeck": {Formats: []Format{PDF, PPTX}, MaxSize: 250 * MB},
+	"Schedule":  {Formats: []Format{PDF, XLSX}, MaxSize: 10 * MB},
+	"Budget":    {Formats: []Format{PDF, XLSX}, MaxSize: 10 * MB},
+	"Shotlist":  {Formats: []Format{PDF, XLSX}, MaxSize: 10 * MB},
+	"Lookbook":  {Formats: []Format{PDF, PPTX}, MaxSize: 250 * MB},
+}
+
+// RuleFor returns what can be uploaded as the document type
+func RuleFor(fileType string) (TypeRule, error) {
+	rule, ok := typeRules[fileType]
+	if !ok {
+		return TypeRule{}, ErrUnknownFileType
+	}
+	return rule, nil
+}
+
+// Validate checks an upload of the size against the rule and works out its format from its
+// content, which has to agree with the extension of its name
+func (r TypeRule) Validate(fileType, fileName string, file io.ReaderAt, size int64) (*Format, error) {
+	err := r.Check(fileType, fileName, size)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := DetectFormat(file, size, fileName)
+	if errors.Is(err, ErrUnrecognisedFormat) {
+		return nil, &FormatError{FileType: fileType, Allowed: r.Formats}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, allowed := range r.Formats {
+		if allowed.Name == f.Name {
+			return f, nil
+		}
+	}
+
+	return nil, &FormatError{FileType: fileType, Allowed: r.Formats}
+}
+
+// Check is what can be validated before the file arrives, going by its size and the extension of its name
+func (r TypeRule) Check(fileType, fileName string, size int64) error {
+	if size > r.MaxSize {
+		return &TooLargeError{FileType: fileType, MaxSize: r.MaxSize}
+	}
+
+	ext := strings.ToLower(filepath.Ext(fileName))
+	for _, f := range r.Formats {
+		if slices.Contains(f.Extensions, ext) {
+			return nil
+		}
+	}
+
+	return &FormatError{FileType: fileType, Allowed: r.Formats}
+}
+
+// DetectFormat sniffs the file's format from its first bytes, and for the Office formats the
+// zip's contents, returning ErrUnrecognisedFormat if it isn't one of the formats or doesn't
+// match the extension of its name
+func DetectFormat(file io.ReaderAt, size int64, fileName string) (*Format, error) {
+	head := make([]byte, 512)
+	n, err := file.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("error reading upload: %v", err)
+	}
+	head = head[:n]
+
+	candidates := []Format{}
+	switch {
+	case bytes.HasPrefix(head, []byte("%PDF-")):
+		candidates = append(candidates, PDF)
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")):
+		f, ok := officeFormat(file, size)
+		if ok {
+			candidates = append(candidates, f)
+		}
+	case isFinalDraft(head):
+		candidates = append(candidates, FDX)
+	case isPlainText(head):
+		// the same content, so the extension says which was meant
+		candidates = append(candidates, Fountain, Text)
+	}
+
+	ext := strings.ToLower(filepath.Ext(fileName))
+	for _, f := range candidates {
+		for _, e := range f.Extensions {
+			if e == ext {
+				return &f, nil
+			}
+		}
+	}
+
+	return nil, ErrUnrecognisedFormat
+}
+
+// ContentTypeFor is the content type of a stored document, documents uploaded before the type
+// was detected go by their extension and anything else is served as plain bytes
+func ContentTypeFor(d *Document) string {
+	if d.ContentType != "" {
+		return d.ContentType
+	}
+
+	ext := strings.ToLower(filepath.Ext(d.FileName))
+	for _, f := range formats {
+		for _, e := range f.Extensions {
+			if e == ext {
+				return f.ContentType
+			}
+		}
+	}
+
+	return "application/octet-stream"
+}
+
+// docx, xlsx and pptx are all zips, told apart by the part at their heart
+func officeFormat(file io.ReaderAt, size int64) (Format, bool) {
+	z, err := zip.NewReader(file, size)
+	if err != nil {
+		return Format{}, false
+	}
+
+	for _, entry := range z.File {
+		switch entry.Name {
+		case "word/document.xml":
+			return DOCX, true
+		case "xl/workbook.xml":
+			return XLSX, true
+		case "ppt/presentation.xml":
+			return PPTX, true
+		}
+	}
+
+	return Format{}, false
+}
+
+func isFinalDraft(head []byte) bool {
+	head = bytes.TrimPrefix(head, []byte("\xef\xbb\xbf"))
+	return strings.HasPrefix(http.DetectContentType(head), "text/xml") && bytes.Contains(head, []byte("<FinalDraft"))
+}
+
+func isPlainText(head []byte) bool {
+	return len(head) > 0 && strings.HasPrefix(http.DetectContentType(head), "text/plain")
+}
+
+// FormatError is an upload in a format the document type doesn't take
+type FormatError struct {
+	FileType string
+	Allowed  []Format
+}
+
+func (e *FormatError) Error() string {
+	names := make([]string, len(e.Allowed))
+	for i, f := range e.Allowed {
+		names[i] = f.Name
+	}
+
+	list := names[0]
+	if len(names) > 1 {
+		list = strings.Join(names[:len(names)-1], ", ") + " or " + names[len(names)-1]
+	}
+
+	return fmt.Sprintf("Error: a %s has to be a %s file", e.FileType, list)
+}
+
+// TooLargeError is an upload over the document type's size limit
+type TooLargeError struct {
+	FileType string
+	MaxSize  int64
+}
+
+func (e *TooLargeError) Error() string {
+	return fmt.Sprintf("Error: a %s can be up to %dMB", e.FileType, e.MaxSize/MB)
+}
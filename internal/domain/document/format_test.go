@@ -0,0 +1,102 @@
+package document_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"filmPackager/internal/domain/document"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// a zip holding the named parts, like the Office formats
+func office(t *testing.T, parts ...string) []byte {
+	var buf bytes.Buffer
+	z := zip.NewWriter(&buf)
+	for _, p := range parts {
+		w, err := z.Create(p)
+		assert.NoError(t, err)
+		w.Write([]byte("<xml/>"))
+	}
+	assert.NoError(t, z.Close())
+	return buf.Bytes()
+}
+
+func TestDetectFormat(t *testing.T) {
+	assert := assert.New(t)
+
+	cases := []struct {
+		name     string
+		content  []byte
+		expected string
+	}{
+		{"script.pdf", []byte("%PDF-1.7\n..."), "PDF"},
+		{"SCRIPT.PDF", []byte("%PDF-1.4"), "PDF"},
+		{"script.fdx", []byte("\xef\xbb\xbf<?xml version=\"1.0\"?>\n<FinalDraft DocumentType=\"Script\">"), "FDX"},
+		{"script.fountain", []byte("INT. HOUSE - DAY\n\nSam walks in."), "Fountain"},
+		{"logline.txt", []byte("A director races to finish a film."), "TXT"},
+		{"budget.xlsx", office(t, "[Content_Types].xml", "xl/workbook.xml"), "XLSX"},
+		{"deck.pptx", office(t, "[Content_Types].xml", "ppt/presentation.xml"), "PPTX"},
+		{"synopsis.docx", office(t, "[Content_Types].xml", "word/document.xml"), "DOCX"},
+	}
+	for _, c := range cases {
+		f, err := document.DetectFormat(bytes.NewReader(c.content), int64(len(c.content)), c.name)
+		if assert.NoError(err, c.name) {
+			assert.Equal(c.expected, f.Name, c.name)
+		}
+	}
+
+	// the content has to match the extension, and be one of the formats at all
+	bad := []struct {
+		name    string
+		content []byte
+	}{
+		{"script.pdf", []byte("INT. HOUSE - DAY")},
+		{"budget.pdf", office(t, "xl/workbook.xml")},
+		{"budget.xlsx", office(t, "word/document.xml")},
+		{"archive.xlsx", office(t, "other.txt")},
+		{"page.txt", []byte("<html><script>alert(1)</script></html>")},
+		{"script.fdx", []byte("<?xml version=\"1.0\"?><svg/>")},
+		{"image.txt", []byte("\x89PNG\r\n\x1a\n\x00\x00")},
+		{"empty.txt", []byte{}},
+	}
+	for _, b := range bad {
+		_, err := document.DetectFormat(bytes.NewReader(b.content), int64(len(b.content)), b.name)
+		assert.ErrorIs(err, document.ErrUnrecognisedFormat, b.name)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := document.RuleFor("Poster")
+	assert.ErrorIs(err, document.ErrUnknownFileType)
+
+	rule, err := document.RuleFor("Budget")
+	assert.NoError(err)
+
+	xlsx := office(t, "xl/workbook.xml")
+	f, err := rule.Validate("Budget", "budget.xlsx", bytes.NewReader(xlsx), int64(len(xlsx)))
+	assert.NoError(err)
+	assert.Equal(document.XLSX.ContentType, f.ContentType)
+
+	// a fine file, just not one a budget can be
+	script := []byte("INT. HOUSE - DAY")
+	_, err = rule.Validate("Budget", "budget.fountain", bytes.NewReader(script), int64(len(script)))
+	var formatErr *document.FormatError
+	assert.ErrorAs(err, &formatErr)
+	assert.Equal("Error: a Budget has to be a PDF or XLSX file", err.Error())
+
+	_, err = rule.Validate("Budget", "budget.xlsx", bytes.NewReader(xlsx), rule.MaxSize+1)
+	var tooLarge *document.TooLargeError
+	assert.ErrorAs(err, &tooLarge)
+	assert.Equal("Error: a Budget can be up to 10MB", err.Error())
+}
+
+func TestContentTypeFor(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("application/pdf", document.ContentTypeFor(&document.Document{FileName: "old.pdf"}))
+	assert.Equal("application/octet-stream", document.ContentTypeFor(&document.Document{FileName: "old.exe"}))
+	assert.Equal(document.XLSX.ContentType, document.ContentTypeFor(&document.Document{FileName: "misnamed.pdf", ContentType: document.XLSX.ContentType}))
+}
@@ -2,6 +2,8 @@ package document
 
 import (
 	"context"
+	"io"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/google/uuid"
@@ -14,16 +16,71 @@ type DocumentRepository interface {
 	GetAllByOrgId(ctx context.Context, orgID uuid.UUID) ([]*Document, error)
 	GetDocumentDetails(ctx context.Context, docID uuid.UUID) (*Document, error)
 	FindStagedByOrganization(ctx context.Context, orgID uuid.UUID) ([]*Document, error)
-	UpdateDocument(ctx context.Context, doc *Document) error
+	// ReplaceStaged keeps the staged document as a superseded version and saves the new one in its place
+	ReplaceStaged(ctx context.Context, old *Document, doc *Document) error
 	GetAllLockedDocumentsByProjectID(ctx context.Context, orgID uuid.UUID) ([]*Document, error)
 	DeleteAllLockedByProjectID(ctx context.Context, orgID uuid.UUID) error
 	UpdateAllStagedToLocked(ctx context.Context, orgID uuid.UUID) error
-	DeleteSelectedDocuments(ctx context.Context, dIDs []uuid.UUID) error
+	SupersedeDocuments(ctx context.Context, dIDs []uuid.UUID) error
+	SaveVersion(ctx context.Context, v *Version) error
+	// oldest first
+	GetVersions(ctx context.Context, orgID uuid.UUID, fileType string) ([]Version, error)
+	GetProjectVersions(ctx context.Context, orgID uuid.UUID) ([]Version, error)
+	DeleteVersion(ctx context.Context, docID uuid.UUID) error
+	DeleteProjectVersions(ctx context.Context, orgID uuid.UUID) error
+	// documents the scanner couldn't check yet, oldest first
+	FindPendingScan(ctx context.Context, limit int) ([]*Document, error)
+	UpdateScanStatus(ctx context.Context, docID uuid.UUID, status string) error
+}
+
+// BlobRepository counts the documents using each of a project's stored files, see BlobKey
+type BlobRepository interface {
+	// AddBlobRef counts one more document using the file. When it's the first, the file is new or
+	// was just deleted and upload is called to put it in the bucket, with the blob locked so others
+	// adding it wait until it's there. The reference isn't counted if upload fails
+	AddBlobRef(ctx context.Context, projectID uuid.UUID, checksum string, size int64, upload func() error) error
+	// ReleaseBlobRef counts one less document using the file. When none are left remove is
+	// called to delete it, with the blob locked so it can't be added back part way through
+	ReleaseBlobRef(ctx context.Context, projectID uuid.UUID, checksum string, remove func() error) error
+	DeleteProjectBlobs(ctx context.Context, projectID uuid.UUID) error
+}
+
+type UploadRepository interface {
+	SaveUpload(ctx context.Context, u *Upload) error
+	GetUpload(ctx context.Context, uploadID uuid.UUID) (*Upload, error)
+	DeleteUpload(ctx context.Context, uploadID uuid.UUID) error
+	GetUploadsStartedBefore(ctx context.Context, t time.Time) ([]*Upload, error)
+}
+
+// UploadStore puts uploads together in the bucket from parts the browser sends straight to it
+type UploadStore interface {
+	CreateUpload(ctx context.Context, u *Upload) (string, error)
+	// a URL the part can be PUT to until it expires
+	PresignPart(ctx context.Context, u *Upload, number int32, expires time.Duration) (string, error)
+	// the parts uploaded so far, in order
+	ListParts(ctx context.Context, u *Upload) ([]Part, error)
+	CompleteUpload(ctx context.Context, u *Upload, parts []Part) error
+	AbortUpload(ctx context.Context, u *Upload) error
+	// the finished file, which is removed with DeleteUploaded once it's been stored as a document
+	GetUploaded(ctx context.Context, u *Upload) (io.ReadCloser, error)
+	DeleteUploaded(ctx context.Context, u *Upload) error
 }
 
 type S3Repository interface {
-	UploadFile(ctx context.Context, doc *Document, fileBody interface{}) (string, error)
+	UploadFile(ctx context.Context, doc *Document, fileBody io.Reader) (string, error)
 	DeleteFile(ctx context.Context, doc *Document) error
 	DeleteAllOrgFiles(ctx context.Context, keys []string) error
-	DownloadFile(ctx context.Context, fileName string, ID uuid.UUID) (*s3.GetObjectOutput, error)
+	// a nil range reads the whole file, otherwise ContentRange is set on what's returned
+	DownloadFile(ctx context.Context, doc *Document, rng *ByteRange) (*s3.GetObjectOutput, error)
+	// see ThumbnailKey, ErrNoThumbnail when there isn't one
+	UploadThumbnail(ctx context.Context, doc *Document, png []byte) error
+	DownloadThumbnail(ctx context.Context, doc *Document) ([]byte, error)
+	DeleteThumbnail(ctx context.Context, doc *Document) error
+}
+
+// FileLinker hands out short-lived links straight to a stored file so the bytes don't go
+// through the app. Files the app has to decrypt on the way out can't be linked to
+type FileLinker interface {
+	// disposition is the Content-Disposition the file is sent with, the link works until it expires
+	LinkFile(ctx context.Context, doc *Document, disposition string, expires time.Duration) (string, error)
 }
@@ -0,0 +1,43 @@
+package document
+
+import (
+	"context"
+	"io"
+)
+
+// the malware scan states. A document can only be downloaded, previewed or locked once it's clean
+const (
+	// the scanner couldn't be reached, it's tried again in the background
+	ScanPending     = "pending"
+	ScanClean       = "clean"
+	ScanQuarantined = "quarantined"
+)
+
+// Scanner checks an upload for malware, returning the name of what it found or "" when the file is clean
+type Scanner interface {
+	Scan(ctx context.Context, file io.Reader) (string, error)
+}
+
+// ScanState turns a scan result into the document's scan status
+func ScanState(signature string, err error) string {
+	switch {
+	case err != nil:
+		return ScanPending
+	case signature != "":
+		return ScanQuarantined
+	default:
+		return ScanClean
+	}
+}
+
+// CheckScan is nil when the document has passed the malware scan
+func (d *Document) CheckScan() error {
+	switch d.ScanStatus {
+	case ScanClean:
+		return nil
+	case ScanQuarantined:
+		return ErrQuarantined
+	default:
+		return ErrScanPending
+	}
+}
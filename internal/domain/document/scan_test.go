@@ -0,0 +1,23 @@
+package document_test
+
+import (
+	"errors"
+	"filmPackager/internal/domain/document"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanState(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(document.ScanClean, document.ScanState("", nil))
+	assert.Equal(document.ScanQuarantined, document.ScanState("Win.Test.EICAR_HDB-1", nil))
+	// a scanner that couldn't be reached doesn't let the file through
+	assert.Equal(document.ScanPending, document.ScanState("", errors.New("connection refused")))
+
+	assert.NoError((&document.Document{ScanStatus: document.ScanClean}).CheckScan())
+	assert.ErrorIs((&document.Document{ScanStatus: document.ScanPending}).CheckScan(), document.ErrScanPending)
+	assert.ErrorIs((&document.Document{ScanStatus: document.ScanQuarantined}).CheckScan(), document.ErrQuarantined)
+	assert.ErrorIs((&document.Document{}).CheckScan(), document.ErrScanPending)
+}
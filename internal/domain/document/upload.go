@@ -0,0 +1,79 @@
+package document
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// the size of each part sent to the bucket, S3 wants at least 5MB for all but the last
+const PartSize = 8 * MB
+
+// how long an unfinished upload is kept before it's abandoned
+const UploadTTL = 24 * time.Hour
+
+// Upload is a file sent straight to the bucket in parts, so large files don't pass through
+// the app. It becomes a staged document once every part is there, see DocumentService.CompleteUpload
+type Upload struct {
+	ID        uuid.UUID
+	ProjectID uuid.UUID
+	UserID    uuid.UUID
+	FileName  string
+	FileType  string
+	Size      int64
+	// the bucket's ID for the multipart upload
+	StoreID   string
+	CreatedAt time.Time
+}
+
+// Part is one uploaded part of an Upload
+type Part struct {
+	Number int32
+	ETag   string
+	Size   int64
+}
+
+func NewUpload(projectID, userID uuid.UUID, fileName, fileType string, size int64, now time.Time) *Upload {
+	return &Upload{
+		ID:        uuid.New(),
+		ProjectID: projectID,
+		UserID:    userID,
+		FileName:  fileName,
+		FileType:  fileType,
+		Size:      size,
+		CreatedAt: now,
+	}
+}
+
+// Key is where the parts are put together, away from the stored documents
+func (u *Upload) Key() string {
+	return "incoming/" + u.ID.String()
+}
+
+func (u *Upload) PartCount() int32 {
+	return int32((u.Size + PartSize - 1) / PartSize)
+}
+
+func (u *Upload) IsExpired(now time.Time) bool {
+	return now.Sub(u.CreatedAt) > UploadTTL
+}
+
+// IsComplete checks every part is there at the size it should be
+func (u *Upload) IsComplete(parts []Part) bool {
+	if u.Size == 0 || int32(len(parts)) != u.PartCount() {
+		return false
+	}
+
+	var total int64
+	for i, p := range parts {
+		if p.Number != int32(i+1) {
+			return false
+		}
+		if p.Number < u.PartCount() && p.Size != PartSize {
+			return false
+		}
+		total += p.Size
+	}
+
+	return total == u.Size
+}
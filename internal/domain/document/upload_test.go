@@ -0,0 +1,56 @@
+package document_test
+
+import (
+	"filmPackager/internal/domain/document"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUploadIsComplete(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Now()
+	u := document.NewUpload(uuid.New(), uuid.New(), "lookbook.pdf", "Lookbook", 2*document.PartSize+100, now)
+	assert.Equal(int32(3), u.PartCount())
+	assert.Equal("incoming/"+u.ID.String(), u.Key())
+
+	parts := []document.Part{
+		{Number: 1, Size: document.PartSize},
+		{Number: 2, Size: document.PartSize},
+		{Number: 3, Size: 100},
+	}
+	assert.True(u.IsComplete(parts))
+
+	// a part missing, short, or the wrong size at the end
+	assert.False(u.IsComplete(parts[:2]))
+	assert.False(u.IsComplete([]document.Part{parts[0], parts[2], parts[1]}))
+	assert.False(u.IsComplete([]document.Part{parts[0], {Number: 2, Size: 10}, parts[2]}))
+	assert.False(u.IsComplete([]document.Part{parts[0], parts[1], {Number: 3, Size: 99}}))
+
+	small := document.NewUpload(uuid.New(), uuid.New(), "logline.txt", "Logline", 10, now)
+	assert.Equal(int32(1), small.PartCount())
+	assert.True(small.IsComplete([]document.Part{{Number: 1, Size: 10}}))
+
+	assert.False(u.IsExpired(now.Add(time.Hour)))
+	assert.True(u.IsExpired(now.Add(document.UploadTTL + time.Minute)))
+}
+
+func TestRuleCheck(t *testing.T) {
+	assert := assert.New(t)
+
+	rule, err := document.RuleFor("Lookbook")
+	assert.NoError(err)
+
+	// too big to go through the app, not for the bucket
+	assert.NoError(rule.Check("Lookbook", "Lookbook.PDF", 100*document.MB))
+
+	var tooLarge *document.TooLargeError
+	assert.ErrorAs(rule.Check("Lookbook", "lookbook.pdf", rule.MaxSize+1), &tooLarge)
+
+	var formatErr *document.FormatError
+	assert.ErrorAs(rule.Check("Lookbook", "lookbook.xlsx", 10), &formatErr)
+	assert.ErrorAs(rule.Check("Lookbook", "lookbook", 10), &formatErr)
+}
@@ -0,0 +1,50 @@
+package document
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Version records each document uploaded for a file type of a project, so the
+// comments left on earlier drafts can still be found after they're replaced
+type Version struct {
+	// the document of the version, kept once a newer one replaces it
+	DocID      uuid.UUID
+	ProjectID  uuid.UUID
+	FileType   string
+	Number     int
+	FileName   string
+	UploadedBy uuid.UUID
+	CreatedAt  time.Time
+}
+
+// NewVersion numbers the document after the latest of the existing versions of its file type
+func NewVersion(doc *Document, versions []Version) *Version {
+	number := 1
+	for _, v := range versions {
+		if v.Number >= number {
+			number = v.Number + 1
+		}
+	}
+
+	return &Version{
+		DocID:      doc.ID,
+		ProjectID:  doc.OrganizationID,
+		FileType:   doc.FileType,
+		Number:     number,
+		FileName:   doc.FileName,
+		UploadedBy: doc.UserID,
+		CreatedAt:  time.Now(),
+	}
+}
+
+// FindVersion returns the version recorded for the document, nil for documents uploaded before versions were kept
+func FindVersion(versions []Version, docID uuid.UUID) *Version {
+	for i := range versions {
+		if versions[i].DocID == docID {
+			return &versions[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,8 @@
+package lockout
+
+import "errors"
+
+var (
+	ErrAttemptsNotFound = errors.New("no failed logins found")
+	ErrNotAdmin         = errors.New("only site admins can unlock logins")
+)
@@ -0,0 +1,161 @@
+package lockout
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	KindAccount = "account"
+	KindIP      = "ip"
+)
+
+// Policy is how many failed logins are allowed before sign in is slowed down and then locked
+type Policy struct {
+	// failures allowed before the account or address is locked
+	MaxAccountFailures int
+	MaxIPFailures      int
+	// failures further apart than this start the count again
+	Window          time.Duration
+	LockoutDuration time.Duration
+	// failures allowed before each attempt has to wait, the wait doubles with
+	// every failure after that up to MaxDelay
+	FreeFailures int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+}
+
+// an address is shared by an office or a set, so it gets more failures than an account
+var DefaultPolicy = Policy{
+	MaxAccountFailures: 5,
+	MaxIPFailures:      20,
+	Window:             15 * time.Minute,
+	LockoutDuration:    15 * time.Minute,
+	FreeFailures:       2,
+	BaseDelay:          time.Second,
+	MaxDelay:           30 * time.Second,
+}
+
+// Attempts counts the recent failed logins for an account or an address
+type Attempts struct {
+	// see AccountKey and IPKey
+	Key         string
+	Kind        string
+	Subject     string
+	Failures    int
+	LastFailure time.Time
+	// nil unless the failures reached the policy's limit
+	LockedUntil *time.Time
+}
+
+// accounts are keyed by email so unknown emails are counted the same as real ones
+func AccountKey(email string) string {
+	return KindAccount + ":" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func IPKey(ip string) string {
+	return KindIP + ":" + ip
+}
+
+func NewAttempts(key string) *Attempts {
+	kind, subject, _ := strings.Cut(key, ":")
+	return &Attempts{Key: key, Kind: kind, Subject: subject}
+}
+
+// Fail records a failed login, returning true when it locks the account or address
+func (a *Attempts) Fail(now time.Time, p Policy) bool {
+	if a.isStale(now, p) {
+		a.reset()
+	}
+
+	a.Failures++
+	a.LastFailure = now
+
+	if a.LockedUntil == nil && a.Failures >= a.limit(p) {
+		until := now.Add(p.LockoutDuration)
+		a.LockedUntil = &until
+		return true
+	}
+
+	return false
+}
+
+func (a *Attempts) IsLocked(now time.Time) bool {
+	return a.LockedUntil != nil && now.Before(*a.LockedUntil)
+}
+
+// RetryAfter is how long until the next login can be tried, 0 if it can be tried now
+func (a *Attempts) RetryAfter(now time.Time, p Policy) time.Duration {
+	if a.IsLocked(now) {
+		return a.LockedUntil.Sub(now)
+	}
+	if a.isStale(now, p) {
+		return 0
+	}
+
+	wait := a.LastFailure.Add(p.delay(a.Failures)).Sub(now)
+	if wait < 0 {
+		return 0
+	}
+	return wait
+}
+
+// the count starts again once a lock runs out or the last failure is outside the window
+func (a *Attempts) isStale(now time.Time, p Policy) bool {
+	if a.LockedUntil != nil {
+		return !now.Before(*a.LockedUntil)
+	}
+	return now.Sub(a.LastFailure) > p.Window
+}
+
+func (a *Attempts) reset() {
+	a.Failures = 0
+	a.LockedUntil = nil
+}
+
+func (a *Attempts) limit(p Policy) int {
+	if a.Kind == KindIP {
+		return p.MaxIPFailures
+	}
+	return p.MaxAccountFailures
+}
+
+func (p Policy) delay(failures int) time.Duration {
+	if failures <= p.FreeFailures {
+		return 0
+	}
+
+	d := p.BaseDelay
+	for i := p.FreeFailures + 1; i < failures && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	return min(d, p.MaxDelay)
+}
+
+// ThrottledError is returned while an account or address has to wait before logging in again
+type ThrottledError struct {
+	RetryAfter time.Duration
+	Locked     bool
+}
+
+func (e *ThrottledError) Error() string {
+	if e.Locked {
+		return fmt.Sprintf("Error: too many failed logins, try again in %s", formatWait(e.RetryAfter))
+	}
+	return fmt.Sprintf("Error: please wait %s before trying again", formatWait(e.RetryAfter))
+}
+
+// rounds up so the wait is never shown shorter than it is
+func formatWait(d time.Duration) string {
+	if d > time.Minute {
+		minutes := int((d + time.Minute - 1) / time.Minute)
+		return fmt.Sprintf("%d minutes", minutes)
+	}
+
+	seconds := max(int((d+time.Second-1)/time.Second), 1)
+	if seconds == 1 {
+		return "1 second"
+	}
+	return fmt.Sprintf("%d seconds", seconds)
+}
@@ -0,0 +1,63 @@
+package lockout_test
+
+import (
+	"filmPackager/internal/domain/lockout"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFailLocksAccount(t *testing.T) {
+	assert := assert.New(t)
+	p := lockout.DefaultPolicy
+	now := time.Now()
+
+	a := lockout.NewAttempts(lockout.AccountKey(" Sam@Example.com"))
+	assert.Equal(lockout.KindAccount, a.Kind)
+	assert.Equal("sam@example.com", a.Subject)
+
+	for i := 1; i < p.MaxAccountFailures; i++ {
+		assert.False(a.Fail(now, p))
+	}
+	assert.True(a.Fail(now, p))
+	assert.True(a.IsLocked(now))
+	assert.Equal(p.LockoutDuration, a.RetryAfter(now, p))
+
+	// failing while locked doesn't lock it again
+	assert.False(a.Fail(now, p))
+
+	// once the lock runs out the count starts again
+	later := now.Add(p.LockoutDuration)
+	assert.False(a.IsLocked(later))
+	assert.Zero(a.RetryAfter(later, p))
+	assert.False(a.Fail(later, p))
+	assert.Equal(1, a.Failures)
+}
+
+func TestRetryAfter(t *testing.T) {
+	assert := assert.New(t)
+	p := lockout.DefaultPolicy
+	now := time.Now()
+
+	// addresses get more failures before they're locked
+	a := lockout.NewAttempts(lockout.IPKey("203.0.113.7"))
+	for i := 0; i < p.FreeFailures; i++ {
+		a.Fail(now, p)
+	}
+	assert.Zero(a.RetryAfter(now, p))
+
+	a.Fail(now, p)
+	assert.Equal(p.BaseDelay, a.RetryAfter(now, p))
+	a.Fail(now, p)
+	assert.Equal(2*p.BaseDelay, a.RetryAfter(now, p))
+
+	for i := 0; i < 10; i++ {
+		a.Fail(now, p)
+	}
+	assert.False(a.IsLocked(now))
+	assert.Equal(p.MaxDelay, a.RetryAfter(now, p))
+
+	// failures outside the window are forgotten
+	assert.Zero(a.RetryAfter(now.Add(p.Window+time.Second), p))
+}
@@ -0,0 +1,17 @@
+package lockout
+
+import (
+	"context"
+	"time"
+)
+
+// AttemptRepository keeps the failed logins, in memory for a single instance or in
+// Postgres when instances share them
+type AttemptRepository interface {
+	// returns ErrAttemptsNotFound when there are no failures for the key
+	GetAttempts(ctx context.Context, key string) (*Attempts, error)
+	SaveAttempts(ctx context.Context, a *Attempts) error
+	DeleteAttempts(ctx context.Context, key string) error
+	// the accounts and addresses locked at the time, for unlocking
+	GetLocked(ctx context.Context, now time.Time) ([]Attempts, error)
+}
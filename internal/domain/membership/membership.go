@@ -45,6 +45,10 @@ func SortRoles(rolesSlc []string) []string {
 	return orderedRoles
 }
 
+func (m *Membership) IsOwner() bool {
+	return slices.Contains(m.Roles, "owner")
+}
+
 func (m *Membership) HasLockingStatus() bool {
 	return slices.Contains(m.Roles, "owner") || slices.Contains(m.Roles, "director") || slices.Contains(m.Roles, "producer")
 }
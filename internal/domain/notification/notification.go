@@ -0,0 +1,58 @@
+package notification
+
+import (
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// the kinds of in-app notifications
+const (
+	KindMention = "mention"
+)
+
+// how much of the comment is kept with the notification
+const excerptLength = 140
+
+type Notification struct {
+	ID uuid.UUID
+	// who the notification is for
+	UserID    uuid.UUID
+	ActorID   uuid.UUID
+	Kind      string
+	ProjectID uuid.UUID
+	DocID     uuid.UUID
+	CommentID uuid.UUID
+	Excerpt   string
+	CreatedAt time.Time
+	ReadAt    *time.Time
+	// nil until the email has gone out
+	EmailedAt *time.Time
+}
+
+func NewMention(userID, actorID, projectID, docID, commentID uuid.UUID, text string) *Notification {
+	return &Notification{
+		ID:        uuid.New(),
+		UserID:    userID,
+		ActorID:   actorID,
+		Kind:      KindMention,
+		ProjectID: projectID,
+		DocID:     docID,
+		CommentID: commentID,
+		Excerpt:   excerpt(text),
+		CreatedAt: time.Now(),
+	}
+}
+
+func (n *Notification) IsRead() bool {
+	return n.ReadAt != nil
+}
+
+func excerpt(text string) string {
+	if utf8.RuneCountInString(text) <= excerptLength {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:excerptLength]) + "..."
+}
@@ -0,0 +1,21 @@
+package notification
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type NotificationRepository interface {
+	CreateNotification(ctx context.Context, n *Notification) error
+	GetNotification(ctx context.Context, notificationID uuid.UUID) (*Notification, error)
+	// newest first
+	GetUserNotifications(ctx context.Context, userID uuid.UUID, limit int) ([]Notification, error)
+	CountUnread(ctx context.Context, userID uuid.UUID) (int, error)
+	MarkRead(ctx context.Context, notificationID, userID uuid.UUID, now time.Time) error
+	MarkAllRead(ctx context.Context, userID uuid.UUID, now time.Time) error
+	// oldest first
+	GetUnemailed(ctx context.Context, limit int) ([]Notification, error)
+	MarkEmailed(ctx context.Context, notificationID uuid.UUID, now time.Time) error
+}
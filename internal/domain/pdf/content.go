@@ -0,0 +1,62 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Operation is an operator from a content stream and the operands written before it
+type Operation struct {
+	Operator string
+	Operands []any
+}
+
+// ParseContent splits a content stream into its operations. Inline images are skipped, and a
+// stream that goes wrong part way returns the operations before it along with the error
+func ParseContent(data []byte) ([]Operation, error) {
+	ps := &parser{buf: data, whole: true}
+	ops := []Operation{}
+	operands := []any{}
+
+	for {
+		ps.skipSpace()
+		if ps.pos >= len(ps.buf) {
+			return ops, nil
+		}
+
+		tok, err := ps.token()
+		if err != nil {
+			return ops, err
+		}
+
+		k, ok := tok.(Keyword)
+		if !ok {
+			operands = append(operands, tok)
+			continue
+		}
+
+		if k == "BI" {
+			// the image data is raw bytes, so it's passed over to the EI on its own after it
+			end := bytes.Index(ps.buf[ps.pos:], []byte("ID"))
+			if end < 0 {
+				return ops, fmt.Errorf("inline image without data")
+			}
+			ps.pos += end + 2
+			for {
+				i := bytes.Index(ps.buf[ps.pos:], []byte("EI"))
+				if i < 0 {
+					return ops, fmt.Errorf("inline image without an end")
+				}
+				ps.pos += i + 2
+				if isSpace(ps.buf[ps.pos-3]) && (ps.pos == len(ps.buf) || isSpace(ps.buf[ps.pos]) || isDelimiter(ps.buf[ps.pos])) {
+					break
+				}
+			}
+			operands = []any{}
+			continue
+		}
+
+		ops = append(ops, Operation{Operator: string(k), Operands: operands})
+		operands = []any{}
+	}
+}
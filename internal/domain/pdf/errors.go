@@ -0,0 +1,8 @@
+package pdf
+
+import "errors"
+
+var (
+	// the stream is encoded with something StreamData doesn't decode, RawStreamData still reads it
+	ErrUnsupportedFilter = errors.New("unsupported filter")
+)
@@ -0,0 +1,161 @@
+package pdf
+
+import "fmt"
+
+// Page is a page with what it inherits from the page tree filled in
+type Page struct {
+	Ref  Ref
+	Dict Dict
+	// resolved, nil when the page has none
+	Resources Dict
+	// the crop box, or the media box when there isn't one
+	Box [4]float64
+	// clockwise, 0, 90, 180 or 270
+	Rotate int
+}
+
+// Pages walks the page tree, filling in what each page inherits from the nodes above it
+func (p *Reader) Pages() ([]Page, error) {
+	root, err := p.Resolve(p.trailer["Root"])
+	if err != nil {
+		return nil, err
+	}
+	catalog, ok := root.(Dict)
+	if !ok {
+		return nil, fmt.Errorf("no catalog")
+	}
+
+	top, ok := catalog["Pages"].(Ref)
+	if !ok {
+		return nil, fmt.Errorf("no page tree")
+	}
+
+	pages := []Page{}
+	seen := map[int]bool{}
+	var walk func(r Ref, inherited Dict) error
+	walk = func(r Ref, inherited Dict) error {
+		if seen[r.Num] {
+			return fmt.Errorf("page tree loops back to object %d", r.Num)
+		}
+		seen[r.Num] = true
+
+		obj, err := p.Resolve(r)
+		if err != nil {
+			return err
+		}
+		node, ok := obj.(Dict)
+		if !ok {
+			return fmt.Errorf("page tree node %d isn't a dictionary", r.Num)
+		}
+
+		attrs := Dict{}
+		for k, v := range inherited {
+			attrs[k] = v
+		}
+		for _, k := range []Name{"Resources", "MediaBox", "CropBox", "Rotate"} {
+			if v, ok := node[k]; ok {
+				attrs[k] = v
+			}
+		}
+
+		kids, hasKids := node["Kids"]
+		if node["Type"] == Name("Pages") || node["Type"] == nil && hasKids {
+			kids, err := p.Resolve(kids)
+			if err != nil {
+				return err
+			}
+			list, _ := kids.(Array)
+			for _, kid := range list {
+				k, ok := kid.(Ref)
+				if !ok {
+					continue
+				}
+				err := walk(k, attrs)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		pg := Page{Ref: r, Dict: node, Box: [4]float64{0, 0, 612, 792}}
+
+		res, err := p.Resolve(attrs["Resources"])
+		if err != nil {
+			return err
+		}
+		pg.Resources, _ = res.(Dict)
+
+		box := attrs["CropBox"]
+		if box == nil {
+			box = attrs["MediaBox"]
+		}
+		box, err = p.Resolve(box)
+		if err != nil {
+			return err
+		}
+		if b, ok := box.(Array); ok && len(b) == 4 {
+			for i := range b {
+				v, err := p.Resolve(b[i])
+				if err != nil {
+					return err
+				}
+				pg.Box[i] = Number(v)
+			}
+		}
+
+		rotate, err := p.Resolve(attrs["Rotate"])
+		if err != nil {
+			return err
+		}
+		pg.Rotate = ((int(Number(rotate)) % 360) + 360) % 360 / 90 * 90
+
+		pages = append(pages, pg)
+		return nil
+	}
+
+	return pages, walk(top, Dict{})
+}
+
+// Number is an integer or real as a float64, anything else is 0
+func Number(v any) float64 {
+	switch n := v.(type) {
+	case int64:
+		return float64(n)
+	case float64:
+		return n
+	}
+	return 0
+}
+
+// Contents is the page's content, its streams decoded and joined in order
+func (p *Reader) Contents(pg Page) ([]byte, error) {
+	obj, err := p.Resolve(pg.Dict["Contents"])
+	if err != nil {
+		return nil, err
+	}
+
+	parts := Array{obj}
+	if a, ok := obj.(Array); ok {
+		parts = a
+	}
+
+	var data []byte
+	for _, part := range parts {
+		o, err := p.Resolve(part)
+		if err != nil {
+			return nil, err
+		}
+		s, ok := o.(*Stream)
+		if !ok {
+			continue
+		}
+		d, err := p.StreamData(s)
+		if err != nil {
+			return nil, err
+		}
+		// the streams are split wherever the writer liked, but never part way through a token
+		data = append(append(data, d...), '\n')
+	}
+	return data, nil
+}
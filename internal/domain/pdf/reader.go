@@ -0,0 +1,800 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// Just enough of a PDF reader to find the pages and what they inherit: the objects, the
+// cross-reference tables and streams the file was written and updated with, and the
+// object streams compressed objects are kept in. Streams are read on demand, only Flate
+// is decoded here and anything else is left to whatever understands it
+
+type Name string
+
+type Ref struct {
+	Num, Gen int
+}
+
+type Dict map[Name]any
+
+type Array []any
+
+type Stream struct {
+	Dict Dict
+	// where the encoded data starts in the file
+	Offset int64
+}
+
+// xrefEntry is where an object is: at an offset in the file, or the index-th object of an object stream
+type xrefEntry struct {
+	offset     int64
+	gen        int
+	objStream  int
+	index      int
+	compressed bool
+}
+
+type Reader struct {
+	r          io.ReaderAt
+	size       int64
+	xref       map[int]xrefEntry
+	trailer    Dict
+	objects    map[int]any
+	objStreams map[int]*objStream
+}
+
+type objStream struct {
+	data    []byte
+	offsets []int
+	first   int
+}
+
+// errShort is returned by the parser when the object runs past the bytes it was given
+var errShort = fmt.Errorf("object runs past the buffer")
+
+// NewReader reads the cross-reference sections from the end of the file, objects are read as they're resolved
+func NewReader(r io.ReaderAt, size int64) (*Reader, error) {
+	p := &Reader{r: r, size: size, xref: map[int]xrefEntry{}, objects: map[int]any{}, objStreams: map[int]*objStream{}}
+
+	tail := int64(1024)
+	if tail > size {
+		tail = size
+	}
+	buf := make([]byte, tail)
+	_, err := r.ReadAt(buf, size-tail)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	i := bytes.LastIndex(buf, []byte("startxref"))
+	if i < 0 {
+		return nil, fmt.Errorf("no startxref")
+	}
+	ps := &parser{buf: buf, pos: i + len("startxref"), whole: true}
+	start, err := ps.object()
+	if err != nil {
+		return nil, err
+	}
+	offset, ok := start.(int64)
+	if !ok {
+		return nil, fmt.Errorf("bad startxref")
+	}
+
+	// newest first, so entries already set aren't replaced by older sections
+	seen := map[int64]bool{}
+	for next := offset; next > 0 && !seen[next]; {
+		seen[next] = true
+
+		trailer, err := p.readXref(next)
+		if err != nil {
+			return nil, err
+		}
+		if p.trailer == nil {
+			p.trailer = trailer
+		}
+
+		// a file updated by something that doesn't read xref streams keeps them aside in XRefStm
+		if stm, ok := trailer["XRefStm"].(int64); ok && !seen[stm] {
+			seen[stm] = true
+			_, err := p.readXref(stm)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		next, _ = trailer["Prev"].(int64)
+	}
+
+	return p, nil
+}
+
+// Trailer is the newest trailer, or the cross-reference stream's dictionary
+func (p *Reader) Trailer() Dict {
+	return p.trailer
+}
+
+// Objects are the objects in use, in number order, those in object streams included
+func (p *Reader) Objects() []Ref {
+	refs := []Ref{}
+	for num, e := range p.xref {
+		if num > 0 && (e.offset >= 0 || e.compressed) {
+			refs = append(refs, Ref{Num: num, Gen: e.gen})
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].Num < refs[j].Num })
+	return refs
+}
+
+// NextObject is the first object number not in use
+func (p *Reader) NextObject() int {
+	next, _ := p.trailer["Size"].(int64)
+	for num := range p.xref {
+		if int64(num) >= next {
+			next = int64(num) + 1
+		}
+	}
+	return int(next)
+}
+
+// readXref reads the cross-reference section at the offset, a table or a stream
+func (p *Reader) readXref(offset int64) (Dict, error) {
+	head := make([]byte, 4)
+	_, err := p.r.ReadAt(head, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	if string(head) != "xref" {
+		obj, err := p.readObjectAt(offset)
+		if err != nil {
+			return nil, err
+		}
+		s, ok := obj.(*Stream)
+		if !ok || s.Dict["Type"] != Name("XRef") {
+			return nil, fmt.Errorf("no cross-reference section at %d", offset)
+		}
+		return s.Dict, p.readXrefStream(s)
+	}
+
+	trailer, err := p.withBuffer(offset, func(ps *parser) (Dict, error) {
+		ps.pos = 4
+		entries := map[int]xrefEntry{}
+		for {
+			tok, err := ps.object()
+			if err != nil {
+				return nil, err
+			}
+			if tok == Keyword("trailer") {
+				break
+			}
+			start, ok := tok.(int64)
+			if !ok {
+				return nil, fmt.Errorf("bad xref subsection")
+			}
+			countTok, err := ps.object()
+			if err != nil {
+				return nil, err
+			}
+			count, ok := countTok.(int64)
+			if !ok {
+				return nil, fmt.Errorf("bad xref subsection")
+			}
+
+			for n := 0; n < int(count); n++ {
+				off, err1 := ps.object()
+				gen, err2 := ps.object()
+				kind, err3 := ps.object()
+				if err1 != nil || err2 != nil || err3 != nil {
+					return nil, errShort
+				}
+				o, _ := off.(int64)
+				g, _ := gen.(int64)
+				if kind == Keyword("n") {
+					entries[int(start)+n] = xrefEntry{offset: o, gen: int(g)}
+				} else {
+					entries[int(start)+n] = xrefEntry{offset: -1}
+				}
+			}
+		}
+
+		trailer, err := ps.object()
+		if err != nil {
+			return nil, err
+		}
+		d, ok := trailer.(Dict)
+		if !ok {
+			return nil, fmt.Errorf("bad trailer")
+		}
+
+		for num, e := range entries {
+			if _, ok := p.xref[num]; !ok {
+				p.xref[num] = e
+			}
+		}
+		return d, nil
+	})
+	return trailer, err
+}
+
+func (p *Reader) readXrefStream(s *Stream) error {
+	data, err := p.StreamData(s)
+	if err != nil {
+		return err
+	}
+
+	widths, ok := s.Dict["W"].(Array)
+	if !ok || len(widths) != 3 {
+		return fmt.Errorf("bad xref stream widths")
+	}
+	w := make([]int, 3)
+	for i := range widths {
+		n, _ := widths[i].(int64)
+		w[i] = int(n)
+	}
+
+	index := Array{int64(0), s.Dict["Size"]}
+	if idx, ok := s.Dict["Index"].(Array); ok {
+		index = idx
+	}
+
+	row := w[0] + w[1] + w[2]
+	pos := 0
+	for i := 0; i+1 < len(index); i += 2 {
+		start, _ := index[i].(int64)
+		count, _ := index[i+1].(int64)
+		for n := 0; n < int(count); n++ {
+			if pos+row > len(data) {
+				return fmt.Errorf("xref stream is short")
+			}
+			kind := int64(1)
+			if w[0] > 0 {
+				kind = field(data[pos : pos+w[0]])
+			}
+			f2 := field(data[pos+w[0] : pos+w[0]+w[1]])
+			f3 := field(data[pos+w[0]+w[1] : pos+row])
+			pos += row
+
+			num := int(start) + n
+			if _, ok := p.xref[num]; ok {
+				continue
+			}
+			switch kind {
+			case 1:
+				p.xref[num] = xrefEntry{offset: f2, gen: int(f3)}
+			case 2:
+				p.xref[num] = xrefEntry{objStream: int(f2), index: int(f3), compressed: true}
+			default:
+				p.xref[num] = xrefEntry{offset: -1}
+			}
+		}
+	}
+
+	return nil
+}
+
+func field(b []byte) int64 {
+	var n int64
+	for _, c := range b {
+		n = n<<8 | int64(c)
+	}
+	return n
+}
+
+// withBuffer parses from the offset, reading more of the file each time the parser runs out
+func (p *Reader) withBuffer(offset int64, parse func(ps *parser) (Dict, error)) (Dict, error) {
+	for n := int64(4096); ; n *= 4 {
+		if offset+n > p.size {
+			n = p.size - offset
+		}
+		if n <= 0 {
+			return nil, errShort
+		}
+		buf := make([]byte, n)
+		_, err := p.r.ReadAt(buf, offset)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		d, err := parse(&parser{buf: buf, whole: offset+n == p.size})
+		if err == errShort && offset+n < p.size {
+			continue
+		}
+		return d, err
+	}
+}
+
+// readObjectAt reads the "num gen obj" object at the offset
+func (p *Reader) readObjectAt(offset int64) (any, error) {
+	var obj any
+	_, err := p.withBuffer(offset, func(ps *parser) (Dict, error) {
+		for i := 0; i < 3; i++ {
+			_, err := ps.object()
+			if err != nil {
+				return nil, err
+			}
+		}
+		o, err := ps.object()
+		if err != nil {
+			return nil, err
+		}
+		obj = o
+
+		d, ok := o.(Dict)
+		if !ok {
+			return nil, nil
+		}
+
+		// the stream keyword is followed by a newline, then the data
+		ps.skipSpace()
+		if !bytes.HasPrefix(ps.buf[ps.pos:], []byte("stream")) {
+			if len(ps.buf)-ps.pos < len("stream") && !ps.whole {
+				return nil, errShort
+			}
+			return nil, nil
+		}
+		ps.pos += len("stream")
+		if ps.pos < len(ps.buf) && ps.buf[ps.pos] == '\r' {
+			ps.pos++
+		}
+		if ps.pos < len(ps.buf) && ps.buf[ps.pos] == '\n' {
+			ps.pos++
+		}
+		obj = &Stream{Dict: d, Offset: offset + int64(ps.pos)}
+		return nil, nil
+	})
+	return obj, err
+}
+
+// Resolve follows a reference to the object, anything else is returned as it is
+func (p *Reader) Resolve(obj any) (any, error) {
+	r, ok := obj.(Ref)
+	if !ok {
+		return obj, nil
+	}
+	if o, ok := p.objects[r.Num]; ok {
+		return o, nil
+	}
+
+	e, ok := p.xref[r.Num]
+	if !ok || e.offset < 0 && !e.compressed {
+		// a missing object is null
+		return nil, nil
+	}
+
+	var o any
+	var err error
+	if e.compressed {
+		o, err = p.readCompressed(e)
+	} else {
+		o, err = p.readObjectAt(e.offset)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("object %d: %w", r.Num, err)
+	}
+
+	p.objects[r.Num] = o
+	return o, nil
+}
+
+func (p *Reader) readCompressed(e xrefEntry) (any, error) {
+	objs, ok := p.objStreams[e.objStream]
+	if !ok {
+		obj, err := p.Resolve(Ref{Num: e.objStream})
+		if err != nil {
+			return nil, err
+		}
+		s, ok := obj.(*Stream)
+		if !ok {
+			return nil, fmt.Errorf("object stream %d isn't a stream", e.objStream)
+		}
+		data, err := p.StreamData(s)
+		if err != nil {
+			return nil, err
+		}
+
+		n, _ := s.Dict["N"].(int64)
+		first, _ := s.Dict["First"].(int64)
+		ps := &parser{buf: data, whole: true}
+		objs = &objStream{data: data, first: int(first)}
+		for i := 0; i < int(n); i++ {
+			_, err1 := ps.object()
+			off, err2 := ps.object()
+			if err1 != nil || err2 != nil {
+				return nil, fmt.Errorf("bad object stream header")
+			}
+			o, _ := off.(int64)
+			objs.offsets = append(objs.offsets, int(o))
+		}
+		p.objStreams[e.objStream] = objs
+	}
+
+	if e.index >= len(objs.offsets) || objs.first+objs.offsets[e.index] > len(objs.data) {
+		return nil, fmt.Errorf("object stream index out of range")
+	}
+	ps := &parser{buf: objs.data, pos: objs.first + objs.offsets[e.index], whole: true}
+	return ps.object()
+}
+
+// RawStreamData reads a stream as it's stored, still encoded
+func (p *Reader) RawStreamData(s *Stream) ([]byte, error) {
+	l, err := p.Resolve(s.Dict["Length"])
+	if err != nil {
+		return nil, err
+	}
+	length, ok := l.(int64)
+	if !ok || length < 0 || s.Offset+length > p.size {
+		return nil, fmt.Errorf("bad stream length")
+	}
+
+	data := make([]byte, length)
+	_, err = p.r.ReadAt(data, s.Offset)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return data, nil
+}
+
+// StreamData reads and decodes a stream, Flate with or without the PNG predictors. Any other filter is an ErrUnsupportedFilter
+func (p *Reader) StreamData(s *Stream) ([]byte, error) {
+	data, err := p.RawStreamData(s)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := s.Dict["Filter"]
+	if a, ok := filter.(Array); ok && len(a) == 1 {
+		filter = a[0]
+	}
+	switch filter {
+	case nil:
+		return data, nil
+	case Name("FlateDecode"):
+	default:
+		return nil, fmt.Errorf("%w %v", ErrUnsupportedFilter, filter)
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	data, err = io.ReadAll(zr)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+
+	parms, _ := s.Dict["DecodeParms"].(Dict)
+	if a, ok := s.Dict["DecodeParms"].(Array); ok && len(a) == 1 {
+		parms, _ = a[0].(Dict)
+	}
+	predictor, _ := parms["Predictor"].(int64)
+	if predictor < 10 {
+		return data, nil
+	}
+	columns, ok := parms["Columns"].(int64)
+	if !ok {
+		columns = 1
+	}
+	return unpredict(data, int(columns))
+}
+
+// unpredict undoes the PNG predictors, one filter byte before each row
+func unpredict(data []byte, columns int) ([]byte, error) {
+	row := columns + 1
+	out := make([]byte, 0, len(data)/row*columns)
+	prev := make([]byte, columns)
+	for i := 0; i+row <= len(data); i += row {
+		cur := append([]byte{}, data[i+1:i+row]...)
+		for j := range cur {
+			var left, upLeft byte
+			if j > 0 {
+				left, upLeft = cur[j-1], prev[j-1]
+			}
+			switch data[i] {
+			case 0:
+			case 1:
+				cur[j] += left
+			case 2:
+				cur[j] += prev[j]
+			case 3:
+				cur[j] += byte((int(left) + int(prev[j])) / 2)
+			case 4:
+				cur[j] += paeth(left, prev[j], upLeft)
+			default:
+				return nil, fmt.Errorf("unknown PNG predictor %d", data[i])
+			}
+		}
+		out = append(out, cur...)
+		prev = cur
+	}
+	return out, nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	if pa <= pb && pa <= pc {
+		return a
+	}
+	if pb <= pc {
+		return b
+	}
+	return c
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+type Keyword string
+
+type parser struct {
+	buf []byte
+	pos int
+	// the buffer runs to the end of what's being parsed, rather than being cut off part way
+	whole bool
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isDelimiter(c byte) bool {
+	return bytes.IndexByte([]byte("()<>[]{}/%"), c) >= 0
+}
+
+func (ps *parser) skipSpace() {
+	for ps.pos < len(ps.buf) {
+		c := ps.buf[ps.pos]
+		if c == '%' {
+			for ps.pos < len(ps.buf) && ps.buf[ps.pos] != '\n' && ps.buf[ps.pos] != '\r' {
+				ps.pos++
+			}
+			continue
+		}
+		if !isSpace(c) {
+			return
+		}
+		ps.pos++
+	}
+}
+
+// object reads the next object, a reference when two numbers are followed by R
+func (ps *parser) object() (any, error) {
+	obj, err := ps.token()
+	if err != nil {
+		return nil, err
+	}
+
+	num, ok := obj.(int64)
+	if !ok {
+		return obj, nil
+	}
+
+	save := ps.pos
+	gen, err := ps.token()
+	if err == errShort {
+		return nil, err
+	}
+	if g, ok := gen.(int64); ok && err == nil {
+		r, err := ps.token()
+		if err == errShort {
+			return nil, err
+		}
+		if r == Keyword("R") && err == nil {
+			return Ref{Num: int(num), Gen: int(g)}, nil
+		}
+	}
+	ps.pos = save
+	return num, nil
+}
+
+func (ps *parser) token() (any, error) {
+	ps.skipSpace()
+	if ps.pos >= len(ps.buf) {
+		if ps.whole {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, errShort
+	}
+
+	switch c := ps.buf[ps.pos]; {
+	case c == '/':
+		return ps.name()
+	case c == '(':
+		return ps.literal()
+	case c == '[':
+		ps.pos++
+		a := Array{}
+		for {
+			ps.skipSpace()
+			if ps.pos >= len(ps.buf) {
+				return nil, errShort
+			}
+			if ps.buf[ps.pos] == ']' {
+				ps.pos++
+				return a, nil
+			}
+			o, err := ps.object()
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, o)
+		}
+	case c == '<':
+		if ps.pos+1 < len(ps.buf) && ps.buf[ps.pos+1] == '<' {
+			return ps.dict()
+		}
+		return ps.hex()
+	case c == ']' || c == '>' || c == ')' || c == '{' || c == '}':
+		return nil, fmt.Errorf("unexpected %q", c)
+	}
+
+	start := ps.pos
+	for ps.pos < len(ps.buf) && !isSpace(ps.buf[ps.pos]) && !isDelimiter(ps.buf[ps.pos]) {
+		ps.pos++
+	}
+	// a number or keyword at the very end of the buffer may carry on past it
+	if ps.pos == len(ps.buf) && !ps.whole {
+		return nil, errShort
+	}
+	word := string(ps.buf[start:ps.pos])
+
+	if n, err := strconv.ParseInt(word, 10, 64); err == nil {
+		return n, nil
+	}
+	if f, err := strconv.ParseFloat(word, 64); err == nil {
+		return f, nil
+	}
+	switch word {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	return Keyword(word), nil
+}
+
+func (ps *parser) name() (any, error) {
+	ps.pos++
+	var b []byte
+	for ps.pos < len(ps.buf) && !isSpace(ps.buf[ps.pos]) && !isDelimiter(ps.buf[ps.pos]) {
+		c := ps.buf[ps.pos]
+		if c == '#' && ps.pos+2 < len(ps.buf) {
+			v, err := strconv.ParseUint(string(ps.buf[ps.pos+1:ps.pos+3]), 16, 8)
+			if err == nil {
+				b = append(b, byte(v))
+				ps.pos += 3
+				continue
+			}
+		}
+		b = append(b, c)
+		ps.pos++
+	}
+	if ps.pos == len(ps.buf) && !ps.whole {
+		return nil, errShort
+	}
+	return Name(b), nil
+}
+
+func (ps *parser) literal() (any, error) {
+	ps.pos++
+	var b []byte
+	depth := 1
+	for ps.pos < len(ps.buf) {
+		c := ps.buf[ps.pos]
+		ps.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return b, nil
+			}
+		case '\\':
+			if ps.pos >= len(ps.buf) {
+				return nil, errShort
+			}
+			e := ps.buf[ps.pos]
+			ps.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r', '\n':
+				// a line continuation
+				if e == '\r' && ps.pos < len(ps.buf) && ps.buf[ps.pos] == '\n' {
+					ps.pos++
+				}
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for i := 0; i < 2 && ps.pos < len(ps.buf) && ps.buf[ps.pos] >= '0' && ps.buf[ps.pos] <= '7'; i++ {
+						v = v*8 + int(ps.buf[ps.pos]-'0')
+						ps.pos++
+					}
+					c = byte(v)
+				} else {
+					c = e
+				}
+			}
+		}
+		b = append(b, c)
+	}
+	return nil, errShort
+}
+
+func (ps *parser) hex() (any, error) {
+	ps.pos++
+	var digits []byte
+	for ps.pos < len(ps.buf) {
+		c := ps.buf[ps.pos]
+		ps.pos++
+		if c == '>' {
+			if len(digits)%2 == 1 {
+				digits = append(digits, '0')
+			}
+			b := make([]byte, len(digits)/2)
+			for i := range b {
+				v, err := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+				if err != nil {
+					return nil, fmt.Errorf("bad hex string")
+				}
+				b[i] = byte(v)
+			}
+			return b, nil
+		}
+		if !isSpace(c) {
+			digits = append(digits, c)
+		}
+	}
+	return nil, errShort
+}
+
+func (ps *parser) dict() (any, error) {
+	ps.pos += 2
+	d := Dict{}
+	for {
+		ps.skipSpace()
+		if ps.pos+1 >= len(ps.buf) {
+			return nil, errShort
+		}
+		if ps.buf[ps.pos] == '>' && ps.buf[ps.pos+1] == '>' {
+			ps.pos += 2
+			return d, nil
+		}
+
+		key, err := ps.token()
+		if err != nil {
+			return nil, err
+		}
+		k, ok := key.(Name)
+		if !ok {
+			return nil, fmt.Errorf("dictionary key isn't a name")
+		}
+
+		v, err := ps.object()
+		if err != nil {
+			return nil, err
+		}
+		d[k] = v
+	}
+}
@@ -0,0 +1,60 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// WriteObject writes the object the way the reader reads it back, dictionaries with their keys sorted
+func WriteObject(b *bytes.Buffer, obj any) {
+	switch v := obj.(type) {
+	case nil:
+		b.WriteString("null")
+	case bool:
+		b.WriteString(strconv.FormatBool(v))
+	case int64:
+		b.WriteString(strconv.FormatInt(v, 10))
+	case float64:
+		b.WriteString(strconv.FormatFloat(v, 'f', -1, 64))
+	case Name:
+		b.WriteByte('/')
+		for _, c := range []byte(v) {
+			if c < 0x21 || c > 0x7e || c == '#' || isDelimiter(c) {
+				fmt.Fprintf(b, "#%02x", c)
+				continue
+			}
+			b.WriteByte(c)
+		}
+	case []byte:
+		fmt.Fprintf(b, "<%x>", v)
+	case Ref:
+		fmt.Fprintf(b, "%d %d R", v.Num, v.Gen)
+	case Keyword:
+		b.WriteString(string(v))
+	case Array:
+		b.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			WriteObject(b, item)
+		}
+		b.WriteByte(']')
+	case Dict:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, string(k))
+		}
+		sort.Strings(keys)
+
+		b.WriteString("<<")
+		for _, k := range keys {
+			WriteObject(b, Name(k))
+			b.WriteByte(' ')
+			WriteObject(b, v[Name(k)])
+		}
+		b.WriteString(">>")
+	}
+}
@@ -5,3 +5,6 @@ import "errors"
 var ErrMemberNotFound = errors.New("member not found")
 var ErrMemberAlreadyExists = errors.New("member already exists")
 var ErrMemberAlreadyInvited = errors.New("member already invited")
+var ErrTwoFactorRequired = errors.New("this project requires two-factor authentication")
+var ErrOwnerNeedsTwoFactor = errors.New("Error: turn on two-factor authentication for your own account before requiring it")
+var ErrNotOwner = errors.New("only the project owner can change this")
@@ -12,6 +12,8 @@ type Project struct {
 	CreatedAt    time.Time
 	OwnerID      uuid.UUID
 	LastUpdateAt time.Time
+	// members have to have two-factor authentication on to open the project
+	RequireTwoFactor bool
 }
 
 type ProjectOverview struct {
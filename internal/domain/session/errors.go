@@ -0,0 +1,8 @@
+package session
+
+import "errors"
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	ErrSessionExpired  = errors.New("session has expired or been logged out")
+)
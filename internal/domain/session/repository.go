@@ -0,0 +1,21 @@
+package session
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SessionRepository interface {
+	CreateSession(ctx context.Context, s *Session) error
+	GetSession(ctx context.Context, id uuid.UUID) (*Session, error)
+	// updates when and where the session was last used
+	TouchSession(ctx context.Context, id uuid.UUID, ip string, at time.Time) error
+	// the user's sessions that haven't expired or been revoked, most recently used first
+	GetActiveSessions(ctx context.Context, userID uuid.UUID, now time.Time) ([]Session, error)
+	// only revokes the session if it belongs to the user
+	RevokeSession(ctx context.Context, userID, id uuid.UUID, at time.Time) error
+	// revokes all of the user's sessions except keep, uuid.Nil keeps none
+	RevokeUserSessions(ctx context.Context, userID, keep uuid.UUID, at time.Time) error
+}
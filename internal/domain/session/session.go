@@ -0,0 +1,100 @@
+package session
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// how long a login lasts, the cookie and the token expire with it
+const Duration = 48 * time.Hour
+
+// last seen is only written this often, rather than on every request
+const TouchInterval = time.Minute
+
+// Client is the browser or script a session was started from
+type Client struct {
+	IP        string
+	UserAgent string
+}
+
+// Session is a login, its ID is the token's jti so revoking the session logs the token out
+type Session struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	UserAgent  string
+	IP         string
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+	ExpiresAt  time.Time
+	// nil until the session is logged out or revoked
+	RevokedAt *time.Time
+}
+
+func NewSession(userID uuid.UUID, client Client, now time.Time) *Session {
+	return &Session{
+		ID:         uuid.New(),
+		UserID:     userID,
+		UserAgent:  client.UserAgent,
+		IP:         client.IP,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(Duration),
+	}
+}
+
+func (s *Session) IsActive(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+// NeedsTouch reports whether last seen is stale enough to be written again
+func (s *Session) NeedsTouch(now time.Time, ip string) bool {
+	return now.Sub(s.LastSeenAt) >= TouchInterval || s.IP != ip
+}
+
+// Device describes the session's browser and operating system, like "Firefox on macOS"
+func (s *Session) Device() string {
+	return Device(s.UserAgent)
+}
+
+// Device gives a short description of a user agent, the full string is kept on the session
+func Device(userAgent string) string {
+	if userAgent == "" {
+		return "Unknown device"
+	}
+
+	browser := firstMatch(userAgent, [][2]string{
+		// Edge and Opera mention Chrome, and Chrome mentions Safari, so they're checked first
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"curl/", "curl"},
+	}, "Unknown browser")
+
+	os := firstMatch(userAgent, [][2]string{
+		{"iPhone", "iPhone"},
+		{"iPad", "iPad"},
+		{"Android", "Android"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"CrOS", "ChromeOS"},
+		{"Linux", "Linux"},
+	}, "")
+
+	if os == "" {
+		return browser
+	}
+	return browser + " on " + os
+}
+
+func firstMatch(userAgent string, names [][2]string, otherwise string) string {
+	for _, n := range names {
+		if strings.Contains(userAgent, n[0]) {
+			return n[1]
+		}
+	}
+	return otherwise
+}
@@ -0,0 +1,38 @@
+package session_test
+
+import (
+	"filmPackager/internal/domain/session"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionIsActive(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+
+	s := session.NewSession(uuid.New(), session.Client{IP: "203.0.113.7"}, now)
+	assert.True(s.IsActive(now))
+	assert.False(s.IsActive(now.Add(session.Duration)))
+
+	// last seen is only written once a minute, or when the address changes
+	assert.False(s.NeedsTouch(now.Add(time.Second), "203.0.113.7"))
+	assert.True(s.NeedsTouch(now.Add(time.Second), "198.51.100.2"))
+	assert.True(s.NeedsTouch(now.Add(session.TouchInterval), "203.0.113.7"))
+
+	revoked := now.Add(time.Minute)
+	s.RevokedAt = &revoked
+	assert.False(s.IsActive(now))
+}
+
+func TestDevice(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("Chrome on macOS", session.Device("Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.0.0 Safari/537.36"))
+	assert.Equal("Edge on Windows", session.Device("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.0.0 Safari/537.36 Edg/129.0.0.0"))
+	assert.Equal("Safari on iPhone", session.Device("Mozilla/5.0 (iPhone; CPU iPhone OS 17_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.6 Mobile/15E148 Safari/604.1"))
+	assert.Equal("curl", session.Device("curl/8.7.1"))
+	assert.Equal("Unknown device", session.Device(""))
+}
@@ -0,0 +1,8 @@
+package thumbnail
+
+import "errors"
+
+var (
+	// an encrypted PDF's content can't be read without its password
+	ErrEncryptedPDF = errors.New("encrypted PDFs can't be previewed")
+)
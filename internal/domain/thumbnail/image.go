@@ -0,0 +1,136 @@
+package thumbnail
+
+import (
+	"bytes"
+	"filmPackager/internal/domain/pdf"
+	"image"
+	"image/color"
+	"image/jpeg"
+)
+
+// decodeImage reads JPEGs and 8-bit gray, RGB, CMYK and palette images, which covers the photos
+// and scans on a first page. Anything else is nil and drawn as a placeholder
+func decodeImage(rd *renderer, s *pdf.Stream) image.Image {
+	filter := rd.resolve(s.Dict["Filter"])
+	if a, ok := filter.(pdf.Array); ok && len(a) == 1 {
+		filter = a[0]
+	}
+
+	if filter == pdf.Name("DCTDecode") {
+		data, err := rd.p.RawStreamData(s)
+		if err != nil {
+			return nil
+		}
+		cfg, err := jpeg.DecodeConfig(bytes.NewReader(data))
+		if err != nil || cfg.Width*cfg.Height > maxImagePixels {
+			return nil
+		}
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil
+		}
+		return img
+	}
+
+	width := int(pdf.Number(rd.resolve(s.Dict["Width"])))
+	height := int(pdf.Number(rd.resolve(s.Dict["Height"])))
+	bits := pdf.Number(rd.resolve(s.Dict["BitsPerComponent"]))
+	if width <= 0 || height <= 0 || width*height > maxImagePixels || bits != 8 {
+		return nil
+	}
+
+	space, ok := rd.colorSpace(rd.resolve(s.Dict["ColorSpace"]))
+	if !ok {
+		return nil
+	}
+
+	data, err := rd.p.StreamData(s)
+	if err != nil || len(data) < width*height*space.components {
+		return nil
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := (y*width + x) * space.components
+			img.SetRGBA(x, y, space.color(data[i:i+space.components]))
+		}
+	}
+	return img
+}
+
+type colorSpace struct {
+	components int
+	color      func(b []byte) color.RGBA
+}
+
+func (rd *renderer) colorSpace(obj any) (colorSpace, bool) {
+	scale := func(b []byte) []float64 {
+		n := make([]float64, len(b))
+		for i := range b {
+			n[i] = float64(b[i]) / 255
+		}
+		return n
+	}
+	device := func(n int) colorSpace {
+		return colorSpace{components: n, color: func(b []byte) color.RGBA { return colorOf(scale(b)) }}
+	}
+
+	switch v := obj.(type) {
+	case pdf.Name:
+		switch v {
+		case "DeviceGray", "CalGray", "G":
+			return device(1), true
+		case "DeviceRGB", "CalRGB", "RGB":
+			return device(3), true
+		case "DeviceCMYK", "CMYK":
+			return device(4), true
+		}
+
+	case pdf.Array:
+		if len(v) < 2 {
+			return colorSpace{}, false
+		}
+		switch v[0] {
+		case pdf.Name("ICCBased"):
+			// the profile is only to get the colors exactly right, its component count is enough here
+			profile, ok := rd.resolve(v[1]).(*pdf.Stream)
+			if !ok {
+				return colorSpace{}, false
+			}
+			n := int(pdf.Number(rd.resolve(profile.Dict["N"])))
+			if n != 1 && n != 3 && n != 4 {
+				return colorSpace{}, false
+			}
+			return device(n), true
+
+		case pdf.Name("CalGray"), pdf.Name("CalRGB"):
+			return rd.colorSpace(v[0])
+
+		case pdf.Name("Indexed"):
+			if len(v) != 4 {
+				return colorSpace{}, false
+			}
+			base, ok := rd.colorSpace(rd.resolve(v[1]))
+			if !ok {
+				return colorSpace{}, false
+			}
+			var lookup []byte
+			switch l := rd.resolve(v[3]).(type) {
+			case []byte:
+				lookup = l
+			case *pdf.Stream:
+				lookup, _ = rd.p.StreamData(l)
+			}
+			return colorSpace{components: 1, color: func(b []byte) color.RGBA {
+				i := int(b[0]) * base.components
+				if i+base.components > len(lookup) {
+					return black
+				}
+				return base.color(lookup[i : i+base.components])
+			}}, true
+		}
+	}
+
+	return colorSpace{}, false
+}
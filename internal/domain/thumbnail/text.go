@@ -0,0 +1,261 @@
+package thumbnail
+
+import (
+	"filmPackager/internal/domain/pdf"
+	"image/color"
+	"math"
+	"strings"
+)
+
+type textState struct {
+	// the text matrix and the start of the line it's on
+	tm, tlm     matrix
+	font        *font
+	size        float64
+	leading     float64
+	charSpacing float64
+	wordSpacing float64
+	// horizontal scaling, 1 is as the font draws it
+	scale float64
+	rise  float64
+	// 3 and 7 draw nothing, as scanned pages use for the text recognised under the image
+	mode int
+}
+
+func newTextState() textState {
+	return textState{tm: identity, tlm: identity, font: &font{missing: 500}, scale: 1}
+}
+
+// font is only what's needed to know where the words end
+type font struct {
+	twoByte bool
+	// in thousandths of the font size
+	widths  map[int]float64
+	missing float64
+}
+
+func (f *font) width(code int) float64 {
+	if w, ok := f.widths[code]; ok && w > 0 {
+		return w
+	}
+	return f.missing
+}
+
+func (rd *renderer) textOperator(op pdf.Operation, n []float64, resources pdf.Dict) {
+	t := &rd.state.text
+	switch op.Operator {
+	case "BT":
+		t.tm, t.tlm = identity, identity
+	case "Tf":
+		if len(op.Operands) == 2 {
+			name, _ := op.Operands[0].(pdf.Name)
+			t.font = rd.font(resources, name)
+			t.size = pdf.Number(op.Operands[1])
+		}
+	case "Tc":
+		if len(n) == 1 {
+			t.charSpacing = n[0]
+		}
+	case "Tw":
+		if len(n) == 1 {
+			t.wordSpacing = n[0]
+		}
+	case "Tz":
+		if len(n) == 1 {
+			t.scale = n[0] / 100
+		}
+	case "TL":
+		if len(n) == 1 {
+			t.leading = n[0]
+		}
+	case "Ts":
+		if len(n) == 1 {
+			t.rise = n[0]
+		}
+	case "Tr":
+		if len(n) == 1 {
+			t.mode = int(n[0])
+		}
+	case "Td", "TD":
+		if len(n) == 2 {
+			if op.Operator == "TD" {
+				t.leading = -n[1]
+			}
+			t.tlm = matrix{1, 0, 0, 1, n[0], n[1]}.times(t.tlm)
+			t.tm = t.tlm
+		}
+	case "Tm":
+		if len(n) == 6 {
+			t.tlm = matrix(n)
+			t.tm = t.tlm
+		}
+	case "T*":
+		rd.nextLine()
+	case "Tj":
+		if len(op.Operands) == 1 {
+			s, _ := op.Operands[0].([]byte)
+			rd.show(s)
+		}
+	case "'":
+		rd.nextLine()
+		if len(op.Operands) == 1 {
+			s, _ := op.Operands[0].([]byte)
+			rd.show(s)
+		}
+	case "\"":
+		if len(op.Operands) == 3 {
+			t.wordSpacing = pdf.Number(op.Operands[0])
+			t.charSpacing = pdf.Number(op.Operands[1])
+			rd.nextLine()
+			s, _ := op.Operands[2].([]byte)
+			rd.show(s)
+		}
+	case "TJ":
+		if len(op.Operands) == 1 {
+			a, _ := op.Operands[0].(pdf.Array)
+			for _, item := range a {
+				switch v := item.(type) {
+				case []byte:
+					rd.show(v)
+				case int64, float64:
+					// a kern, moving back by thousandths of the font size
+					rd.advance(-pdf.Number(v) / 1000 * t.size * t.scale)
+				}
+			}
+		}
+	}
+}
+
+func (rd *renderer) nextLine() {
+	t := &rd.state.text
+	t.tlm = matrix{1, 0, 0, 1, 0, -t.leading}.times(t.tlm)
+	t.tm = t.tlm
+}
+
+func (rd *renderer) advance(tx float64) {
+	t := &rd.state.text
+	t.tm = matrix{1, 0, 0, 1, tx, 0}.times(t.tm)
+}
+
+// show draws a bar under each word of the string, from the baseline to about the height of a lowercase letter
+func (rd *renderer) show(s []byte) {
+	t := &rd.state.text
+	visible := t.mode != 3 && t.mode != 7
+	c := mix(rd.state.fill, white, 0.45)
+	if t.mode == 1 || t.mode == 5 {
+		c = mix(rd.state.stroke, white, 0.45)
+	}
+
+	x, wordStart := 0.0, -1.0
+	draw := func(end float64) {
+		if !visible || wordStart < 0 || end <= wordStart {
+			return
+		}
+		m := t.tm.times(rd.toDevice())
+		y0, y1 := t.rise, t.rise+0.5*t.size
+		rd.fill([][]point{{m.apply(wordStart, y0), m.apply(end, y0), m.apply(end, y1), m.apply(wordStart, y1)}}, c, false)
+	}
+
+	step := 1
+	if t.font.twoByte {
+		step = 2
+	}
+	for i := 0; i+step <= len(s); i += step {
+		code := int(s[i])
+		if step == 2 {
+			code = code<<8 | int(s[i+1])
+		}
+
+		w := t.font.width(code)/1000*t.size + t.charSpacing
+		// word spacing only applies to a single byte space
+		space := step == 1 && code == ' '
+		if space {
+			w += t.wordSpacing
+			draw(x)
+			wordStart = -1
+		} else if wordStart < 0 {
+			wordStart = x
+		}
+		x += w * t.scale
+	}
+	draw(x)
+	rd.advance(x)
+}
+
+func mix(a, b color.RGBA, amount float64) color.RGBA {
+	m := func(x, y uint8) uint8 {
+		return uint8(math.Round(float64(x)*(1-amount) + float64(y)*amount))
+	}
+	return color.RGBA{m(a.R, b.R), m(a.G, b.G), m(a.B, b.B), 255}
+}
+
+// font reads the widths of the font the resources name. Fonts without them are taken as the
+// average width of a letter, except Courier, which every screenplay is written in
+func (rd *renderer) font(resources pdf.Dict, name pdf.Name) *font {
+	fonts, _ := rd.resolve(resources["Font"]).(pdf.Dict)
+	r, isRef := fonts[name].(pdf.Ref)
+	if f, ok := rd.fonts[r]; isRef && ok {
+		return f
+	}
+
+	f := &font{widths: map[int]float64{}, missing: 500}
+	d, _ := rd.resolve(fonts[name]).(pdf.Dict)
+	if base, ok := d["BaseFont"].(pdf.Name); ok && strings.Contains(string(base), "Courier") {
+		f.missing = 600
+	}
+
+	switch d["Subtype"] {
+	case pdf.Name("Type0"):
+		// CID fonts, which are two bytes a glyph with the Identity encodings nearly all of them use
+		f.twoByte = true
+		f.missing = 1000
+		descendants, _ := rd.resolve(d["DescendantFonts"]).(pdf.Array)
+		if len(descendants) > 0 {
+			cid, _ := rd.resolve(descendants[0]).(pdf.Dict)
+			if dw, ok := rd.resolve(cid["DW"]).(int64); ok {
+				f.missing = float64(dw)
+			}
+			w, _ := rd.resolve(cid["W"]).(pdf.Array)
+			rd.cidWidths(f, w)
+		}
+
+	default:
+		first := int(pdf.Number(rd.resolve(d["FirstChar"])))
+		widths, _ := rd.resolve(d["Widths"]).(pdf.Array)
+		// Type3 glyphs are drawn in their own units, the font matrix scales them to text space
+		unit := 1.0
+		if fm, ok := rd.resolve(d["FontMatrix"]).(pdf.Array); ok && len(fm) == 6 {
+			unit = pdf.Number(fm[0]) * 1000
+		}
+		for i, w := range widths {
+			f.widths[first+i] = pdf.Number(rd.resolve(w)) * unit
+		}
+	}
+
+	if isRef {
+		rd.fonts[r] = f
+	}
+	return f
+}
+
+// cidWidths reads the W array, runs of "first [w1 w2 ...]" and "first last w"
+func (rd *renderer) cidWidths(f *font, w pdf.Array) {
+	for i := 0; i+1 < len(w); {
+		first := int(pdf.Number(w[i]))
+		if list, ok := rd.resolve(w[i+1]).(pdf.Array); ok {
+			for j, v := range list {
+				f.widths[first+j] = pdf.Number(v)
+			}
+			i += 2
+			continue
+		}
+		if i+2 >= len(w) {
+			return
+		}
+		last := int(pdf.Number(w[i+1]))
+		for code := first; code <= last && code-first < 65536; code++ {
+			f.widths[code] = pdf.Number(w[i+2])
+		}
+		i += 3
+	}
+}
@@ -0,0 +1,524 @@
+package thumbnail
+
+import (
+	"bytes"
+	"filmPackager/internal/domain/pdf"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+)
+
+// A thumbnail is a sketch of the first page rather than a faithful rendering: the shapes and
+// images are drawn, but text is drawn as grey bars where its words are, as drawing the glyphs
+// would mean reading every kind of font a PDF can embed. At thumbnail size the layout is
+// what tells a script from a budget or a pitch deck, and nothing on the page can be read off it
+
+// Width of a thumbnail in pixels, the height follows the shape of the page
+const Width = 240
+
+// how many operations are drawn, so a page made of millions of them can't hold up the worker
+const maxOperations = 200000
+
+// forms drawn inside forms are only followed so far, in case they loop
+const maxFormDepth = 8
+
+// images bigger than this are drawn as a placeholder rather than decoded
+const maxImagePixels = 25_000_000
+
+var (
+	white       = color.RGBA{255, 255, 255, 255}
+	black       = color.RGBA{0, 0, 0, 255}
+	placeholder = color.RGBA{208, 208, 208, 255}
+)
+
+// Render draws the first page of the PDF as a PNG
+func Render(r io.ReaderAt, size int64) ([]byte, error) {
+	p, err := pdf.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := p.Trailer()["Encrypt"]; ok {
+		return nil, ErrEncryptedPDF
+	}
+
+	pages, err := p.Pages()
+	if err != nil {
+		return nil, err
+	}
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("no pages")
+	}
+	pg := pages[0]
+
+	content, err := p.Contents(pg)
+	if err != nil {
+		return nil, err
+	}
+
+	rd := newRenderer(p, pg)
+	ops, _ := pdf.ParseContent(content)
+	rd.run(ops, pg.Resources, 0)
+
+	var b bytes.Buffer
+	err = png.Encode(&b, rd.img)
+	if err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// matrix is a PDF transformation, [a b c d e f]
+type matrix [6]float64
+
+var identity = matrix{1, 0, 0, 1, 0, 0}
+
+// times is m then n
+func (m matrix) times(n matrix) matrix {
+	return matrix{
+		m[0]*n[0] + m[1]*n[2], m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2], m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4], m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+func (m matrix) apply(x, y float64) point {
+	return point{m[0]*x + m[2]*y + m[4], m[1]*x + m[3]*y + m[5]}
+}
+
+func (m matrix) invert() (matrix, bool) {
+	det := m[0]*m[3] - m[1]*m[2]
+	if math.Abs(det) < 1e-12 {
+		return matrix{}, false
+	}
+	return matrix{
+		m[3] / det, -m[1] / det,
+		-m[2] / det, m[0] / det,
+		(m[2]*m[5] - m[3]*m[4]) / det, (m[1]*m[4] - m[0]*m[5]) / det,
+	}, true
+}
+
+// scale is how much longer a line gets, on average
+func (m matrix) scale() float64 {
+	return math.Sqrt(math.Abs(m[0]*m[3] - m[1]*m[2]))
+}
+
+type point struct {
+	x, y float64
+}
+
+type state struct {
+	ctm          matrix
+	fill, stroke color.RGBA
+	lineWidth    float64
+	text         textState
+}
+
+type renderer struct {
+	p   *pdf.Reader
+	img *image.RGBA
+	// from the page's space to the image's, which runs down from the top left
+	device matrix
+	state  state
+	stack  []state
+	path   [][]point
+	fonts  map[pdf.Ref]*font
+	left   int
+}
+
+func newRenderer(p *pdf.Reader, pg pdf.Page) *renderer {
+	llx, lly := math.Min(pg.Box[0], pg.Box[2]), math.Min(pg.Box[1], pg.Box[3])
+	urx, ury := math.Max(pg.Box[0], pg.Box[2]), math.Max(pg.Box[1], pg.Box[3])
+	w, h := urx-llx, ury-lly
+	if w <= 0 || h <= 0 {
+		llx, lly, urx, ury, w, h = 0, 0, 612, 792, 612, 792
+	}
+
+	// the page is drawn the way it's shown, turned clockwise by its rotation
+	vw, vh := w, h
+	if pg.Rotate == 90 || pg.Rotate == 270 {
+		vw, vh = h, w
+	}
+	s := Width / vw
+	height := int(math.Round(vh * s))
+	height = max(1, min(height, 4*Width))
+
+	var device matrix
+	switch pg.Rotate {
+	case 90:
+		device = matrix{0, s, s, 0, -lly * s, -llx * s}
+	case 180:
+		device = matrix{-s, 0, 0, s, urx * s, -lly * s}
+	case 270:
+		device = matrix{0, -s, -s, 0, ury * s, urx * s}
+	default:
+		device = matrix{s, 0, 0, -s, -llx * s, ury * s}
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, Width, height))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+
+	return &renderer{
+		p:      p,
+		img:    img,
+		device: device,
+		state:  state{ctm: identity, fill: black, stroke: black, lineWidth: 1, text: newTextState()},
+		fonts:  map[pdf.Ref]*font{},
+		left:   maxOperations,
+	}
+}
+
+// toDevice is from the current user space to the image
+func (rd *renderer) toDevice() matrix {
+	return rd.state.ctm.times(rd.device)
+}
+
+func (rd *renderer) run(ops []pdf.Operation, resources pdf.Dict, depth int) {
+	for _, op := range ops {
+		if rd.left <= 0 {
+			return
+		}
+		rd.left--
+
+		n := numbers(op.Operands)
+		switch op.Operator {
+		case "q":
+			rd.stack = append(rd.stack, rd.state)
+		case "Q":
+			if len(rd.stack) > 0 {
+				rd.state = rd.stack[len(rd.stack)-1]
+				rd.stack = rd.stack[:len(rd.stack)-1]
+			}
+		case "cm":
+			if len(n) == 6 {
+				rd.state.ctm = matrix(n).times(rd.state.ctm)
+			}
+		case "w":
+			if len(n) == 1 {
+				rd.state.lineWidth = n[0]
+			}
+
+		case "g", "rg", "k":
+			rd.state.fill = colorOf(n)
+		case "G", "RG", "K":
+			rd.state.stroke = colorOf(n)
+		case "sc", "scn":
+			if len(n) > 0 {
+				rd.state.fill = colorOf(n)
+			}
+		case "SC", "SCN":
+			if len(n) > 0 {
+				rd.state.stroke = colorOf(n)
+			}
+		case "cs":
+			rd.state.fill = black
+		case "CS":
+			rd.state.stroke = black
+
+		case "m", "l", "c", "v", "y", "h", "re":
+			rd.buildPath(op.Operator, n)
+		case "f", "F", "f*":
+			rd.fill(rd.path, rd.state.fill, op.Operator == "f*")
+			rd.path = nil
+		case "S", "s":
+			if op.Operator == "s" {
+				rd.buildPath("h", nil)
+			}
+			rd.strokePath()
+			rd.path = nil
+		case "B", "B*", "b", "b*":
+			if op.Operator == "b" || op.Operator == "b*" {
+				rd.buildPath("h", nil)
+			}
+			rd.fill(rd.path, rd.state.fill, op.Operator == "B*" || op.Operator == "b*")
+			rd.strokePath()
+			rd.path = nil
+		case "n":
+			rd.path = nil
+
+		case "Do":
+			if len(op.Operands) == 1 {
+				name, _ := op.Operands[0].(pdf.Name)
+				rd.drawXObject(resources, name, depth)
+			}
+
+		default:
+			rd.textOperator(op, n, resources)
+		}
+	}
+}
+
+func numbers(operands []any) []float64 {
+	n := []float64{}
+	for _, o := range operands {
+		switch v := o.(type) {
+		case int64, float64:
+			n = append(n, pdf.Number(v))
+		}
+	}
+	return n
+}
+
+// colorOf reads gray, RGB or CMYK by how many components there are
+func colorOf(n []float64) color.RGBA {
+	c := func(v float64) uint8 {
+		return uint8(math.Round(math.Max(0, math.Min(1, v)) * 255))
+	}
+	switch len(n) {
+	case 1:
+		return color.RGBA{c(n[0]), c(n[0]), c(n[0]), 255}
+	case 3:
+		return color.RGBA{c(n[0]), c(n[1]), c(n[2]), 255}
+	case 4:
+		k := 1 - n[3]
+		return color.RGBA{c((1 - n[0]) * k), c((1 - n[1]) * k), c((1 - n[2]) * k), 255}
+	}
+	return black
+}
+
+func (rd *renderer) buildPath(op string, n []float64) {
+	m := rd.toDevice()
+	current := func() (point, bool) {
+		if len(rd.path) == 0 || len(rd.path[len(rd.path)-1]) == 0 {
+			return point{}, false
+		}
+		sub := rd.path[len(rd.path)-1]
+		return sub[len(sub)-1], true
+	}
+	extend := func(pts ...point) {
+		if len(rd.path) == 0 {
+			rd.path = append(rd.path, []point{})
+		}
+		rd.path[len(rd.path)-1] = append(rd.path[len(rd.path)-1], pts...)
+	}
+
+	switch {
+	case op == "m" && len(n) == 2:
+		rd.path = append(rd.path, []point{m.apply(n[0], n[1])})
+	case op == "l" && len(n) == 2:
+		extend(m.apply(n[0], n[1]))
+	case op == "re" && len(n) == 4:
+		x, y, w, h := n[0], n[1], n[2], n[3]
+		rd.path = append(rd.path, []point{m.apply(x, y), m.apply(x+w, y), m.apply(x+w, y+h), m.apply(x, y+h), m.apply(x, y)})
+	case op == "h":
+		if len(rd.path) > 0 && len(rd.path[len(rd.path)-1]) > 0 {
+			extend(rd.path[len(rd.path)-1][0])
+		}
+	case op == "c" || op == "v" || op == "y":
+		start, ok := current()
+		if !ok {
+			return
+		}
+		var c1, c2, end point
+		switch {
+		case op == "c" && len(n) == 6:
+			c1, c2, end = m.apply(n[0], n[1]), m.apply(n[2], n[3]), m.apply(n[4], n[5])
+		case op == "v" && len(n) == 4:
+			c1, c2, end = start, m.apply(n[0], n[1]), m.apply(n[2], n[3])
+		case op == "y" && len(n) == 4:
+			c1, end = m.apply(n[0], n[1]), m.apply(n[2], n[3])
+			c2 = end
+		default:
+			return
+		}
+		// a curve is a handful of pixels across at this size, so a few straight pieces will do
+		for i := 1; i <= 8; i++ {
+			t := float64(i) / 8
+			u := 1 - t
+			extend(point{
+				u*u*u*start.x + 3*u*u*t*c1.x + 3*u*t*t*c2.x + t*t*t*end.x,
+				u*u*u*start.y + 3*u*u*t*c1.y + 3*u*t*t*c2.y + t*t*t*end.y,
+			})
+		}
+	}
+}
+
+// fill paints the inside of the path, by the nonzero rule unless evenOdd. Shapes thinner
+// than a pixel, rules and underlines mostly, are drawn as lines so they don't vanish
+func (rd *renderer) fill(path [][]point, c color.RGBA, evenOdd bool) {
+	if len(path) == 0 {
+		return
+	}
+
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, sub := range path {
+		for _, pt := range sub {
+			minX, minY = math.Min(minX, pt.x), math.Min(minY, pt.y)
+			maxX, maxY = math.Max(maxX, pt.x), math.Max(maxY, pt.y)
+		}
+	}
+	if maxX-minX < 1 || maxY-minY < 1 {
+		rd.line(point{minX, minY}, point{maxX, maxY}, 1, c)
+		return
+	}
+
+	bounds := rd.img.Bounds()
+	top := max(bounds.Min.Y, int(math.Floor(minY)))
+	bottom := min(bounds.Max.Y-1, int(math.Ceil(maxY)))
+
+	type crossing struct {
+		x   float64
+		dir int
+	}
+	for y := top; y <= bottom; y++ {
+		cy := float64(y) + 0.5
+		crossings := []crossing{}
+		for _, sub := range path {
+			for i := range sub {
+				a, b := sub[i], sub[(i+1)%len(sub)]
+				if (a.y <= cy) == (b.y <= cy) {
+					continue
+				}
+				dir := 1
+				if b.y < a.y {
+					dir = -1
+				}
+				crossings = append(crossings, crossing{a.x + (cy-a.y)*(b.x-a.x)/(b.y-a.y), dir})
+			}
+		}
+		if len(crossings) < 2 {
+			continue
+		}
+		for i := 1; i < len(crossings); i++ {
+			for j := i; j > 0 && crossings[j].x < crossings[j-1].x; j-- {
+				crossings[j], crossings[j-1] = crossings[j-1], crossings[j]
+			}
+		}
+
+		winding := 0
+		for i := 0; i < len(crossings)-1; i++ {
+			winding += crossings[i].dir
+			inside := winding != 0
+			if evenOdd {
+				inside = i%2 == 0
+			}
+			if !inside {
+				continue
+			}
+			from := max(bounds.Min.X, int(math.Round(crossings[i].x)))
+			to := min(bounds.Max.X, int(math.Round(crossings[i+1].x)))
+			for x := from; x < to; x++ {
+				rd.img.SetRGBA(x, y, c)
+			}
+		}
+	}
+}
+
+func (rd *renderer) strokePath() {
+	width := math.Max(1, rd.state.lineWidth*rd.toDevice().scale())
+	for _, sub := range rd.path {
+		for i := 1; i < len(sub); i++ {
+			rd.line(sub[i-1], sub[i], width, rd.state.stroke)
+		}
+	}
+}
+
+// line stamps squares of the width along the line, plenty at this size
+func (rd *renderer) line(a, b point, width float64, c color.RGBA) {
+	length := math.Hypot(b.x-a.x, b.y-a.y)
+	steps := int(math.Min(4*Width, math.Ceil(length*2))) + 1
+	size := max(1, int(math.Round(width)))
+	for i := 0; i <= steps; i++ {
+		t := float64(i) / float64(steps)
+		x := int(math.Floor(a.x + (b.x-a.x)*t - float64(size)/2 + 0.5))
+		y := int(math.Floor(a.y + (b.y-a.y)*t - float64(size)/2 + 0.5))
+		for py := y; py < y+size; py++ {
+			for px := x; px < x+size; px++ {
+				if (image.Point{px, py}).In(rd.img.Bounds()) {
+					rd.img.SetRGBA(px, py, c)
+				}
+			}
+		}
+	}
+}
+
+func (rd *renderer) drawXObject(resources pdf.Dict, name pdf.Name, depth int) {
+	xobjects, _ := rd.resolve(resources["XObject"]).(pdf.Dict)
+	s, ok := rd.resolve(xobjects[name]).(*pdf.Stream)
+	if !ok {
+		return
+	}
+
+	switch s.Dict["Subtype"] {
+	case pdf.Name("Image"):
+		// a stencil mask is paint in the shape of the image, rarely more than a logo's outline
+		if rd.resolve(s.Dict["ImageMask"]) == true {
+			return
+		}
+		rd.drawImage(decodeImage(rd, s))
+
+	case pdf.Name("Form"):
+		if depth >= maxFormDepth {
+			return
+		}
+		data, err := rd.p.StreamData(s)
+		if err != nil {
+			return
+		}
+		ops, _ := pdf.ParseContent(data)
+
+		res, ok := rd.resolve(s.Dict["Resources"]).(pdf.Dict)
+		if !ok {
+			res = resources
+		}
+
+		saved := rd.state
+		if m, ok := rd.resolve(s.Dict["Matrix"]).(pdf.Array); ok && len(m) == 6 {
+			n := numbers(m)
+			if len(n) == 6 {
+				rd.state.ctm = matrix(n).times(rd.state.ctm)
+			}
+		}
+		rd.run(ops, res, depth+1)
+		rd.state = saved
+	}
+}
+
+// drawImage maps the image onto the unit square of user space, a nil image is drawn as a placeholder
+func (rd *renderer) drawImage(img image.Image) {
+	m := rd.toDevice()
+	inverse, ok := m.invert()
+	if !ok {
+		return
+	}
+
+	corners := []point{m.apply(0, 0), m.apply(1, 0), m.apply(1, 1), m.apply(0, 1)}
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, c := range corners {
+		minX, minY = math.Min(minX, c.x), math.Min(minY, c.y)
+		maxX, maxY = math.Max(maxX, c.x), math.Max(maxY, c.y)
+	}
+	area := image.Rect(int(math.Floor(minX)), int(math.Floor(minY)), int(math.Ceil(maxX)), int(math.Ceil(maxY))).Intersect(rd.img.Bounds())
+
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			u := inverse.apply(float64(x)+0.5, float64(y)+0.5)
+			if u.x < 0 || u.x >= 1 || u.y < 0 || u.y >= 1 {
+				continue
+			}
+			if img == nil {
+				rd.img.SetRGBA(x, y, placeholder)
+				continue
+			}
+			b := img.Bounds()
+			// the image's first row is at the top of the square
+			sx := b.Min.X + int(u.x*float64(b.Dx()))
+			sy := b.Min.Y + int((1-u.y)*float64(b.Dy()))
+			rd.img.Set(x, y, img.At(sx, sy))
+		}
+	}
+}
+
+// resolve follows references, anything that can't be read is nil
+func (rd *renderer) resolve(obj any) any {
+	o, err := rd.p.Resolve(obj)
+	if err != nil {
+		return nil
+	}
+	return o
+}
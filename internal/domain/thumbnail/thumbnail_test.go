@@ -0,0 +1,113 @@
+package thumbnail_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"filmPackager/internal/domain/thumbnail"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func buildPDF(trailer string, objects ...string) []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	offsets := []int{}
+	for i, o := range objects {
+		offsets = append(offsets, b.Len())
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, o)
+	}
+	start := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f\r\n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n\r\n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R %s >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, trailer, start)
+	return b.Bytes()
+}
+
+func stream(dict string, data []byte) string {
+	return fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", dict, len(data), data)
+}
+
+func render(t *testing.T, file []byte) image.Image {
+	data, err := thumbnail.Render(bytes.NewReader(file), int64(len(file)))
+	assert.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(data))
+	assert.NoError(t, err)
+	return img
+}
+
+func TestRender(t *testing.T) {
+	assert := assert.New(t)
+
+	var pixels bytes.Buffer
+	w := zlib.NewWriter(&pixels)
+	w.Write([]byte{0, 255, 255, 0})
+	w.Close()
+
+	content := []byte(`0 0 1 rg 100 600 200 100 re f
+0 g BT /F1 24 Tf 72 500 Td (HELLO WORLD) Tj ET
+BT 3 Tr /F1 24 Tf 72 300 Td (UNDER A SCAN) Tj ET
+q 100 0 0 100 300 100 cm /Im1 Do Q`)
+
+	file := buildPDF("",
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R /Resources << /Font << /F1 << /Type /Font /Subtype /Type1 /BaseFont /Helvetica >> >> /XObject << /Im1 5 0 R >> >> >>",
+		stream("", content),
+		stream("/Type /XObject /Subtype /Image /Width 2 /Height 2 /BitsPerComponent 8 /ColorSpace /DeviceGray /Filter /FlateDecode", pixels.Bytes()),
+	)
+
+	img := render(t, file)
+	assert.Equal(image.Rect(0, 0, thumbnail.Width, 311), img.Bounds())
+
+	at := func(x, y int) color.RGBA {
+		return color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+	}
+	white := color.RGBA{255, 255, 255, 255}
+
+	assert.Equal(color.RGBA{0, 0, 255, 255}, at(80, 55))
+	// the words are grey bars with a gap between them
+	assert.Equal(color.RGBA{115, 115, 115, 255}, at(40, 112))
+	assert.Equal(white, at(54, 112))
+	assert.Equal(color.RGBA{115, 115, 115, 255}, at(60, 112))
+	// invisible text isn't drawn
+	assert.Equal(white, at(40, 190))
+	// the image's first row is at the top
+	assert.Equal(color.RGBA{0, 0, 0, 255}, at(127, 240))
+	assert.Equal(white, at(147, 240))
+	assert.Equal(white, at(5, 5))
+}
+
+func TestRenderRotated(t *testing.T) {
+	file := buildPDF("",
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 /Rotate 90 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R >>",
+		stream("", []byte("0 0 0 rg 0 0 100 100 re f")),
+	)
+
+	img := render(t, file)
+	assert.Equal(t, image.Rect(0, 0, thumbnail.Width, 185), img.Bounds())
+	// turned clockwise, the page's bottom left corner is at the top left
+	r, _, _, _ := img.At(10, 10).RGBA()
+	assert.Equal(t, uint32(0), r)
+	r, _, _, _ = img.At(10, 170).RGBA()
+	assert.Equal(t, uint32(0xffff), r)
+}
+
+func TestRenderEncrypted(t *testing.T) {
+	file := buildPDF("/Encrypt 3 0 R",
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [] /Count 0 >>",
+		"<< /Filter /Standard /V 2 >>",
+	)
+
+	_, err := thumbnail.Render(bytes.NewReader(file), int64(len(file)))
+	assert.ErrorIs(t, err, thumbnail.ErrEncryptedPDF)
+}
@@ -0,0 +1,10 @@
+package twofactor
+
+import "errors"
+
+var (
+	ErrNotEnrolled      = errors.New("two-factor authentication isn't set up")
+	ErrAlreadyEnabled   = errors.New("two-factor authentication is already on")
+	ErrChallengeExpired = errors.New("Error: the login took too long, please log in again")
+	ErrInvalidCode      = errors.New("Error: that code isn't valid, check your authenticator app and try again")
+)
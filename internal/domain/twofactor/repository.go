@@ -0,0 +1,15 @@
+package twofactor
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+type TwoFactorRepository interface {
+	// returns ErrNotEnrolled when the user hasn't started setting it up
+	GetTwoFactor(ctx context.Context, userID uuid.UUID) (*TwoFactor, error)
+	// creates or replaces the user's two-factor settings
+	SaveTwoFactor(ctx context.Context, t *TwoFactor) error
+	DeleteTwoFactor(ctx context.Context, userID uuid.UUID) error
+}
@@ -0,0 +1,129 @@
+package twofactor
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// how many recovery codes are given out, each works once
+const RecoveryCodeCount = 10
+
+// the name shown in authenticator apps
+const Issuer = "Film Packager"
+
+// TwoFactor is a user's authenticator app, it only protects the account once it's
+// enabled by entering a code from the app
+type TwoFactor struct {
+	UserID uuid.UUID
+	Secret string
+	// nil while the user is still setting it up
+	EnabledAt *time.Time
+	// the step of the last code used, so a code can't be used twice
+	LastUsedStep int64
+	// only the sha256 of the recovery codes is kept, the codes are shown once
+	RecoveryHashes []string
+	CreatedAt      time.Time
+}
+
+func NewEnrollment(userID uuid.UUID, now time.Time) *TwoFactor {
+	return &TwoFactor{
+		UserID:         userID,
+		Secret:         GenerateSecret(),
+		RecoveryHashes: []string{},
+		CreatedAt:      now,
+	}
+}
+
+func (t *TwoFactor) IsEnabled() bool {
+	return t != nil && t.EnabledAt != nil
+}
+
+// Enable turns two-factor on once the user shows their app has the secret, returning the recovery codes to show them
+func (t *TwoFactor) Enable(code string, now time.Time) ([]string, error) {
+	if t.IsEnabled() {
+		return nil, ErrAlreadyEnabled
+	}
+
+	err := t.verifyCode(code, now)
+	if err != nil {
+		return nil, err
+	}
+
+	t.EnabledAt = &now
+
+	return t.NewRecoveryCodes(), nil
+}
+
+// Verify checks a code from the app, or failing that one of the recovery codes which is then used up
+func (t *TwoFactor) Verify(code string, now time.Time) error {
+	if !t.IsEnabled() {
+		return ErrNotEnrolled
+	}
+
+	code = normalizeCode(code)
+	if len(code) == Digits {
+		return t.verifyCode(code, now)
+	}
+
+	return t.useRecoveryCode(code)
+}
+
+// NewRecoveryCodes replaces the recovery codes, returning the new ones to show the user
+func (t *TwoFactor) NewRecoveryCodes() []string {
+	codes := make([]string, RecoveryCodeCount)
+	t.RecoveryHashes = make([]string, RecoveryCodeCount)
+	for i := range codes {
+		codes[i] = newRecoveryCode()
+		t.RecoveryHashes[i] = hashRecoveryCode(codes[i])
+	}
+	return codes
+}
+
+func (t *TwoFactor) verifyCode(code string, now time.Time) error {
+	s, ok := matchStep(t.Secret, normalizeCode(code), now)
+	if !ok || s <= t.LastUsedStep {
+		return ErrInvalidCode
+	}
+
+	t.LastUsedStep = s
+
+	return nil
+}
+
+func (t *TwoFactor) useRecoveryCode(code string) error {
+	hash := hashRecoveryCode(code)
+	for i, h := range t.RecoveryHashes {
+		if h == hash {
+			t.RecoveryHashes = append(t.RecoveryHashes[:i], t.RecoveryHashes[i+1:]...)
+			return nil
+		}
+	}
+	return ErrInvalidCode
+}
+
+// recovery codes are 10 characters from the base32 alphabet, shown as xxxxx-xxxxx
+func newRecoveryCode() string {
+	b := make([]byte, 10)
+	_, err := rand.Read(b)
+	if err != nil {
+		panic("error generating recovery code: " + err.Error())
+	}
+
+	code := strings.ToLower(encoding.EncodeToString(b))[:10]
+	return code[:5] + "-" + code[5:]
+}
+
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(normalizeCode(code)))
+	return hex.EncodeToString(sum[:])
+}
+
+// people type codes with spaces and dashes and in either case
+func normalizeCode(code string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(code)))
+}
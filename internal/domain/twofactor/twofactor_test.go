@@ -0,0 +1,93 @@
+package twofactor_test
+
+import (
+	"encoding/base32"
+	"filmPackager/internal/domain/twofactor"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCode(t *testing.T) {
+	assert := assert.New(t)
+
+	// the SHA1 vectors from RFC 6238 appendix B, truncated to 6 digits
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	vectors := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for unix, expected := range vectors {
+		code, err := twofactor.Code(secret, time.Unix(unix, 0))
+		assert.NoError(err)
+		assert.Equal(expected, code)
+	}
+
+	_, err := twofactor.Code("not base32!", time.Now())
+	assert.Error(err)
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri := twofactor.ProvisioningURI("Film Packager", "sam@example.com", "ABC")
+	assert.Equal(t, "otpauth://totp/Film%20Packager:sam@example.com?algorithm=SHA1&digits=6&issuer=Film+Packager&period=30&secret=ABC", uri)
+}
+
+func TestEnableAndVerify(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+
+	tf := twofactor.NewEnrollment(uuid.New(), now)
+	assert.False(tf.IsEnabled())
+	assert.ErrorIs(tf.Verify("123456", now), twofactor.ErrNotEnrolled)
+
+	_, err := tf.Enable("000000", now)
+	assert.ErrorIs(err, twofactor.ErrInvalidCode)
+
+	code, _ := twofactor.Code(tf.Secret, now)
+	codes, err := tf.Enable(code, now)
+	assert.NoError(err)
+	assert.True(tf.IsEnabled())
+	assert.Len(codes, twofactor.RecoveryCodeCount)
+	assert.Len(tf.RecoveryHashes, twofactor.RecoveryCodeCount)
+	assert.NotContains(tf.RecoveryHashes, codes[0])
+
+	_, err = tf.Enable(code, now)
+	assert.ErrorIs(err, twofactor.ErrAlreadyEnabled)
+
+	// the code that enabled it can't be used again
+	assert.ErrorIs(tf.Verify(code, now), twofactor.ErrInvalidCode)
+
+	// the next step's code works, typed with a space
+	next, _ := twofactor.Code(tf.Secret, now.Add(twofactor.Period))
+	assert.NoError(tf.Verify(next[:3]+" "+next[3:], now))
+
+	// codes too far off aren't accepted
+	old, _ := twofactor.Code(tf.Secret, now.Add(-5*twofactor.Period))
+	assert.ErrorIs(tf.Verify(old, now.Add(5*twofactor.Period)), twofactor.ErrInvalidCode)
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+
+	tf := twofactor.NewEnrollment(uuid.New(), now)
+	code, _ := twofactor.Code(tf.Secret, now)
+	codes, err := tf.Enable(code, now)
+	assert.NoError(err)
+
+	// recovery codes work once, whatever the case or dashes
+	assert.NoError(tf.Verify(strings.ToUpper(strings.ReplaceAll(codes[0], "-", "")), now))
+	assert.Len(tf.RecoveryHashes, twofactor.RecoveryCodeCount-1)
+	assert.ErrorIs(tf.Verify(codes[0], now), twofactor.ErrInvalidCode)
+
+	// new codes replace the old ones
+	fresh := tf.NewRecoveryCodes()
+	assert.Len(tf.RecoveryHashes, twofactor.RecoveryCodeCount)
+	assert.ErrorIs(tf.Verify(codes[1], now), twofactor.ErrInvalidCode)
+	assert.NoError(tf.Verify(fresh[1], now))
+}
@@ -0,0 +1,11 @@
+package watermark
+
+import "errors"
+
+var (
+	ErrInvalidPolicy = errors.New("invalid watermark policy")
+	// the file couldn't be read as a PDF, so it isn't sent without its watermark
+	ErrUnreadablePDF = errors.New("Error: this PDF couldn't be watermarked, ask the project owner for a copy")
+	// PDFs with their own password or permissions can't be changed without the password
+	ErrEncryptedPDF = errors.New("Error: this PDF is password protected so it couldn't be watermarked, ask the project owner for a copy")
+)
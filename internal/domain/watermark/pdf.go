@@ -0,0 +1,401 @@
+package watermark
+
+import (
+	"bytes"
+	"filmPackager/internal/domain/pdf"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// A PDF is watermarked by writing it out again: every object in use is copied across, the
+// pages replaced by ones with the stamp, and a single cross-reference table indexes the lot.
+// Nothing is left of the original's own cross-reference sections, so there's no earlier,
+// unstamped file to cut the download back to. Each page gets its content wrapped in q/Q, so
+// nothing it leaves set carries over, then a stream drawing the stamp, with a standard font
+// that needs no embedding. Streams are copied as they're stored, so any PDF the reader can
+// find the pages of can be stamped however it was made
+
+const (
+	fontName = "FPWmF"
+	gsName   = "FPWmGS"
+)
+
+// StampPDF writes the PDF in r to w with every page stamped, returning how many bytes it wrote.
+// What's been written is no use on its own when there's an error
+func StampPDF(r io.ReaderAt, size int64, s Stamp, w io.Writer) (int64, error) {
+	p, err := pdf.NewReader(r, size)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrUnreadablePDF, err)
+	}
+
+	if _, ok := p.Trailer()["Encrypt"]; ok {
+		return 0, ErrEncryptedPDF
+	}
+
+	pages, err := p.Pages()
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrUnreadablePDF, err)
+	}
+	if len(pages) == 0 {
+		return 0, fmt.Errorf("%w: no pages", ErrUnreadablePDF)
+	}
+
+	rw := &rewrite{w: w, next: p.NextObject(), offsets: map[int]int64{}, gens: map[int]int{}, replaced: map[int]pdf.Dict{}}
+	// the binary comment tells transfer tools the file isn't text
+	rw.write([]byte("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n"))
+
+	font := rw.add(pdf.Dict{"Type": pdf.Name("Font"), "Subtype": pdf.Name("Type1"), "BaseFont": pdf.Name("Helvetica"), "Encoding": pdf.Name("WinAnsiEncoding")})
+	gs := rw.add(pdf.Dict{"Type": pdf.Name("ExtGState"), "ca": 0.18, "CA": 0.18})
+	open := rw.addStream(pdf.Dict{}, []byte("q\n"))
+
+	// pages the same size and way round share their stamp
+	marks := map[string]pdf.Ref{}
+	for _, pg := range pages {
+		key := fmt.Sprint(pg.Box, pg.Rotate)
+		mark, ok := marks[key]
+		if !ok {
+			mark = rw.addStream(pdf.Dict{}, markContent(pg.Box, pg.Rotate, s))
+			marks[key] = mark
+		}
+
+		contents := pdf.Array{open}
+		switch c := pg.Dict["Contents"].(type) {
+		case pdf.Ref:
+			obj, err := p.Resolve(c)
+			if err != nil {
+				return 0, fmt.Errorf("%w: %v", ErrUnreadablePDF, err)
+			}
+			if a, ok := obj.(pdf.Array); ok {
+				contents = append(contents, a...)
+			} else {
+				contents = append(contents, c)
+			}
+		case pdf.Array:
+			contents = append(contents, c...)
+		}
+		contents = append(contents, mark)
+
+		resources, err := withStamp(p, pg.Resources, font, gs)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %v", ErrUnreadablePDF, err)
+		}
+
+		d := pdf.Dict{}
+		for k, v := range pg.Dict {
+			d[k] = v
+		}
+		d["Contents"] = contents
+		d["Resources"] = resources
+		rw.replaced[pg.Ref.Num] = d
+	}
+
+	for _, ref := range p.Objects() {
+		err = rw.copy(p, ref)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %v", ErrUnreadablePDF, err)
+		}
+	}
+
+	rw.finish(p)
+	if rw.err != nil {
+		return 0, rw.err
+	}
+
+	return rw.written, nil
+}
+
+// withStamp copies the page's resources with the stamp's font and graphics state added,
+// the shared resources the page may have are left as they are for the other pages
+func withStamp(p *pdf.Reader, resources pdf.Dict, font, gs pdf.Ref) (pdf.Dict, error) {
+	res := pdf.Dict{}
+	for k, v := range resources {
+		res[k] = v
+	}
+
+	for _, entry := range []struct {
+		key   pdf.Name
+		label pdf.Name
+		obj   pdf.Ref
+	}{{"Font", fontName, font}, {"ExtGState", gsName, gs}} {
+		existing, err := p.Resolve(res[entry.key])
+		if err != nil {
+			return nil, err
+		}
+
+		d := pdf.Dict{}
+		if e, ok := existing.(pdf.Dict); ok {
+			for k, v := range e {
+				d[k] = v
+			}
+		}
+		d[entry.label] = entry.obj
+		res[entry.key] = d
+	}
+
+	return res, nil
+}
+
+// markContent draws the stamp the way the page is shown, allowing for its box and rotation
+func markContent(box [4]float64, rotate int, s Stamp) []byte {
+	w, h := math.Abs(box[2]-box[0]), math.Abs(box[3]-box[1])
+	llx, lly := math.Min(box[0], box[2]), math.Min(box[1], box[3])
+
+	// from the page as it's shown to the page's own space
+	vw, vh := w, h
+	turn := "1 0 0 1 0 0"
+	switch rotate {
+	case 90:
+		vw, vh = h, w
+		turn = fmt.Sprintf("0 1 -1 0 %s 0", num(w))
+	case 180:
+		turn = fmt.Sprintf("-1 0 0 -1 %s %s", num(w), num(h))
+	case 270:
+		vw, vh = h, w
+		turn = fmt.Sprintf("0 -1 1 0 0 %s", num(h))
+	}
+	place := fmt.Sprintf("1 0 0 1 %s %s cm %s cm\n", num(llx), num(lly), turn)
+
+	var b bytes.Buffer
+	// closes the q put before the page's own content, so whatever it left set doesn't move the mark
+	b.WriteString("Q\n")
+
+	// the recipient across the middle, corner to corner, with the details under it
+	recipient, details := winAnsi(s.Recipient()), winAnsi(s.Details())
+	angle := math.Atan2(vh, vw)
+	cos, sin := math.Cos(angle), math.Sin(angle)
+	diagonal := math.Hypot(vw, vh)
+	size := math.Min(48, 0.8*diagonal*1000/textWidth(recipient))
+
+	b.WriteString("q\n" + place)
+	b.WriteString("/" + gsName + " gs 0.5 0.5 0.5 rg\n")
+	for i, line := range []struct {
+		text []byte
+		size float64
+	}{{recipient, size}, {details, size / 2}} {
+		half := textWidth(line.text) * line.size / 2000
+		// the second line sits under the first, across the same diagonal
+		drop := float64(i) * size * 1.1
+		x := vw/2 - half*cos + drop*sin - line.size*0.35*-sin
+		y := vh/2 - half*sin - drop*cos - line.size*0.35*cos
+		fmt.Fprintf(&b, "BT /%s %s Tf %s %s %s %s %s %s Tm %s Tj ET\n", fontName, num(line.size), num(cos), num(sin), num(-sin), num(cos), num(x), num(y), literal(line.text))
+	}
+	b.WriteString("Q\n")
+
+	// the footer is small and solid, so it survives being printed
+	footer := winAnsi(s.Footer())
+	footerSize := math.Min(7, (vw-36)*1000/textWidth(footer))
+	b.WriteString("q\n" + place)
+	fmt.Fprintf(&b, "0.35 0.35 0.35 rg BT /%s %s Tf 1 0 0 1 18 12 Tm %s Tj ET\nQ\n", fontName, num(footerSize), literal(footer))
+
+	return b.Bytes()
+}
+
+func num(f float64) string {
+	return strconv.FormatFloat(math.Round(f*1000)/1000, 'f', -1, 64)
+}
+
+// winAnsi encodes the text for the standard font, anything it doesn't have becomes ?
+func winAnsi(s string) []byte {
+	b := []byte{}
+	for _, r := range s {
+		switch {
+		case r < 0x20:
+			b = append(b, ' ')
+		case r < 0x7f || r >= 0xa0 && r <= 0xff:
+			b = append(b, byte(r))
+		case r == '–':
+			b = append(b, 0x96)
+		case r == '—':
+			b = append(b, 0x97)
+		case r == '’':
+			b = append(b, 0x92)
+		default:
+			b = append(b, '?')
+		}
+	}
+	return b
+}
+
+// Helvetica's widths of the printable ASCII characters, in thousandths of the font size
+var helvetica = [95]float64{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+func textWidth(text []byte) float64 {
+	w := 0.0
+	for _, c := range text {
+		if c >= 0x20 && c < 0x7f {
+			w += helvetica[c-0x20]
+		} else {
+			w += 556
+		}
+	}
+	if w == 0 {
+		return 1
+	}
+	return w
+}
+
+func literal(text []byte) string {
+	var b bytes.Buffer
+	b.WriteByte('(')
+	for _, c := range text {
+		if c == '(' || c == ')' || c == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// rewrite writes the stamped file, keeping track of where each object starts
+type rewrite struct {
+	w       io.Writer
+	written int64
+	// the first write that failed, nothing's written after it
+	err     error
+	next    int
+	offsets map[int]int64
+	gens    map[int]int
+	// the pages written in place of the original ones
+	replaced map[int]pdf.Dict
+}
+
+func (rw *rewrite) write(b []byte) {
+	if rw.err != nil {
+		return
+	}
+	n, err := rw.w.Write(b)
+	rw.written += int64(n)
+	rw.err = err
+}
+
+func (rw *rewrite) object(r pdf.Ref, obj any) {
+	rw.offsets[r.Num] = rw.written
+	rw.gens[r.Num] = r.Gen
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%d %d obj\n", r.Num, r.Gen)
+	pdf.WriteObject(&b, obj)
+	b.WriteString("\nendobj\n")
+	rw.write(b.Bytes())
+}
+
+func (rw *rewrite) stream(r pdf.Ref, d pdf.Dict, data []byte) {
+	rw.offsets[r.Num] = rw.written
+	rw.gens[r.Num] = r.Gen
+
+	dict := pdf.Dict{}
+	for k, v := range d {
+		dict[k] = v
+	}
+	// the length may have been an object of its own, it's written in place here
+	dict["Length"] = int64(len(data))
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%d %d obj\n", r.Num, r.Gen)
+	pdf.WriteObject(&b, dict)
+	b.WriteString("\nstream\n")
+	rw.write(b.Bytes())
+	rw.write(data)
+	rw.write([]byte("\nendstream\nendobj\n"))
+}
+
+func (rw *rewrite) add(obj any) pdf.Ref {
+	r := pdf.Ref{Num: rw.next}
+	rw.next++
+	rw.object(r, obj)
+	return r
+}
+
+func (rw *rewrite) addStream(d pdf.Dict, data []byte) pdf.Ref {
+	r := pdf.Ref{Num: rw.next}
+	rw.next++
+	rw.stream(r, d, data)
+	return r
+}
+
+// copy writes the original object, or the page replacing it. Object streams and cross-reference
+// streams are left behind, the objects they held are written out on their own
+func (rw *rewrite) copy(p *pdf.Reader, ref pdf.Ref) error {
+	if d, ok := rw.replaced[ref.Num]; ok {
+		rw.object(ref, d)
+		return nil
+	}
+
+	obj, err := p.Resolve(ref)
+	if err != nil {
+		return err
+	}
+
+	switch o := obj.(type) {
+	case nil:
+		// a missing object is null, it's left out and its number is free
+	case pdf.Dict:
+		// the offsets a linearized file gives for fast web view don't hold once it's written again
+		if _, ok := o["Linearized"]; ok {
+			return nil
+		}
+		rw.object(ref, o)
+	case *pdf.Stream:
+		if o.Dict["Type"] == pdf.Name("ObjStm") || o.Dict["Type"] == pdf.Name("XRef") {
+			return nil
+		}
+		data, err := p.RawStreamData(o)
+		if err != nil {
+			return err
+		}
+		rw.stream(ref, o.Dict, data)
+	default:
+		rw.object(ref, o)
+	}
+
+	return nil
+}
+
+// finish writes the cross-reference table and trailer, the numbers nothing was written for are marked free
+func (rw *rewrite) finish(p *pdf.Reader) {
+	trailer := pdf.Dict{"Size": int64(rw.next)}
+	for _, k := range []pdf.Name{"Root", "Info", "ID"} {
+		if v, ok := p.Trailer()[k]; ok {
+			trailer[k] = v
+		}
+	}
+
+	// each free number points to the next one, so they're worked out from the end
+	free := make([]int, rw.next)
+	nextFree := 0
+	for n := rw.next - 1; n >= 0; n-- {
+		if _, ok := rw.offsets[n]; !ok {
+			free[n] = nextFree
+			nextFree = n
+		}
+	}
+
+	start := rw.written
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "xref\n0 %d\n", rw.next)
+	for n := 0; n < rw.next; n++ {
+		switch off, ok := rw.offsets[n]; {
+		case ok:
+			fmt.Fprintf(&b, "%010d %05d n\r\n", off, rw.gens[n])
+		case n == 0:
+			fmt.Fprintf(&b, "%010d 65535 f\r\n", free[n])
+		default:
+			fmt.Fprintf(&b, "%010d 00000 f\r\n", free[n])
+		}
+	}
+
+	b.WriteString("trailer\n")
+	pdf.WriteObject(&b, trailer)
+	fmt.Fprintf(&b, "\nstartxref\n%d\n%%%%EOF\n", start)
+	rw.write(b.Bytes())
+}
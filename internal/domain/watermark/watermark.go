@@ -0,0 +1,84 @@
+package watermark
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Stamp is who a watermarked copy was made for, printed across every page so a leaked copy can be traced
+type Stamp struct {
+	Name    string
+	Email   string
+	Project string
+	Time    time.Time
+}
+
+// Recipient is printed large across the middle of the page
+func (s Stamp) Recipient() string {
+	return fmt.Sprintf("%s <%s>", s.Name, s.Email)
+}
+
+// Details is printed under the recipient
+func (s Stamp) Details() string {
+	return fmt.Sprintf("%s · %s", s.Project, s.Time.UTC().Format("2006-01-02 15:04 UTC"))
+}
+
+// Footer is printed along the bottom of the page, where cropping the middle out of a photo doesn't reach
+func (s Stamp) Footer() string {
+	return fmt.Sprintf("Confidential · %s copy for %s <%s> · %s", s.Project, s.Name, s.Email, s.Time.UTC().Format("2006-01-02 15:04 UTC"))
+}
+
+// Policy lists the document types each role gets watermarked copies of, "*" for every type
+type Policy map[string][]string
+
+// DefaultPolicy watermarks everything readers download, as they're usually outside the production
+const DefaultPolicy = "reader=*"
+
+// ParsePolicy reads roles and their document types, e.g. "reader=*;writer=Script,Budget". "off" watermarks nothing
+func ParsePolicy(s string) (Policy, error) {
+	p := Policy{}
+	s = strings.TrimSpace(s)
+	if s == "off" {
+		return p, nil
+	}
+
+	for _, rule := range strings.Split(s, ";") {
+		if strings.TrimSpace(rule) == "" {
+			continue
+		}
+
+		role, types, ok := strings.Cut(rule, "=")
+		role = strings.TrimSpace(role)
+		if !ok || role == "" {
+			return nil, fmt.Errorf("%w: %q should be role=types", ErrInvalidPolicy, rule)
+		}
+
+		for _, t := range strings.Split(types, ",") {
+			t = strings.TrimSpace(t)
+			if t == "" {
+				return nil, fmt.Errorf("%w: no document types for %q", ErrInvalidPolicy, role)
+			}
+			p[role] = append(p[role], t)
+		}
+	}
+
+	return p, nil
+}
+
+// PolicyFromEnv reads WATERMARK_POLICY, using DefaultPolicy when it isn't set
+func PolicyFromEnv() (Policy, error) {
+	s, ok := os.LookupEnv("WATERMARK_POLICY")
+	if !ok {
+		s = DefaultPolicy
+	}
+	return ParsePolicy(s)
+}
+
+// Applies checks whether a member with the role gets a watermarked copy of the document type
+func (p Policy) Applies(role, fileType string) bool {
+	types := p[role]
+	return slices.Contains(types, "*") || slices.Contains(types, fileType)
+}
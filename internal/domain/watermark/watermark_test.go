@@ -0,0 +1,200 @@
+package watermark_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"filmPackager/internal/domain/watermark"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var stamp = watermark.Stamp{Name: "Jane Doe", Email: "jane@example.com", Project: "Night (Draft)", Time: time.Date(2026, 10, 19, 15, 4, 0, 0, time.UTC)}
+
+// classicPDF writes the objects with a cross-reference table
+func classicPDF(trailer string, objects ...string) []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	offsets := []int{}
+	for i, o := range objects {
+		offsets = append(offsets, b.Len())
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, o)
+	}
+	start := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f\r\n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n\r\n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R %s >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, trailer, start)
+	return b.Bytes()
+}
+
+func stream(data string) string {
+	return fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(data), data)
+}
+
+func deflate(data []byte) []byte {
+	var b bytes.Buffer
+	w := zlib.NewWriter(&b)
+	w.Write(data)
+	w.Close()
+	return b.Bytes()
+}
+
+// compressedPDF keeps the pages in an object stream and indexes them with a cross-reference
+// stream using the PNG Up predictor, the way most PDF libraries write files now
+func compressedPDF() []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.5\n")
+
+	offsets := map[int]int{}
+	write := func(num int, body string) {
+		offsets[num] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", num, body)
+	}
+
+	write(1, "<< /Type /Catalog /Pages 2 0 R >>")
+	write(2, "<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 /MediaBox [0 0 595 842] >>")
+	write(6, stream("BT /F1 12 Tf 72 700 Td (Scene 1) Tj ET"))
+
+	pages := []string{
+		"<< /Type /Page /Parent 2 0 R /Contents 6 0 R /Resources << /Font << /F1 << /Type /Font /Subtype /Type1 /BaseFont /Courier >> >> >> >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 6 0 R /Rotate 270 >>",
+	}
+	header := fmt.Sprintf("3 0 4 %d ", len(pages[0])+1)
+	packed := deflate([]byte(header + pages[0] + " " + pages[1]))
+	offsets[5] = b.Len()
+	fmt.Fprintf(&b, "5 0 obj\n<< /Type /ObjStm /N 2 /First %d /Filter /FlateDecode /Length %d >>\nstream\n", len(header), len(packed))
+	b.Write(packed)
+	b.WriteString("\nendstream\nendobj\n")
+
+	start := b.Len()
+	rows := [][]byte{}
+	for num := 0; num <= 7; num++ {
+		switch {
+		case num == 3 || num == 4:
+			rows = append(rows, []byte{2, 0, 5, byte(num - 3)})
+		case offsets[num] > 0 || num == 7:
+			off := offsets[num]
+			if num == 7 {
+				off = start
+			}
+			rows = append(rows, []byte{1, byte(off >> 8), byte(off), 0})
+		default:
+			rows = append(rows, []byte{0, 0, 0, 0})
+		}
+	}
+	var predicted []byte
+	prev := make([]byte, 4)
+	for _, row := range rows {
+		predicted = append(predicted, 2)
+		for i := range row {
+			predicted = append(predicted, row[i]-prev[i])
+		}
+		prev = row
+	}
+	data := deflate(predicted)
+	fmt.Fprintf(&b, "7 0 obj\n<< /Type /XRef /Size 8 /W [1 2 1] /Root 1 0 R /Filter /FlateDecode /DecodeParms << /Predictor 12 /Columns 4 >> /Length %d >>\nstream\n", len(data))
+	b.Write(data)
+	fmt.Fprintf(&b, "\nendstream\nendobj\nstartxref\n%d\n%%%%EOF\n", start)
+	return b.Bytes()
+}
+
+// stamped checks the file is written again with one cross-reference table, and that it can be stamped again
+func stamped(t *testing.T, original []byte) []byte {
+	var b bytes.Buffer
+	n, err := watermark.StampPDF(bytes.NewReader(original), int64(len(original)), stamp, &b)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(b.Len()), n)
+
+	file := b.Bytes()
+	assert.True(t, bytes.HasPrefix(file, []byte("%PDF-")))
+	assert.Equal(t, 1, bytes.Count(file, []byte("startxref")))
+	m := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindSubmatch(file)
+	assert.NotNil(t, m)
+	start, _ := strconv.Atoi(string(m[1]))
+	assert.True(t, bytes.HasPrefix(file[start:], []byte("xref\n")))
+	// nothing points back at the original
+	assert.NotContains(t, string(file), "/Prev")
+
+	var again bytes.Buffer
+	_, err = watermark.StampPDF(bytes.NewReader(file), int64(len(file)), stamp, &again)
+	assert.NoError(t, err)
+	return file
+}
+
+func TestStampPDF(t *testing.T) {
+	assert := assert.New(t)
+
+	// resources and the page size from the page tree, a rotated page, contents in an array
+	original := classicPDF("",
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 4 0 R 5 0 R] /Count 3 /MediaBox [0 0 612 792] /Resources << /Font 6 0 R >> >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 7 0 R >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 7 0 R /Rotate 90 >>",
+		"<< /Type /Page /Parent 2 0 R /Contents [7 0 R 8 0 R] /MediaBox [0 0 595 842] >>",
+		"<< /F1 << /Type /Font /Subtype /Type1 /BaseFont /Times-Roman >> >>",
+		stream("BT /F1 12 Tf 72 700 Td (INT. KITCHEN - NIGHT) Tj ET"),
+		stream("BT /F1 12 Tf 72 680 Td (She (quietly) reads.) Tj ET"),
+	)
+
+	file := stamped(t, original)
+	text := string(file)
+	assert.Equal(3, bytes.Count(file, []byte("/Type /Page>>")))
+	// the unstamped pages aren't in the file at all
+	assert.NotContains(text, "/Type /Page /Parent")
+	assert.Contains(text, "(Jane Doe <jane@example.com>)")
+	assert.Contains(text, `Night \(Draft\)`)
+	assert.Contains(text, "/Contents [11 0 R 7 0 R 8 0 R")
+	assert.Contains(text, "(INT. KITCHEN - NIGHT) Tj")
+	// the page tree's font is kept alongside the stamp's
+	assert.Contains(text, "/Font <</F1 <<")
+
+	// a file indexed with a cross-reference stream, its object stream unpacked
+	file = stamped(t, compressedPDF())
+	text = string(file)
+	assert.Equal(2, bytes.Count(file, []byte("/Type /Page>>")))
+	assert.NotContains(text, "/ObjStm")
+	assert.NotContains(text, "/XRef")
+	assert.Contains(text, "/F1 <</BaseFont /Courier")
+}
+
+func TestStampPDFFailsClosed(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := watermark.StampPDF(bytes.NewReader([]byte("not a pdf")), 9, stamp, io.Discard)
+	assert.ErrorIs(err, watermark.ErrUnreadablePDF)
+
+	encrypted := classicPDF("/Encrypt 3 0 R",
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [] /Count 0 >>",
+		"<< /Filter /Standard /V 2 >>",
+	)
+	_, err = watermark.StampPDF(bytes.NewReader(encrypted), int64(len(encrypted)), stamp, io.Discard)
+	assert.ErrorIs(err, watermark.ErrEncryptedPDF)
+}
+
+func TestPolicy(t *testing.T) {
+	assert := assert.New(t)
+
+	p, err := watermark.ParsePolicy("reader=*; writer=Script,Budget")
+	assert.NoError(err)
+	assert.True(p.Applies("reader", "Lookbook"))
+	assert.True(p.Applies("writer", "Budget"))
+	assert.False(p.Applies("writer", "Lookbook"))
+	assert.False(p.Applies("owner", "Script"))
+
+	p, err = watermark.ParsePolicy("off")
+	assert.NoError(err)
+	assert.False(p.Applies("reader", "Script"))
+
+	_, err = watermark.ParsePolicy("reader")
+	assert.ErrorIs(err, watermark.ErrInvalidPolicy)
+	_, err = watermark.ParsePolicy("reader=")
+	assert.ErrorIs(err, watermark.ErrInvalidPolicy)
+}
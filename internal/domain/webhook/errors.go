@@ -0,0 +1,12 @@
+package webhook
+
+import "errors"
+
+var (
+	ErrWebhookNotFound   = errors.New("webhook not found")
+	ErrDeliveryNotFound  = errors.New("webhook delivery not found")
+	ErrInvalidURL        = errors.New("webhook URL must be an absolute http or https URL")
+	ErrPrivateAddress    = errors.New("webhook URL must point to a public address")
+	ErrInvalidEventTypes = errors.New("choose at least one valid event type")
+	ErrAccessDenied      = errors.New("only project owners can manage webhooks")
+)
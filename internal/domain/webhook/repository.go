@@ -0,0 +1,21 @@
+package webhook
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type WebhookRepository interface {
+	CreateWebhook(ctx context.Context, w *Webhook) error
+	GetWebhook(ctx context.Context, webhookID uuid.UUID) (*Webhook, error)
+	GetProjectWebhooks(ctx context.Context, projectID uuid.UUID) ([]Webhook, error)
+	DeleteWebhook(ctx context.Context, webhookID uuid.UUID) error
+	CreateDelivery(ctx context.Context, d *Delivery) error
+	GetDelivery(ctx context.Context, deliveryID uuid.UUID) (*Delivery, error)
+	GetWebhookDeliveries(ctx context.Context, webhookID uuid.UUID, limit int) ([]Delivery, error)
+	// ClaimDueDeliveries marks the due deliveries as sending until ClaimTimeout, so no other worker takes them
+	ClaimDueDeliveries(ctx context.Context, now time.Time, limit int) ([]Delivery, error)
+	UpdateDelivery(ctx context.Context, d *Delivery) error
+}
@@ -0,0 +1,138 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DeliveryPending = "pending"
+	// claimed by a worker, it's claimed again if the attempt isn't recorded by ClaimTimeout
+	DeliverySending   = "sending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// longer than a worker takes to send a batch, so a delivery is only sent twice when one stops part way
+const ClaimTimeout = 15 * time.Minute
+
+// a delivery is given up on after this many attempts
+const MaxAttempts = 8
+
+const (
+	baseBackoff = 30 * time.Second
+	maxBackoff  = 6 * time.Hour
+)
+
+type Webhook struct {
+	ID         uuid.UUID
+	ProjectID  uuid.UUID
+	URL        string
+	Secret     string
+	EventTypes []string
+	CreatedBy  uuid.UUID
+	CreatedAt  time.Time
+}
+
+type Delivery struct {
+	ID            uuid.UUID
+	WebhookID     uuid.UUID
+	EventType     string
+	Payload       []byte
+	Status        string
+	Attempts      int
+	NextAttemptAt time.Time
+	// zero until a response has been received
+	ResponseCode int
+	LastError    string
+	CreatedAt    time.Time
+	DeliveredAt  *time.Time
+}
+
+// NewWebhook generates a secret when one isn't provided
+func NewWebhook(projectID, createdBy uuid.UUID, url, secret string, eventTypes []string) *Webhook {
+	if secret == "" {
+		secret = NewSecret()
+	}
+	return &Webhook{
+		ID:         uuid.New(),
+		ProjectID:  projectID,
+		URL:        url,
+		Secret:     secret,
+		EventTypes: eventTypes,
+		CreatedBy:  createdBy,
+		CreatedAt:  time.Now(),
+	}
+}
+
+func NewSecret() string {
+	b := make([]byte, 24)
+	_, err := rand.Read(b)
+	if err != nil {
+		panic("error generating webhook secret: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
+
+func (w *Webhook) Subscribes(eventType string) bool {
+	return slices.Contains(w.EventTypes, eventType)
+}
+
+func NewDelivery(webhookID uuid.UUID, eventType string, payload []byte) *Delivery {
+	now := time.Now()
+	return &Delivery{
+		ID:            uuid.New(),
+		WebhookID:     webhookID,
+		EventType:     eventType,
+		Payload:       payload,
+		Status:        DeliveryPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+}
+
+func (d *Delivery) RecordSuccess(now time.Time, responseCode int) {
+	d.Attempts++
+	d.Status = DeliverySucceeded
+	d.ResponseCode = responseCode
+	d.LastError = ""
+	d.DeliveredAt = &now
+}
+
+// RecordFailure schedules the next attempt with exponential backoff, or gives up after MaxAttempts
+func (d *Delivery) RecordFailure(now time.Time, responseCode int, reason string) {
+	d.Attempts++
+	d.ResponseCode = responseCode
+	d.LastError = reason
+	if d.Attempts >= MaxAttempts {
+		d.Status = DeliveryFailed
+		return
+	}
+	d.Status = DeliveryPending
+	d.NextAttemptAt = now.Add(Backoff(d.Attempts))
+}
+
+// Backoff returns the wait before the next attempt, doubling from 30 seconds up to 6 hours
+func Backoff(attempts int) time.Duration {
+	wait := baseBackoff
+	for i := 1; i < attempts; i++ {
+		wait *= 2
+		if wait >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return wait
+}
+
+// Sign returns the value of the signature header, a hex HMAC-SHA256 of the body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
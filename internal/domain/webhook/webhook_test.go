@@ -0,0 +1,43 @@
+package webhook_test
+
+import (
+	"filmPackager/internal/domain/webhook"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSign(t *testing.T) {
+	// matches: echo -n '{"event":"documents.locked"}' | openssl dgst -sha256 -hmac secret
+	sig := webhook.Sign("secret", []byte(`{"event":"documents.locked"}`))
+	assert.Equal(t, "sha256=069206df64cf657064535d19acaa5bc29fbb8a06b85e42e77547bb859ea1cc2a", sig)
+	assert.NotEqual(t, sig, webhook.Sign("other", []byte(`{"event":"documents.locked"}`)))
+}
+
+func TestDeliveryBackoff(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+
+	assert.Equal(30*time.Second, webhook.Backoff(1))
+	assert.Equal(time.Minute, webhook.Backoff(2))
+	assert.Equal(6*time.Hour, webhook.Backoff(20))
+
+	d := webhook.NewDelivery(uuid.New(), "document.uploaded", []byte(`{}`))
+	// claimed by the worker, a failed attempt puts it back in the queue
+	d.Status = webhook.DeliverySending
+	d.RecordFailure(now, 500, "server error")
+	assert.Equal(webhook.DeliveryPending, d.Status)
+	assert.Equal(now.Add(30*time.Second), d.NextAttemptAt)
+
+	for d.Attempts < webhook.MaxAttempts {
+		d.RecordFailure(now, 0, "timeout")
+	}
+	assert.Equal(webhook.DeliveryFailed, d.Status)
+
+	d = webhook.NewDelivery(uuid.New(), "document.uploaded", []byte(`{}`))
+	d.RecordSuccess(now, 204)
+	assert.Equal(webhook.DeliverySucceeded, d.Status)
+	assert.Equal(&now, d.DeliveredAt)
+}
@@ -0,0 +1,60 @@
+package infrastructure
+
+import (
+	"context"
+	"filmPackager/internal/domain/activity"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresActivityRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresActivityRepository(db *pgxpool.Pool) *PostgresActivityRepository {
+	return &PostgresActivityRepository{db: db}
+}
+
+func (r *PostgresActivityRepository) Record(ctx context.Context, a *activity.Activity) error {
+	query := `INSERT INTO project_activity (id, organization_id, user_id, kind, detail, created_at) VALUES ($1, $2, $3, $4, $5, $6)`
+
+	_, err := r.db.Exec(ctx, query, a.ID, a.ProjectID, a.ActorID, a.Kind, a.Detail, a.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error recording activity: %v", err)
+	}
+
+	return nil
+}
+
+func (r *PostgresActivityRepository) GetForProjectsSince(ctx context.Context, projectIDs []uuid.UUID, since time.Time) ([]activity.Activity, error) {
+	query := `SELECT id, organization_id, user_id, kind, detail, created_at FROM project_activity WHERE organization_id = ANY($1) AND created_at > $2 ORDER BY created_at`
+
+	rows, err := r.db.Query(ctx, query, projectIDs, since)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving activity from db: %v", err)
+	}
+
+	defer rows.Close()
+
+	var activities []activity.Activity
+
+	for rows.Next() {
+		var a activity.Activity
+
+		err = rows.Scan(&a.ID, &a.ProjectID, &a.ActorID, &a.Kind, &a.Detail, &a.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning rows: %v", err)
+		}
+
+		activities = append(activities, a)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return activities, nil
+}
@@ -0,0 +1,100 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"filmPackager/internal/domain/apitoken"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresTokenRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresTokenRepository(db *pgxpool.Pool) *PostgresTokenRepository {
+	return &PostgresTokenRepository{db: db}
+}
+
+func (r *PostgresTokenRepository) CreateToken(ctx context.Context, t *apitoken.Token) error {
+	query := `INSERT INTO api_tokens (id, user_id, name, token_hash, scope, project_ids, expires_at, last_used_at, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	_, err := r.db.Exec(ctx, query, t.ID, t.UserID, t.Name, t.Hash, t.Scope, t.ProjectIDs, t.ExpiresAt, t.LastUsedAt, t.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error creating api token: %v", err)
+	}
+
+	return nil
+}
+
+func (r *PostgresTokenRepository) GetByHash(ctx context.Context, hash string) (*apitoken.Token, error) {
+	query := `SELECT id, user_id, name, token_hash, scope, project_ids, expires_at, last_used_at, created_at FROM api_tokens WHERE token_hash = $1`
+
+	var t apitoken.Token
+
+	err := r.db.QueryRow(ctx, query, hash).Scan(&t.ID, &t.UserID, &t.Name, &t.Hash, &t.Scope, &t.ProjectIDs, &t.ExpiresAt, &t.LastUsedAt, &t.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, apitoken.ErrTokenNotFound
+		}
+		return nil, fmt.Errorf("error scanning api token: %v", err)
+	}
+
+	return &t, nil
+}
+
+func (r *PostgresTokenRepository) GetUserTokens(ctx context.Context, userID uuid.UUID) ([]apitoken.Token, error) {
+	query := `SELECT id, user_id, name, token_hash, scope, project_ids, expires_at, last_used_at, created_at FROM api_tokens WHERE user_id = $1 ORDER BY created_at DESC`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving api tokens from db: %v", err)
+	}
+
+	defer rows.Close()
+
+	var tokens []apitoken.Token
+
+	for rows.Next() {
+		var t apitoken.Token
+
+		err = rows.Scan(&t.ID, &t.UserID, &t.Name, &t.Hash, &t.Scope, &t.ProjectIDs, &t.ExpiresAt, &t.LastUsedAt, &t.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning rows: %v", err)
+		}
+
+		tokens = append(tokens, t)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return tokens, nil
+}
+
+func (r *PostgresTokenRepository) DeleteToken(ctx context.Context, userID, tokenID uuid.UUID) error {
+	tag, err := r.db.Exec(ctx, `DELETE FROM api_tokens WHERE id = $1 AND user_id = $2`, tokenID, userID)
+	if err != nil {
+		return fmt.Errorf("error deleting api token: %v", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return apitoken.ErrTokenNotFound
+	}
+
+	return nil
+}
+
+func (r *PostgresTokenRepository) UpdateLastUsed(ctx context.Context, tokenID uuid.UUID, at time.Time) error {
+	_, err := r.db.Exec(ctx, `UPDATE api_tokens SET last_used_at = $1 WHERE id = $2`, at, tokenID)
+	if err != nil {
+		return fmt.Errorf("error updating api token: %v", err)
+	}
+
+	return nil
+}
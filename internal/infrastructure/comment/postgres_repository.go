@@ -2,9 +2,12 @@ package infrastructure
 
 import (
 	"context"
+	"errors"
 	"filmPackager/internal/domain/comment"
+	"fmt"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -17,15 +20,17 @@ func NewPostgresCommentRepository(db *pgxpool.Pool) *PostgresCommentRepository {
 }
 
 func (r *PostgresCommentRepository) CreateDocComment(ctx context.Context, comment *comment.Comment) error {
-	query := `INSERT INTO doc_comments (id, document_id, user_id, comment, created_at) VALUES ($1, $2, $3, $4, $5)`
+	query := `INSERT INTO doc_comments (id, document_id, user_id, comment, created_at, parent_id, anchor_page, anchor_x, anchor_y, anchor_width, anchor_height, anchor_text, mentions) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
 
-	_, err := r.db.Exec(ctx, query, comment.ID, comment.DocID, comment.AuthorID, comment.Content, comment.CreatedAt)
+	a := newAnchorColumns(comment.Anchor)
+
+	_, err := r.db.Exec(ctx, query, comment.ID, comment.DocID, comment.AuthorID, comment.Content, comment.CreatedAt, comment.ParentID, a.Page, a.X, a.Y, a.Width, a.Height, a.Text, comment.Mentions)
 
 	return err
 }
 
 func (r *PostgresCommentRepository) GetDocComments(ctx context.Context, docID uuid.UUID) ([]comment.Comment, error) {
-	query := `SELECT id, document_id, user_id, comment, created_at FROM doc_comments WHERE document_id = $1`
+	query := `SELECT id, document_id, user_id, comment, created_at, parent_id, resolved_at, resolved_by, anchor_page, anchor_x, anchor_y, anchor_width, anchor_height, anchor_text, edited_at, mentions, carried_from_version FROM doc_comments WHERE document_id = $1 ORDER BY created_at`
 
 	rows, err := r.db.Query(ctx, query, docID)
 	if err != nil {
@@ -38,11 +43,13 @@ func (r *PostgresCommentRepository) GetDocComments(ctx context.Context, docID uu
 
 	for rows.Next() {
 		var comment comment.Comment
+		var a anchorColumns
 
-		err = rows.Scan(&comment.ID, &comment.DocID, &comment.AuthorID, &comment.Content, &comment.CreatedAt)
+		err = rows.Scan(&comment.ID, &comment.DocID, &comment.AuthorID, &comment.Content, &comment.CreatedAt, &comment.ParentID, &comment.ResolvedAt, &comment.ResolvedBy, &a.Page, &a.X, &a.Y, &a.Width, &a.Height, &a.Text, &comment.EditedAt, &comment.Mentions, &comment.CarriedFrom)
 		if err != nil {
 			return nil, err
 		}
+		comment.Anchor = a.toAnchor()
 
 		comments = append(comments, comment)
 	}
@@ -51,32 +58,161 @@ func (r *PostgresCommentRepository) GetDocComments(ctx context.Context, docID uu
 }
 
 func (r *PostgresCommentRepository) DeleteDocComments(ctx context.Context, docID uuid.UUID) error {
+	revisionsQuery := `DELETE FROM comment_revisions WHERE comment_id IN (SELECT id FROM doc_comments WHERE document_id = $1)`
+
+	_, err := r.db.Exec(ctx, revisionsQuery, docID)
+	if err != nil {
+		return err
+	}
+
 	query := `DELETE FROM doc_comments WHERE document_id = $1`
 
-	_, err := r.db.Exec(ctx, query, docID)
+	_, err = r.db.Exec(ctx, query, docID)
 
 	return err
 }
 
+// deleting the first comment of a thread deletes its replies too, along with their revisions
 func (r *PostgresCommentRepository) DeleteDocComment(ctx context.Context, commentID uuid.UUID) error {
-	query := `DELETE FROM doc_comments WHERE id = $1`
+	revisionsQuery := `DELETE FROM comment_revisions WHERE comment_id IN (SELECT id FROM doc_comments WHERE id = $1 OR parent_id = $1)`
+
+	_, err := r.db.Exec(ctx, revisionsQuery, commentID)
+	if err != nil {
+		return err
+	}
+
+	query := `DELETE FROM doc_comments WHERE id = $1 OR parent_id = $1`
 
-	_, err := r.db.Exec(ctx, query, commentID)
+	_, err = r.db.Exec(ctx, query, commentID)
 
 	return err
 }
 
 func (r *PostgresCommentRepository) GetDocComment(ctx context.Context, commentID uuid.UUID) (*comment.Comment, error) {
-	query := `SELECT id, document_id, user_id, comment, created_at FROM doc_comments WHERE id = $1`
+	query := `SELECT id, document_id, user_id, comment, created_at, parent_id, resolved_at, resolved_by, anchor_page, anchor_x, anchor_y, anchor_width, anchor_height, anchor_text, edited_at, mentions, carried_from_version FROM doc_comments WHERE id = $1`
 
 	row := r.db.QueryRow(ctx, query, commentID)
 
-	var comment comment.Comment
+	var c comment.Comment
+	var a anchorColumns
+
+	err := row.Scan(&c.ID, &c.DocID, &c.AuthorID, &c.Content, &c.CreatedAt, &c.ParentID, &c.ResolvedAt, &c.ResolvedBy, &a.Page, &a.X, &a.Y, &a.Width, &a.Height, &a.Text, &c.EditedAt, &c.Mentions, &c.CarriedFrom)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, comment.ErrCommentNotFound
+		}
+		return nil, err
+	}
+	c.Anchor = a.toAnchor()
+
+	return &c, nil
+}
+
+func (r *PostgresCommentRepository) UpdateDocComment(ctx context.Context, c *comment.Comment) error {
+	query := `UPDATE doc_comments SET comment = $1, resolved_at = $2, resolved_by = $3, edited_at = $4, mentions = $5 WHERE id = $6`
+
+	_, err := r.db.Exec(ctx, query, c.Content, c.ResolvedAt, c.ResolvedBy, c.EditedAt, c.Mentions, c.ID)
+	if err != nil {
+		return fmt.Errorf("error updating comment: %v", err)
+	}
+
+	return nil
+}
+
+// a thread carried forward more than once keeps the version it was first written on
+func (r *PostgresCommentRepository) MoveThread(ctx context.Context, threadID uuid.UUID, docID uuid.UUID, carriedFrom int) error {
+	moveQuery := `UPDATE doc_comments SET document_id = $1 WHERE id = $2 OR parent_id = $2`
+
+	_, err := r.db.Exec(ctx, moveQuery, docID, threadID)
+	if err != nil {
+		return fmt.Errorf("error moving thread: %v", err)
+	}
+
+	labelQuery := `UPDATE doc_comments SET carried_from_version = COALESCE(carried_from_version, $1) WHERE id = $2`
+
+	_, err = r.db.Exec(ctx, labelQuery, carriedFrom, threadID)
+	if err != nil {
+		return fmt.Errorf("error labelling thread: %v", err)
+	}
+
+	return nil
+}
+
+func (r *PostgresCommentRepository) CreateRevision(ctx context.Context, rev *comment.Revision) error {
+	query := `INSERT INTO comment_revisions (id, comment_id, comment, created_at) VALUES ($1, $2, $3, $4)`
 
-	err := row.Scan(&comment.ID, &comment.DocID, &comment.AuthorID, &comment.Content, &comment.CreatedAt)
+	_, err := r.db.Exec(ctx, query, rev.ID, rev.CommentID, rev.Content, rev.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error creating revision: %v", err)
+	}
+
+	return nil
+}
+
+func (r *PostgresCommentRepository) GetRevisions(ctx context.Context, commentID uuid.UUID) ([]comment.Revision, error) {
+	query := `SELECT id, comment_id, comment, created_at FROM comment_revisions WHERE comment_id = $1 ORDER BY created_at`
+
+	rows, err := r.db.Query(ctx, query, commentID)
 	if err != nil {
 		return nil, err
 	}
 
-	return &comment, nil
+	defer rows.Close()
+
+	var revisions []comment.Revision
+
+	for rows.Next() {
+		var rev comment.Revision
+
+		err = rows.Scan(&rev.ID, &rev.CommentID, &rev.Content, &rev.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, rev)
+	}
+
+	return revisions, nil
+}
+
+// the anchor is spread over nullable columns, they're all null for comments on the whole document
+type anchorColumns struct {
+	Page   *int
+	X      *float64
+	Y      *float64
+	Width  *float64
+	Height *float64
+	Text   *string
+}
+
+func newAnchorColumns(a *comment.Anchor) anchorColumns {
+	if a == nil {
+		return anchorColumns{}
+	}
+
+	cols := anchorColumns{Page: &a.Page}
+	if a.Rect != nil {
+		cols.X, cols.Y, cols.Width, cols.Height = &a.Rect.X, &a.Rect.Y, &a.Rect.Width, &a.Rect.Height
+	}
+	if a.Text != "" {
+		cols.Text = &a.Text
+	}
+
+	return cols
+}
+
+func (cols anchorColumns) toAnchor() *comment.Anchor {
+	if cols.Page == nil {
+		return nil
+	}
+
+	a := &comment.Anchor{Page: *cols.Page}
+	if cols.X != nil && cols.Y != nil && cols.Width != nil && cols.Height != nil {
+		a.Rect = &comment.Rect{X: *cols.X, Y: *cols.Y, Width: *cols.Width, Height: *cols.Height}
+	}
+	if cols.Text != nil {
+		a.Text = *cols.Text
+	}
+
+	return a
 }
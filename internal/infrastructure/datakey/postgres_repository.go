@@ -0,0 +1,81 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"filmPackager/internal/domain/datakey"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresDataKeyRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresDataKeyRepository(db *pgxpool.Pool) *PostgresDataKeyRepository {
+	return &PostgresDataKeyRepository{db: db}
+}
+
+func (r *PostgresDataKeyRepository) GetDataKey(ctx context.Context, projectID uuid.UUID) (*datakey.DataKey, error) {
+	query := `SELECT project_id, master_key_id, wrapped_key, created_at, rotated_at FROM data_keys WHERE project_id = $1`
+
+	var k datakey.DataKey
+
+	err := r.db.QueryRow(ctx, query, projectID).Scan(&k.ProjectID, &k.MasterKeyID, &k.Wrapped, &k.CreatedAt, &k.RotatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, datakey.ErrDataKeyNotFound
+		}
+		return nil, fmt.Errorf("error scanning data key: %v", err)
+	}
+
+	return &k, nil
+}
+
+func (r *PostgresDataKeyRepository) CreateDataKey(ctx context.Context, k *datakey.DataKey) error {
+	// the files already encrypted with an existing key would be lost if it were replaced
+	query := `INSERT INTO data_keys (project_id, master_key_id, wrapped_key, created_at) VALUES ($1, $2, $3, $4) ON CONFLICT (project_id) DO NOTHING`
+
+	_, err := r.db.Exec(ctx, query, k.ProjectID, k.MasterKeyID, k.Wrapped, k.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error saving data key: %v", err)
+	}
+
+	return nil
+}
+
+func (r *PostgresDataKeyRepository) UpdateDataKey(ctx context.Context, k *datakey.DataKey) error {
+	query := `UPDATE data_keys SET master_key_id = $1, wrapped_key = $2, rotated_at = $3 WHERE project_id = $4`
+
+	_, err := r.db.Exec(ctx, query, k.MasterKeyID, k.Wrapped, k.RotatedAt, k.ProjectID)
+	if err != nil {
+		return fmt.Errorf("error updating data key: %v", err)
+	}
+
+	return nil
+}
+
+func (r *PostgresDataKeyRepository) GetDataKeysNotWrappedWith(ctx context.Context, masterKeyID string) ([]*datakey.DataKey, error) {
+	query := `SELECT project_id, master_key_id, wrapped_key, created_at, rotated_at FROM data_keys WHERE master_key_id <> $1`
+
+	rows, err := r.db.Query(ctx, query, masterKeyID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting data keys: %v", err)
+	}
+	defer rows.Close()
+
+	keys := []*datakey.DataKey{}
+	for rows.Next() {
+		var k datakey.DataKey
+		err = rows.Scan(&k.ProjectID, &k.MasterKeyID, &k.Wrapped, &k.CreatedAt, &k.RotatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning data key: %v", err)
+		}
+		keys = append(keys, &k)
+	}
+
+	return keys, rows.Err()
+}
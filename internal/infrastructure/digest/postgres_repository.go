@@ -0,0 +1,105 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"filmPackager/internal/domain/digest"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresDigestRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresDigestRepository(db *pgxpool.Pool) *PostgresDigestRepository {
+	return &PostgresDigestRepository{db: db}
+}
+
+func (r *PostgresDigestRepository) GetByUserID(ctx context.Context, userID uuid.UUID) (*digest.Subscription, error) {
+	query := `SELECT user_id, frequency, unsubscribe_token, last_sent_at FROM digest_subscriptions WHERE user_id = $1`
+
+	var s digest.Subscription
+
+	err := r.db.QueryRow(ctx, query, userID).Scan(&s.UserID, &s.Frequency, &s.UnsubscribeToken, &s.LastSentAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, digest.ErrSubscriptionNotFound
+		}
+		return nil, fmt.Errorf("error scanning subscription: %v", err)
+	}
+
+	return &s, nil
+}
+
+func (r *PostgresDigestRepository) GetByUnsubscribeToken(ctx context.Context, token string) (*digest.Subscription, error) {
+	query := `SELECT user_id, frequency, unsubscribe_token, last_sent_at FROM digest_subscriptions WHERE unsubscribe_token = $1`
+
+	var s digest.Subscription
+
+	err := r.db.QueryRow(ctx, query, token).Scan(&s.UserID, &s.Frequency, &s.UnsubscribeToken, &s.LastSentAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, digest.ErrSubscriptionNotFound
+		}
+		return nil, fmt.Errorf("error scanning subscription: %v", err)
+	}
+
+	return &s, nil
+}
+
+func (r *PostgresDigestRepository) GetAllUserSubscriptions(ctx context.Context) ([]digest.Subscription, error) {
+	// users without a row get the default frequency and an empty token, which is generated on first send
+	query := `
+		SELECT
+	u.id,
+	COALESCE(d.frequency, $1),
+	COALESCE(d.unsubscribe_token, ''),
+	d.last_sent_at
+	FROM
+	users u
+	LEFT JOIN digest_subscriptions d ON d.user_id = u.id`
+
+	rows, err := r.db.Query(ctx, query, digest.DefaultFrequency)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving subscriptions from db: %v", err)
+	}
+
+	defer rows.Close()
+
+	var subs []digest.Subscription
+
+	for rows.Next() {
+		var s digest.Subscription
+
+		err = rows.Scan(&s.UserID, &s.Frequency, &s.UnsubscribeToken, &s.LastSentAt)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning rows: %v", err)
+		}
+
+		subs = append(subs, s)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return subs, nil
+}
+
+func (r *PostgresDigestRepository) Save(ctx context.Context, s *digest.Subscription) error {
+	query := `
+		INSERT INTO digest_subscriptions (user_id, frequency, unsubscribe_token, last_sent_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO UPDATE SET frequency = $2, unsubscribe_token = $3, last_sent_at = $4`
+
+	_, err := r.db.Exec(ctx, query, s.UserID, s.Frequency, s.UnsubscribeToken, s.LastSentAt)
+	if err != nil {
+		return fmt.Errorf("error saving subscription: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresBlobRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresBlobRepository(db *pgxpool.Pool) *PostgresBlobRepository {
+	return &PostgresBlobRepository{db: db}
+}
+
+func (r *PostgresBlobRepository) AddBlobRef(ctx context.Context, projectID uuid.UUID, checksum string, size int64, upload func() error) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// waits on a blob locked by ReleaseBlobRef or another AddBlobRef, by then it's either still there or
+	// gone and added again at 1. The row stays locked until the transaction ends
+	query := `INSERT INTO blobs (project_id, checksum, size, ref_count) VALUES ($1, $2, $3, 1)
+		ON CONFLICT (project_id, checksum) DO UPDATE SET ref_count = blobs.ref_count + 1
+		RETURNING ref_count`
+
+	var refs int
+	err = tx.QueryRow(ctx, query, projectID, checksum, size).Scan(&refs)
+	if err != nil {
+		return fmt.Errorf("error adding blob reference: %v", err)
+	}
+
+	if refs == 1 {
+		// rolled back on failure, so a document waiting on the blob goes on to upload it itself
+		err = upload()
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("error adding blob reference: %v", err)
+	}
+
+	return nil
+}
+
+func (r *PostgresBlobRepository) ReleaseBlobRef(ctx context.Context, projectID uuid.UUID, checksum string, remove func() error) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// the update holds the row lock until the transaction ends
+	query := `UPDATE blobs SET ref_count = ref_count - 1 WHERE project_id = $1 AND checksum = $2 RETURNING ref_count`
+
+	var refs int
+	err = tx.QueryRow(ctx, query, projectID, checksum).Scan(&refs)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// not counted, so there's no telling what else uses the file and it's left alone
+		log.Printf("no blob %s counted for project %s, its file wasn't deleted", checksum, projectID)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error releasing blob reference: %v", err)
+	}
+
+	if refs > 0 {
+		return tx.Commit(ctx)
+	}
+
+	_, err = tx.Exec(ctx, `DELETE FROM blobs WHERE project_id = $1 AND checksum = $2`, projectID, checksum)
+	if err != nil {
+		return fmt.Errorf("error deleting blob: %v", err)
+	}
+
+	// the blob goes even when the file can't be deleted, nothing uses the file and uploading it again puts it back
+	removeErr := remove()
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("error deleting blob: %v", err)
+	}
+
+	return removeErr
+}
+
+func (r *PostgresBlobRepository) DeleteProjectBlobs(ctx context.Context, projectID uuid.UUID) error {
+	_, err := r.db.Exec(ctx, `DELETE FROM blobs WHERE project_id = $1`, projectID)
+	if err != nil {
+		return fmt.Errorf("error deleting project blobs: %v", err)
+	}
+
+	return nil
+}
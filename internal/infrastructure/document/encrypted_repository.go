@@ -0,0 +1,259 @@
+package infrastructure
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"filmPackager/internal/domain/datakey"
+	"filmPackager/internal/domain/document"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// EncryptedS3Repository encrypts files with their project's data key on the way into
+// the bucket and decrypts them on the way out, the rest is left to the wrapped repository.
+// Files stored before encryption was turned on are read as they are
+type EncryptedS3Repository struct {
+	document.S3Repository
+	keys    datakey.DataKeyRepository
+	keyring *datakey.Keyring
+}
+
+func NewEncryptedS3Repository(files document.S3Repository, keys datakey.DataKeyRepository, keyring *datakey.Keyring) *EncryptedS3Repository {
+	return &EncryptedS3Repository{S3Repository: files, keys: keys, keyring: keyring}
+}
+
+func (r *EncryptedS3Repository) UploadFile(ctx context.Context, doc *document.Document, file io.Reader) (string, error) {
+	key, err := r.keyring.GetOrCreate(ctx, r.keys, doc.OrganizationID)
+	if err != nil {
+		return "", err
+	}
+
+	// S3 wants a body it can seek, and the large uploads shouldn't be held in memory
+	sealed, err := os.CreateTemp("", "filmpackager-sealed-*")
+	if err != nil {
+		return "", fmt.Errorf("error creating temp file: %v", err)
+	}
+	defer os.Remove(sealed.Name())
+	defer sealed.Close()
+
+	w, err := datakey.NewEncrypter(sealed, key, doc.OrganizationID[:])
+	if err != nil {
+		return "", fmt.Errorf("error starting encryption: %v", err)
+	}
+
+	_, err = io.Copy(w, file)
+	if err != nil {
+		return "", fmt.Errorf("error encrypting file: %v", err)
+	}
+
+	err = w.Close()
+	if err != nil {
+		return "", fmt.Errorf("error encrypting file: %v", err)
+	}
+
+	_, err = sealed.Seek(0, io.SeekStart)
+	if err != nil {
+		return "", fmt.Errorf("error rewinding encrypted file: %v", err)
+	}
+
+	return r.S3Repository.UploadFile(ctx, doc, sealed)
+}
+
+func (r *EncryptedS3Repository) DownloadFile(ctx context.Context, doc *document.Document, rng *document.ByteRange) (*s3.GetObjectOutput, error) {
+	if rng != nil {
+		return r.downloadRange(ctx, doc, *rng)
+	}
+
+	out, err := r.S3Repository.DownloadFile(ctx, doc, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	body := bufio.NewReader(out.Body)
+	head, _ := body.Peek(len(datakey.Magic))
+	if !datakey.IsEncrypted(head) {
+		out.Body = readCloser{Reader: body, Closer: out.Body}
+		return out, nil
+	}
+
+	key, err := r.dataKey(ctx, doc)
+	if err != nil {
+		out.Body.Close()
+		return nil, err
+	}
+
+	plain, err := datakey.NewDecrypter(body, key, doc.OrganizationID[:])
+	if err != nil {
+		out.Body.Close()
+		return nil, fmt.Errorf("error decrypting file: %v", err)
+	}
+
+	// the stored length is the sealed file's, not what's read from the body
+	decrypted := *out
+	decrypted.Body = readCloser{Reader: plain, Closer: out.Body}
+	decrypted.ContentLength = nil
+	return &decrypted, nil
+}
+
+// downloadRange reads only the segments holding the range. The header comes first as it
+// has the nonce prefix, and its Content-Range gives the sealed size the segments are worked out from
+func (r *EncryptedS3Repository) downloadRange(ctx context.Context, doc *document.Document, rng document.ByteRange) (*s3.GetObjectOutput, error) {
+	head, err := r.S3Repository.DownloadFile(ctx, doc, &document.ByteRange{Start: 0, End: datakey.HeaderSize - 1})
+	if err != nil {
+		return nil, err
+	}
+	header, err := io.ReadAll(head.Body)
+	head.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("error reading file header: %v", err)
+	}
+
+	// stored before encryption was turned on, the bucket can read the range itself
+	if !datakey.IsEncrypted(header) {
+		return r.S3Repository.DownloadFile(ctx, doc, &rng)
+	}
+
+	sealedSize, err := totalSize(head.ContentRange)
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := datakey.PlainSize(sealedSize)
+	if err != nil {
+		return nil, err
+	}
+
+	start, end, err := rng.Resolve(size)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := r.dataKey(ctx, doc)
+	if err != nil {
+		return nil, err
+	}
+
+	from, to := datakey.SealedRange(start, end, sealedSize)
+	out, err := r.S3Repository.DownloadFile(ctx, doc, &document.ByteRange{Start: from, End: to})
+	if err != nil {
+		return nil, err
+	}
+
+	plain, err := datakey.NewDecrypterAt(header, out.Body, key, doc.OrganizationID[:], start, sealedSize)
+	if err != nil {
+		out.Body.Close()
+		return nil, fmt.Errorf("error decrypting file: %v", err)
+	}
+
+	decrypted := *out
+	decrypted.Body = readCloser{Reader: io.LimitReader(plain, end-start+1), Closer: out.Body}
+	decrypted.ContentLength = aws.Int64(end - start + 1)
+	decrypted.ContentRange = aws.String(document.ContentRange(start, end, size))
+	return &decrypted, nil
+}
+
+// UploadThumbnail encrypts the thumbnail the same as the file, as it shows what's on the first page
+func (r *EncryptedS3Repository) UploadThumbnail(ctx context.Context, doc *document.Document, png []byte) error {
+	key, err := r.keyring.GetOrCreate(ctx, r.keys, doc.OrganizationID)
+	if err != nil {
+		return err
+	}
+
+	var sealed bytes.Buffer
+	w, err := datakey.NewEncrypter(&sealed, key, doc.OrganizationID[:])
+	if err != nil {
+		return fmt.Errorf("error starting encryption: %v", err)
+	}
+	_, err = w.Write(png)
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		return fmt.Errorf("error encrypting thumbnail: %v", err)
+	}
+
+	return r.S3Repository.UploadThumbnail(ctx, doc, sealed.Bytes())
+}
+
+func (r *EncryptedS3Repository) DownloadThumbnail(ctx context.Context, doc *document.Document) ([]byte, error) {
+	data, err := r.S3Repository.DownloadThumbnail(ctx, doc)
+	if err != nil || !datakey.IsEncrypted(data) {
+		return data, err
+	}
+
+	key, err := r.dataKey(ctx, doc)
+	if err != nil {
+		return nil, err
+	}
+
+	plain, err := datakey.NewDecrypter(bytes.NewReader(data), key, doc.OrganizationID[:])
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting thumbnail: %v", err)
+	}
+	return io.ReadAll(plain)
+}
+
+func (r *EncryptedS3Repository) dataKey(ctx context.Context, doc *document.Document) ([]byte, error) {
+	d, err := r.keys.GetDataKey(ctx, doc.OrganizationID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting data key: %v", err)
+	}
+
+	return r.keyring.Unwrap(d)
+}
+
+// totalSize reads the whole file's size from the end of a Content-Range, e.g. "bytes 0-10/2048"
+func totalSize(contentRange *string) (int64, error) {
+	_, size, ok := strings.Cut(aws.ToString(contentRange), "/")
+	if !ok {
+		return 0, fmt.Errorf("no file size in content range %q", aws.ToString(contentRange))
+	}
+
+	return strconv.ParseInt(size, 10, 64)
+}
+
+// RewrapDataKeys moves every data key onto the current master key, run after a new
+// master key is added so the old one can be removed. The files themselves don't change
+func (r *EncryptedS3Repository) RewrapDataKeys(ctx context.Context) error {
+	keys, err := r.keys.GetDataKeysNotWrappedWith(ctx, r.keyring.CurrentKeyID())
+	if err != nil {
+		return err
+	}
+
+	rewrapped := 0
+	for _, k := range keys {
+		_, err := r.keyring.Rewrap(k, time.Now())
+		if err != nil {
+			// a key wrapped with a master key that's already gone can't be saved now
+			log.Printf("error rewrapping the data key for project %s: %v", k.ProjectID, err)
+			continue
+		}
+
+		err = r.keys.UpdateDataKey(ctx, k)
+		if err != nil {
+			log.Printf("error saving the rewrapped data key for project %s: %v", k.ProjectID, err)
+			continue
+		}
+		rewrapped++
+	}
+
+	if rewrapped > 0 {
+		log.Printf("rewrapped %d data keys with master key %q", rewrapped, r.keyring.CurrentKeyID())
+	}
+
+	return nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,207 @@
+package infrastructure_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"filmPackager/internal/domain/datakey"
+	"filmPackager/internal/domain/document"
+	infrastructure "filmPackager/internal/infrastructure/document"
+	"io"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// bucket keeps the stored files in memory
+type bucket struct {
+	document.S3Repository
+	files map[uuid.UUID][]byte
+}
+
+func (b *bucket) UploadFile(ctx context.Context, doc *document.Document, file io.Reader) (string, error) {
+	data, err := io.ReadAll(file)
+	b.files[doc.ID] = data
+	return doc.FileName, err
+}
+
+func (b *bucket) DownloadFile(ctx context.Context, doc *document.Document, rng *document.ByteRange) (*s3.GetObjectOutput, error) {
+	data := b.files[doc.ID]
+	if rng == nil {
+		return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(data))}, nil
+	}
+
+	start, end, err := rng.Resolve(int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	return &s3.GetObjectOutput{
+		Body:         io.NopCloser(bytes.NewReader(data[start : end+1])),
+		ContentRange: aws.String(document.ContentRange(start, end, int64(len(data)))),
+	}, nil
+}
+
+func (b *bucket) UploadThumbnail(ctx context.Context, doc *document.Document, png []byte) error {
+	b.files[doc.ID] = png
+	return nil
+}
+
+func (b *bucket) DownloadThumbnail(ctx context.Context, doc *document.Document) ([]byte, error) {
+	data, ok := b.files[doc.ID]
+	if !ok {
+		return nil, document.ErrNoThumbnail
+	}
+	return data, nil
+}
+
+type keyStore struct {
+	keys map[uuid.UUID]datakey.DataKey
+}
+
+func (s *keyStore) GetDataKey(ctx context.Context, projectID uuid.UUID) (*datakey.DataKey, error) {
+	k, ok := s.keys[projectID]
+	if !ok {
+		return nil, datakey.ErrDataKeyNotFound
+	}
+	return &k, nil
+}
+
+func (s *keyStore) CreateDataKey(ctx context.Context, k *datakey.DataKey) error {
+	if _, ok := s.keys[k.ProjectID]; !ok {
+		s.keys[k.ProjectID] = *k
+	}
+	return nil
+}
+
+func (s *keyStore) UpdateDataKey(ctx context.Context, k *datakey.DataKey) error {
+	s.keys[k.ProjectID] = *k
+	return nil
+}
+
+func (s *keyStore) GetDataKeysNotWrappedWith(ctx context.Context, masterKeyID string) ([]*datakey.DataKey, error) {
+	keys := []*datakey.DataKey{}
+	for _, k := range s.keys {
+		if k.MasterKeyID != masterKeyID {
+			k := k
+			keys = append(keys, &k)
+		}
+	}
+	return keys, nil
+}
+
+func masterKey(id string) datakey.MasterKey {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return datakey.MasterKey{ID: id, Key: key}
+}
+
+func keyring(t *testing.T, keys ...datakey.MasterKey) *datakey.Keyring {
+	ring, err := datakey.NewKeyring(keys)
+	assert.NoError(t, err)
+	return ring
+}
+
+func download(t *testing.T, r document.S3Repository, doc *document.Document, rng *document.ByteRange) []byte {
+	out, err := r.DownloadFile(context.Background(), doc, rng)
+	assert.NoError(t, err)
+	defer out.Body.Close()
+	data, err := io.ReadAll(out.Body)
+	assert.NoError(t, err)
+	return data
+}
+
+func TestEncryptedS3Repository(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	files := &bucket{files: map[uuid.UUID][]byte{}}
+	keys := &keyStore{keys: map[uuid.UUID]datakey.DataKey{}}
+	var r document.S3Repository = infrastructure.NewEncryptedS3Repository(files, keys, keyring(t, masterKey("1")))
+
+	budget := []byte("%PDF-1.7 the budget nobody should see")
+	doc := &document.Document{ID: uuid.New(), OrganizationID: uuid.New(), FileName: "budget.pdf"}
+
+	_, err := r.UploadFile(ctx, doc, bytes.NewReader(budget))
+	assert.NoError(err)
+	assert.True(datakey.IsEncrypted(files.files[doc.ID]))
+	assert.NotContains(string(files.files[doc.ID]), "budget nobody")
+	assert.Equal(budget, download(t, r, doc, nil))
+
+	// the project's other files share its data key
+	other := &document.Document{ID: uuid.New(), OrganizationID: doc.OrganizationID, FileName: "script.pdf"}
+	_, err = r.UploadFile(ctx, other, bytes.NewReader([]byte("%PDF-1.7 script")))
+	assert.NoError(err)
+	assert.Equal(1, len(keys.keys))
+
+	// stored before encryption was turned on
+	legacy := &document.Document{ID: uuid.New(), OrganizationID: uuid.New(), FileName: "old.pdf"}
+	files.files[legacy.ID] = []byte("%PDF-1.4 plain")
+	assert.Equal([]byte("%PDF-1.4 plain"), download(t, r, legacy, nil))
+
+	// a range is read from just the segments holding it, whether the file is encrypted or not
+	large := &document.Document{ID: uuid.New(), OrganizationID: doc.OrganizationID, FileName: "deck.pdf"}
+	deck := make([]byte, 200*1024)
+	rand.Read(deck)
+	_, err = r.UploadFile(ctx, large, bytes.NewReader(deck))
+	assert.NoError(err)
+
+	assert.Equal(deck[70000:140001], download(t, r, large, &document.ByteRange{Start: 70000, End: 140000}))
+	assert.Equal(deck[len(deck)-100:], download(t, r, large, &document.ByteRange{Start: -1, End: 100}))
+	assert.Equal([]byte("plain"), download(t, r, legacy, &document.ByteRange{Start: 9, End: -1}))
+
+	out, err := r.DownloadFile(ctx, large, &document.ByteRange{Start: 0, End: 9})
+	assert.NoError(err)
+	assert.Equal("bytes 0-9/204800", *out.ContentRange)
+	assert.Equal(int64(10), *out.ContentLength)
+
+	_, err = r.DownloadFile(ctx, large, &document.ByteRange{Start: int64(len(deck)), End: -1})
+	assert.ErrorIs(err, document.ErrRangeNotSatisfiable)
+
+	// after rotating the master key the files still read without being rewritten
+	first, second := masterKey("1"), masterKey("2")
+	files.files = map[uuid.UUID][]byte{}
+	keys.keys = map[uuid.UUID]datakey.DataKey{}
+
+	r = infrastructure.NewEncryptedS3Repository(files, keys, keyring(t, first))
+	_, err = r.UploadFile(ctx, doc, bytes.NewReader(budget))
+	assert.NoError(err)
+	stored := files.files[doc.ID]
+
+	rotated := infrastructure.NewEncryptedS3Repository(files, keys, keyring(t, second, first))
+	assert.NoError(rotated.RewrapDataKeys(ctx))
+	assert.Equal("2", keys.keys[doc.OrganizationID].MasterKeyID)
+	assert.Equal(stored, files.files[doc.ID])
+
+	// so the old master key can go
+	r = infrastructure.NewEncryptedS3Repository(files, keys, keyring(t, second))
+	assert.Equal(budget, download(t, r, doc, nil))
+}
+
+func TestEncryptedThumbnail(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	files := &bucket{files: map[uuid.UUID][]byte{}}
+	r := infrastructure.NewEncryptedS3Repository(files, &keyStore{keys: map[uuid.UUID]datakey.DataKey{}}, keyring(t, masterKey("1")))
+
+	doc := &document.Document{ID: uuid.New(), OrganizationID: uuid.New(), FileName: "script.pdf"}
+	_, err := r.DownloadThumbnail(ctx, doc)
+	assert.ErrorIs(err, document.ErrNoThumbnail)
+
+	png := []byte("\x89PNG the first page")
+	assert.NoError(r.UploadThumbnail(ctx, doc, png))
+	assert.True(datakey.IsEncrypted(files.files[doc.ID]))
+
+	data, err := r.DownloadThumbnail(ctx, doc)
+	assert.NoError(err)
+	assert.Equal(png, data)
+
+	// the marker for a file that couldn't be rendered stays empty
+	assert.NoError(r.UploadThumbnail(ctx, doc, []byte{}))
+	data, err = r.DownloadThumbnail(ctx, doc)
+	assert.NoError(err)
+	assert.Empty(data)
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -21,7 +22,7 @@ func NewPostgresDocumentRepository(db *pgxpool.Pool) *PostgresDocumentRepository
 
 // GetAllByOrgId returns all documents for a given organization
 func (r *PostgresDocumentRepository) GetAllByOrgId(ctx context.Context, orgID uuid.UUID) ([]*document.Document, error) {
-	query := `SELECT id, organization_id, user_id, file_name, file_type, status, date, color FROM documents WHERE organization_id = $1`
+	query := `SELECT id, organization_id, user_id, file_name, file_type, status, date, color, COALESCE(content_type, ''), COALESCE(scan_status, 'clean'), COALESCE(checksum, '') FROM documents WHERE organization_id = $1`
 
 	rows, err := r.db.Query(ctx, query, orgID)
 	if err != nil {
@@ -35,7 +36,7 @@ func (r *PostgresDocumentRepository) GetAllByOrgId(ctx context.Context, orgID uu
 	for rows.Next() {
 		var doc document.Document
 
-		err = rows.Scan(&doc.ID, &doc.OrganizationID, &doc.UserID, &doc.FileName, &doc.FileType, &doc.Status, &doc.Date, &doc.Color)
+		err = rows.Scan(&doc.ID, &doc.OrganizationID, &doc.UserID, &doc.FileName, &doc.FileType, &doc.Status, &doc.Date, &doc.Color, &doc.ContentType, &doc.ScanStatus, &doc.Checksum)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning rows: %v", err)
 		}
@@ -51,33 +52,46 @@ func (r *PostgresDocumentRepository) GetAllByOrgId(ctx context.Context, orgID uu
 }
 
 func (r *PostgresDocumentRepository) Save(ctx context.Context, doc *document.Document) error {
-	query := `INSERT INTO documents (id, organization_id, user_id, file_name, file_type, date, color, status) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	query := `INSERT INTO documents (id, organization_id, user_id, file_name, file_type, date, color, status, content_type, scan_status, checksum) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
 
-	_, err := r.db.Exec(ctx, query, doc.ID, doc.OrganizationID, doc.UserID, doc.FileName, doc.FileType, doc.Date, doc.Color, doc.Status)
+	_, err := r.db.Exec(ctx, query, doc.ID, doc.OrganizationID, doc.UserID, doc.FileName, doc.FileType, doc.Date, doc.Color, doc.Status, doc.ContentType, doc.ScanStatus, doc.Checksum)
 
 	return err
 }
 
-func (r *PostgresDocumentRepository) UpdateDocument(ctx context.Context, doc *document.Document) error {
-	// update the document with the same org_id and file_type and status = 'staged'
-	query := `UPDATE documents SET id = $1, user_id = $2, file_name = $3, file_type = $4, status = $5, date = $6, color = $7 WHERE organization_id = $8 AND file_type = $4 AND status = 'staged'`
+func (r *PostgresDocumentRepository) ReplaceStaged(ctx context.Context, old *document.Document, doc *document.Document) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// the old row keeps its id, so its comments and version still point at it
+	supersedeQuery := `UPDATE documents SET status = 'superseded' WHERE id = $1 AND status = 'staged'`
 
-	_, err := r.db.Exec(ctx, query, doc.ID, doc.UserID, doc.FileName, doc.FileType, doc.Status, doc.Date, doc.Color, doc.OrganizationID)
+	_, err = tx.Exec(ctx, supersedeQuery, old.ID)
 	if err != nil {
-		return fmt.Errorf("error updating document: %v", err)
+		return fmt.Errorf("error superseding document: %v", err)
 	}
 
-	return err
+	query := `INSERT INTO documents (id, organization_id, user_id, file_name, file_type, date, color, status, content_type, scan_status, checksum) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+
+	_, err = tx.Exec(ctx, query, doc.ID, doc.OrganizationID, doc.UserID, doc.FileName, doc.FileType, doc.Date, doc.Color, doc.Status, doc.ContentType, doc.ScanStatus, doc.Checksum)
+	if err != nil {
+		return fmt.Errorf("error saving document: %v", err)
+	}
+
+	return tx.Commit(ctx)
 }
 
 func (r *PostgresDocumentRepository) GetDocumentDetails(ctx context.Context, docID uuid.UUID) (*document.Document, error) {
-	query := `SELECT id, organization_id, user_id, file_name, file_type, status, date, color FROM documents WHERE id = $1`
+	query := `SELECT id, organization_id, user_id, file_name, file_type, status, date, color, COALESCE(content_type, ''), COALESCE(scan_status, 'clean'), COALESCE(checksum, '') FROM documents WHERE id = $1`
 
 	row := r.db.QueryRow(ctx, query, docID)
 
 	var doc document.Document
 
-	err := row.Scan(&doc.ID, &doc.OrganizationID, &doc.UserID, &doc.FileName, &doc.FileType, &doc.Status, &doc.Date, &doc.Color)
+	err := row.Scan(&doc.ID, &doc.OrganizationID, &doc.UserID, &doc.FileName, &doc.FileType, &doc.Status, &doc.Date, &doc.Color, &doc.ContentType, &doc.ScanStatus, &doc.Checksum)
 	if err != nil {
 		return nil, fmt.Errorf("error scanning row: %v", err)
 	}
@@ -86,13 +100,13 @@ func (r *PostgresDocumentRepository) GetDocumentDetails(ctx context.Context, doc
 }
 
 func (r *PostgresDocumentRepository) FindStagedByType(ctx context.Context, orgID uuid.UUID, fileType string) (*document.Document, error) {
-	checkStagedQuery := `SELECT id, organization_id, user_id, file_name, file_type, status, date, color FROM documents WHERE organization_id = $1 AND status = 'staged' AND file_type = $2`
+	checkStagedQuery := `SELECT id, organization_id, user_id, file_name, file_type, status, date, color, COALESCE(content_type, ''), COALESCE(scan_status, 'clean'), COALESCE(checksum, '') FROM documents WHERE organization_id = $1 AND status = 'staged' AND file_type = $2`
 
 	row := r.db.QueryRow(ctx, checkStagedQuery, orgID, fileType)
 
 	var doc document.Document
 
-	err := row.Scan(&doc.ID, &doc.OrganizationID, &doc.UserID, &doc.FileName, &doc.FileType, &doc.Status, &doc.Date, &doc.Color)
+	err := row.Scan(&doc.ID, &doc.OrganizationID, &doc.UserID, &doc.FileName, &doc.FileType, &doc.Status, &doc.Date, &doc.Color, &doc.ContentType, &doc.ScanStatus, &doc.Checksum)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -131,7 +145,7 @@ func (r *PostgresDocumentRepository) GetKeysForDeleteAll(ctx, orgID uuid.UUID) (
 }
 
 func (r *PostgresDocumentRepository) FindStagedByOrganization(ctx context.Context, orgID uuid.UUID) ([]*document.Document, error) {
-	query := `SELECT id, organization_id, user_id, file_name, file_type, status, date, color FROM documents WHERE organization_id = $1 AND status = 'staged'`
+	query := `SELECT id, organization_id, user_id, file_name, file_type, status, date, color, COALESCE(content_type, ''), COALESCE(scan_status, 'clean'), COALESCE(checksum, '') FROM documents WHERE organization_id = $1 AND status = 'staged'`
 	rows, err := r.db.Query(ctx, query, orgID)
 	if err != nil {
 		return nil, fmt.Errorf("error retrieving documents from db: %v", err)
@@ -140,7 +154,7 @@ func (r *PostgresDocumentRepository) FindStagedByOrganization(ctx context.Contex
 	var docs []*document.Document
 	for rows.Next() {
 		var doc document.Document
-		err = rows.Scan(&doc.ID, &doc.OrganizationID, &doc.UserID, &doc.FileName, &doc.FileType, &doc.Status, &doc.Date, &doc.Color)
+		err = rows.Scan(&doc.ID, &doc.OrganizationID, &doc.UserID, &doc.FileName, &doc.FileType, &doc.Status, &doc.Date, &doc.Color, &doc.ContentType, &doc.ScanStatus, &doc.Checksum)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning rows: %v", err)
 		}
@@ -153,7 +167,7 @@ func (r *PostgresDocumentRepository) FindStagedByOrganization(ctx context.Contex
 }
 
 func (r *PostgresDocumentRepository) GetAllLockedDocumentsByProjectID(ctx context.Context, orgID uuid.UUID) ([]*document.Document, error) {
-	query := `SELECT id, organization_id, user_id, file_name, file_type, status, date, color FROM documents WHERE organization_id = $1 AND status = 'locked'`
+	query := `SELECT id, organization_id, user_id, file_name, file_type, status, date, color, COALESCE(content_type, ''), COALESCE(scan_status, 'clean'), COALESCE(checksum, '') FROM documents WHERE organization_id = $1 AND status = 'locked'`
 
 	rows, err := r.db.Query(ctx, query, orgID)
 	if err != nil {
@@ -166,7 +180,7 @@ func (r *PostgresDocumentRepository) GetAllLockedDocumentsByProjectID(ctx contex
 	for rows.Next() {
 		var doc document.Document
 
-		err = rows.Scan(&doc.ID, &doc.OrganizationID, &doc.UserID, &doc.FileName, &doc.FileType, &doc.Status, &doc.Date, &doc.Color)
+		err = rows.Scan(&doc.ID, &doc.OrganizationID, &doc.UserID, &doc.FileName, &doc.FileType, &doc.Status, &doc.Date, &doc.Color, &doc.ContentType, &doc.ScanStatus, &doc.Checksum)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning rows: %v", err)
 		}
@@ -197,10 +211,115 @@ func (r *PostgresDocumentRepository) UpdateAllStagedToLocked(ctx context.Context
 	return err
 }
 
-func (r *PostgresDocumentRepository) DeleteSelectedDocuments(ctx context.Context, dIDs []uuid.UUID) error {
-	deleteQuery := `DELETE FROM documents WHERE id = ANY($1)`
+func (r *PostgresDocumentRepository) SupersedeDocuments(ctx context.Context, dIDs []uuid.UUID) error {
+	updateQuery := `UPDATE documents SET status = 'superseded' WHERE id = ANY($1)`
+
+	_, err := r.db.Exec(ctx, updateQuery, dIDs)
+
+	return err
+}
+
+func (r *PostgresDocumentRepository) SaveVersion(ctx context.Context, v *document.Version) error {
+	query := `INSERT INTO document_versions (document_id, organization_id, file_type, version, file_name, user_id, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	_, err := r.db.Exec(ctx, query, v.DocID, v.ProjectID, v.FileType, v.Number, v.FileName, v.UploadedBy, v.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error saving document version: %v", err)
+	}
+
+	return nil
+}
+
+func (r *PostgresDocumentRepository) GetVersions(ctx context.Context, orgID uuid.UUID, fileType string) ([]document.Version, error) {
+	query := `SELECT document_id, organization_id, file_type, version, file_name, user_id, created_at FROM document_versions WHERE organization_id = $1 AND file_type = $2 ORDER BY version`
+
+	rows, err := r.db.Query(ctx, query, orgID, fileType)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving document versions from db: %v", err)
+	}
+
+	return scanVersions(rows)
+}
+
+func (r *PostgresDocumentRepository) GetProjectVersions(ctx context.Context, orgID uuid.UUID) ([]document.Version, error) {
+	query := `SELECT document_id, organization_id, file_type, version, file_name, user_id, created_at FROM document_versions WHERE organization_id = $1 ORDER BY file_type, version`
+
+	rows, err := r.db.Query(ctx, query, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving document versions from db: %v", err)
+	}
+
+	return scanVersions(rows)
+}
+
+func (r *PostgresDocumentRepository) DeleteVersion(ctx context.Context, docID uuid.UUID) error {
+	query := `DELETE FROM document_versions WHERE document_id = $1`
+
+	_, err := r.db.Exec(ctx, query, docID)
+
+	return err
+}
+
+func (r *PostgresDocumentRepository) DeleteProjectVersions(ctx context.Context, orgID uuid.UUID) error {
+	query := `DELETE FROM document_versions WHERE organization_id = $1`
 
-	_, err := r.db.Exec(ctx, deleteQuery, dIDs)
+	_, err := r.db.Exec(ctx, query, orgID)
 
 	return err
 }
+
+func (r *PostgresDocumentRepository) FindPendingScan(ctx context.Context, limit int) ([]*document.Document, error) {
+	query := `SELECT id, organization_id, user_id, file_name, file_type, status, date, color, COALESCE(content_type, ''), COALESCE(scan_status, 'clean'), COALESCE(checksum, '') FROM documents WHERE scan_status = 'pending' ORDER BY date LIMIT $1`
+
+	rows, err := r.db.Query(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error getting documents pending a scan: %v", err)
+	}
+	defer rows.Close()
+
+	docs := []*document.Document{}
+	for rows.Next() {
+		doc := &document.Document{}
+		err = rows.Scan(&doc.ID, &doc.OrganizationID, &doc.UserID, &doc.FileName, &doc.FileType, &doc.Status, &doc.Date, &doc.Color, &doc.ContentType, &doc.ScanStatus, &doc.Checksum)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning document: %v", err)
+		}
+		docs = append(docs, doc)
+	}
+
+	return docs, rows.Err()
+}
+
+func (r *PostgresDocumentRepository) UpdateScanStatus(ctx context.Context, docID uuid.UUID, status string) error {
+	query := `UPDATE documents SET scan_status = $1 WHERE id = $2`
+
+	_, err := r.db.Exec(ctx, query, status, docID)
+	if err != nil {
+		return fmt.Errorf("error updating scan status: %v", err)
+	}
+
+	return nil
+}
+
+func scanVersions(rows pgx.Rows) ([]document.Version, error) {
+	defer rows.Close()
+
+	var versions []document.Version
+
+	for rows.Next() {
+		var v document.Version
+
+		err := rows.Scan(&v.DocID, &v.ProjectID, &v.FileType, &v.Number, &v.FileName, &v.UploadedBy, &v.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning rows: %v", err)
+		}
+
+		versions = append(versions, v)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return versions, nil
+}
@@ -1,35 +1,38 @@
 package infrastructure
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"filmPackager/internal/domain/document"
-	"fmt"
+	"io"
 	"log"
-	"mime/multipart"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
-	"github.com/google/uuid"
+	"github.com/aws/smithy-go"
 )
 
 type S3DocumentRepository struct {
-	client *s3.Client
-	bucket string
+	client  *s3.Client
+	presign *s3.PresignClient
+	bucket  string
 }
 
 func NewS3DocumentRepository(client *s3.Client, bucket string) *S3DocumentRepository {
-	return &S3DocumentRepository{client: client, bucket: bucket}
+	return &S3DocumentRepository{client: client, presign: s3.NewPresignClient(client), bucket: bucket}
 }
 
-func (r *S3DocumentRepository) UploadFile(ctx context.Context, doc *document.Document, file interface{}) (string, error) {
-	key := fmt.Sprintf("%s=%s", doc.FileName, doc.ID)
+func (r *S3DocumentRepository) UploadFile(ctx context.Context, doc *document.Document, file io.Reader) (string, error) {
+	key := doc.Key()
 
 	_, err := r.client.PutObject(ctx, &s3.PutObjectInput{
-		Bucket: aws.String(r.bucket),
-		Key:    aws.String(key),
-		Body:   file.(multipart.File),
+		Bucket:      aws.String(r.bucket),
+		Key:         aws.String(key),
+		Body:        file,
+		ContentType: aws.String(document.ContentTypeFor(doc)),
 	})
 
 	if err != nil {
@@ -40,7 +43,7 @@ func (r *S3DocumentRepository) UploadFile(ctx context.Context, doc *document.Doc
 }
 
 func (r *S3DocumentRepository) DeleteFile(ctx context.Context, doc *document.Document) error {
-	key := fmt.Sprintf("%s=%s", doc.FileName, doc.ID)
+	key := doc.Key()
 
 	_, err := r.client.DeleteObject(ctx, &s3.DeleteObjectInput{
 		Bucket: aws.String(r.bucket),
@@ -85,18 +88,26 @@ func (r *S3DocumentRepository) DeleteAllOrgFiles(ctx context.Context, keys []str
 }
 
 // need to understand this a little better
-func (r *S3DocumentRepository) DownloadFile(ctx context.Context, fileName string, id uuid.UUID) (*s3.GetObjectOutput, error) {
-	key := fmt.Sprintf("%s=%s", fileName, id)
+func (r *S3DocumentRepository) DownloadFile(ctx context.Context, doc *document.Document, rng *document.ByteRange) (*s3.GetObjectOutput, error) {
+	key := doc.Key()
 
-	result, err := r.client.GetObject(ctx, &s3.GetObjectInput{
+	input := &s3.GetObjectInput{
 		Bucket: aws.String(r.bucket),
 		Key:    aws.String(key),
-	})
+	}
+	if rng != nil {
+		input.Range = aws.String(rng.String())
+	}
+
+	result, err := r.client.GetObject(ctx, input)
 
 	if err != nil {
 		var noKey *types.NoSuchKey
+		var apiErr smithy.APIError
 
-		if errors.As(err, &noKey) {
+		if errors.As(err, &apiErr) && apiErr.ErrorCode() == "InvalidRange" {
+			err = document.ErrRangeNotSatisfiable
+		} else if errors.As(err, &noKey) {
 			log.Printf("Can't get object %s from bucket %s. No such key exists.\n", key, r.bucket)
 			err = noKey
 		} else {
@@ -107,3 +118,55 @@ func (r *S3DocumentRepository) DownloadFile(ctx context.Context, fileName string
 
 	return result, nil
 }
+
+func (r *S3DocumentRepository) UploadThumbnail(ctx context.Context, doc *document.Document, png []byte) error {
+	_, err := r.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(r.bucket),
+		Key:         aws.String(doc.ThumbnailKey()),
+		Body:        bytes.NewReader(png),
+		ContentType: aws.String("image/png"),
+	})
+	return err
+}
+
+func (r *S3DocumentRepository) DownloadThumbnail(ctx context.Context, doc *document.Document) ([]byte, error) {
+	result, err := r.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(r.bucket),
+		Key:    aws.String(doc.ThumbnailKey()),
+	})
+	if err != nil {
+		var noKey *types.NoSuchKey
+		if errors.As(err, &noKey) {
+			return nil, document.ErrNoThumbnail
+		}
+		return nil, err
+	}
+	defer result.Body.Close()
+
+	return io.ReadAll(result.Body)
+}
+
+func (r *S3DocumentRepository) DeleteThumbnail(ctx context.Context, doc *document.Document) error {
+	_, err := r.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(r.bucket),
+		Key:    aws.String(doc.ThumbnailKey()),
+	})
+	return err
+}
+
+// LinkFile presigns a GET for the file, the bucket sends it with the document's name and content type
+func (r *S3DocumentRepository) LinkFile(ctx context.Context, doc *document.Document, disposition string, expires time.Duration) (string, error) {
+	key := doc.Key()
+
+	req, err := r.presign.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket:                     aws.String(r.bucket),
+		Key:                        aws.String(key),
+		ResponseContentDisposition: aws.String(disposition),
+		ResponseContentType:        aws.String(document.ContentTypeFor(doc)),
+	}, s3.WithPresignExpires(expires))
+	if err != nil {
+		return "", err
+	}
+
+	return req.URL, nil
+}
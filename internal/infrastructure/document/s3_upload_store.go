@@ -0,0 +1,145 @@
+package infrastructure
+
+import (
+	"context"
+	"filmPackager/internal/domain/document"
+	"io"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// S3UploadStore runs multipart uploads in the bucket. The bucket's CORS rules have to allow
+// PUT from the app's origin and expose the ETag header for the browser to send the parts
+type S3UploadStore struct {
+	client  *s3.Client
+	presign *s3.PresignClient
+	bucket  string
+}
+
+func NewS3UploadStore(client *s3.Client, bucket string) *S3UploadStore {
+	return &S3UploadStore{client: client, presign: s3.NewPresignClient(client), bucket: bucket}
+}
+
+// Origin is where the browser sends the parts, for the Content-Security-Policy
+func (s *S3UploadStore) Origin(ctx context.Context) (string, error) {
+	// presigning is done locally, so this doesn't touch the bucket
+	req, err := s.presign.PresignHeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String("incoming/"),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(req.URL)
+	if err != nil {
+		return "", err
+	}
+
+	return u.Scheme + "://" + u.Host, nil
+}
+
+func (s *S3UploadStore) CreateUpload(ctx context.Context, u *document.Upload) (string, error) {
+	out, err := s.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(u.Key()),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return aws.ToString(out.UploadId), nil
+}
+
+func (s *S3UploadStore) PresignPart(ctx context.Context, u *document.Upload, number int32, expires time.Duration) (string, error) {
+	req, err := s.presign.PresignUploadPart(ctx, &s3.UploadPartInput{
+		Bucket:     aws.String(s.bucket),
+		Key:        aws.String(u.Key()),
+		UploadId:   aws.String(u.StoreID),
+		PartNumber: aws.Int32(number),
+	}, s3.WithPresignExpires(expires))
+	if err != nil {
+		return "", err
+	}
+
+	return req.URL, nil
+}
+
+func (s *S3UploadStore) ListParts(ctx context.Context, u *document.Upload) ([]document.Part, error) {
+	parts := []document.Part{}
+
+	paginator := s3.NewListPartsPaginator(s.client, &s3.ListPartsInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(u.Key()),
+		UploadId: aws.String(u.StoreID),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range page.Parts {
+			parts = append(parts, document.Part{
+				Number: aws.ToInt32(p.PartNumber),
+				ETag:   aws.ToString(p.ETag),
+				Size:   aws.ToInt64(p.Size),
+			})
+		}
+	}
+
+	sort.Slice(parts, func(i, j int) bool { return parts[i].Number < parts[j].Number })
+	return parts, nil
+}
+
+func (s *S3UploadStore) CompleteUpload(ctx context.Context, u *document.Upload, parts []document.Part) error {
+	completed := make([]types.CompletedPart, len(parts))
+	for i, p := range parts {
+		completed[i] = types.CompletedPart{PartNumber: aws.Int32(p.Number), ETag: aws.String(p.ETag)}
+	}
+
+	_, err := s.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(s.bucket),
+		Key:             aws.String(u.Key()),
+		UploadId:        aws.String(u.StoreID),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+	})
+
+	return err
+}
+
+func (s *S3UploadStore) AbortUpload(ctx context.Context, u *document.Upload) error {
+	_, err := s.client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(u.Key()),
+		UploadId: aws.String(u.StoreID),
+	})
+
+	return err
+}
+
+func (s *S3UploadStore) GetUploaded(ctx context.Context, u *document.Upload) (io.ReadCloser, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(u.Key()),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return out.Body, nil
+}
+
+func (s *S3UploadStore) DeleteUploaded(ctx context.Context, u *document.Upload) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(u.Key()),
+	})
+
+	return err
+}
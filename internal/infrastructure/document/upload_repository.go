@@ -0,0 +1,81 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"filmPackager/internal/domain/document"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresUploadRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresUploadRepository(db *pgxpool.Pool) *PostgresUploadRepository {
+	return &PostgresUploadRepository{db: db}
+}
+
+func (r *PostgresUploadRepository) SaveUpload(ctx context.Context, u *document.Upload) error {
+	query := `INSERT INTO uploads (id, project_id, user_id, file_name, file_type, size, store_id, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	_, err := r.db.Exec(ctx, query, u.ID, u.ProjectID, u.UserID, u.FileName, u.FileType, u.Size, u.StoreID, u.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error saving upload: %v", err)
+	}
+
+	return nil
+}
+
+func (r *PostgresUploadRepository) GetUpload(ctx context.Context, uploadID uuid.UUID) (*document.Upload, error) {
+	query := `SELECT id, project_id, user_id, file_name, file_type, size, store_id, created_at FROM uploads WHERE id = $1`
+
+	var u document.Upload
+
+	err := r.db.QueryRow(ctx, query, uploadID).Scan(&u.ID, &u.ProjectID, &u.UserID, &u.FileName, &u.FileType, &u.Size, &u.StoreID, &u.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, document.ErrUploadNotFound
+		}
+		return nil, fmt.Errorf("error scanning upload: %v", err)
+	}
+
+	return &u, nil
+}
+
+func (r *PostgresUploadRepository) DeleteUpload(ctx context.Context, uploadID uuid.UUID) error {
+	query := `DELETE FROM uploads WHERE id = $1`
+
+	_, err := r.db.Exec(ctx, query, uploadID)
+	if err != nil {
+		return fmt.Errorf("error deleting upload: %v", err)
+	}
+
+	return nil
+}
+
+func (r *PostgresUploadRepository) GetUploadsStartedBefore(ctx context.Context, t time.Time) ([]*document.Upload, error) {
+	query := `SELECT id, project_id, user_id, file_name, file_type, size, store_id, created_at FROM uploads WHERE created_at < $1`
+
+	rows, err := r.db.Query(ctx, query, t)
+	if err != nil {
+		return nil, fmt.Errorf("error getting uploads: %v", err)
+	}
+	defer rows.Close()
+
+	uploads := []*document.Upload{}
+	for rows.Next() {
+		var u document.Upload
+		err = rows.Scan(&u.ID, &u.ProjectID, &u.UserID, &u.FileName, &u.FileType, &u.Size, &u.StoreID, &u.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning upload: %v", err)
+		}
+		uploads = append(uploads, &u)
+	}
+
+	return uploads, rows.Err()
+}
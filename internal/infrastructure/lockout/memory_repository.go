@@ -0,0 +1,80 @@
+package infrastructure
+
+import (
+	"context"
+	"filmPackager/internal/domain/lockout"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryAttemptRepository keeps the failed logins in the process, so each instance
+// counts its own and they're forgotten on restart
+// how many accounts and addresses are kept before the old ones are cleared out
+const pruneAbove = 10000
+
+type MemoryAttemptRepository struct {
+	mu       sync.Mutex
+	attempts map[string]lockout.Attempts
+}
+
+func NewMemoryAttemptRepository() *MemoryAttemptRepository {
+	return &MemoryAttemptRepository{attempts: make(map[string]lockout.Attempts)}
+}
+
+func (r *MemoryAttemptRepository) GetAttempts(ctx context.Context, key string) (*lockout.Attempts, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	a, ok := r.attempts[key]
+	if !ok {
+		return nil, lockout.ErrAttemptsNotFound
+	}
+
+	return &a, nil
+}
+
+func (r *MemoryAttemptRepository) SaveAttempts(ctx context.Context, a *lockout.Attempts) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// failures from addresses that never come back would otherwise pile up
+	if len(r.attempts) >= pruneAbove {
+		for key, old := range r.attempts {
+			if !old.IsLocked(a.LastFailure) && a.LastFailure.Sub(old.LastFailure) > time.Hour {
+				delete(r.attempts, key)
+			}
+		}
+	}
+
+	r.attempts[a.Key] = *a
+
+	return nil
+}
+
+func (r *MemoryAttemptRepository) DeleteAttempts(ctx context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.attempts, key)
+
+	return nil
+}
+
+func (r *MemoryAttemptRepository) GetLocked(ctx context.Context, now time.Time) ([]lockout.Attempts, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	locked := []lockout.Attempts{}
+	for _, a := range r.attempts {
+		if a.IsLocked(now) {
+			locked = append(locked, a)
+		}
+	}
+
+	sort.Slice(locked, func(i, j int) bool {
+		return locked[i].LockedUntil.After(*locked[j].LockedUntil)
+	})
+
+	return locked, nil
+}
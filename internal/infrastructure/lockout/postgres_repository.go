@@ -0,0 +1,84 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"filmPackager/internal/domain/lockout"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresAttemptRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresAttemptRepository(db *pgxpool.Pool) *PostgresAttemptRepository {
+	return &PostgresAttemptRepository{db: db}
+}
+
+func (r *PostgresAttemptRepository) GetAttempts(ctx context.Context, key string) (*lockout.Attempts, error) {
+	query := `SELECT key, kind, subject, failures, last_failure, locked_until FROM login_attempts WHERE key = $1`
+
+	var a lockout.Attempts
+
+	err := r.db.QueryRow(ctx, query, key).Scan(&a.Key, &a.Kind, &a.Subject, &a.Failures, &a.LastFailure, &a.LockedUntil)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, lockout.ErrAttemptsNotFound
+		}
+		return nil, fmt.Errorf("error scanning login attempts: %v", err)
+	}
+
+	return &a, nil
+}
+
+func (r *PostgresAttemptRepository) SaveAttempts(ctx context.Context, a *lockout.Attempts) error {
+	query := `INSERT INTO login_attempts (key, kind, subject, failures, last_failure, locked_until) VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (key) DO UPDATE SET failures = $4, last_failure = $5, locked_until = $6`
+
+	_, err := r.db.Exec(ctx, query, a.Key, a.Kind, a.Subject, a.Failures, a.LastFailure, a.LockedUntil)
+	if err != nil {
+		return fmt.Errorf("error saving login attempts: %v", err)
+	}
+
+	return nil
+}
+
+func (r *PostgresAttemptRepository) DeleteAttempts(ctx context.Context, key string) error {
+	query := `DELETE FROM login_attempts WHERE key = $1`
+
+	_, err := r.db.Exec(ctx, query, key)
+	if err != nil {
+		return fmt.Errorf("error deleting login attempts: %v", err)
+	}
+
+	return nil
+}
+
+func (r *PostgresAttemptRepository) GetLocked(ctx context.Context, now time.Time) ([]lockout.Attempts, error) {
+	query := `SELECT key, kind, subject, failures, last_failure, locked_until FROM login_attempts WHERE locked_until > $1 ORDER BY locked_until DESC`
+
+	rows, err := r.db.Query(ctx, query, now)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving locked logins from db: %v", err)
+	}
+
+	defer rows.Close()
+
+	locked := []lockout.Attempts{}
+	for rows.Next() {
+		var a lockout.Attempts
+
+		err = rows.Scan(&a.Key, &a.Kind, &a.Subject, &a.Failures, &a.LastFailure, &a.LockedUntil)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning locked login: %v", err)
+		}
+
+		locked = append(locked, a)
+	}
+
+	return locked, nil
+}
@@ -0,0 +1,138 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"filmPackager/internal/domain/notification"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresNotificationRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresNotificationRepository(db *pgxpool.Pool) *PostgresNotificationRepository {
+	return &PostgresNotificationRepository{db: db}
+}
+
+const notificationColumns = `id, user_id, actor_id, kind, organization_id, document_id, comment_id, excerpt, created_at, read_at, emailed_at`
+
+func (r *PostgresNotificationRepository) CreateNotification(ctx context.Context, n *notification.Notification) error {
+	query := `INSERT INTO notifications (` + notificationColumns + `) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+
+	_, err := r.db.Exec(ctx, query, n.ID, n.UserID, n.ActorID, n.Kind, n.ProjectID, n.DocID, n.CommentID, n.Excerpt, n.CreatedAt, n.ReadAt, n.EmailedAt)
+	if err != nil {
+		return fmt.Errorf("error creating notification: %v", err)
+	}
+
+	return nil
+}
+
+func (r *PostgresNotificationRepository) GetNotification(ctx context.Context, notificationID uuid.UUID) (*notification.Notification, error) {
+	query := `SELECT ` + notificationColumns + ` FROM notifications WHERE id = $1`
+
+	var n notification.Notification
+
+	err := r.db.QueryRow(ctx, query, notificationID).Scan(&n.ID, &n.UserID, &n.ActorID, &n.Kind, &n.ProjectID, &n.DocID, &n.CommentID, &n.Excerpt, &n.CreatedAt, &n.ReadAt, &n.EmailedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, notification.ErrNotificationNotFound
+		}
+		return nil, fmt.Errorf("error scanning notification: %v", err)
+	}
+
+	return &n, nil
+}
+
+func (r *PostgresNotificationRepository) GetUserNotifications(ctx context.Context, userID uuid.UUID, limit int) ([]notification.Notification, error) {
+	query := `SELECT ` + notificationColumns + ` FROM notifications WHERE user_id = $1 ORDER BY created_at DESC LIMIT $2`
+
+	rows, err := r.db.Query(ctx, query, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving notifications from db: %v", err)
+	}
+
+	return scanNotifications(rows)
+}
+
+func (r *PostgresNotificationRepository) CountUnread(ctx context.Context, userID uuid.UUID) (int, error) {
+	query := `SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL`
+
+	var count int
+
+	err := r.db.QueryRow(ctx, query, userID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("error counting notifications: %v", err)
+	}
+
+	return count, nil
+}
+
+// only the user the notification is for can mark it read
+func (r *PostgresNotificationRepository) MarkRead(ctx context.Context, notificationID, userID uuid.UUID, now time.Time) error {
+	query := `UPDATE notifications SET read_at = $1 WHERE id = $2 AND user_id = $3 AND read_at IS NULL`
+
+	_, err := r.db.Exec(ctx, query, now, notificationID, userID)
+	if err != nil {
+		return fmt.Errorf("error marking notification read: %v", err)
+	}
+
+	return nil
+}
+
+func (r *PostgresNotificationRepository) MarkAllRead(ctx context.Context, userID uuid.UUID, now time.Time) error {
+	query := `UPDATE notifications SET read_at = $1 WHERE user_id = $2 AND read_at IS NULL`
+
+	_, err := r.db.Exec(ctx, query, now, userID)
+	if err != nil {
+		return fmt.Errorf("error marking notifications read: %v", err)
+	}
+
+	return nil
+}
+
+func (r *PostgresNotificationRepository) GetUnemailed(ctx context.Context, limit int) ([]notification.Notification, error) {
+	query := `SELECT ` + notificationColumns + ` FROM notifications WHERE emailed_at IS NULL ORDER BY created_at LIMIT $1`
+
+	rows, err := r.db.Query(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving notifications from db: %v", err)
+	}
+
+	return scanNotifications(rows)
+}
+
+func (r *PostgresNotificationRepository) MarkEmailed(ctx context.Context, notificationID uuid.UUID, now time.Time) error {
+	query := `UPDATE notifications SET emailed_at = $1 WHERE id = $2`
+
+	_, err := r.db.Exec(ctx, query, now, notificationID)
+	if err != nil {
+		return fmt.Errorf("error marking notification emailed: %v", err)
+	}
+
+	return nil
+}
+
+func scanNotifications(rows pgx.Rows) ([]notification.Notification, error) {
+	defer rows.Close()
+
+	var notifications []notification.Notification
+
+	for rows.Next() {
+		var n notification.Notification
+
+		err := rows.Scan(&n.ID, &n.UserID, &n.ActorID, &n.Kind, &n.ProjectID, &n.DocID, &n.CommentID, &n.Excerpt, &n.CreatedAt, &n.ReadAt, &n.EmailedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning notification: %v", err)
+		}
+
+		notifications = append(notifications, n)
+	}
+
+	return notifications, nil
+}
@@ -59,9 +59,9 @@ func (r *PostgresProjectRepository) DeleteProject(ctx context.Context, projectId
 func (r *PostgresProjectRepository) GetProjectByID(ctx context.Context, projectId uuid.UUID) (*project.Project, error) {
 	var project project.Project
 
-	query := `SELECT id, name, owner_id FROM organizations WHERE id = $1`
+	query := `SELECT id, name, owner_id, require_two_factor FROM organizations WHERE id = $1`
 
-	err := r.db.QueryRow(ctx, query, projectId).Scan(&project.ID, &project.Name, &project.OwnerID)
+	err := r.db.QueryRow(ctx, query, projectId).Scan(&project.ID, &project.Name, &project.OwnerID, &project.RequireTwoFactor)
 	if err != nil {
 		return nil, fmt.Errorf("error getting project from db: %v", err)
 	}
@@ -103,9 +103,9 @@ func (r *PostgresProjectRepository) UpdateMemberRoles(ctx context.Context, proje
 }
 
 func (r *PostgresProjectRepository) UpdateProject(ctx context.Context, p *project.Project) error {
-	query := `UPDATE organizations SET name = $1, updated_at = $2 WHERE id = $3`
+	query := `UPDATE organizations SET name = $1, updated_at = $2, require_two_factor = $3 WHERE id = $4`
 
-	_, err := r.db.Exec(ctx, query, p.Name, p.LastUpdateAt, p.ID)
+	_, err := r.db.Exec(ctx, query, p.Name, p.LastUpdateAt, p.RequireTwoFactor, p.ID)
 	if err != nil {
 		return fmt.Errorf("error updating project: %v", err)
 	}
@@ -0,0 +1,96 @@
+package infrastructure
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// the largest chunk sent to clamd at once, well under its default StreamMaxLength
+const chunkSize = 64 * 1024
+
+// how long a scan can take when the context has no deadline of its own
+const scanTimeout = 2 * time.Minute
+
+// ClamdScanner streams uploads to a ClamAV daemon with the INSTREAM command
+type ClamdScanner struct {
+	network string
+	address string
+}
+
+// NewClamdScanner connects over a unix socket, e.g. /var/run/clamav/clamd.ctl
+func NewClamdScanner(socket string) *ClamdScanner {
+	return &ClamdScanner{network: "unix", address: socket}
+}
+
+func (s *ClamdScanner) Scan(ctx context.Context, file io.Reader) (string, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, s.network, s.address)
+	if err != nil {
+		return "", fmt.Errorf("error connecting to clamd: %v", err)
+	}
+	defer conn.Close()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(scanTimeout)
+	}
+	conn.SetDeadline(deadline)
+
+	// the z prefix means the command and reply are null terminated
+	_, err = conn.Write([]byte("zINSTREAM\x00"))
+	if err != nil {
+		return "", fmt.Errorf("error starting the scan: %v", err)
+	}
+
+	// each chunk is its length as 4 bytes, big endian, then the data. An empty chunk ends the stream
+	buf := make([]byte, chunkSize+4)
+	for {
+		n, err := file.Read(buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf[:4], uint32(n))
+			if _, werr := conn.Write(buf[:n+4]); werr != nil {
+				return "", fmt.Errorf("error sending the file to clamd: %v", werr)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("error reading the file: %v", err)
+		}
+	}
+
+	_, err = conn.Write([]byte{0, 0, 0, 0})
+	if err != nil {
+		return "", fmt.Errorf("error ending the scan: %v", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadBytes(0)
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("error reading the scan result: %v", err)
+	}
+
+	return parseReply(string(bytes.TrimRight(reply, "\x00")))
+}
+
+// the reply is "stream: OK", "stream: <signature> FOUND" or "<reason> ERROR"
+func parseReply(reply string) (string, error) {
+	reply = strings.TrimSpace(reply)
+	result := strings.TrimPrefix(reply, "stream: ")
+
+	switch {
+	case result == "OK":
+		return "", nil
+	case strings.HasSuffix(result, " FOUND"):
+		return strings.TrimSuffix(result, " FOUND"), nil
+	default:
+		return "", fmt.Errorf("clamd couldn't scan the file: %s", reply)
+	}
+}
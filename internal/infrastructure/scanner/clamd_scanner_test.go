@@ -0,0 +1,81 @@
+package infrastructure_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	infrastructure "filmPackager/internal/infrastructure/scanner"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// fakeClamd answers INSTREAM like clamd, finding EICAR in whatever it's sent
+func fakeClamd(t *testing.T) string {
+	socket := filepath.Join(t.TempDir(), "clamd.ctl")
+	l, err := net.Listen("unix", socket)
+	assert.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			r := bufio.NewReader(conn)
+			cmd, _ := r.ReadString(0)
+			if cmd != "zINSTREAM\x00" {
+				conn.Write([]byte("UNKNOWN COMMAND\x00"))
+				conn.Close()
+				continue
+			}
+
+			var body bytes.Buffer
+			for {
+				var size uint32
+				if binary.Read(r, binary.BigEndian, &size) != nil || size == 0 {
+					break
+				}
+				io.CopyN(&body, r, int64(size))
+			}
+
+			if strings.Contains(body.String(), "EICAR-STANDARD-ANTIVIRUS-TEST-FILE") {
+				conn.Write([]byte("stream: Win.Test.EICAR_HDB-1 FOUND\x00"))
+			} else {
+				conn.Write([]byte("stream: OK\x00"))
+			}
+			conn.Close()
+		}
+	}()
+
+	return socket
+}
+
+func TestClamdScanner(t *testing.T) {
+	assert := assert.New(t)
+
+	s := infrastructure.NewClamdScanner(fakeClamd(t))
+
+	signature, err := s.Scan(context.Background(), strings.NewReader("%PDF-1.7 a perfectly normal script"))
+	assert.NoError(err)
+	assert.Empty(signature)
+
+	// bigger than a chunk, with the signature across the end of the first one
+	big := strings.Repeat("x", 64*1024-10) + eicar
+	signature, err = s.Scan(context.Background(), strings.NewReader(big))
+	assert.NoError(err)
+	assert.Equal("Win.Test.EICAR_HDB-1", signature)
+
+	// no daemon to talk to
+	_, err = infrastructure.NewClamdScanner(filepath.Join(t.TempDir(), "missing.ctl")).Scan(context.Background(), strings.NewReader("x"))
+	assert.Error(err)
+}
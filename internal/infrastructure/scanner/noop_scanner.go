@@ -0,0 +1,17 @@
+package infrastructure
+
+import (
+	"context"
+	"io"
+)
+
+// NoopScanner passes every file, for running without a ClamAV daemon
+type NoopScanner struct{}
+
+func NewNoopScanner() *NoopScanner {
+	return &NoopScanner{}
+}
+
+func (s *NoopScanner) Scan(ctx context.Context, file io.Reader) (string, error) {
+	return "", nil
+}
@@ -0,0 +1,110 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"filmPackager/internal/domain/session"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresSessionRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresSessionRepository(db *pgxpool.Pool) *PostgresSessionRepository {
+	return &PostgresSessionRepository{db: db}
+}
+
+func (r *PostgresSessionRepository) CreateSession(ctx context.Context, s *session.Session) error {
+	query := `INSERT INTO sessions (id, user_id, user_agent, ip, created_at, last_seen_at, expires_at, revoked_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	_, err := r.db.Exec(ctx, query, s.ID, s.UserID, s.UserAgent, s.IP, s.CreatedAt, s.LastSeenAt, s.ExpiresAt, s.RevokedAt)
+	if err != nil {
+		return fmt.Errorf("error creating session: %v", err)
+	}
+
+	return nil
+}
+
+func (r *PostgresSessionRepository) GetSession(ctx context.Context, id uuid.UUID) (*session.Session, error) {
+	query := `SELECT id, user_id, user_agent, ip, created_at, last_seen_at, expires_at, revoked_at FROM sessions WHERE id = $1`
+
+	var s session.Session
+
+	err := r.db.QueryRow(ctx, query, id).Scan(&s.ID, &s.UserID, &s.UserAgent, &s.IP, &s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt, &s.RevokedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, session.ErrSessionNotFound
+		}
+		return nil, fmt.Errorf("error scanning session: %v", err)
+	}
+
+	return &s, nil
+}
+
+func (r *PostgresSessionRepository) TouchSession(ctx context.Context, id uuid.UUID, ip string, at time.Time) error {
+	query := `UPDATE sessions SET ip = $1, last_seen_at = $2 WHERE id = $3`
+
+	_, err := r.db.Exec(ctx, query, ip, at, id)
+	if err != nil {
+		return fmt.Errorf("error updating session: %v", err)
+	}
+
+	return nil
+}
+
+func (r *PostgresSessionRepository) GetActiveSessions(ctx context.Context, userID uuid.UUID, now time.Time) ([]session.Session, error) {
+	query := `SELECT id, user_id, user_agent, ip, created_at, last_seen_at, expires_at, revoked_at FROM sessions WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > $2 ORDER BY last_seen_at DESC`
+
+	rows, err := r.db.Query(ctx, query, userID, now)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving sessions from db: %v", err)
+	}
+
+	defer rows.Close()
+
+	sessions := []session.Session{}
+	for rows.Next() {
+		var s session.Session
+
+		err = rows.Scan(&s.ID, &s.UserID, &s.UserAgent, &s.IP, &s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt, &s.RevokedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning session: %v", err)
+		}
+
+		sessions = append(sessions, s)
+	}
+
+	return sessions, nil
+}
+
+func (r *PostgresSessionRepository) RevokeSession(ctx context.Context, userID, id uuid.UUID, at time.Time) error {
+	query := `UPDATE sessions SET revoked_at = $1 WHERE id = $2 AND user_id = $3 AND revoked_at IS NULL`
+
+	tag, err := r.db.Exec(ctx, query, at, id, userID)
+	if err != nil {
+		return fmt.Errorf("error revoking session: %v", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return session.ErrSessionNotFound
+	}
+
+	return nil
+}
+
+func (r *PostgresSessionRepository) RevokeUserSessions(ctx context.Context, userID, keep uuid.UUID, at time.Time) error {
+	query := `UPDATE sessions SET revoked_at = $1 WHERE user_id = $2 AND id <> $3 AND revoked_at IS NULL`
+
+	_, err := r.db.Exec(ctx, query, at, userID, keep)
+	if err != nil {
+		return fmt.Errorf("error revoking sessions: %v", err)
+	}
+
+	return nil
+}
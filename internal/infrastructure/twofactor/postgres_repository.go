@@ -0,0 +1,59 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"filmPackager/internal/domain/twofactor"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresTwoFactorRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresTwoFactorRepository(db *pgxpool.Pool) *PostgresTwoFactorRepository {
+	return &PostgresTwoFactorRepository{db: db}
+}
+
+func (r *PostgresTwoFactorRepository) GetTwoFactor(ctx context.Context, userID uuid.UUID) (*twofactor.TwoFactor, error) {
+	query := `SELECT user_id, secret, enabled_at, last_used_step, recovery_hashes, created_at FROM two_factor WHERE user_id = $1`
+
+	var t twofactor.TwoFactor
+
+	err := r.db.QueryRow(ctx, query, userID).Scan(&t.UserID, &t.Secret, &t.EnabledAt, &t.LastUsedStep, &t.RecoveryHashes, &t.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, twofactor.ErrNotEnrolled
+		}
+		return nil, fmt.Errorf("error scanning two-factor settings: %v", err)
+	}
+
+	return &t, nil
+}
+
+func (r *PostgresTwoFactorRepository) SaveTwoFactor(ctx context.Context, t *twofactor.TwoFactor) error {
+	query := `INSERT INTO two_factor (user_id, secret, enabled_at, last_used_step, recovery_hashes, created_at) VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id) DO UPDATE SET secret = $2, enabled_at = $3, last_used_step = $4, recovery_hashes = $5, created_at = $6`
+
+	_, err := r.db.Exec(ctx, query, t.UserID, t.Secret, t.EnabledAt, t.LastUsedStep, t.RecoveryHashes, t.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error saving two-factor settings: %v", err)
+	}
+
+	return nil
+}
+
+func (r *PostgresTwoFactorRepository) DeleteTwoFactor(ctx context.Context, userID uuid.UUID) error {
+	query := `DELETE FROM two_factor WHERE user_id = $1`
+
+	_, err := r.db.Exec(ctx, query, userID)
+	if err != nil {
+		return fmt.Errorf("error deleting two-factor settings: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,183 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"filmPackager/internal/domain/webhook"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresWebhookRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresWebhookRepository(db *pgxpool.Pool) *PostgresWebhookRepository {
+	return &PostgresWebhookRepository{db: db}
+}
+
+func (r *PostgresWebhookRepository) CreateWebhook(ctx context.Context, w *webhook.Webhook) error {
+	query := `INSERT INTO webhooks (id, organization_id, url, secret, event_types, created_by, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	_, err := r.db.Exec(ctx, query, w.ID, w.ProjectID, w.URL, w.Secret, w.EventTypes, w.CreatedBy, w.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error creating webhook: %v", err)
+	}
+
+	return nil
+}
+
+func (r *PostgresWebhookRepository) GetWebhook(ctx context.Context, webhookID uuid.UUID) (*webhook.Webhook, error) {
+	query := `SELECT id, organization_id, url, secret, event_types, created_by, created_at FROM webhooks WHERE id = $1`
+
+	var w webhook.Webhook
+
+	err := r.db.QueryRow(ctx, query, webhookID).Scan(&w.ID, &w.ProjectID, &w.URL, &w.Secret, &w.EventTypes, &w.CreatedBy, &w.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, webhook.ErrWebhookNotFound
+		}
+		return nil, fmt.Errorf("error scanning webhook: %v", err)
+	}
+
+	return &w, nil
+}
+
+func (r *PostgresWebhookRepository) GetProjectWebhooks(ctx context.Context, projectID uuid.UUID) ([]webhook.Webhook, error) {
+	query := `SELECT id, organization_id, url, secret, event_types, created_by, created_at FROM webhooks WHERE organization_id = $1 ORDER BY created_at`
+
+	rows, err := r.db.Query(ctx, query, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving webhooks from db: %v", err)
+	}
+
+	defer rows.Close()
+
+	var hooks []webhook.Webhook
+
+	for rows.Next() {
+		var w webhook.Webhook
+
+		err = rows.Scan(&w.ID, &w.ProjectID, &w.URL, &w.Secret, &w.EventTypes, &w.CreatedBy, &w.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning rows: %v", err)
+		}
+
+		hooks = append(hooks, w)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return hooks, nil
+}
+
+func (r *PostgresWebhookRepository) DeleteWebhook(ctx context.Context, webhookID uuid.UUID) error {
+	// the delivery log goes with the webhook
+	_, err := r.db.Exec(ctx, `DELETE FROM webhook_deliveries WHERE webhook_id = $1`, webhookID)
+	if err != nil {
+		return fmt.Errorf("error deleting webhook deliveries: %v", err)
+	}
+
+	_, err = r.db.Exec(ctx, `DELETE FROM webhooks WHERE id = $1`, webhookID)
+	if err != nil {
+		return fmt.Errorf("error deleting webhook: %v", err)
+	}
+
+	return nil
+}
+
+func (r *PostgresWebhookRepository) CreateDelivery(ctx context.Context, d *webhook.Delivery) error {
+	query := `
+		INSERT INTO webhook_deliveries (id, webhook_id, event_type, payload, status, attempts, next_attempt_at, response_code, last_error, created_at, delivered_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+
+	_, err := r.db.Exec(ctx, query, d.ID, d.WebhookID, d.EventType, d.Payload, d.Status, d.Attempts, d.NextAttemptAt, d.ResponseCode, d.LastError, d.CreatedAt, d.DeliveredAt)
+	if err != nil {
+		return fmt.Errorf("error creating webhook delivery: %v", err)
+	}
+
+	return nil
+}
+
+const deliveryColumns = `id, webhook_id, event_type, payload, status, attempts, next_attempt_at, response_code, last_error, created_at, delivered_at`
+
+func scanDelivery(row pgx.Row, d *webhook.Delivery) error {
+	return row.Scan(&d.ID, &d.WebhookID, &d.EventType, &d.Payload, &d.Status, &d.Attempts, &d.NextAttemptAt, &d.ResponseCode, &d.LastError, &d.CreatedAt, &d.DeliveredAt)
+}
+
+func (r *PostgresWebhookRepository) GetDelivery(ctx context.Context, deliveryID uuid.UUID) (*webhook.Delivery, error) {
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries WHERE id = $1`
+
+	var d webhook.Delivery
+
+	err := scanDelivery(r.db.QueryRow(ctx, query, deliveryID), &d)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, webhook.ErrDeliveryNotFound
+		}
+		return nil, fmt.Errorf("error scanning webhook delivery: %v", err)
+	}
+
+	return &d, nil
+}
+
+func (r *PostgresWebhookRepository) GetWebhookDeliveries(ctx context.Context, webhookID uuid.UUID, limit int) ([]webhook.Delivery, error) {
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries WHERE webhook_id = $1 ORDER BY created_at DESC LIMIT $2`
+
+	return r.queryDeliveries(ctx, query, webhookID, limit)
+}
+
+// ClaimDueDeliveries takes the due deliveries in one statement, rows another worker has locked are skipped
+// rather than waited on. A sending delivery past its claim was left by a worker that stopped, so it's due again
+func (r *PostgresWebhookRepository) ClaimDueDeliveries(ctx context.Context, now time.Time, limit int) ([]webhook.Delivery, error) {
+	query := `UPDATE webhook_deliveries SET status = $1, next_attempt_at = $2 WHERE id IN (
+		SELECT id FROM webhook_deliveries WHERE status IN ($1, $3) AND next_attempt_at <= $4 ORDER BY next_attempt_at LIMIT $5 FOR UPDATE SKIP LOCKED
+	) RETURNING ` + deliveryColumns
+
+	return r.queryDeliveries(ctx, query, webhook.DeliverySending, now.Add(webhook.ClaimTimeout), webhook.DeliveryPending, now, limit)
+}
+
+func (r *PostgresWebhookRepository) queryDeliveries(ctx context.Context, query string, args ...any) ([]webhook.Delivery, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving webhook deliveries from db: %v", err)
+	}
+
+	defer rows.Close()
+
+	var deliveries []webhook.Delivery
+
+	for rows.Next() {
+		var d webhook.Delivery
+
+		err = scanDelivery(rows, &d)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning rows: %v", err)
+		}
+
+		deliveries = append(deliveries, d)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return deliveries, nil
+}
+
+func (r *PostgresWebhookRepository) UpdateDelivery(ctx context.Context, d *webhook.Delivery) error {
+	query := `UPDATE webhook_deliveries SET status = $1, attempts = $2, next_attempt_at = $3, response_code = $4, last_error = $5, delivered_at = $6 WHERE id = $7`
+
+	_, err := r.db.Exec(ctx, query, d.Status, d.Attempts, d.NextAttemptAt, d.ResponseCode, d.LastError, d.DeliveredAt, d.ID)
+	if err != nil {
+		return fmt.Errorf("error updating webhook delivery: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"errors"
+	"filmPackager/internal/application/documentservice"
+	"filmPackager/internal/application/membershipservice"
+	"filmPackager/internal/application/middleware/auth"
+	"filmPackager/internal/application/middleware/csrf"
+	"filmPackager/internal/domain/membership"
+	"filmPackager/internal/domain/project"
+	"filmPackager/internal/domain/user"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// RequireUser rejects requests without a logged in user or valid API token, the HTML routes redirect instead
+func RequireUser() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if auth.GetUserFromContext(c) == nil {
+			return respondError(c, errUnauthorized())
+		}
+
+		t := auth.GetTokenFromContext(c)
+		if t != nil && !t.AllowsMethod(c.Method()) {
+			return respondError(c, errForbidden("this API token is read only"))
+		}
+
+		// requests made with the session cookie need the CSRF token like the HTML routes
+		if t == nil {
+			err := csrf.Check(c)
+			if err != nil {
+				return respondError(c, errForbidden(err.Error()))
+			}
+		}
+
+		return c.Next()
+	}
+}
+
+// RequireUser runs first so the user is always set
+func currentUser(c *fiber.Ctx) *user.User {
+	return auth.GetUserFromContext(c)
+}
+
+func uuidParam(c *fiber.Ctx, name string) (uuid.UUID, error) {
+	id, err := uuid.Parse(c.Params(name))
+	if err != nil {
+		return uuid.Nil, errBadRequest("invalid " + name)
+	}
+	return id, nil
+}
+
+// API tokens can be limited to some of the user's projects, sessions reach them all
+func tokenAllowsProject(c *fiber.Ctx, projectID uuid.UUID) bool {
+	t := auth.GetTokenFromContext(c)
+	return t == nil || t.AllowsProject(projectID)
+}
+
+// returns the user's accepted membership of the project, pending invites don't count
+func requireMember(c *fiber.Ctx, memberSvc *membershipservice.MembershipService, projectID uuid.UUID) (*membership.Membership, error) {
+	if !tokenAllowsProject(c, projectID) {
+		return nil, errNotFound("project not found")
+	}
+
+	rv, err := memberSvc.GetMembership(c.Context(), projectID, currentUser(c).Id)
+	if err != nil || rv.Membership.InviteStatus != "accepted" {
+		return nil, errNotFound("project not found")
+	}
+
+	err = memberSvc.CheckTwoFactor(c.Context(), projectID, currentUser(c).Id)
+	if errors.Is(err, project.ErrTwoFactorRequired) {
+		return nil, errForbidden(err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return rv.Membership, nil
+}
+
+// looks up the document and checks the user can see its project
+func requireDocument(c *fiber.Ctx, docSvc *documentservice.DocumentService, memberSvc *membershipservice.MembershipService, docID uuid.UUID) (*documentservice.GetDocumentDetailsResponse, *membership.Membership, error) {
+	doc, err := docSvc.GetDocumentDetails(c.Context(), docID)
+	if err != nil {
+		return nil, nil, errNotFound("document not found")
+	}
+
+	// members without two-factor authentication are told why, everyone else can't tell the document exists
+	m, err := requireMember(c, memberSvc, doc.OrgID)
+	var apiErr *Error
+	if errors.As(err, &apiErr) && apiErr.Status == fiber.StatusForbidden {
+		return nil, nil, err
+	}
+	if err != nil {
+		return nil, nil, errNotFound("document not found")
+	}
+
+	return doc, m, nil
+}
@@ -0,0 +1,272 @@
+package api
+
+import (
+	"errors"
+	"filmPackager/internal/application/commentservice"
+	"filmPackager/internal/application/documentservice"
+	"filmPackager/internal/application/membershipservice"
+	"filmPackager/internal/domain/comment"
+	"filmPackager/internal/domain/membership"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type commentRequest struct {
+	Text   string  `json:"text"`
+	Anchor *Anchor `json:"anchor"`
+}
+
+// reads the comment from the body, the text can't be blank
+func parseCommentRequest(c *fiber.Ctx) (*commentRequest, error) {
+	req := commentRequest{}
+	err := c.BodyParser(&req)
+	if err != nil {
+		return nil, errBadRequest("invalid request body")
+	}
+
+	req.Text = strings.TrimSpace(req.Text)
+	if req.Text == "" {
+		return nil, errBadRequest("text is required")
+	}
+
+	return &req, nil
+}
+
+// the anchor is optional, only comments that start a thread can have one
+func (req *commentRequest) anchor() (*comment.Anchor, error) {
+	if req.Anchor == nil {
+		return nil, nil
+	}
+
+	var rect *comment.Rect
+	if req.Anchor.Rect != nil {
+		rect = &comment.Rect{X: req.Anchor.Rect.X, Y: req.Anchor.Rect.Y, Width: req.Anchor.Rect.Width, Height: req.Anchor.Rect.Height}
+	}
+
+	a, err := comment.NewAnchor(req.Anchor.Page, rect, strings.TrimSpace(req.Anchor.Text))
+	if err != nil {
+		return nil, errBadRequest(err.Error())
+	}
+
+	return a, nil
+}
+
+// looks up the comment and checks the user can see its document
+func requireComment(c *fiber.Ctx, svc *commentservice.CommentService, docSvc *documentservice.DocumentService, memberSvc *membershipservice.MembershipService) (*comment.Comment, *membership.Membership, error) {
+	commentID, err := uuidParam(c, "comment_id")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cm, err := svc.GetComment(c.Context(), commentID)
+	if err != nil {
+		return nil, nil, errNotFound("comment not found")
+	}
+
+	_, m, err := requireDocument(c, docSvc, memberSvc, cm.DocID)
+	if err != nil {
+		return nil, nil, errNotFound("comment not found")
+	}
+
+	return cm, m, nil
+}
+
+// lists the document's threads newest first, ?status=open or ?status=resolved narrows the list
+func ListComments(svc *commentservice.CommentService, docSvc *documentservice.DocumentService, memberSvc *membershipservice.MembershipService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		docID, err := uuidParam(c, "doc_id")
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		_, _, err = requireDocument(c, docSvc, memberSvc, docID)
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		status := c.Query("status", comment.FilterAll)
+		if !comment.IsValidFilter(status) {
+			return respondError(c, errBadRequest("status must be open or resolved"))
+		}
+
+		rv, err := svc.GetDocComments(c.Context(), docID, currentUser(c).Id, status)
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		comments := []Comment{}
+		for _, cm := range rv.Comments {
+			comments = append(comments, newComment(cm))
+		}
+
+		return sendPage(c, comments)
+	}
+}
+
+func CreateComment(svc *commentservice.CommentService, docSvc *documentservice.DocumentService, memberSvc *membershipservice.MembershipService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		docID, err := uuidParam(c, "doc_id")
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		_, _, err = requireDocument(c, docSvc, memberSvc, docID)
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		req, err := parseCommentRequest(c)
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		anchor, err := req.anchor()
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		cm, err := svc.CreateComment(c.Context(), req.Text, currentUser(c).Id, docID, anchor)
+		if err != nil {
+			return respondError(c, commentError(err))
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(dataBody{Data: newComment(*cm)})
+	}
+}
+
+// only the author can edit a comment, the old text is kept as a revision
+func EditComment(svc *commentservice.CommentService, docSvc *documentservice.DocumentService, memberSvc *membershipservice.MembershipService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		cm, _, err := requireComment(c, svc, docSvc, memberSvc)
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		req, err := parseCommentRequest(c)
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		if req.Anchor != nil {
+			return respondError(c, errBadRequest("the anchor can't be changed"))
+		}
+
+		thread, err := svc.EditComment(c.Context(), cm.ID, currentUser(c).Id, req.Text)
+		if err != nil {
+			return respondError(c, commentError(err))
+		}
+
+		return c.JSON(dataBody{Data: newComment(*thread)})
+	}
+}
+
+func ListRevisions(svc *commentservice.CommentService, docSvc *documentservice.DocumentService, memberSvc *membershipservice.MembershipService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		cm, _, err := requireComment(c, svc, docSvc, memberSvc)
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		rv, err := svc.GetRevisions(c.Context(), cm.ID, currentUser(c).Id)
+		if err != nil {
+			return respondError(c, commentError(err))
+		}
+
+		revisions := []Revision{}
+		for _, r := range rv.Revisions {
+			revisions = append(revisions, Revision{Text: r.Text, ReplacedAt: r.ReplacedAt})
+		}
+
+		return sendPage(c, revisions)
+	}
+}
+
+// comments can be deleted by their author or the project owner
+func DeleteComment(svc *commentservice.CommentService, docSvc *documentservice.DocumentService, memberSvc *membershipservice.MembershipService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		cm, _, err := requireComment(c, svc, docSvc, memberSvc)
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		_, err = svc.DeleteComment(c.Context(), cm.ID, currentUser(c).Id)
+		if err != nil {
+			return respondError(c, commentError(err))
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+// replies to the comment's thread and returns the whole thread
+func CreateReply(svc *commentservice.CommentService, docSvc *documentservice.DocumentService, memberSvc *membershipservice.MembershipService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		cm, _, err := requireComment(c, svc, docSvc, memberSvc)
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		req, err := parseCommentRequest(c)
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		// replies share their thread's anchor
+		if req.Anchor != nil {
+			return respondError(c, errBadRequest("replies can't be anchored"))
+		}
+
+		thread, err := svc.CreateReply(c.Context(), req.Text, currentUser(c).Id, cm.ID)
+		if err != nil {
+			return respondError(c, commentError(err))
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(dataBody{Data: newComment(*thread)})
+	}
+}
+
+func ResolveThread(svc *commentservice.CommentService, docSvc *documentservice.DocumentService, memberSvc *membershipservice.MembershipService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		cm, _, err := requireComment(c, svc, docSvc, memberSvc)
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		thread, err := svc.ResolveThread(c.Context(), cm.ID, currentUser(c).Id)
+		if err != nil {
+			return respondError(c, commentError(err))
+		}
+
+		return c.JSON(dataBody{Data: newComment(*thread)})
+	}
+}
+
+func ReopenThread(svc *commentservice.CommentService, docSvc *documentservice.DocumentService, memberSvc *membershipservice.MembershipService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		cm, _, err := requireComment(c, svc, docSvc, memberSvc)
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		thread, err := svc.ReopenThread(c.Context(), cm.ID, currentUser(c).Id)
+		if err != nil {
+			return respondError(c, commentError(err))
+		}
+
+		return c.JSON(dataBody{Data: newComment(*thread)})
+	}
+}
+
+func commentError(err error) error {
+	if errors.Is(err, comment.ErrNotAThread) || errors.Is(err, comment.ErrAlreadyResolved) || errors.Is(err, comment.ErrNotResolved) {
+		return errConflict(err.Error())
+	}
+	if errors.Is(err, comment.ErrNotAuthor) || errors.Is(err, comment.ErrCannotDelete) || errors.Is(err, comment.ErrNotMember) {
+		return errForbidden(err.Error())
+	}
+	if errors.Is(err, comment.ErrEmptyComment) || errors.Is(err, comment.ErrCommentTooLong) {
+		return errBadRequest(err.Error())
+	}
+	return err
+}
@@ -0,0 +1,228 @@
+package api
+
+import (
+	"errors"
+	"filmPackager/internal/application/documentservice"
+	"filmPackager/internal/application/membershipservice"
+	"filmPackager/internal/application/projectservice"
+	"filmPackager/internal/domain/document"
+	"filmPackager/internal/domain/watermark"
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// the document types a project can hold, each one has at most one staged and one locked document
+var fileTypes = []string{"Script", "Logline", "Synopsis", "PitchDeck", "Schedule", "Budget", "Shotlist", "Lookbook"}
+
+// lists the project's documents, ?status=staged or ?status=locked narrows the list
+func ListDocuments(svc *projectservice.ProjectService, memberSvc *membershipservice.MembershipService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		pID, err := uuidParam(c, "project_id")
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		_, err = requireMember(c, memberSvc, pID)
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		status := c.Query("status")
+		if status != "" && status != "staged" && status != "locked" {
+			return respondError(c, errBadRequest("status must be staged or locked"))
+		}
+
+		rv, err := svc.GetProjectDetails(c.Context(), pID, currentUser(c).Id)
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		docs := []DocumentSummary{}
+		for _, d := range newDocumentSummaries(rv) {
+			if status == "" || d.Status == status {
+				docs = append(docs, d)
+			}
+		}
+
+		return sendPage(c, docs)
+	}
+}
+
+// stages a new document, replacing any staged document of the same type
+func UploadDocument(svc *documentservice.DocumentService, memberSvc *membershipservice.MembershipService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		pID, err := uuidParam(c, "project_id")
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		_, err = requireMember(c, memberSvc, pID)
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		fileType := c.FormValue("file_type")
+		if !slices.Contains(fileTypes, fileType) {
+			return respondError(c, errBadRequest("file_type must be one of Script, Logline, Synopsis, PitchDeck, Schedule, Budget, Shotlist, Lookbook"))
+		}
+
+		file, err := c.FormFile("file")
+		if err != nil {
+			return respondError(c, errBadRequest("file is required"))
+		}
+
+		f, err := file.Open()
+		if err != nil {
+			return respondError(c, err)
+		}
+		defer f.Close()
+
+		staged, err := svc.UploadDocument(c.Context(), pID, currentUser(c).Id, file.Filename, fileType, f, file.Size)
+		if err != nil {
+			return respondError(c, uploadError(err))
+		}
+
+		d := staged[fileType]
+
+		return c.Status(fiber.StatusCreated).JSON(dataBody{Data: DocumentSummary{ID: d.ID, FileType: fileType, Status: "staged", ScanStatus: d.ScanStatus, Date: d.Date}})
+	}
+}
+
+// the errors an upload can be turned away with, anything else is left as it is
+func uploadError(err error) error {
+	var formatErr *document.FormatError
+	var tooLarge *document.TooLargeError
+	message := strings.TrimPrefix(err.Error(), "Error: ")
+
+	switch {
+	case errors.Is(err, document.ErrAccessDenied):
+		return errForbidden("your role can't upload this document type")
+	case errors.As(err, &formatErr), errors.Is(err, document.ErrEmptyUpload), errors.Is(err, document.ErrIncompleteUpload):
+		return errBadRequest(message)
+	case errors.As(err, &tooLarge):
+		return errTooLarge(message)
+	case errors.Is(err, document.ErrUploadNotFound):
+		return errNotFound("upload not found")
+	}
+
+	return err
+}
+
+// moves the staged documents to locked, replacing the locked documents of the same type
+func LockDocuments(svc *documentservice.DocumentService, memberSvc *membershipservice.MembershipService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		pID, err := uuidParam(c, "project_id")
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		_, err = requireMember(c, memberSvc, pID)
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		err = svc.LockDocuments(c.Context(), pID, currentUser(c).Id)
+		if err != nil {
+			switch {
+			case errors.Is(err, document.ErrAccessDenied):
+				return respondError(c, errForbidden("only owners, directors and producers can lock documents"))
+			case errors.Is(err, document.ErrScanPending), errors.Is(err, document.ErrQuarantined):
+				return respondError(c, errConflict("every staged document has to pass the malware scan before locking"))
+			}
+			return respondError(c, err)
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+func GetDocument(svc *documentservice.DocumentService, memberSvc *membershipservice.MembershipService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		docID, err := uuidParam(c, "doc_id")
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		doc, _, err := requireDocument(c, svc, memberSvc, docID)
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		return c.JSON(dataBody{Data: newDocument(doc)})
+	}
+}
+
+func DownloadDocument(svc *documentservice.DocumentService, memberSvc *membershipservice.MembershipService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		docID, err := uuidParam(c, "doc_id")
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		_, _, err = requireDocument(c, svc, memberSvc, docID)
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		rv, err := svc.DownloadDocument(c.Context(), docID, documentservice.DownloadDocumentRequest{UserID: currentUser(c).Id, Range: document.ParseRange(c.Get(fiber.HeaderRange))})
+		if err != nil {
+			if errors.Is(err, document.ErrScanPending) || errors.Is(err, document.ErrQuarantined) {
+				return respondError(c, errConflict(strings.TrimPrefix(err.Error(), "Error: ")))
+			}
+			if errors.Is(err, watermark.ErrUnreadablePDF) || errors.Is(err, watermark.ErrEncryptedPDF) {
+				return respondError(c, errConflict(strings.TrimPrefix(err.Error(), "Error: ")))
+			}
+			if errors.Is(err, document.ErrRangeNotSatisfiable) {
+				return respondError(c, errRangeNotSatisfiable("the range starts past the end of the file"))
+			}
+			return respondError(c, err)
+		}
+
+		// the client follows the redirect to the bucket, or gets the file from here
+		if rv.URL != "" {
+			return c.Redirect(rv.URL, fiber.StatusTemporaryRedirect)
+		}
+
+		c.Set(fiber.HeaderContentType, rv.ContentType)
+		c.Set(fiber.HeaderContentDisposition, rv.Disposition)
+		if !rv.Watermarked {
+			c.Set(fiber.HeaderAcceptRanges, "bytes")
+		}
+		if rv.ContentRange != "" {
+			c.Set(fiber.HeaderContentRange, rv.ContentRange)
+			c.Status(fiber.StatusPartialContent)
+		}
+
+		// streamed rather than copied into the response, fasthttp closes the body once it's sent
+		c.Context().SetBodyStream(rv.Body, int(rv.Size))
+		return nil
+	}
+}
+
+// only staged documents can be deleted, the same as the delete button on the document page
+func DeleteDocument(svc *documentservice.DocumentService, memberSvc *membershipservice.MembershipService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		docID, err := uuidParam(c, "doc_id")
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		doc, _, err := requireDocument(c, svc, memberSvc, docID)
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		if doc.Status != "staged" {
+			return respondError(c, errConflict("locked documents can't be deleted"))
+		}
+
+		_, err = svc.DeleteDocument(c.Context(), docID, currentUser(c).Id)
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"errors"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Error is returned by every endpoint as {"error": {"code": ..., "message": ...}}
+type Error struct {
+	Status  int    `json:"-"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+type errorBody struct {
+	Error *Error `json:"error"`
+}
+
+func errBadRequest(message string) *Error {
+	return &Error{Status: fiber.StatusBadRequest, Code: "bad_request", Message: message}
+}
+
+func errUnauthorized() *Error {
+	return &Error{Status: fiber.StatusUnauthorized, Code: "unauthorized", Message: "authentication required"}
+}
+
+func errForbidden(message string) *Error {
+	return &Error{Status: fiber.StatusForbidden, Code: "forbidden", Message: message}
+}
+
+func errNotFound(message string) *Error {
+	return &Error{Status: fiber.StatusNotFound, Code: "not_found", Message: message}
+}
+
+func errConflict(message string) *Error {
+	return &Error{Status: fiber.StatusConflict, Code: "conflict", Message: message}
+}
+
+func errTooLarge(message string) *Error {
+	return &Error{Status: fiber.StatusRequestEntityTooLarge, Code: "too_large", Message: message}
+}
+
+func errRangeNotSatisfiable(message string) *Error {
+	return &Error{Status: fiber.StatusRequestedRangeNotSatisfiable, Code: "range_not_satisfiable", Message: message}
+}
+
+// respondError writes the error body, anything that isn't an *Error is logged and hidden behind a 500
+func respondError(c *fiber.Ctx, err error) error {
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		log.Printf("api error on %s %s: %v", c.Method(), c.Path(), err)
+		apiErr = &Error{Status: fiber.StatusInternalServerError, Code: "internal", Message: "internal server error"}
+	}
+
+	return c.Status(apiErr.Status).JSON(errorBody{Error: apiErr})
+}
+
+// NotFound answers any unmatched /api/v1 path with a JSON 404
+func NotFound() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return respondError(c, errNotFound("no such endpoint"))
+	}
+}
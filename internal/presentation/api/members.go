@@ -0,0 +1,140 @@
+package api
+
+import (
+	"errors"
+	"filmPackager/internal/application/membershipservice"
+	"filmPackager/internal/domain/membership"
+	"slices"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// the roles that can be added to a member, owner is only set on project creation
+var assignableRoles = []string{"director", "producer", "writer", "cinematographer", "production_designer"}
+
+type inviteRequest struct {
+	UserID uuid.UUID `json:"user_id"`
+}
+
+type roleRequest struct {
+	Role string `json:"role"`
+}
+
+// lists the accepted members followed by the pending invites
+func ListMembers(memberSvc *membershipservice.MembershipService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		pID, err := uuidParam(c, "project_id")
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		_, err = requireMember(c, memberSvc, pID)
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		rv, err := memberSvc.GetProjectMemberships(c.Context(), pID)
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		members := []Member{}
+		for _, m := range rv.Members {
+			members = append(members, newMember(m))
+		}
+		for _, m := range rv.Invited {
+			members = append(members, newMember(m))
+		}
+
+		return sendPage(c, members)
+	}
+}
+
+func InviteMember(memberSvc *membershipservice.MembershipService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		pID, err := uuidParam(c, "project_id")
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		m, err := requireMember(c, memberSvc, pID)
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		if !m.HasLockingStatus() {
+			return respondError(c, errForbidden("only owners, directors and producers can invite members"))
+		}
+
+		req := inviteRequest{}
+		err = c.BodyParser(&req)
+		if err != nil || req.UserID == uuid.Nil {
+			return respondError(c, errBadRequest("user_id is required"))
+		}
+
+		invited, err := memberSvc.InviteUserToProject(c.Context(), currentUser(c).Id, req.UserID, pID)
+		if err != nil {
+			if errors.Is(err, membership.ErrUserAlreadyMember) {
+				return respondError(c, errConflict(err.Error()))
+			}
+			return respondError(c, err)
+		}
+
+		for _, i := range invited {
+			if i.UserID == req.UserID {
+				return c.Status(fiber.StatusCreated).JSON(dataBody{Data: newMember(i)})
+			}
+		}
+
+		return c.SendStatus(fiber.StatusCreated)
+	}
+}
+
+// adds a role to a member of the project
+func UpdateMemberRoles(memberSvc *membershipservice.MembershipService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		pID, err := uuidParam(c, "project_id")
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		userID, err := uuidParam(c, "user_id")
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		m, err := requireMember(c, memberSvc, pID)
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		if !m.IsOwner() {
+			return respondError(c, errForbidden("only the owner can change member roles"))
+		}
+
+		req := roleRequest{}
+		err = c.BodyParser(&req)
+		if err != nil || !slices.Contains(assignableRoles, req.Role) {
+			return respondError(c, errBadRequest("role must be one of director, producer, writer, cinematographer, production_designer"))
+		}
+
+		_, err = memberSvc.GetMembership(c.Context(), pID, userID)
+		if err != nil {
+			return respondError(c, errNotFound("member not found"))
+		}
+
+		_, err = memberSvc.UpdateMemberRoles(c.Context(), currentUser(c).Id, pID, userID, req.Role)
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		// re-read so the name and email are filled in
+		rv, err := memberSvc.GetMembership(c.Context(), pID, userID)
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		return c.JSON(dataBody{Data: newMember(*rv.Membership)})
+	}
+}
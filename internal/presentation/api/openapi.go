@@ -0,0 +1,18 @@
+package api
+
+import (
+	_ "embed"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+//go:embed openapi.yaml
+var openAPISpec []byte
+
+// OpenAPI serves the API description, keep openapi.yaml in step with the routes in server.go
+func OpenAPI() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Set("Content-Type", "application/yaml")
+		return c.Send(openAPISpec)
+	}
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	defaultPerPage = 20
+	maxPerPage     = 100
+)
+
+type Pagination struct {
+	Page       int `json:"page"`
+	PerPage    int `json:"per_page"`
+	Total      int `json:"total"`
+	TotalPages int `json:"total_pages"`
+}
+
+type listBody[T any] struct {
+	Data       []T        `json:"data"`
+	Pagination Pagination `json:"pagination"`
+}
+
+// reads ?page= and ?per_page=, out of range values fall back to the defaults
+func pageParams(c *fiber.Ctx) (int, int) {
+	page := c.QueryInt("page", 1)
+	if page < 1 {
+		page = 1
+	}
+
+	perPage := c.QueryInt("per_page", defaultPerPage)
+	if perPage < 1 || perPage > maxPerPage {
+		perPage = defaultPerPage
+	}
+
+	return page, perPage
+}
+
+// Paginate returns the items on the requested page along with the page info
+func Paginate[T any](items []T, page, perPage int) ([]T, Pagination) {
+	total := len(items)
+	p := Pagination{
+		Page:       page,
+		PerPage:    perPage,
+		Total:      total,
+		TotalPages: (total + perPage - 1) / perPage,
+	}
+
+	// checked before multiplying so a huge ?page= can't overflow the offset
+	if page > p.TotalPages {
+		return []T{}, p
+	}
+
+	start := (page - 1) * perPage
+
+	end := min(start+perPage, total)
+
+	return items[start:end], p
+}
+
+// sends a page of the items as {"data": [...], "pagination": {...}}
+func sendPage[T any](c *fiber.Ctx, items []T) error {
+	page, perPage := pageParams(c)
+	data, p := Paginate(items, page, perPage)
+
+	return c.JSON(listBody[T]{Data: data, Pagination: p})
+}
@@ -0,0 +1,43 @@
+package api_test
+
+import (
+	"filmPackager/internal/presentation/api"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPaginate(t *testing.T) {
+	items := []int{1, 2, 3, 4, 5}
+
+	t.Run("first page", func(t *testing.T) {
+		data, p := api.Paginate(items, 1, 2)
+		assert.Equal(t, []int{1, 2}, data)
+		assert.Equal(t, api.Pagination{Page: 1, PerPage: 2, Total: 5, TotalPages: 3}, p)
+	})
+
+	t.Run("last partial page", func(t *testing.T) {
+		data, _ := api.Paginate(items, 3, 2)
+		assert.Equal(t, []int{5}, data)
+	})
+
+	t.Run("page past the end", func(t *testing.T) {
+		data, p := api.Paginate(items, 4, 2)
+		assert.Empty(t, data)
+		assert.NotNil(t, data)
+		assert.Equal(t, 5, p.Total)
+	})
+
+	t.Run("page too large to multiply", func(t *testing.T) {
+		data, p := api.Paginate(items, math.MaxInt, 2)
+		assert.Empty(t, data)
+		assert.Equal(t, 3, p.TotalPages)
+	})
+
+	t.Run("empty list", func(t *testing.T) {
+		data, p := api.Paginate([]int{}, 1, 20)
+		assert.Empty(t, data)
+		assert.Equal(t, 0, p.TotalPages)
+	})
+}
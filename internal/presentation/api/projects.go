@@ -0,0 +1,180 @@
+package api
+
+import (
+	"filmPackager/internal/application/membershipservice"
+	"filmPackager/internal/application/middleware/auth"
+	"filmPackager/internal/application/projectservice"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type projectRequest struct {
+	Name string `json:"name"`
+}
+
+// reads the project name from the body, it can't be blank
+func parseProjectRequest(c *fiber.Ctx) (string, error) {
+	req := projectRequest{}
+	err := c.BodyParser(&req)
+	if err != nil {
+		return "", errBadRequest("invalid request body")
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return "", errBadRequest("name is required")
+	}
+
+	return name, nil
+}
+
+func ListProjects(svc *projectservice.ProjectService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rv, err := svc.GetUsersProjects(c.Context(), currentUser(c))
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		projects := []Project{}
+		for _, p := range newProjects(rv) {
+			if tokenAllowsProject(c, p.ID) {
+				projects = append(projects, p)
+			}
+		}
+
+		return sendPage(c, projects)
+	}
+}
+
+func CreateProject(svc *projectservice.ProjectService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		t := auth.GetTokenFromContext(c)
+		if t != nil && !t.AllowsNewProjects() {
+			return respondError(c, errForbidden("this API token is limited to specific projects"))
+		}
+
+		name, err := parseProjectRequest(c)
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		p, err := svc.CreateNewProject(c.Context(), name, currentUser(c).Id)
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(dataBody{Data: Project{ID: p.ID, Name: p.Name, InviteStatus: "accepted", Roles: p.Roles}})
+	}
+}
+
+func GetProject(svc *projectservice.ProjectService, memberSvc *membershipservice.MembershipService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		pID, err := uuidParam(c, "project_id")
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		_, err = requireMember(c, memberSvc, pID)
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		rv, err := svc.GetProjectDetails(c.Context(), pID, currentUser(c).Id)
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		return c.JSON(dataBody{Data: newProjectDetails(rv)})
+	}
+}
+
+func UpdateProject(svc *projectservice.ProjectService, memberSvc *membershipservice.MembershipService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		pID, err := uuidParam(c, "project_id")
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		m, err := requireMember(c, memberSvc, pID)
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		// the same members who can lock can rename, see the edit icon in doc-list.html
+		if !m.HasLockingStatus() {
+			return respondError(c, errForbidden("only owners, directors and producers can rename the project"))
+		}
+
+		name, err := parseProjectRequest(c)
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		p, err := svc.UpdateProjectName(c.Context(), pID, name)
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		return c.JSON(dataBody{Data: newProject(p)})
+	}
+}
+
+func DeleteProject(svc *projectservice.ProjectService, memberSvc *membershipservice.MembershipService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		pID, err := uuidParam(c, "project_id")
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		m, err := requireMember(c, memberSvc, pID)
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		if !m.IsOwner() {
+			return respondError(c, errForbidden("only the owner can delete the project"))
+		}
+
+		_, err = svc.DeleteProject(c.Context(), pID, currentUser(c))
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+// accepts a pending invite to the project
+func JoinProject(svc *projectservice.ProjectService, memberSvc *membershipservice.MembershipService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		pID, err := uuidParam(c, "project_id")
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		if !tokenAllowsProject(c, pID) {
+			return respondError(c, errNotFound("no invite to this project"))
+		}
+
+		rv, err := memberSvc.GetMembership(c.Context(), pID, currentUser(c).Id)
+		if err != nil {
+			return respondError(c, errNotFound("no invite to this project"))
+		}
+		if rv.Membership.InviteStatus != "pending" {
+			return respondError(c, errConflict("already a member of this project"))
+		}
+
+		err = svc.JoinProject(c.Context(), pID, currentUser(c).Id)
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		rv, err = memberSvc.GetMembership(c.Context(), pID, currentUser(c).Id)
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		return c.JSON(dataBody{Data: newMember(*rv.Membership)})
+	}
+}
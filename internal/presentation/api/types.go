@@ -0,0 +1,258 @@
+package api
+
+import (
+	"filmPackager/internal/application/commentservice"
+	"filmPackager/internal/application/documentservice"
+	"filmPackager/internal/application/projectservice"
+	"filmPackager/internal/domain/comment"
+	"filmPackager/internal/domain/membership"
+	"filmPackager/internal/domain/project"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+// the JSON representations returned by the API, kept separate from the
+// service responses so the templates and the API can change independently
+
+type dataBody struct {
+	Data any `json:"data"`
+}
+
+type Project struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+	// "accepted" or "pending" for the requesting user
+	InviteStatus string   `json:"invite_status,omitempty"`
+	Roles        []string `json:"roles,omitempty"`
+}
+
+type ProjectDetails struct {
+	ID           uuid.UUID         `json:"id"`
+	Name         string            `json:"name"`
+	OwnerID      uuid.UUID         `json:"owner_id"`
+	CreatedAt    string            `json:"created_at"`
+	LastUpdateAt string            `json:"last_update_at"`
+	Documents    []DocumentSummary `json:"documents"`
+	CanUpload    bool              `json:"can_upload"`
+	CanLock      bool              `json:"can_lock"`
+	// members without two-factor authentication get 403 for the project and its documents
+	RequireTwoFactor bool `json:"require_two_factor"`
+}
+
+type Member struct {
+	UserID       uuid.UUID `json:"user_id"`
+	Name         string    `json:"name"`
+	Email        string    `json:"email"`
+	Roles        []string  `json:"roles"`
+	InviteStatus string    `json:"invite_status"`
+}
+
+type DocumentSummary struct {
+	ID         uuid.UUID `json:"id"`
+	FileType   string    `json:"file_type"`
+	Status     string    `json:"status"`
+	ScanStatus string    `json:"scan_status"`
+	Date       string    `json:"date"`
+}
+
+type Document struct {
+	ID           uuid.UUID `json:"id"`
+	ProjectID    uuid.UUID `json:"project_id"`
+	FileName     string    `json:"file_name"`
+	FileType     string    `json:"file_type"`
+	Status       string    `json:"status"`
+	ScanStatus   string    `json:"scan_status"`
+	Checksum     string    `json:"checksum,omitempty"`
+	UploaderName string    `json:"uploader_name"`
+	UploadDate   string    `json:"upload_date"`
+}
+
+type UploadPart struct {
+	Number int32  `json:"number"`
+	URL    string `json:"url"`
+}
+
+type UploadSession struct {
+	ID       uuid.UUID `json:"id"`
+	PartSize int64     `json:"part_size"`
+	// PUT each part's bytes to its URL
+	Parts []UploadPart `json:"parts"`
+	Done  []int32      `json:"done"`
+}
+
+type Author struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+}
+
+type Comment struct {
+	ID         uuid.UUID  `json:"id"`
+	DocumentID uuid.UUID  `json:"document_id"`
+	ParentID   *uuid.UUID `json:"parent_id,omitempty"`
+	Text       string     `json:"text"`
+	// the text rendered from Markdown, safe to display as is
+	HTML      string `json:"html"`
+	Author    Author `json:"author"`
+	CreatedAt string `json:"created_at"`
+	// only set on the comment that starts a thread
+	Resolved   *bool     `json:"resolved,omitempty"`
+	ResolvedBy *Author   `json:"resolved_by,omitempty"`
+	ResolvedAt string    `json:"resolved_at,omitempty"`
+	Replies    []Comment `json:"replies,omitempty"`
+	Anchor     *Anchor   `json:"anchor,omitempty"`
+	Edited     bool      `json:"edited"`
+	EditedAt   string    `json:"edited_at,omitempty"`
+	// the version the thread was written on when it was carried forward
+	CarriedFromVersion int `json:"carried_from_version,omitempty"`
+}
+
+type Revision struct {
+	Text       string `json:"text"`
+	ReplacedAt string `json:"replaced_at"`
+}
+
+type Anchor struct {
+	// pages start at 1
+	Page int `json:"page"`
+	// fractions of the page measured from the top left corner
+	Rect *Rect  `json:"rect,omitempty"`
+	Text string `json:"text,omitempty"`
+}
+
+type Rect struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+func newProjects(rv *projectservice.GetUsersProjectsResponse) []Project {
+	projects := []Project{}
+	for _, p := range rv.Accepted {
+		projects = append(projects, Project{ID: p.ID, Name: p.Name, InviteStatus: "accepted", Roles: p.Roles})
+	}
+	for _, p := range rv.Invited {
+		projects = append(projects, Project{ID: p.ID, Name: p.Name, InviteStatus: "pending", Roles: p.Roles})
+	}
+	return projects
+}
+
+func newProject(p *project.Project) Project {
+	return Project{ID: p.ID, Name: p.Name}
+}
+
+func newProjectDetails(rv *projectservice.GetProjectDetailsResponse) ProjectDetails {
+	return ProjectDetails{
+		ID:           rv.Project.ID,
+		Name:         rv.Project.Name,
+		OwnerID:      rv.Project.OwnerID,
+		CreatedAt:    rv.Project.CreatedAt.Format("01-02-2006"),
+		LastUpdateAt: rv.Project.LastUpdateAt.Format("01-02-2006"),
+		Documents:    newDocumentSummaries(rv),
+		CanUpload:    rv.UploadStatus,
+		CanLock:      rv.LockStatus,
+
+		RequireTwoFactor: rv.Project.RequireTwoFactor,
+	}
+}
+
+// flattens the staged and locked maps, staged first and then by file type so pages are stable
+func newDocumentSummaries(rv *projectservice.GetProjectDetailsResponse) []DocumentSummary {
+	docs := []DocumentSummary{}
+	if rv.Staged != nil {
+		for fileType, d := range *rv.Staged {
+			docs = append(docs, DocumentSummary{ID: d.ID, FileType: fileType, Status: "staged", ScanStatus: d.ScanStatus, Date: d.Date})
+		}
+	}
+	if rv.Locked != nil {
+		for fileType, d := range *rv.Locked {
+			docs = append(docs, DocumentSummary{ID: d.ID, FileType: fileType, Status: "locked", ScanStatus: d.ScanStatus, Date: d.Date})
+		}
+	}
+
+	sort.Slice(docs, func(i, j int) bool {
+		if docs[i].Status != docs[j].Status {
+			return docs[i].Status == "staged"
+		}
+		return docs[i].FileType < docs[j].FileType
+	})
+
+	return docs
+}
+
+func newMember(m membership.Membership) Member {
+	return Member{
+		UserID:       m.UserID,
+		Name:         m.UserName,
+		Email:        m.UserEmail,
+		Roles:        membership.SortRoles(m.Roles),
+		InviteStatus: m.InviteStatus,
+	}
+}
+
+func newDocument(rv *documentservice.GetDocumentDetailsResponse) Document {
+	return Document{
+		ID:           rv.ID,
+		ProjectID:    rv.OrgID,
+		FileName:     rv.FileName,
+		FileType:     rv.DocType,
+		Status:       rv.Status,
+		ScanStatus:   rv.ScanStatus,
+		Checksum:     rv.Checksum,
+		UploaderName: rv.UploaderName,
+		UploadDate:   rv.UploadDate,
+	}
+}
+
+func newUploadSession(rv *documentservice.UploadSessionResponse) UploadSession {
+	parts := make([]UploadPart, len(rv.Parts))
+	for i, p := range rv.Parts {
+		parts[i] = UploadPart{Number: p.Number, URL: p.URL}
+	}
+
+	return UploadSession{ID: rv.ID, PartSize: rv.PartSize, Parts: parts, Done: rv.Done}
+}
+
+func newComment(c commentservice.CommentResponse) Comment {
+	rv := Comment{
+		ID:                 c.ID,
+		DocumentID:         c.DocID,
+		ParentID:           c.ParentID,
+		Text:               c.Text,
+		HTML:               string(c.HTML),
+		Author:             Author{ID: c.Author.Id, Name: c.Author.Name},
+		CreatedAt:          c.CreatedAt,
+		Anchor:             newAnchor(c.Anchor),
+		Edited:             c.Edited,
+		EditedAt:           c.EditedAt,
+		CarriedFromVersion: c.CarriedFrom,
+	}
+
+	if c.ParentID == nil {
+		resolved := c.Resolved
+		rv.Resolved = &resolved
+		if c.Resolved {
+			rv.ResolvedBy = &Author{ID: c.ResolvedBy.Id, Name: c.ResolvedBy.Name}
+			rv.ResolvedAt = c.ResolvedAt
+		}
+		rv.Replies = []Comment{}
+		for _, r := range c.Replies {
+			rv.Replies = append(rv.Replies, newComment(r))
+		}
+	}
+
+	return rv
+}
+
+func newAnchor(a *comment.Anchor) *Anchor {
+	if a == nil {
+		return nil
+	}
+
+	rv := &Anchor{Page: a.Page, Text: a.Text}
+	if a.Rect != nil {
+		rv.Rect = &Rect{X: a.Rect.X, Y: a.Rect.Y, Width: a.Rect.Width, Height: a.Rect.Height}
+	}
+	return rv
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"filmPackager/internal/application/documentservice"
+	"filmPackager/internal/application/membershipservice"
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type uploadRequest struct {
+	FileName string `json:"file_name"`
+	FileType string `json:"file_type"`
+	Size     int64  `json:"size"`
+}
+
+// starts sending a file straight to the bucket, for files too large to go through the app
+func StartUpload(svc *documentservice.DocumentService, memberSvc *membershipservice.MembershipService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		pID, err := uuidParam(c, "project_id")
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		_, err = requireMember(c, memberSvc, pID)
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		req := uploadRequest{}
+		err = c.BodyParser(&req)
+		if err != nil {
+			return respondError(c, errBadRequest("invalid request body"))
+		}
+
+		if !slices.Contains(fileTypes, req.FileType) {
+			return respondError(c, errBadRequest("file_type must be one of Script, Logline, Synopsis, PitchDeck, Schedule, Budget, Shotlist, Lookbook"))
+		}
+		if strings.TrimSpace(req.FileName) == "" {
+			return respondError(c, errBadRequest("file_name is required"))
+		}
+
+		rv, err := svc.StartUpload(c.Context(), pID, currentUser(c).Id, req.FileName, req.FileType, req.Size)
+		if err != nil {
+			return respondError(c, uploadError(err))
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(dataBody{Data: newUploadSession(rv)})
+	}
+}
+
+// fresh URLs for the parts still to send, to carry on after a dropped connection or a reload
+func ResumeUpload(svc *documentservice.DocumentService, memberSvc *membershipservice.MembershipService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		pID, err := uuidParam(c, "project_id")
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		uploadID, err := uuidParam(c, "upload_id")
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		_, err = requireMember(c, memberSvc, pID)
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		rv, err := svc.ResumeUpload(c.Context(), pID, uploadID, currentUser(c).Id)
+		if err != nil {
+			return respondError(c, uploadError(err))
+		}
+
+		return c.JSON(dataBody{Data: newUploadSession(rv)})
+	}
+}
+
+// stages the uploaded file once every part has been sent
+func CompleteUpload(svc *documentservice.DocumentService, memberSvc *membershipservice.MembershipService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		pID, err := uuidParam(c, "project_id")
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		uploadID, err := uuidParam(c, "upload_id")
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		_, err = requireMember(c, memberSvc, pID)
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		rv, err := svc.CompleteUpload(c.Context(), pID, uploadID, currentUser(c).Id)
+		if err != nil {
+			return respondError(c, uploadError(err))
+		}
+
+		d := rv.Staged[rv.FileType]
+
+		return c.Status(fiber.StatusCreated).JSON(dataBody{Data: DocumentSummary{ID: d.ID, FileType: rv.FileType, Status: "staged", ScanStatus: d.ScanStatus, Date: d.Date}})
+	}
+}
+
+func CancelUpload(svc *documentservice.DocumentService, memberSvc *membershipservice.MembershipService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		pID, err := uuidParam(c, "project_id")
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		uploadID, err := uuidParam(c, "upload_id")
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		_, err = requireMember(c, memberSvc, pID)
+		if err != nil {
+			return respondError(c, err)
+		}
+
+		err = svc.CancelUpload(c.Context(), pID, uploadID, currentUser(c).Id)
+		if err != nil {
+			return respondError(c, uploadError(err))
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
@@ -0,0 +1,50 @@
+package routes
+
+import (
+	"errors"
+	"filmPackager/internal/application/commentservice"
+	"filmPackager/internal/application/documentservice"
+	"filmPackager/internal/application/membershipservice"
+	"filmPackager/internal/application/middleware/auth"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+var errNotMember = errors.New("not a member of this project")
+
+// requireMember checks the user has accepted their invite to the project and has two-factor authentication on if the
+// project requires it, the fragments that render project data go through it
+func requireMember(c *fiber.Ctx, memberSvc *membershipservice.MembershipService, projectID uuid.UUID) error {
+	u := auth.GetUserFromContext(c)
+	if u == nil {
+		return errNotMember
+	}
+
+	rv, err := memberSvc.GetMembership(c.Context(), projectID, u.Id)
+	if err != nil || rv.Membership.InviteStatus != "accepted" {
+		return errNotMember
+	}
+
+	return memberSvc.CheckTwoFactor(c.Context(), projectID, u.Id)
+}
+
+// requireDocumentMember checks the user can see the project the document belongs to
+func requireDocumentMember(c *fiber.Ctx, docSvc *documentservice.DocumentService, memberSvc *membershipservice.MembershipService, docID uuid.UUID) error {
+	doc, err := docSvc.GetDocumentDetails(c.Context(), docID)
+	if err != nil {
+		return errNotMember
+	}
+
+	return requireMember(c, memberSvc, doc.OrgID)
+}
+
+// requireCommentMember checks the user can see the project of the document the comment is on
+func requireCommentMember(c *fiber.Ctx, svc *commentservice.CommentService, docSvc *documentservice.DocumentService, memberSvc *membershipservice.MembershipService, commentID uuid.UUID) error {
+	cm, err := svc.GetComment(c.Context(), commentID)
+	if err != nil {
+		return errNotMember
+	}
+
+	return requireDocumentMember(c, docSvc, memberSvc, cm.DocID)
+}
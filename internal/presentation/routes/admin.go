@@ -0,0 +1,58 @@
+package routes
+
+import (
+	"errors"
+	"filmPackager/internal/application/authservice"
+	"filmPackager/internal/application/middleware/auth"
+	"filmPackager/internal/domain/lockout"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// the lockouts page is only for the site admins in ADMIN_EMAILS
+func GetLockouts(svc *authservice.AuthService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		u := auth.GetUserFromContext(c)
+		if u == nil {
+			return c.Redirect("/login/")
+		}
+		if !svc.IsAdmin(u) {
+			return c.Status(fiber.StatusForbidden).SendString(lockout.ErrNotAdmin.Error())
+		}
+
+		lockouts, err := svc.GetLockouts(c.Context())
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("error getting lockouts")
+		}
+
+		return c.Render("admin-lockouts", fiber.Map{
+			"Lockouts": lockouts,
+		})
+	}
+}
+
+func UnlockLogin(svc *authservice.AuthService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		u := auth.GetUserFromContext(c)
+		if u == nil {
+			return c.Redirect("/login/")
+		}
+
+		err := svc.Unlock(c.Context(), u, c.FormValue("key"))
+		if err != nil {
+			if errors.Is(err, lockout.ErrNotAdmin) {
+				return c.Status(fiber.StatusForbidden).SendString(err.Error())
+			}
+			return c.Status(fiber.StatusInternalServerError).SendString("error unlocking login")
+		}
+
+		lockouts, err := svc.GetLockouts(c.Context())
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("error getting lockouts")
+		}
+
+		return c.Render("lockout-listHTML", fiber.Map{
+			"Lockouts": lockouts,
+		})
+	}
+}
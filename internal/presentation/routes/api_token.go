@@ -0,0 +1,95 @@
+package routes
+
+import (
+	"errors"
+	"filmPackager/internal/application/apitokenservice"
+	"filmPackager/internal/application/middleware/auth"
+	"filmPackager/internal/domain/apitoken"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+func GetAPITokens(svc *apitokenservice.APITokenService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		u := auth.GetUserFromContext(c)
+		if u == nil {
+			return c.Redirect("/login/")
+		}
+
+		rv, err := svc.GetTokens(c.Context(), u.Id)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("error getting api tokens")
+		}
+
+		return c.Render("api-tokensHTML", *rv)
+	}
+}
+
+func CreateAPIToken(svc *apitokenservice.APITokenService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		u := auth.GetUserFromContext(c)
+		if u == nil {
+			return c.Redirect("/login/")
+		}
+
+		name := strings.TrimSpace(c.FormValue("name"))
+		scope := c.FormValue("scope")
+		expiresIn := c.Request().PostArgs().GetUintOrZero("expires-in")
+
+		// the projects are checkboxes sharing a name, none checked means all projects
+		projectIDs := []uuid.UUID{}
+		for _, v := range c.Request().PostArgs().PeekMulti("project-ids") {
+			id, err := uuid.Parse(string(v))
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).SendString("error parsing Id from request")
+			}
+			projectIDs = append(projectIDs, id)
+		}
+
+		rv, err := svc.CreateToken(c.Context(), u.Id, name, scope, projectIDs, expiresIn)
+		if err != nil {
+			if errors.Is(err, apitoken.ErrInvalidName) || errors.Is(err, apitoken.ErrInvalidScope) || errors.Is(err, apitoken.ErrInvalidProject) {
+				rv, getErr := svc.GetTokens(c.Context(), u.Id)
+				if getErr != nil {
+					return c.Status(fiber.StatusInternalServerError).SendString("error getting api tokens")
+				}
+				return c.Render("api-tokensHTML", fiber.Map{
+					"Tokens":        rv.Tokens,
+					"Projects":      rv.Projects,
+					"Scopes":        rv.Scopes,
+					"ExpiryOptions": rv.ExpiryOptions,
+					"Error":         err.Error(),
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).SendString("error creating api token")
+		}
+
+		return c.Render("api-tokensHTML", *rv)
+	}
+}
+
+func RevokeAPIToken(svc *apitokenservice.APITokenService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		u := auth.GetUserFromContext(c)
+		if u == nil {
+			return c.Redirect("/login/")
+		}
+
+		tokenID, err := uuid.Parse(c.Params("token_id"))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("error parsing Id from request")
+		}
+
+		rv, err := svc.RevokeToken(c.Context(), u.Id, tokenID)
+		if err != nil {
+			if errors.Is(err, apitoken.ErrTokenNotFound) {
+				return c.Status(fiber.StatusNotFound).SendString(err.Error())
+			}
+			return c.Status(fiber.StatusInternalServerError).SendString("error revoking api token")
+		}
+
+		return c.Render("api-tokensHTML", *rv)
+	}
+}
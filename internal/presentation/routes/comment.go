@@ -1,15 +1,21 @@
 package routes
 
 import (
+	"errors"
 	"filmPackager/internal/application/commentservice"
+	"filmPackager/internal/application/documentservice"
+	"filmPackager/internal/application/membershipservice"
 	"filmPackager/internal/application/middleware/auth"
+	"filmPackager/internal/domain/comment"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 )
 
-func GetDocCommentSection(svc *commentservice.CommentService) fiber.Handler {
+func GetDocCommentSection(svc *commentservice.CommentService, docSvc *documentservice.DocumentService, memberSvc *membershipservice.MembershipService) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		docId := c.Params("doc_id")
 		docUUID, err := uuid.Parse(docId)
@@ -17,39 +23,429 @@ func GetDocCommentSection(svc *commentservice.CommentService) fiber.Handler {
 			return c.Status(fiber.StatusInternalServerError).SendString("error parsing Id from request")
 		}
 
-		rv, err := svc.GetDocComments(c.Context(), docUUID)
+		u := auth.GetUserFromContext(c)
+		if u == nil {
+			return c.Status(fiber.StatusUnauthorized).SendString("login required")
+		}
+
+		err = requireDocumentMember(c, docSvc, memberSvc, docUUID)
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).SendString("error getting comments")
+			return c.Status(fiber.StatusForbidden).SendString(err.Error())
 		}
 
-		return c.Render("document-commentsHTML", fiber.Map{
-			"Comments": rv.Comments,
-			"DocID":    docId,
-		})
+		return renderDocCommentSection(c, svc, docUUID, u.Id, c.Query("filter"))
 	}
 }
 
-func AddDocComment(svc *commentservice.CommentService) fiber.Handler {
+// moves the open threads of an earlier version onto the document and re-renders its comments
+func CarryCommentsForward(svc *commentservice.CommentService, docSvc *documentservice.DocumentService, memberSvc *membershipservice.MembershipService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		docUUID, err := uuid.Parse(c.Params("doc_id"))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("error parsing Id from request")
+		}
+
+		fromUUID, err := uuid.Parse(c.Params("from_doc_id"))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("error parsing Id from request")
+		}
+
+		err = requireDocumentMember(c, docSvc, memberSvc, docUUID)
+		if err != nil {
+			return c.Status(fiber.StatusForbidden).SendString(err.Error())
+		}
+
+		u := auth.GetUserFromContext(c)
+
+		err = svc.CarryForward(c.Context(), docUUID, fromUUID, u.Id)
+		if err != nil {
+			if errors.Is(err, comment.ErrNotMember) {
+				return c.Status(fiber.StatusForbidden).SendString(err.Error())
+			}
+			if errors.Is(err, comment.ErrNotAnEarlierVersion) {
+				return c.Status(fiber.StatusConflict).SendString(err.Error())
+			}
+			return c.Status(fiber.StatusInternalServerError).SendString("error carrying comments forward")
+		}
+
+		return renderDocCommentSection(c, svc, docUUID, u.Id, c.Query("filter"))
+	}
+}
+
+func renderDocCommentSection(c *fiber.Ctx, svc *commentservice.CommentService, docID, userID uuid.UUID, filter string) error {
+	rv, err := svc.GetDocComments(c.Context(), docID, userID, filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("error getting comments")
+	}
+
+	prior, err := svc.GetPriorVersionComments(c.Context(), docID, rv.Filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("error getting comments")
+	}
+
+	return c.Render("document-commentsHTML", fiber.Map{
+		"Comments":      rv.Comments,
+		"DocID":         docID,
+		"Filter":        rv.Filter,
+		"Filters":       comment.Filters,
+		"OpenCount":     rv.OpenCount,
+		"ResolvedCount": rv.ResolvedCount,
+		"Version":       prior.Version,
+		"PriorVersions": prior.PriorVersions,
+	})
+}
+
+func AddDocComment(svc *commentservice.CommentService, docSvc *documentservice.DocumentService, memberSvc *membershipservice.MembershipService) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		docId := c.Params("doc_id")
 		docUUID, err := uuid.Parse(docId)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).SendString("error parsing Id from request")
 		}
-		comment := c.FormValue("comment")
+
+		err = requireDocumentMember(c, docSvc, memberSvc, docUUID)
+		if err != nil {
+			return c.Status(fiber.StatusForbidden).SendString(err.Error())
+		}
+
+		text := c.FormValue("comment")
 		u := auth.GetUserFromContext(c)
 
-		nc, err := svc.CreateComment(c.Context(), comment, u.Id, docUUID)
+		anchor, err := parseAnchorForm(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+		}
+
+		nc, err := svc.CreateComment(c.Context(), text, u.Id, docUUID, anchor)
 		if err != nil {
+			if errors.Is(err, comment.ErrNotMember) {
+				return c.Status(fiber.StatusForbidden).SendString(err.Error())
+			}
+			if errors.Is(err, comment.ErrCommentTooLong) {
+				return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+			}
 			fmt.Println("error adding comment", err)
 			return c.Status(fiber.StatusInternalServerError).SendString("error adding comment")
 		}
 
-		return c.Render("doc-commentHTML", nc)
+		// a new comment starts its own thread
+		return c.Render("doc-comment-threadHTML", nc)
 	}
 }
 
-func DeleteComment(svc *commentservice.CommentService) fiber.Handler {
+// reads the optional anchor sent from the preview page, the region comes in as
+// percentages of the page and is left out unless all four values are set
+func parseAnchorForm(c *fiber.Ctx) (*comment.Anchor, error) {
+	pageValue := strings.TrimSpace(c.FormValue("anchor_page"))
+	if pageValue == "" {
+		return nil, nil
+	}
+
+	page, err := strconv.Atoi(pageValue)
+	if err != nil {
+		return nil, comment.ErrInvalidAnchorPage
+	}
+
+	var rect *comment.Rect
+	values := []float64{}
+	for _, field := range []string{"anchor_x", "anchor_y", "anchor_width", "anchor_height"} {
+		v := strings.TrimSpace(c.FormValue(field))
+		if v == "" {
+			break
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, comment.ErrInvalidAnchorRect
+		}
+		values = append(values, f/100)
+	}
+	if len(values) == 4 {
+		rect = &comment.Rect{X: values[0], Y: values[1], Width: values[2], Height: values[3]}
+	}
+
+	return comment.NewAnchor(page, rect, strings.TrimSpace(c.FormValue("anchor_text")))
+}
+
+// renders the page markers beside the PDF preview, used to refresh them live
+func GetDocCommentMarkers(svc *commentservice.CommentService, docSvc *documentservice.DocumentService, memberSvc *membershipservice.MembershipService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		docId := c.Params("doc_id")
+		docUUID, err := uuid.Parse(docId)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("error parsing Id from request")
+		}
+
+		u := auth.GetUserFromContext(c)
+		if u == nil {
+			return c.Status(fiber.StatusUnauthorized).SendString("login required")
+		}
+
+		err = requireDocumentMember(c, docSvc, memberSvc, docUUID)
+		if err != nil {
+			return c.Status(fiber.StatusForbidden).SendString(err.Error())
+		}
+
+		rv, err := svc.GetDocComments(c.Context(), docUUID, u.Id, comment.FilterOpen)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("error getting comments")
+		}
+
+		return c.Render("preview-doc-markersHTML", fiber.Map{
+			"ID":      docId,
+			"Markers": rv.Markers,
+		})
+	}
+}
+
+func AddCommentReply(svc *commentservice.CommentService, docSvc *documentservice.DocumentService, memberSvc *membershipservice.MembershipService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		commentUUID, err := uuid.Parse(c.Params("comment_id"))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("error parsing Id from request")
+		}
+
+		err = requireCommentMember(c, svc, docSvc, memberSvc, commentUUID)
+		if err != nil {
+			return c.Status(fiber.StatusForbidden).SendString(err.Error())
+		}
+
+		reply := c.FormValue("comment")
+		u := auth.GetUserFromContext(c)
+
+		thread, err := svc.CreateReply(c.Context(), reply, u.Id, commentUUID)
+		if err != nil {
+			if errors.Is(err, comment.ErrCommentTooLong) {
+				return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+			}
+			return c.Status(fiber.StatusInternalServerError).SendString("error adding reply")
+		}
+
+		return c.Render("doc-comment-threadHTML", thread)
+	}
+}
+
+func ResolveCommentThread(svc *commentservice.CommentService, docSvc *documentservice.DocumentService, memberSvc *membershipservice.MembershipService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		commentUUID, err := uuid.Parse(c.Params("comment_id"))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("error parsing Id from request")
+		}
+
+		err = requireCommentMember(c, svc, docSvc, memberSvc, commentUUID)
+		if err != nil {
+			return c.Status(fiber.StatusForbidden).SendString(err.Error())
+		}
+
+		u := auth.GetUserFromContext(c)
+
+		thread, err := svc.ResolveThread(c.Context(), commentUUID, u.Id)
+		if err != nil {
+			return commentThreadError(c, err)
+		}
+
+		return c.Render("doc-comment-threadHTML", thread)
+	}
+}
+
+func ReopenCommentThread(svc *commentservice.CommentService, docSvc *documentservice.DocumentService, memberSvc *membershipservice.MembershipService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		commentUUID, err := uuid.Parse(c.Params("comment_id"))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("error parsing Id from request")
+		}
+
+		err = requireCommentMember(c, svc, docSvc, memberSvc, commentUUID)
+		if err != nil {
+			return c.Status(fiber.StatusForbidden).SendString(err.Error())
+		}
+
+		u := auth.GetUserFromContext(c)
+
+		thread, err := svc.ReopenThread(c.Context(), commentUUID, u.Id)
+		if err != nil {
+			return commentThreadError(c, err)
+		}
+
+		return c.Render("doc-comment-threadHTML", thread)
+	}
+}
+
+// suggests members to mention while the comment is typed, the textarea sends its text as comment
+func GetMentionSuggestions(svc *commentservice.CommentService, docSvc *documentservice.DocumentService, memberSvc *membershipservice.MembershipService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		docUUID, err := uuid.Parse(c.Params("doc_id"))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("error parsing Id from request")
+		}
+
+		err = requireDocumentMember(c, docSvc, memberSvc, docUUID)
+		if err != nil {
+			return c.Status(fiber.StatusForbidden).SendString(err.Error())
+		}
+
+		suggestions, err := svc.SuggestMentions(c.Context(), docUUID, c.Query("comment"))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("error getting suggestions")
+		}
+
+		return c.Render("mention-suggestionsHTML", fiber.Map{
+			"Suggestions": suggestions,
+		})
+	}
+}
+
+// renders the comment being written as it would show once posted
+func PreviewComment(svc *commentservice.CommentService, docSvc *documentservice.DocumentService, memberSvc *membershipservice.MembershipService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		docUUID, err := uuid.Parse(c.Params("doc_id"))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("error parsing Id from request")
+		}
+
+		err = requireDocumentMember(c, docSvc, memberSvc, docUUID)
+		if err != nil {
+			return c.Status(fiber.StatusForbidden).SendString(err.Error())
+		}
+
+		html, err := svc.PreviewComment(c.Context(), docUUID, c.FormValue("comment"))
+		if err != nil {
+			if errors.Is(err, comment.ErrCommentTooLong) {
+				return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+			}
+			return c.Status(fiber.StatusInternalServerError).SendString("error previewing comment")
+		}
+
+		return c.Render("doc-comment-previewHTML", fiber.Map{
+			"HTML": html,
+		})
+	}
+}
+
+// renders a single thread, used to put it back when an edit is cancelled
+func GetCommentThread(svc *commentservice.CommentService, docSvc *documentservice.DocumentService, memberSvc *membershipservice.MembershipService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		commentUUID, err := uuid.Parse(c.Params("comment_id"))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("error parsing Id from request")
+		}
+
+		err = requireCommentMember(c, svc, docSvc, memberSvc, commentUUID)
+		if err != nil {
+			return c.Status(fiber.StatusForbidden).SendString(err.Error())
+		}
+
+		u := auth.GetUserFromContext(c)
+
+		thread, err := svc.GetThread(c.Context(), commentUUID, u.Id)
+		if err != nil {
+			if errors.Is(err, comment.ErrNotMember) {
+				return c.Status(fiber.StatusForbidden).SendString(err.Error())
+			}
+			return c.Status(fiber.StatusInternalServerError).SendString("error getting thread")
+		}
+
+		return c.Render("doc-comment-threadHTML", thread)
+	}
+}
+
+// swaps the comment's text for a form to edit it, only the author gets the form
+func GetEditCommentForm(svc *commentservice.CommentService, docSvc *documentservice.DocumentService, memberSvc *membershipservice.MembershipService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		commentUUID, err := uuid.Parse(c.Params("comment_id"))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("error parsing Id from request")
+		}
+
+		err = requireCommentMember(c, svc, docSvc, memberSvc, commentUUID)
+		if err != nil {
+			return c.Status(fiber.StatusForbidden).SendString(err.Error())
+		}
+
+		u := auth.GetUserFromContext(c)
+
+		cm, err := svc.GetComment(c.Context(), commentUUID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("error getting comment")
+		}
+
+		if !cm.CanEdit(u.Id) {
+			return c.Status(fiber.StatusForbidden).SendString(comment.ErrNotAuthor.Error())
+		}
+
+		return c.Render("doc-comment-edit-formHTML", fiber.Map{
+			"ID":       cm.ID,
+			"DocID":    cm.DocID,
+			"ThreadID": cm.ThreadID(),
+			"Text":     cm.Content,
+		})
+	}
+}
+
+func EditComment(svc *commentservice.CommentService, docSvc *documentservice.DocumentService, memberSvc *membershipservice.MembershipService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		commentUUID, err := uuid.Parse(c.Params("comment_id"))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("error parsing Id from request")
+		}
+
+		err = requireCommentMember(c, svc, docSvc, memberSvc, commentUUID)
+		if err != nil {
+			return c.Status(fiber.StatusForbidden).SendString(err.Error())
+		}
+
+		u := auth.GetUserFromContext(c)
+
+		thread, err := svc.EditComment(c.Context(), commentUUID, u.Id, c.FormValue("comment"))
+		if err != nil {
+			if errors.Is(err, comment.ErrNotAuthor) {
+				return c.Status(fiber.StatusForbidden).SendString(err.Error())
+			}
+			if errors.Is(err, comment.ErrEmptyComment) || errors.Is(err, comment.ErrCommentTooLong) {
+				return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+			}
+			return c.Status(fiber.StatusInternalServerError).SendString("error editing comment")
+		}
+
+		return c.Render("doc-comment-threadHTML", thread)
+	}
+}
+
+// lists the earlier versions of an edited comment
+func GetCommentHistory(svc *commentservice.CommentService, docSvc *documentservice.DocumentService, memberSvc *membershipservice.MembershipService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		commentUUID, err := uuid.Parse(c.Params("comment_id"))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("error parsing Id from request")
+		}
+
+		err = requireCommentMember(c, svc, docSvc, memberSvc, commentUUID)
+		if err != nil {
+			return c.Status(fiber.StatusForbidden).SendString(err.Error())
+		}
+
+		u := auth.GetUserFromContext(c)
+
+		rv, err := svc.GetRevisions(c.Context(), commentUUID, u.Id)
+		if err != nil {
+			if errors.Is(err, comment.ErrNotMember) {
+				return c.Status(fiber.StatusForbidden).SendString(err.Error())
+			}
+			return c.Status(fiber.StatusInternalServerError).SendString("error getting comment history")
+		}
+
+		return c.Render("doc-comment-historyHTML", rv)
+	}
+}
+
+// resolving twice or resolving a reply is a conflict rather than a failure
+func commentThreadError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, comment.ErrNotAThread) || errors.Is(err, comment.ErrAlreadyResolved) || errors.Is(err, comment.ErrNotResolved) {
+		return c.Status(fiber.StatusConflict).SendString(err.Error())
+	}
+	return c.Status(fiber.StatusInternalServerError).SendString("error updating thread")
+}
+
+func DeleteComment(svc *commentservice.CommentService, docSvc *documentservice.DocumentService, memberSvc *membershipservice.MembershipService) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		commentId := c.Params("comment_id")
 		commentUUID, err := uuid.Parse(commentId)
@@ -57,8 +453,18 @@ func DeleteComment(svc *commentservice.CommentService) fiber.Handler {
 			return c.Status(fiber.StatusInternalServerError).SendString("error parsing Id from request")
 		}
 
-		rv, err := svc.DeleteComment(c.Context(), commentUUID)
+		err = requireCommentMember(c, svc, docSvc, memberSvc, commentUUID)
+		if err != nil {
+			return c.Status(fiber.StatusForbidden).SendString(err.Error())
+		}
+
+		u := auth.GetUserFromContext(c)
+
+		rv, err := svc.DeleteComment(c.Context(), commentUUID, u.Id)
 		if err != nil {
+			if errors.Is(err, comment.ErrCannotDelete) {
+				return c.Status(fiber.StatusForbidden).SendString(err.Error())
+			}
 			return c.Status(fiber.StatusInternalServerError).SendString("error deleting comment")
 		}
 
@@ -69,3 +475,31 @@ func DeleteComment(svc *commentservice.CommentService) fiber.Handler {
 		})
 	}
 }
+
+// renders the comments on their own, used to refresh the thread live
+func GetDocCommentsList(svc *commentservice.CommentService, docSvc *documentservice.DocumentService, memberSvc *membershipservice.MembershipService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		docId := c.Params("doc_id")
+		docUUID, err := uuid.Parse(docId)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("error parsing Id from request")
+		}
+
+		err = requireDocumentMember(c, docSvc, memberSvc, docUUID)
+		if err != nil {
+			return c.Status(fiber.StatusForbidden).SendString(err.Error())
+		}
+
+		u := auth.GetUserFromContext(c)
+
+		rv, err := svc.GetDocComments(c.Context(), docUUID, u.Id, c.Query("filter"))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("error getting comments")
+		}
+
+		return c.Render("doc-comments-listHTML", fiber.Map{
+			"Comments": rv.Comments,
+			"DocID":    docId,
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package routes
+
+import (
+	"filmPackager/internal/application/digestservice"
+	"filmPackager/internal/application/middleware/auth"
+	"filmPackager/internal/domain/digest"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func GetDigestSettings(svc *digestservice.DigestService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		u := auth.GetUserFromContext(c)
+		if u == nil {
+			return c.Redirect("/login/")
+		}
+
+		rv, err := svc.GetDigestSettings(c.Context(), u.Id)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("error getting digest settings")
+		}
+
+		return c.Render("digest-settingsHTML", *rv)
+	}
+}
+
+func UpdateDigestSettings(svc *digestservice.DigestService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		u := auth.GetUserFromContext(c)
+		if u == nil {
+			return c.Redirect("/login/")
+		}
+
+		frequency := c.FormValue("frequency")
+
+		rv, err := svc.UpdateFrequency(c.Context(), u.Id, frequency)
+		if err != nil {
+			if err == digest.ErrInvalidFrequency {
+				return c.Status(fiber.StatusBadRequest).SendString("invalid digest frequency")
+			}
+			return c.Status(fiber.StatusInternalServerError).SendString("error updating digest settings")
+		}
+
+		return c.Render("digest-settingsHTML", fiber.Map{
+			"Frequency":   rv.Frequency,
+			"Frequencies": rv.Frequencies,
+			"Saved":       true,
+		})
+	}
+}
+
+// the unsubscribe link is opened from an email, so it doesn't require a login
+func UnsubscribeFromDigest(svc *digestservice.DigestService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		token := c.Params("token")
+
+		err := svc.Unsubscribe(c.Context(), token)
+		if err != nil {
+			if err == digest.ErrSubscriptionNotFound {
+				return c.Status(fiber.StatusNotFound).Render("unsubscribe", fiber.Map{
+					"Error": "This unsubscribe link is invalid or has expired.",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).SendString("error unsubscribing")
+		}
+
+		return c.Render("unsubscribe", nil)
+	}
+}
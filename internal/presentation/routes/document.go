@@ -1,17 +1,20 @@
 package routes
 
 import (
+	"filmPackager/internal/application/commentservice"
 	"filmPackager/internal/application/documentservice"
+	"filmPackager/internal/application/membershipservice"
 	"filmPackager/internal/application/middleware/auth"
+	"filmPackager/internal/domain/comment"
 	"filmPackager/internal/domain/document"
+	"filmPackager/internal/domain/watermark"
 	"fmt"
-	"io"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 )
 
-func LockStagedDocs(svc *documentservice.DocumentService) fiber.Handler {
+func LockStagedDocs(svc *documentservice.DocumentService, memberSvc *membershipservice.MembershipService) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		u := auth.GetUserFromContext(c)
 
@@ -21,12 +24,20 @@ func LockStagedDocs(svc *documentservice.DocumentService) fiber.Handler {
 			return c.Status(fiber.StatusInternalServerError).SendString("error parsing Id from request")
 		}
 
+		err = requireMember(c, memberSvc, pID)
+		if err != nil {
+			return c.Status(fiber.StatusForbidden).SendString(err.Error())
+		}
+
 		err = svc.LockDocuments(c.Context(), pID, u.Id)
 		if err != nil {
 			if err == document.ErrAccessDenied {
 				// alert the user that they don't have permission to lock the documents
 				return c.Status(fiber.StatusOK).SendString("Access denied.")
 			}
+			if err == document.ErrScanPending || err == document.ErrQuarantined {
+				return c.Status(fiber.StatusOK).SendString("Every staged document has to pass the malware scan before locking.")
+			}
 			return c.Status(fiber.StatusInternalServerError).SendString("error locking documents")
 		}
 
@@ -34,7 +45,7 @@ func LockStagedDocs(svc *documentservice.DocumentService) fiber.Handler {
 	}
 }
 
-func DownloadDocument(svc *documentservice.DocumentService) fiber.Handler {
+func DownloadDocument(svc *documentservice.DocumentService, memberSvc *membershipservice.MembershipService) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		docId := c.Params("doc_id")
 		docUUID, err := uuid.Parse(docId)
@@ -42,29 +53,27 @@ func DownloadDocument(svc *documentservice.DocumentService) fiber.Handler {
 			return c.Status(fiber.StatusInternalServerError).SendString("error parsing Id from request")
 		}
 
-		rv, err := svc.DownloadDocument(c.Context(), docUUID)
+		err = requireDocumentMember(c, svc, memberSvc, docUUID)
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).SendString("error downloading document")
+			return c.Status(fiber.StatusForbidden).SendString(err.Error())
 		}
-		defer rv.DocStream.Body.Close()
-
-		// need the file name...
-		attachment := fmt.Sprintf("attachment; filename=%s", rv.FileName)
-
-		// Set the appropriate headers
-		c.Set("Content-Type", "application/octet-stream") // Adjust Content-Type as needed
-		c.Set("Content-Disposition", attachment)
 
-		// Stream the body to the client
-		if _, err := io.Copy(c, rv.DocStream.Body); err != nil {
-			return c.Status(fiber.StatusInternalServerError).SendString("error copying file to response")
+		rv, err := svc.DownloadDocument(c.Context(), docUUID, documentservice.DownloadDocumentRequest{UserID: auth.GetUserFromContext(c).Id, Range: document.ParseRange(c.Get(fiber.HeaderRange))})
+		if err == document.ErrAccessDenied || err == document.ErrScanPending || err == document.ErrQuarantined || err == watermark.ErrUnreadablePDF || err == watermark.ErrEncryptedPDF {
+			return c.Status(fiber.StatusForbidden).SendString(err.Error())
+		}
+		if err == document.ErrRangeNotSatisfiable {
+			return c.Status(fiber.StatusRequestedRangeNotSatisfiable).SendString(err.Error())
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("error downloading document")
 		}
 
-		return nil
+		return sendDocument(c, rv)
 	}
 }
 
-func PreviewDocument(svc *documentservice.DocumentService) fiber.Handler {
+func PreviewDocument(svc *documentservice.DocumentService, memberSvc *membershipservice.MembershipService) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		docId := c.Params("doc_id")
 		docUUID, err := uuid.Parse(docId)
@@ -72,83 +81,112 @@ func PreviewDocument(svc *documentservice.DocumentService) fiber.Handler {
 			return c.Status(fiber.StatusInternalServerError).SendString("error parsing Id from request")
 		}
 
-		rv, err := svc.DownloadDocument(c.Context(), docUUID)
+		err = requireDocumentMember(c, svc, memberSvc, docUUID)
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).SendString("error getting document details")
+			return c.Status(fiber.StatusForbidden).SendString(err.Error())
 		}
-		defer rv.DocStream.Body.Close()
-
-		// set different headers for docx
-		c.Set("Content-Type", "application/pdf") // Adjust Content-Type as needed
-		c.Set("Content-Disposition", "inline; filename="+rv.FileName)
 
-		_, err = io.Copy(c, rv.DocStream.Body)
+		// the browser's PDF viewer asks for ranges so the first pages show before the rest arrives
+		rv, err := svc.DownloadDocument(c.Context(), docUUID, documentservice.DownloadDocumentRequest{UserID: auth.GetUserFromContext(c).Id, Inline: true, Range: document.ParseRange(c.Get(fiber.HeaderRange))})
+		if err == document.ErrAccessDenied || err == document.ErrScanPending || err == document.ErrQuarantined || err == watermark.ErrUnreadablePDF || err == watermark.ErrEncryptedPDF {
+			return c.Status(fiber.StatusForbidden).SendString(err.Error())
+		}
+		if err == document.ErrRangeNotSatisfiable {
+			return c.Status(fiber.StatusRequestedRangeNotSatisfiable).SendString(err.Error())
+		}
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).SendString("error copying file to response")
+			return c.Status(fiber.StatusInternalServerError).SendString("error getting document details")
 		}
-		return nil
+
+		return sendDocument(c, rv)
 	}
 }
 
-func PreviewDocumentPage(svc *documentservice.DocumentService) fiber.Handler {
+// GetThumbnail sends the picture of a document's first page, or the icon for its kind of file when there isn't one
+func GetThumbnail(svc *documentservice.DocumentService, memberSvc *membershipservice.MembershipService) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		docId := c.Params("doc_id")
-		docUUID, err := uuid.Parse(docId)
+		docUUID, err := uuid.Parse(c.Params("doc_id"))
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).SendString("error parsing Id from request")
 		}
 
-		rv, err := svc.GetDocumentDetails(c.Context(), docUUID)
+		err = requireDocumentMember(c, svc, memberSvc, docUUID)
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).SendString("error getting document details")
+			return c.Status(fiber.StatusForbidden).SendString(err.Error())
+		}
+
+		png, err := svc.GetThumbnail(c.Context(), docUUID, auth.GetUserFromContext(c).Id)
+		if err == document.ErrNoThumbnail {
+			// not cached, the thumbnail may be ready on the next load
+			c.Set(fiber.HeaderCacheControl, "no-store")
+			return c.Redirect("/static/icons/description_32dp_E8EAED_FILL0_wght400_GRAD0_opsz40.svg", fiber.StatusTemporaryRedirect)
 		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("error getting thumbnail")
+		}
+
+		// a new upload is a new document, so a thumbnail never changes
+		c.Set(fiber.HeaderCacheControl, "private, max-age=86400")
+		c.Set(fiber.HeaderContentType, "image/png")
+		return c.Send(png)
+	}
+}
+
+// sendDocument redirects to the bucket when there's a link to the file, otherwise it's
+// streamed from here rather than copied into the response, fasthttp closes the body once it's sent
+func sendDocument(c *fiber.Ctx, rv documentservice.DownloadDocumentResponse) error {
+	if rv.URL != "" {
+		return c.Redirect(rv.URL, fiber.StatusTemporaryRedirect)
+	}
 
-		return c.Render("preview-doc-pageHTML", *rv)
+	c.Set(fiber.HeaderContentType, rv.ContentType)
+	c.Set(fiber.HeaderContentDisposition, rv.Disposition)
+	if !rv.Watermarked {
+		c.Set(fiber.HeaderAcceptRanges, "bytes")
 	}
+	if rv.ContentRange != "" {
+		c.Set(fiber.HeaderContentRange, rv.ContentRange)
+		c.Status(fiber.StatusPartialContent)
+	}
+
+	c.Context().SetBodyStream(rv.Body, int(rv.Size))
+	return nil
 }
 
-func UploadDocumentHandler(svc *documentservice.DocumentService) fiber.Handler {
+// the preview shows the open threads anchored to each page beside the PDF
+func PreviewDocumentPage(svc *documentservice.DocumentService, commentSvc *commentservice.CommentService, memberSvc *membershipservice.MembershipService) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		orgID := c.Params("project_id")
-		fileType := c.FormValue("file-type")
-		file, err := c.FormFile("file")
+		docId := c.Params("doc_id")
+		docUUID, err := uuid.Parse(docId)
 		if err != nil {
-			return c.Status(fiber.StatusBadRequest).SendString("File is required")
+			return c.Status(fiber.StatusInternalServerError).SendString("error parsing Id from request")
 		}
 
-		u := auth.GetUserFromContext(c)
-
-		// send the file to the service??
-		f, err := file.Open()
+		err = requireDocumentMember(c, svc, memberSvc, docUUID)
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).SendString("error opening file")
+			return c.Status(fiber.StatusForbidden).SendString(err.Error())
 		}
-		defer f.Close()
 
-		orgUUID, err := uuid.Parse(orgID)
+		rv, err := svc.GetDocumentDetails(c.Context(), docUUID)
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).SendString("error parsing Id from request")
+			return c.Status(fiber.StatusInternalServerError).SendString("error getting document details")
 		}
 
-		// returns a map of staged documents
-		documents, err := svc.UploadDocument(c.Context(), orgUUID, u.Id, file.Filename, fileType, f)
+		u := auth.GetUserFromContext(c)
+
+		comments, err := commentSvc.GetDocComments(c.Context(), docUUID, u.Id, comment.FilterOpen)
 		if err != nil {
-			fmt.Println("Error uploading document:", err)
-			// if the user doesn't have permission to upload the document type
-			if err == document.ErrAccessDenied {
-				// UPDATE: this should send the template for the access error
-				return c.Status(fiber.StatusUnauthorized).SendString("error uploading document")
-			}
-			return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+			return c.Status(fiber.StatusInternalServerError).SendString("error getting comments")
 		}
 
-		return c.Render("staged-listHTML", fiber.Map{
-			"Staged": documents,
+		return c.Render("preview-doc-pageHTML", fiber.Map{
+			"ID":      rv.ID,
+			"Markers": comments.Markers,
 		})
 	}
 }
 
-func GetDocDetails(svc *documentservice.DocumentService) fiber.Handler {
+func GetDocDetails(svc *documentservice.DocumentService, memberSvc *membershipservice.MembershipService) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		docId := c.Params("doc_id")
 		docUUID, err := uuid.Parse(docId)
@@ -156,6 +194,11 @@ func GetDocDetails(svc *documentservice.DocumentService) fiber.Handler {
 			return c.Status(fiber.StatusInternalServerError).SendString("error parsing Id from request")
 		}
 
+		err = requireDocumentMember(c, svc, memberSvc, docUUID)
+		if err != nil {
+			return c.Status(fiber.StatusForbidden).SendString(err.Error())
+		}
+
 		rv, err := svc.GetDocumentDetails(c.Context(), docUUID)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).SendString("error getting document details")
@@ -165,7 +208,7 @@ func GetDocDetails(svc *documentservice.DocumentService) fiber.Handler {
 	}
 }
 
-func DeleteDocument(svc *documentservice.DocumentService) fiber.Handler {
+func DeleteDocument(svc *documentservice.DocumentService, memberSvc *membershipservice.MembershipService) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		docId := c.Params("doc_id")
 		docUUID, err := uuid.Parse(docId)
@@ -174,12 +217,20 @@ func DeleteDocument(svc *documentservice.DocumentService) fiber.Handler {
 			return c.Status(fiber.StatusInternalServerError).SendString("error parsing Id from request")
 		}
 
-		pID, err := svc.DeleteDocument(c.Context(), docUUID)
+		err = requireDocumentMember(c, svc, memberSvc, docUUID)
+		if err != nil {
+			return c.Status(fiber.StatusForbidden).SendString(err.Error())
+		}
+
+		u := auth.GetUserFromContext(c)
+
+		pID, err := svc.DeleteDocument(c.Context(), docUUID, u.Id)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).SendString("error deleting document")
 		}
 
+		// 303 so the redirect is followed with a GET rather than another DELETE
 		url := fmt.Sprintf("/project/%s/", pID)
-		return c.Redirect(url)
+		return c.Redirect(url, fiber.StatusSeeOther)
 	}
 }
@@ -0,0 +1,90 @@
+package routes
+
+import (
+	"bufio"
+	"filmPackager/internal/application/eventbroker"
+	"filmPackager/internal/application/membershipservice"
+	"filmPackager/internal/application/middleware/auth"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/valyala/fasthttp"
+)
+
+// keeps idle connections from being closed by proxies
+const sseKeepAlive = 30 * time.Second
+
+// sseEventNames maps an application event to the SSE events the project page listens for,
+// see the hx-trigger="sse:..." attributes in the project templates
+func sseEventNames(e eventbroker.Event) []string {
+	switch e.Kind {
+	case eventbroker.DocumentUploaded, eventbroker.DocumentDeleted:
+		return []string{"staged"}
+	case eventbroker.DocumentsLocked:
+		return []string{"staged", "locked"}
+	case eventbroker.MemberInvited, eventbroker.MemberJoined, eventbroker.MemberRolesUpdated:
+		return []string{"members"}
+	case eventbroker.CommentCreated, eventbroker.CommentDeleted, eventbroker.CommentResolved, eventbroker.CommentReopened, eventbroker.CommentEdited:
+		return []string{"comments-" + e.DocID.String()}
+	default:
+		return nil
+	}
+}
+
+func ProjectEvents(broker *eventbroker.Broker, memberSvc *membershipservice.MembershipService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		u := auth.GetUserFromContext(c)
+		if u == nil {
+			return c.Status(fiber.StatusUnauthorized).SendString("login required")
+		}
+
+		pID, err := uuid.Parse(c.Params("project_id"))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("error parsing Id from request")
+		}
+
+		// only members of the project can follow its events
+		err = requireMember(c, memberSvc, pID)
+		if err != nil {
+			return c.Status(fiber.StatusForbidden).SendString(err.Error())
+		}
+
+		c.Set("Content-Type", "text/event-stream")
+		c.Set("Cache-Control", "no-cache")
+		c.Set("Connection", "keep-alive")
+		c.Set("X-Accel-Buffering", "no")
+
+		events, unsubscribe := broker.Subscribe(pID)
+
+		c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+			defer unsubscribe()
+
+			ticker := time.NewTicker(sseKeepAlive)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case e, ok := <-events:
+					if !ok {
+						return
+					}
+					for _, name := range sseEventNames(e) {
+						fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, e.Kind)
+					}
+				case <-ticker.C:
+					fmt.Fprint(w, ": keep-alive\n\n")
+				}
+
+				// a failed flush means the client has gone away
+				err := w.Flush()
+				if err != nil {
+					return
+				}
+			}
+		}))
+
+		return nil
+	}
+}
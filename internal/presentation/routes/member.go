@@ -2,6 +2,7 @@ package routes
 
 import (
 	"filmPackager/internal/application/membershipservice"
+	"filmPackager/internal/application/middleware/auth"
 	"filmPackager/internal/domain/membership"
 	"filmPackager/internal/domain/project"
 	"fmt"
@@ -50,7 +51,9 @@ func UpdateMemberRoles(svc *membershipservice.MembershipService) fiber.Handler {
 
 		role := c.FormValue("role-select")
 
-		m, err := svc.UpdateMemberRoles(c.Context(), projUUID, mUserUUID, role)
+		u := auth.GetUserFromContext(c)
+
+		m, err := svc.UpdateMemberRoles(c.Context(), u.Id, projUUID, mUserUUID, role)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).SendString("error updating member roles")
 		}
@@ -89,6 +92,8 @@ func GetSidebar(svc *membershipservice.MembershipService) fiber.Handler {
 			"ProjectID": pID,
 			"Invited":   rv.Invited,
 			"Members":   rv.Members,
+			// the search form and live updates are keyed on .Project.ID
+			"Project": fiber.Map{"ID": pID},
 		})
 	}
 }
@@ -146,7 +151,9 @@ func InviteMember(svc *membershipservice.MembershipService) fiber.Handler {
 			return c.Status(fiber.StatusInternalServerError).SendString("error parsing user Id from request")
 		}
 
-		invitedMembers, err := svc.InviteUserToProject(c.Context(), userUUID, projUUID)
+		u := auth.GetUserFromContext(c)
+
+		invitedMembers, err := svc.InviteUserToProject(c.Context(), u.Id, userUUID, projUUID)
 		if err != nil {
 			if err == project.ErrMemberAlreadyInvited {
 				// return the proper html fragment
@@ -162,3 +169,51 @@ func InviteMember(svc *membershipservice.MembershipService) fiber.Handler {
 		})
 	}
 }
+
+// renders the accepted members on their own, used to refresh the sidebar live
+func GetMemberList(svc *membershipservice.MembershipService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		pID, err := uuid.Parse(c.Params("project_id"))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("error parsing Id from request")
+		}
+
+		err = requireMember(c, svc, pID)
+		if err != nil {
+			return c.Status(fiber.StatusForbidden).SendString(err.Error())
+		}
+
+		rv, err := svc.GetProjectMemberships(c.Context(), pID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("error getting project memberships")
+		}
+
+		return c.Render("member-listHTML", fiber.Map{
+			"Members": rv.Members,
+		})
+	}
+}
+
+// renders the pending invites on their own, used to refresh the sidebar live
+func GetInvitedList(svc *membershipservice.MembershipService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		pID, err := uuid.Parse(c.Params("project_id"))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("error parsing Id from request")
+		}
+
+		err = requireMember(c, svc, pID)
+		if err != nil {
+			return c.Status(fiber.StatusForbidden).SendString(err.Error())
+		}
+
+		rv, err := svc.GetProjectMemberships(c.Context(), pID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("error getting project memberships")
+		}
+
+		return c.Render("invited-membersHTML", fiber.Map{
+			"Invited": rv.Invited,
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package routes
+
+import (
+	"errors"
+	"filmPackager/internal/application/middleware/auth"
+	"filmPackager/internal/application/notificationservice"
+	"filmPackager/internal/domain/notification"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+func GetNotifications(svc *notificationservice.NotificationService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		u := auth.GetUserFromContext(c)
+		if u == nil {
+			return c.Redirect("/login/")
+		}
+
+		rv, err := svc.GetNotifications(c.Context(), u.Id)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("error getting notifications")
+		}
+
+		return c.Render("notificationsHTML", *rv)
+	}
+}
+
+// the badge on the notifications button in the header, empty when there's nothing unread
+func GetUnreadNotificationCount(svc *notificationservice.NotificationService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		u := auth.GetUserFromContext(c)
+		if u == nil {
+			return c.SendString("")
+		}
+
+		count, err := svc.CountUnread(c.Context(), u.Id)
+		if err != nil || count == 0 {
+			return c.SendString("")
+		}
+
+		return c.SendString(strconv.Itoa(count))
+	}
+}
+
+func MarkAllNotificationsRead(svc *notificationservice.NotificationService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		u := auth.GetUserFromContext(c)
+		if u == nil {
+			return c.Redirect("/login/")
+		}
+
+		rv, err := svc.MarkAllRead(c.Context(), u.Id)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("error marking notifications read")
+		}
+
+		return c.Render("notificationsHTML", *rv)
+	}
+}
+
+// marks the notification read and takes the user to the project it's about
+func OpenNotification(svc *notificationservice.NotificationService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		u := auth.GetUserFromContext(c)
+		if u == nil {
+			return c.Redirect("/login/")
+		}
+
+		nID, err := uuid.Parse(c.Params("notification_id"))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("error parsing Id from request")
+		}
+
+		n, err := svc.OpenNotification(c.Context(), nID, u.Id)
+		if err != nil {
+			if errors.Is(err, notification.ErrNotificationNotFound) {
+				return c.Status(fiber.StatusNotFound).SendString(err.Error())
+			}
+			return c.Status(fiber.StatusInternalServerError).SendString("error opening notification")
+		}
+
+		// htmx follows the redirect and swaps the project page into the target
+		return c.Redirect("/project/" + n.ProjectID.String())
+	}
+}
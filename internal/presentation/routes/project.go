@@ -1,8 +1,11 @@
 package routes
 
 import (
+	"errors"
+	"filmPackager/internal/application/membershipservice"
 	"filmPackager/internal/application/middleware/auth"
 	"filmPackager/internal/application/projectservice"
+	"filmPackager/internal/domain/project"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
@@ -10,7 +13,7 @@ import (
 
 func GetHomePage(svc *projectservice.ProjectService) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		tokenString := c.Cookies("filmpackager")
+		tokenString := c.Cookies(auth.CookieName)
 		if c.Get("HX-Request") == "true" {
 			c.Set("HX-Redirect", "/") // Redirect to homepage or desired URL
 			return nil
@@ -44,6 +47,9 @@ func GetProject(svc *projectservice.ProjectService) fiber.Handler {
 		}
 
 		p, err := svc.GetProjectDetails(c.Context(), projUUID, u.Id)
+		if errors.Is(err, project.ErrTwoFactorRequired) {
+			return c.Render("two-factor-requiredHTML", nil)
+		}
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).SendString("error retrieving project data")
 		}
@@ -186,3 +192,87 @@ func UpdateProjectName(svc *projectservice.ProjectService) fiber.Handler {
 		return c.Render("edit-projectHTML", p)
 	}
 }
+
+// turns the two-factor requirement for the project's members on or off
+func SetProjectTwoFactor(svc *projectservice.ProjectService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		u := auth.GetUserFromContext(c)
+
+		projUUID, err := uuid.Parse(c.Params("project_id"))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("error parsing Id from request")
+		}
+
+		_, err = svc.SetRequireTwoFactor(c.Context(), projUUID, u.Id, c.FormValue("require") == "true")
+		if err != nil {
+			if errors.Is(err, project.ErrNotOwner) {
+				return c.Status(fiber.StatusForbidden).SendString(err.Error())
+			}
+			if errors.Is(err, project.ErrOwnerNeedsTwoFactor) {
+				return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+			}
+			return c.Status(fiber.StatusInternalServerError).SendString("error updating project")
+		}
+
+		p, err := svc.GetProjectDetails(c.Context(), projUUID, u.Id)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("error retrieving project data")
+		}
+
+		return c.Render("project-two-factorHTML", *p)
+	}
+}
+
+// renders the staged documents on their own, used to refresh the list live
+func GetStagedList(svc *projectservice.ProjectService, memberSvc *membershipservice.MembershipService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		u := auth.GetUserFromContext(c)
+
+		projUUID, err := uuid.Parse(c.Params("project_id"))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("error parsing Id from request")
+		}
+
+		err = requireMember(c, memberSvc, projUUID)
+		if err != nil {
+			return c.Status(fiber.StatusForbidden).SendString(err.Error())
+		}
+
+		p, err := svc.GetProjectDetails(c.Context(), projUUID, u.Id)
+		if errors.Is(err, project.ErrTwoFactorRequired) {
+			return c.Status(fiber.StatusForbidden).SendString(err.Error())
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("error retrieving project data")
+		}
+
+		return c.Render("staged-listHTML", *p)
+	}
+}
+
+// renders the locked documents on their own, used to refresh the list live
+func GetLockedList(svc *projectservice.ProjectService, memberSvc *membershipservice.MembershipService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		u := auth.GetUserFromContext(c)
+
+		projUUID, err := uuid.Parse(c.Params("project_id"))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("error parsing Id from request")
+		}
+
+		err = requireMember(c, memberSvc, projUUID)
+		if err != nil {
+			return c.Status(fiber.StatusForbidden).SendString(err.Error())
+		}
+
+		p, err := svc.GetProjectDetails(c.Context(), projUUID, u.Id)
+		if errors.Is(err, project.ErrTwoFactorRequired) {
+			return c.Status(fiber.StatusForbidden).SendString(err.Error())
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("error retrieving project data")
+		}
+
+		return c.Render("locked-listHTML", *p)
+	}
+}
@@ -0,0 +1,86 @@
+package routes
+
+import (
+	"errors"
+	"filmPackager/internal/application/authservice"
+	"filmPackager/internal/application/middleware/auth"
+	"filmPackager/internal/domain/session"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+func GetSessions(svc *authservice.AuthService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		u := auth.GetUserFromContext(c)
+		sess := auth.GetSessionFromContext(c)
+		if u == nil || sess == nil {
+			return c.Redirect("/login/")
+		}
+
+		return renderSessions(c, svc, u.Id, sess.ID)
+	}
+}
+
+func RevokeSession(svc *authservice.AuthService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		u := auth.GetUserFromContext(c)
+		sess := auth.GetSessionFromContext(c)
+		if u == nil || sess == nil {
+			return c.Redirect("/login/")
+		}
+
+		sessionID, err := uuid.Parse(c.Params("session_id"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString("error parsing Id from request")
+		}
+
+		err = svc.RevokeSession(c.Context(), u.Id, sessionID)
+		if err != nil {
+			if errors.Is(err, session.ErrSessionNotFound) {
+				return c.Status(fiber.StatusNotFound).SendString(err.Error())
+			}
+			return c.Status(fiber.StatusInternalServerError).SendString("error revoking session")
+		}
+
+		// revoking the current session is logging out
+		if sessionID == sess.ID {
+			clearSessionCookie(c)
+			c.Set("HX-Redirect", "/login/")
+			return c.SendStatus(fiber.StatusNoContent)
+		}
+
+		return renderSessions(c, svc, u.Id, sess.ID)
+	}
+}
+
+// logs out every session, including this one
+func RevokeAllSessions(svc *authservice.AuthService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		u := auth.GetUserFromContext(c)
+		if u == nil {
+			return c.Redirect("/login/")
+		}
+
+		err := svc.RevokeAllSessions(c.Context(), u.Id)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("error revoking sessions")
+		}
+
+		clearSessionCookie(c)
+		c.Set("HX-Redirect", "/login/")
+
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+func renderSessions(c *fiber.Ctx, svc *authservice.AuthService, userID, currentID uuid.UUID) error {
+	sessions, err := svc.GetSessions(c.Context(), userID, currentID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("error getting sessions")
+	}
+
+	return c.Render("sessionsHTML", fiber.Map{
+		"Sessions": sessions,
+	})
+}
@@ -0,0 +1,116 @@
+package routes
+
+import (
+	"errors"
+	"filmPackager/internal/application/authservice"
+	"filmPackager/internal/application/middleware/auth"
+	"filmPackager/internal/domain/twofactor"
+	"filmPackager/internal/domain/user"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func GetTwoFactor(svc *authservice.AuthService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		u := auth.GetUserFromContext(c)
+		if u == nil {
+			return c.Redirect("/login/")
+		}
+
+		return renderTwoFactor(c, svc, u, fiber.Map{})
+	}
+}
+
+func StartTwoFactor(svc *authservice.AuthService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		u := auth.GetUserFromContext(c)
+		if u == nil {
+			return c.Redirect("/login/")
+		}
+
+		_, err := svc.StartTwoFactor(c.Context(), u)
+		if err != nil {
+			if errors.Is(err, twofactor.ErrAlreadyEnabled) {
+				return renderTwoFactor(c, svc, u, fiber.Map{"Error": "Error: " + err.Error()})
+			}
+			return c.Status(fiber.StatusInternalServerError).SendString("error setting up two-factor authentication")
+		}
+
+		return renderTwoFactor(c, svc, u, fiber.Map{})
+	}
+}
+
+func EnableTwoFactor(svc *authservice.AuthService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		u := auth.GetUserFromContext(c)
+		if u == nil {
+			return c.Redirect("/login/")
+		}
+
+		codes, err := svc.EnableTwoFactor(c.Context(), u.Id, strings.TrimSpace(c.FormValue("code")))
+		if err != nil {
+			return twoFactorError(c, svc, u, err)
+		}
+
+		return renderTwoFactor(c, svc, u, fiber.Map{"RecoveryCodes": codes})
+	}
+}
+
+func NewRecoveryCodes(svc *authservice.AuthService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		u := auth.GetUserFromContext(c)
+		if u == nil {
+			return c.Redirect("/login/")
+		}
+
+		codes, err := svc.NewRecoveryCodes(c.Context(), u.Id, strings.TrimSpace(c.FormValue("code")))
+		if err != nil {
+			return twoFactorError(c, svc, u, err)
+		}
+
+		return renderTwoFactor(c, svc, u, fiber.Map{"RecoveryCodes": codes})
+	}
+}
+
+func DisableTwoFactor(svc *authservice.AuthService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		u := auth.GetUserFromContext(c)
+		if u == nil {
+			return c.Redirect("/login/")
+		}
+
+		err := svc.DisableTwoFactor(c.Context(), u.Id, c.FormValue("password"))
+		if err != nil {
+			if errors.Is(err, user.ErrInvalidPassword) {
+				return renderTwoFactor(c, svc, u, fiber.Map{"Error": "Error: incorrect password"})
+			}
+			return c.Status(fiber.StatusInternalServerError).SendString("error turning off two-factor authentication")
+		}
+
+		return renderTwoFactor(c, svc, u, fiber.Map{})
+	}
+}
+
+func twoFactorError(c *fiber.Ctx, svc *authservice.AuthService, u *user.User, err error) error {
+	switch {
+	case errors.Is(err, twofactor.ErrInvalidCode):
+		return renderTwoFactor(c, svc, u, fiber.Map{"Error": err.Error()})
+	case errors.Is(err, twofactor.ErrNotEnrolled), errors.Is(err, twofactor.ErrAlreadyEnabled):
+		return renderTwoFactor(c, svc, u, fiber.Map{"Error": "Error: " + err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).SendString("error updating two-factor authentication")
+	}
+}
+
+// renders the settings page with the extra values, the recovery codes or an error
+func renderTwoFactor(c *fiber.Ctx, svc *authservice.AuthService, u *user.User, data fiber.Map) error {
+	rv, err := svc.GetTwoFactor(c.Context(), u)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("error getting two-factor authentication")
+	}
+
+	data["TwoFactor"] = rv
+
+	return c.Render("two-factorHTML", data)
+}
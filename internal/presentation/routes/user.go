@@ -1,9 +1,16 @@
 package routes
 
 import (
+	"errors"
 	"filmPackager/internal/application/authservice"
 	"filmPackager/internal/application/middleware/auth"
 	"filmPackager/internal/application/userservice"
+	"filmPackager/internal/domain/lockout"
+	"filmPackager/internal/domain/session"
+	"filmPackager/internal/domain/twofactor"
+	"filmPackager/internal/domain/user"
+	"log"
+	"strconv"
 	"strings"
 	"time"
 
@@ -12,16 +19,8 @@ import (
 
 func GetLoginPage(svc *authservice.AuthService) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		// need to check if cookie is valid, if not render login
-		tokenString := c.Cookies("filmpackager")
-		if tokenString == "" {
-			return c.Render("login-form", nil)
-		}
-
-		tokenString = tokenString[len("Bearer "):]
-
-		err := svc.VerifyToken(tokenString)
-		if err != nil {
+		// the auth middleware only sets the user for a valid, active session
+		if auth.GetUserFromContext(c) == nil {
 			return c.Render("login-form", nil)
 		}
 		return c.Redirect("/")
@@ -36,20 +35,14 @@ func PostCreateAccount(svc *authservice.AuthService) fiber.Handler {
 		password := strings.Trim(c.FormValue("password"), " ")
 		secondPassword := strings.Trim(c.FormValue("secondPassword"), " ")
 
-		tokenString, err := svc.CreateNewUser(c.Context(), firstName, lastName, email, password, secondPassword)
+		tokenString, err := svc.CreateNewUser(c.Context(), firstName, lastName, email, password, secondPassword, auth.ClientFromContext(c))
 		if err != nil {
 			return c.Render("create-accountHTML", fiber.Map{
 				"Error": err.Error(),
 			})
 		}
 
-		c.Cookie(&fiber.Cookie{
-			Name:     "filmpackager",
-			Value:    "Bearer " + tokenString,
-			HTTPOnly: true,
-			Path:     "/",
-			Expires:  time.Now().Add(48 * time.Hour),
-		})
+		setSessionCookie(c, tokenString)
 
 		return c.Redirect("/")
 	}
@@ -67,45 +60,104 @@ func LoginUserHandler(svc *authservice.AuthService) fiber.Handler {
 		password := strings.TrimSpace(c.FormValue("password"))
 
 		// create login token
-		tokenString, err := svc.CreateLoginToken(c.Context(), email, password)
+		rv, err := svc.CreateLoginToken(c.Context(), email, password, auth.ClientFromContext(c))
+		if err != nil {
+			return loginError(c, "login-formHTML", fiber.Map{}, err)
+		}
+
+		// the cookie waits for the code from the user's authenticator app
+		if rv.ChallengeToken != "" {
+			return c.Render("login-two-factorHTML", fiber.Map{
+				"Challenge": rv.ChallengeToken,
+			})
+		}
+
+		setSessionCookie(c, rv.SessionToken)
+
+		return c.Redirect("/")
+	}
+}
+
+// the second step of logging in for users with two-factor authentication on
+func LoginTwoFactorHandler(svc *authservice.AuthService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		challenge := c.FormValue("challenge")
+		code := strings.TrimSpace(c.FormValue("code"))
+
+		tokenString, err := svc.VerifyLoginCode(c.Context(), challenge, code, auth.ClientFromContext(c))
+		if errors.Is(err, twofactor.ErrChallengeExpired) {
+			c.Status(fiber.StatusUnauthorized)
+			return c.Render("login-formHTML", fiber.Map{
+				"Error": err.Error(),
+			})
+		}
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).SendString("Error generating JWT")
+			return loginError(c, "login-two-factorHTML", fiber.Map{"Challenge": challenge}, err)
 		}
 
-		c.Cookie(&fiber.Cookie{
-			Name:     "filmpackager",
-			Value:    "Bearer " + tokenString,
-			HTTPOnly: true,
-			Path:     "/",
-			Expires:  time.Now().Add(48 * time.Hour),
-		})
+		setSessionCookie(c, tokenString)
 
 		return c.Redirect("/")
 	}
 }
 
-func LogoutUser(svc *userservice.UserService) fiber.Handler {
+func setSessionCookie(c *fiber.Ctx, tokenString string) {
+	c.Cookie(auth.NewCookie(auth.CookieName, "Bearer "+tokenString, time.Now().Add(session.Duration)))
+}
+
+// the login page swaps 4xx responses in, so failed logins re-render the form with the reason
+func loginError(c *fiber.Ctx, form string, data fiber.Map, err error) error {
+	var throttled *lockout.ThrottledError
+	switch {
+	case errors.As(err, &throttled):
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(throttled.RetryAfter.Round(time.Second).Seconds())))
+		c.Status(fiber.StatusTooManyRequests)
+	case errors.Is(err, user.ErrInvalidPassword):
+		// the same message for unknown emails so it doesn't reveal who has an account
+		err = errors.New("Error: incorrect email or password")
+		c.Status(fiber.StatusUnauthorized)
+	case errors.Is(err, twofactor.ErrInvalidCode):
+		c.Status(fiber.StatusUnauthorized)
+	case errors.Is(err, user.ErrMissingLoginField):
+		c.Status(fiber.StatusBadRequest)
+	default:
+		log.Printf("error logging in: %v", err)
+		err = errors.New("Error: something went wrong logging in, please try again")
+		c.Status(fiber.StatusInternalServerError)
+	}
+
+	data["Error"] = err.Error()
+
+	return c.Render(form, data)
+}
+
+func LogoutUser(svc *authservice.AuthService) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		c.Cookie(&fiber.Cookie{
-			Name:  "filmpackager",
-			Value: "",
-			// Set expiration to the past to delete the cookie
-			Expires: time.Now().Add(-time.Hour),
-			// Ensure the path is the same as when the cookie was set
-			Path: "/",
-			// Ensure other flags match those of the original cookie
-			HTTPOnly: true,
-			// Set to true if the original cookie was secure
-			Secure: true,
-		})
-		return c.Redirect("/login/")
+		// revoking the session means a copy of the cookie can't be used either
+		u := auth.GetUserFromContext(c)
+		sess := auth.GetSessionFromContext(c)
+		if u != nil && sess != nil {
+			err := svc.RevokeSession(c.Context(), u.Id, sess.ID)
+			if err != nil {
+				log.Printf("error revoking session on logout: %v", err)
+			}
+		}
+
+		clearSessionCookie(c)
+
+		return c.Redirect("/login/", fiber.StatusSeeOther)
 	}
 }
 
+func clearSessionCookie(c *fiber.Ctx) {
+	// an expiry in the past deletes the cookie, the flags match the ones it was set with
+	c.Cookie(auth.NewCookie(auth.CookieName, "", time.Now().Add(-time.Hour)))
+}
+
 func GetResetPasswordPage(svc *userservice.UserService) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		// get the user from the cookie
-		tokenString := c.Cookies("filmpackager")
+		tokenString := c.Cookies(auth.CookieName)
 		if tokenString == "" {
 			return c.Redirect("/login/")
 		}
@@ -122,7 +174,7 @@ func VerifyOldPassword(svc *userservice.UserService) fiber.Handler {
 		pw1 := strings.TrimSpace(c.FormValue("password1"))
 		pw2 := strings.TrimSpace(c.FormValue("password2"))
 
-		tokenString := c.Cookies("filmpackager")
+		tokenString := c.Cookies(auth.CookieName)
 		if tokenString == "" {
 			return c.Redirect("/login/")
 		}
@@ -146,19 +198,17 @@ func SetNewPassword(svc *userservice.UserService) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		// get the user from the context
 		u := auth.GetUserFromContext(c)
+		sess := auth.GetSessionFromContext(c)
+		if u == nil || sess == nil {
+			return c.Redirect("/login/")
+		}
 
 		// send the passwords to the user service
 		pw1 := strings.TrimSpace(c.FormValue("new-password1"))
 		pw2 := strings.TrimSpace(c.FormValue("new-password2"))
 
-		tokenString := c.Cookies("Authorization")
-		if tokenString == "" {
-			return c.Redirect("/login/")
-		}
-
-		tokenString = tokenString[len("Bearer "):]
-
-		err := svc.SetNewPassword(c.Context(), u.Id, pw1, pw2)
+		// the other sessions are logged out, this one carries on
+		err := svc.SetNewPassword(c.Context(), u.Id, sess.ID, pw1, pw2)
 		if err != nil {
 			return c.Render("new-pw-formHTML", fiber.Map{
 				"Error": err.Error(),
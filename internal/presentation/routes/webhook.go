@@ -0,0 +1,135 @@
+package routes
+
+import (
+	"errors"
+	"filmPackager/internal/application/middleware/auth"
+	"filmPackager/internal/application/webhookservice"
+	"filmPackager/internal/domain/webhook"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// maps the webhook errors to a status code and message
+func webhookError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, webhook.ErrAccessDenied):
+		return c.Status(fiber.StatusForbidden).SendString(err.Error())
+	case errors.Is(err, webhook.ErrWebhookNotFound), errors.Is(err, webhook.ErrDeliveryNotFound):
+		return c.Status(fiber.StatusNotFound).SendString(err.Error())
+	default:
+		return c.Status(fiber.StatusInternalServerError).SendString("error managing webhooks")
+	}
+}
+
+func GetProjectWebhooks(svc *webhookservice.WebhookService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		u := auth.GetUserFromContext(c)
+
+		pID, err := uuid.Parse(c.Params("project_id"))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("error parsing Id from request")
+		}
+
+		rv, err := svc.GetProjectWebhooks(c.Context(), u.Id, pID)
+		if err != nil {
+			return webhookError(c, err)
+		}
+
+		return c.Render("webhooksHTML", *rv)
+	}
+}
+
+func CreateWebhook(svc *webhookservice.WebhookService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		u := auth.GetUserFromContext(c)
+
+		pID, err := uuid.Parse(c.Params("project_id"))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("error parsing Id from request")
+		}
+
+		url := strings.TrimSpace(c.FormValue("url"))
+		secret := strings.TrimSpace(c.FormValue("secret"))
+
+		// the event types are checkboxes sharing a name
+		eventTypes := []string{}
+		for _, t := range c.Request().PostArgs().PeekMulti("event-types") {
+			eventTypes = append(eventTypes, string(t))
+		}
+
+		rv, err := svc.CreateWebhook(c.Context(), u.Id, pID, url, secret, eventTypes)
+		if err != nil {
+			if errors.Is(err, webhook.ErrInvalidURL) || errors.Is(err, webhook.ErrPrivateAddress) || errors.Is(err, webhook.ErrInvalidEventTypes) {
+				rv, getErr := svc.GetProjectWebhooks(c.Context(), u.Id, pID)
+				if getErr != nil {
+					return webhookError(c, getErr)
+				}
+				return c.Render("webhooksHTML", fiber.Map{
+					"ProjectID":  rv.ProjectID,
+					"Webhooks":   rv.Webhooks,
+					"EventTypes": rv.EventTypes,
+					"Error":      err.Error(),
+				})
+			}
+			return webhookError(c, err)
+		}
+
+		return c.Render("webhooksHTML", *rv)
+	}
+}
+
+func DeleteWebhook(svc *webhookservice.WebhookService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		u := auth.GetUserFromContext(c)
+
+		webhookID, err := uuid.Parse(c.Params("webhook_id"))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("error parsing Id from request")
+		}
+
+		rv, err := svc.DeleteWebhook(c.Context(), u.Id, webhookID)
+		if err != nil {
+			return webhookError(c, err)
+		}
+
+		return c.Render("webhooksHTML", *rv)
+	}
+}
+
+func GetWebhookDeliveries(svc *webhookservice.WebhookService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		u := auth.GetUserFromContext(c)
+
+		webhookID, err := uuid.Parse(c.Params("webhook_id"))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("error parsing Id from request")
+		}
+
+		rv, err := svc.GetWebhookDeliveries(c.Context(), u.Id, webhookID)
+		if err != nil {
+			return webhookError(c, err)
+		}
+
+		return c.Render("webhook-deliveriesHTML", *rv)
+	}
+}
+
+func RedeliverWebhook(svc *webhookservice.WebhookService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		u := auth.GetUserFromContext(c)
+
+		deliveryID, err := uuid.Parse(c.Params("delivery_id"))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("error parsing Id from request")
+		}
+
+		rv, err := svc.Redeliver(c.Context(), u.Id, deliveryID)
+		if err != nil {
+			return webhookError(c, err)
+		}
+
+		return c.Render("webhook-deliveriesHTML", *rv)
+	}
+}
@@ -2,26 +2,53 @@ package interfaces
 
 import (
 	"context"
+	"filmPackager/internal/application/apitokenservice"
 	"filmPackager/internal/application/authservice"
 	"filmPackager/internal/application/commentservice"
+	"filmPackager/internal/application/digestservice"
 	"filmPackager/internal/application/documentservice"
+	"filmPackager/internal/application/eventbroker"
 	"filmPackager/internal/application/membershipservice"
 	"filmPackager/internal/application/middleware/auth"
+	"filmPackager/internal/application/middleware/auth/email"
+	"filmPackager/internal/application/middleware/auth/signer"
+	"filmPackager/internal/application/middleware/csrf"
+	"filmPackager/internal/application/middleware/headers"
+	"filmPackager/internal/application/notificationservice"
 	"filmPackager/internal/application/projectservice"
 	"filmPackager/internal/application/userservice"
+	"filmPackager/internal/application/webhookservice"
+	"filmPackager/internal/domain/datakey"
+	"filmPackager/internal/domain/document"
+	"filmPackager/internal/domain/lockout"
+	"filmPackager/internal/domain/watermark"
+	activityInf "filmPackager/internal/infrastructure/activity"
+	apiTokenInf "filmPackager/internal/infrastructure/apitoken"
 	commInf "filmPackager/internal/infrastructure/comment"
+	dataKeyInf "filmPackager/internal/infrastructure/datakey"
+	digestInf "filmPackager/internal/infrastructure/digest"
 	docInf "filmPackager/internal/infrastructure/document"
+	lockoutInf "filmPackager/internal/infrastructure/lockout"
 	memInf "filmPackager/internal/infrastructure/membership"
+	notificationInf "filmPackager/internal/infrastructure/notification"
 	projectInf "filmPackager/internal/infrastructure/project"
+	scannerInf "filmPackager/internal/infrastructure/scanner"
+	sessionInf "filmPackager/internal/infrastructure/session"
+	twoFactorInf "filmPackager/internal/infrastructure/twofactor"
 	userInf "filmPackager/internal/infrastructure/user"
+	webhookInf "filmPackager/internal/infrastructure/webhook"
+	"filmPackager/internal/presentation/api"
 	"filmPackager/internal/presentation/routes"
 	s3Conn "filmPackager/internal/store"
 	"filmPackager/internal/store/db"
 	"log"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/logger"
+	"github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/gofiber/fiber/v2/middleware/requestid"
 	"github.com/gofiber/fiber/v2/utils"
 	"github.com/gofiber/template/html/v2"
@@ -49,6 +76,12 @@ func NewServer(app *fiber.App) *Server {
 		log.Fatal("BUCKET env var not set")
 	}
 
+	// tokens can't be signed or checked without a key, so there's no point starting without one
+	tokenSigner, err := signer.FromEnv()
+	if err != nil {
+		log.Fatalf("Error loading the JWT signing keys: %v", err)
+	}
+
 	// set up the database connection
 	conn := db.PoolConnect()
 	if conn == nil {
@@ -64,6 +97,8 @@ func NewServer(app *fiber.App) *Server {
 				Views: viewEngine,
 				// 30MB file size limit
 				BodyLimit: 1024 * 1024 * 30,
+				// behind a proxy the client's address comes from a header (e.g. Fly-Client-IP), it's used to limit logins
+				ProxyHeader: os.Getenv("PROXY_HEADER"),
 			},
 		),
 	}
@@ -76,27 +111,134 @@ func NewServer(app *fiber.App) *Server {
 	projectRepo := projectInf.NewPostgresProjectRepository(conn)
 	docPGRepo := docInf.NewPostgresDocumentRepository(conn)
 	memberRepo := memInf.NewPostgresMembershipRepository(conn)
-	docS3Repo := docInf.NewS3DocumentRepository(s3Client, bucket)
+	s3Files := docInf.NewS3DocumentRepository(s3Client, bucket)
+	var docS3Repo document.S3Repository = s3Files
 	commentRepo := commInf.NewPostgresCommentRepository(conn)
+	activityRepo := activityInf.NewPostgresActivityRepository(conn)
+	digestRepo := digestInf.NewPostgresDigestRepository(conn)
+	webhookRepo := webhookInf.NewPostgresWebhookRepository(conn)
+	tokenRepo := apiTokenInf.NewPostgresTokenRepository(conn)
+	notificationRepo := notificationInf.NewPostgresNotificationRepository(conn)
+	sessionRepo := sessionInf.NewPostgresSessionRepository(conn)
+	twoFactorRepo := twoFactorInf.NewPostgresTwoFactorRepository(conn)
+	dataKeyRepo := dataKeyInf.NewPostgresDataKeyRepository(conn)
+	uploadRepo := docInf.NewPostgresUploadRepository(conn)
+	blobRepo := docInf.NewPostgresBlobRepository(conn)
+
+	// large files are sent by the browser straight to the bucket and can be downloaded from it, which the CSP has to allow
+	uploadStore := docInf.NewS3UploadStore(s3Client, bucket)
+	bucketOrigin, err := uploadStore.Origin(context.Background())
+	if err != nil {
+		log.Fatalf("Error working out the bucket's address: %v", err)
+	}
+
+	// with master keys configured each project's files are encrypted with its own data key
+	keyring, err := datakey.KeyringFromEnv()
+	if err != nil {
+		log.Fatalf("Error loading the storage master keys: %v", err)
+	}
+	if keyring != nil {
+		encrypted := docInf.NewEncryptedS3Repository(docS3Repo, dataKeyRepo, keyring)
+		docS3Repo = encrypted
+
+		// move the data keys still wrapped with an older master key onto the current one
+		go func() {
+			err := encrypted.RewrapDataKeys(context.Background())
+			if err != nil {
+				log.Printf("error rewrapping data keys: %v", err)
+			}
+		}()
+	} else {
+		log.Println("STORAGE_MASTER_KEYS not set, documents are stored unencrypted")
+	}
+
+	// downloads can come straight from the bucket, but not while the app has to decrypt them
+	var linker document.FileLinker
+	if os.Getenv("PRESIGNED_DOWNLOADS") == "true" {
+		if keyring == nil {
+			linker = s3Files
+		} else {
+			log.Println("PRESIGNED_DOWNLOADS is ignored while documents are encrypted, downloads go through the app")
+		}
+	}
+
+	// PDFs are stamped with who downloaded them for the roles the policy names
+	watermarks, err := watermark.PolicyFromEnv()
+	if err != nil {
+		log.Fatalf("Error reading WATERMARK_POLICY: %v", err)
+	}
+
+	// failed logins are shared through Postgres unless there's only the one instance
+	var attemptRepo lockout.AttemptRepository = lockoutInf.NewPostgresAttemptRepository(conn)
+	if os.Getenv("LOGIN_ATTEMPT_STORE") == "memory" {
+		attemptRepo = lockoutInf.NewMemoryAttemptRepository()
+	}
+
+	// uploads are checked by a ClamAV daemon when there's one to talk to
+	var scanner document.Scanner = scannerInf.NewNoopScanner()
+	if socket := os.Getenv("CLAMD_SOCKET"); socket != "" {
+		scanner = scannerInf.NewClamdScanner(socket)
+	} else {
+		log.Println("CLAMD_SOCKET not set, uploads won't be scanned for malware")
+	}
+
+	// the public URL is used for links in outgoing emails
+	appURL := os.Getenv("APP_URL")
+	if appURL == "" {
+		appURL = "https://film-packager.fly.dev"
+	}
+
+	// project events are shared between the services and the live project page
+	broker := eventbroker.NewBroker()
+
+	// instantiate the services, the webhook service first as the others record their events with it
+	webhookService := webhookservice.NewWebhookService(webhookRepo, memberRepo, webhookservice.NewClient(10*time.Second))
+	userService := userservice.NewUserService(userRepo, projectRepo, sessionRepo)
+	projService := projectservice.NewProjectService(projectRepo, docPGRepo, docS3Repo, blobRepo, userRepo, memberRepo, commentRepo, twoFactorRepo, broker, webhookService)
+	docService := documentservice.NewDocumentService(docPGRepo, docS3Repo, blobRepo, uploadRepo, uploadStore, linker, scanner, userRepo, memberRepo, projectRepo, commentRepo, activityRepo, broker, webhookService, watermarks)
+	memberService := membershipservice.NewMembershipService(memberRepo, userRepo, activityRepo, projectRepo, twoFactorRepo, broker, webhookService)
+	authService := authservice.NewAuthService(userRepo, attemptRepo, sessionRepo, twoFactorRepo, tokenSigner, email.SendEmail, adminEmails())
+	commentService := commentservice.NewCommentService(commentRepo, userRepo, docPGRepo, memberRepo, activityRepo, notificationRepo, broker, webhookService)
+	digestService := digestservice.NewDigestService(digestRepo, activityRepo, memberRepo, projectRepo, userRepo, email.SendEmail, appURL)
+	tokenService := apitokenservice.NewAPITokenService(tokenRepo, userRepo, memberRepo, projectRepo)
+	notificationService := notificationservice.NewNotificationService(notificationRepo, userRepo, projectRepo, docPGRepo, email.SendEmail, appURL)
+
+	// send the daily and weekly digests in the background
+	go digestService.Start(context.Background(), time.Hour)
+
+	// email mentions shortly after they happen
+	go notificationService.Start(context.Background(), time.Minute)
 
-	// instantiate the services
-	userService := userservice.NewUserService(userRepo, projectRepo)
-	projService := projectservice.NewProjectService(projectRepo, docPGRepo, docS3Repo, userRepo, memberRepo, commentRepo)
-	docService := documentservice.NewDocumentService(docPGRepo, docS3Repo, userRepo, memberRepo, projectRepo, commentRepo)
-	memberService := membershipservice.NewMembershipService(memberRepo, userRepo)
-	authService := authservice.NewAuthService(userRepo)
-	commentService := commentservice.NewCommentService(commentRepo, userRepo)
+	// retry the uploads the scanner couldn't check at the time, and clear away abandoned ones
+	go docService.Start(context.Background(), time.Minute)
+
+	// send the webhook deliveries in the background
+	go webhookService.StartWorker(context.Background(), 10*time.Second)
 
 	// register the middleware BEFORE registering the routes
-	s.RegisterMiddleware(authService)
+	s.RegisterMiddleware(authService, tokenService, tokenSigner, bucketOrigin)
 
 	// register the routes
-	s.RegisterRoutes(userService, projService, docService, memberService, authService, commentService)
+	s.RegisterRoutes(userService, projService, docService, memberService, authService, commentService, digestService, webhookService, tokenService, notificationService, broker)
+
+	// register the JSON API
+	s.RegisterAPIRoutes(projService, docService, memberService, commentService)
 
 	return s
 }
 
-func (s *Server) RegisterMiddleware(authService *authservice.AuthService) {
+// the site admins are listed by email, comma separated, in ADMIN_EMAILS
+func adminEmails() []string {
+	emails := []string{}
+	for _, e := range strings.Split(os.Getenv("ADMIN_EMAILS"), ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			emails = append(emails, e)
+		}
+	}
+	return emails
+}
+
+func (s *Server) RegisterMiddleware(authService *authservice.AuthService, tokenService *apitokenservice.APITokenService, tokenSigner *signer.Signer, bucketOrigin string) {
 	// add middleware here
 	s.fiberApp.Use(
 		requestid.New(
@@ -107,57 +249,173 @@ func (s *Server) RegisterMiddleware(authService *authservice.AuthService) {
 	)
 
 	s.fiberApp.Use(logger.New())
-	s.fiberApp.Use(auth.New(authService))
+	// a panicking handler answers with a 500 instead of taking the server down
+	s.fiberApp.Use(recover.New())
+	headerConfig := headers.FromEnv()
+	// uploads are sent to the bucket, and previews are framed from it when downloads are linked
+	headerConfig.Policy = headerConfig.Policy.With("connect-src", bucketOrigin).With("frame-src", bucketOrigin)
+	s.fiberApp.Use(headers.New(headerConfig))
+	s.fiberApp.Use(auth.New(authService, tokenService))
+
+	// after auth so the CSRF token can be tied to the session
+	s.fiberApp.Use(csrf.New(tokenSigner))
 }
 
 func (s *Server) Start() error {
 	return s.fiberApp.Listen("0.0.0.0:8080")
 }
 
-func (s *Server) RegisterRoutes(userService *userservice.UserService, projectService *projectservice.ProjectService, documentService *documentservice.DocumentService, membershipService *membershipservice.MembershipService, authService *authservice.AuthService, commentService *commentservice.CommentService) {
+func (s *Server) RegisterRoutes(userService *userservice.UserService, projectService *projectservice.ProjectService, documentService *documentservice.DocumentService, membershipService *membershipservice.MembershipService, authService *authservice.AuthService, commentService *commentservice.CommentService, digestService *digestservice.DigestService, webhookService *webhookservice.WebhookService, tokenService *apitokenservice.APITokenService, notificationService *notificationservice.NotificationService, broker *eventbroker.Broker) {
 	// homepage
 	s.fiberApp.Get("/", routes.GetHomePage(projectService))
 
 	// auth routes - only for login
 	s.fiberApp.Get("/login/", routes.GetLoginPage(authService))
 	s.fiberApp.Post("/login/", routes.LoginUserHandler(authService))
+	s.fiberApp.Post("/login/two-factor", routes.LoginTwoFactorHandler(authService))
 	s.fiberApp.Post("/create-account", routes.PostCreateAccount(authService))
 
+	// admin routes
+	s.fiberApp.Get("/admin/lockouts/", routes.GetLockouts(authService))
+	s.fiberApp.Post("/admin/lockouts/unlock", routes.UnlockLogin(authService))
+
 	// user routes
 	s.fiberApp.Get("/create-account/", routes.GetCreateAccount(userService))
-	s.fiberApp.Get("/logout/", routes.LogoutUser(userService))
+	s.fiberApp.Post("/logout/", routes.LogoutUser(authService))
 	s.fiberApp.Get("/reset-password/", routes.GetResetPasswordPage(userService))
-	s.fiberApp.Get("/verify-old-password/", routes.VerifyOldPassword(userService))
+	s.fiberApp.Post("/verify-old-password/", routes.VerifyOldPassword(userService))
 	s.fiberApp.Post("/new-password/", routes.SetNewPassword(userService))
 
+	// session routes
+	s.fiberApp.Get("/sessions/", routes.GetSessions(authService))
+	s.fiberApp.Delete("/session/:session_id", routes.RevokeSession(authService))
+	s.fiberApp.Post("/sessions/revoke-all", routes.RevokeAllSessions(authService))
+
+	// two-factor authentication routes
+	s.fiberApp.Get("/two-factor/", routes.GetTwoFactor(authService))
+	s.fiberApp.Post("/two-factor/start", routes.StartTwoFactor(authService))
+	s.fiberApp.Post("/two-factor/enable", routes.EnableTwoFactor(authService))
+	s.fiberApp.Post("/two-factor/recovery-codes", routes.NewRecoveryCodes(authService))
+	s.fiberApp.Post("/two-factor/disable", routes.DisableTwoFactor(authService))
+
 	// member routes
 	s.fiberApp.Post("/search-users/:id", routes.SearchMembersByName(membershipService))
 	s.fiberApp.Post("/invite-member/:id/:project_id/", routes.InviteMember(membershipService))
 	s.fiberApp.Get("/member/:project_id/:member_id/", routes.GetMemberPage(membershipService))
 	s.fiberApp.Post("/member-roles/:project_id/:member_id/", routes.UpdateMemberRoles(membershipService))
 	s.fiberApp.Get("/sidebar/:project_id/", routes.GetSidebar(membershipService))
+	s.fiberApp.Get("/member-list/:project_id/", routes.GetMemberList(membershipService))
+	s.fiberApp.Get("/invited-list/:project_id/", routes.GetInvitedList(membershipService))
 
 	// project routes
-	s.fiberApp.Get("/create-project/", routes.CreateProject(projectService))
+	s.fiberApp.Post("/create-project/", routes.CreateProject(projectService))
 	s.fiberApp.Post("/join-org/:project_id/:role", routes.JoinOrg(projectService))
 	s.fiberApp.Get("/project/:project_id/", routes.GetProject(projectService))
-	s.fiberApp.Get("/delete-project/:project_id/", routes.DeleteProject(projectService))
+	s.fiberApp.Delete("/project/:project_id/", routes.DeleteProject(projectService))
 	s.fiberApp.Get("/click-delete-project/:project_id/", routes.ClickDeleteProject(projectService))
 	s.fiberApp.Get("/cancel-delete-project/:project_id/", routes.CancelDeleteProject(projectService))
 	s.fiberApp.Get("/project-name-form/:project_id/", routes.GetUpdateNameForm(projectService))
 	s.fiberApp.Post("/project-name/:project_id/", routes.UpdateProjectName(projectService))
+	s.fiberApp.Post("/project-two-factor/:project_id", routes.SetProjectTwoFactor(projectService))
+	s.fiberApp.Get("/staged-list/:project_id/", routes.GetStagedList(projectService, membershipService))
+	s.fiberApp.Get("/locked-list/:project_id/", routes.GetLockedList(projectService, membershipService))
+
+	// live project updates over server-sent events
+	s.fiberApp.Get("/events/:project_id/", routes.ProjectEvents(broker, membershipService))
+
+	// document routes
+	s.fiberApp.Get("/doc-details/:doc_id", routes.GetDocDetails(documentService, membershipService))
+	s.fiberApp.Post("/lock-staged-docs/:project_id/", routes.LockStagedDocs(documentService, membershipService))
+	s.fiberApp.Get("/download-doc/:doc_id", routes.DownloadDocument(documentService, membershipService))
+	s.fiberApp.Get("/thumbnail/:doc_id", routes.GetThumbnail(documentService, membershipService))
+	s.fiberApp.Delete("/doc/:doc_id", routes.DeleteDocument(documentService, membershipService))
+	s.fiberApp.Get("/preview-doc-page/:doc_id", routes.PreviewDocumentPage(documentService, commentService, membershipService))
+	s.fiberApp.Get("/preview-doc/:doc_id", routes.PreviewDocument(documentService, membershipService))
+
+	// comment routes
+	s.fiberApp.Get("/doc-comments/:doc_id", routes.GetDocCommentSection(commentService, documentService, membershipService))
+	s.fiberApp.Get("/doc-comments-list/:doc_id", routes.GetDocCommentsList(commentService, documentService, membershipService))
+	s.fiberApp.Post("/doc-comments/:doc_id/carry-forward/:from_doc_id", routes.CarryCommentsForward(commentService, documentService, membershipService))
+	s.fiberApp.Get("/doc-comment-markers/:doc_id", routes.GetDocCommentMarkers(commentService, documentService, membershipService))
+	s.fiberApp.Post("/doc-comment/:doc_id", routes.AddDocComment(commentService, documentService, membershipService))
+	s.fiberApp.Delete("/doc-comment/:comment_id", routes.DeleteComment(commentService, documentService, membershipService))
+	s.fiberApp.Put("/doc-comment/:comment_id", routes.EditComment(commentService, documentService, membershipService))
+	s.fiberApp.Get("/doc-comment-edit/:comment_id", routes.GetEditCommentForm(commentService, documentService, membershipService))
+	s.fiberApp.Get("/doc-comment-history/:comment_id", routes.GetCommentHistory(commentService, documentService, membershipService))
+	s.fiberApp.Get("/doc-comment-thread/:comment_id", routes.GetCommentThread(commentService, documentService, membershipService))
+	s.fiberApp.Get("/mention-suggestions/:doc_id", routes.GetMentionSuggestions(commentService, documentService, membershipService))
+	s.fiberApp.Post("/doc-comment-preview/:doc_id", routes.PreviewComment(commentService, documentService, membershipService))
+	s.fiberApp.Post("/doc-comment-reply/:comment_id", routes.AddCommentReply(commentService, documentService, membershipService))
+	s.fiberApp.Post("/doc-comment/:comment_id/resolve", routes.ResolveCommentThread(commentService, documentService, membershipService))
+	s.fiberApp.Post("/doc-comment/:comment_id/reopen", routes.ReopenCommentThread(commentService, documentService, membershipService))
+
+	// digest routes
+	s.fiberApp.Get("/digest-settings/", routes.GetDigestSettings(digestService))
+	s.fiberApp.Post("/digest-settings/", routes.UpdateDigestSettings(digestService))
+	s.fiberApp.Get("/unsubscribe/:token", routes.UnsubscribeFromDigest(digestService))
+
+	// api token routes
+	s.fiberApp.Get("/api-tokens/", routes.GetAPITokens(tokenService))
+	s.fiberApp.Post("/api-tokens/", routes.CreateAPIToken(tokenService))
+	s.fiberApp.Delete("/api-token/:token_id", routes.RevokeAPIToken(tokenService))
+
+	// notification routes
+	s.fiberApp.Get("/notifications/", routes.GetNotifications(notificationService))
+	s.fiberApp.Get("/notifications/count", routes.GetUnreadNotificationCount(notificationService))
+	s.fiberApp.Post("/notifications/read", routes.MarkAllNotificationsRead(notificationService))
+	s.fiberApp.Post("/notification/:notification_id/read", routes.OpenNotification(notificationService))
+
+	// webhook routes
+	s.fiberApp.Get("/webhooks/:project_id/", routes.GetProjectWebhooks(webhookService))
+	s.fiberApp.Post("/webhooks/:project_id/", routes.CreateWebhook(webhookService))
+	s.fiberApp.Delete("/webhook/:webhook_id", routes.DeleteWebhook(webhookService))
+	s.fiberApp.Get("/webhook-deliveries/:webhook_id", routes.GetWebhookDeliveries(webhookService))
+	s.fiberApp.Post("/webhook-delivery/:delivery_id/redeliver", routes.RedeliverWebhook(webhookService))
+}
+
+// the JSON API, see api/openapi.yaml
+func (s *Server) RegisterAPIRoutes(projectService *projectservice.ProjectService, documentService *documentservice.DocumentService, membershipService *membershipservice.MembershipService, commentService *commentservice.CommentService) {
+	v1 := s.fiberApp.Group("/api/v1")
+
+	// the description is public, everything after it needs a session or an API token
+	v1.Get("/openapi.yaml", api.OpenAPI())
+	v1.Use(api.RequireUser())
+
+	// project routes
+	v1.Get("/projects", api.ListProjects(projectService))
+	v1.Post("/projects", api.CreateProject(projectService))
+	v1.Get("/projects/:project_id", api.GetProject(projectService, membershipService))
+	v1.Patch("/projects/:project_id", api.UpdateProject(projectService, membershipService))
+	v1.Delete("/projects/:project_id", api.DeleteProject(projectService, membershipService))
+	v1.Post("/projects/:project_id/join", api.JoinProject(projectService, membershipService))
+
+	// member routes
+	v1.Get("/projects/:project_id/members", api.ListMembers(membershipService))
+	v1.Post("/projects/:project_id/members", api.InviteMember(membershipService))
+	v1.Patch("/projects/:project_id/members/:user_id", api.UpdateMemberRoles(membershipService))
 
 	// document routes
-	s.fiberApp.Get("/doc-details/:doc_id", routes.GetDocDetails(documentService))
-	s.fiberApp.Post("/file-submit/:project_id", routes.UploadDocumentHandler(documentService))
-	s.fiberApp.Post("/lock-staged-docs/:project_id/", routes.LockStagedDocs(documentService))
-	s.fiberApp.Get("/download-doc/:doc_id", routes.DownloadDocument(documentService))
-	s.fiberApp.Get("/delete-doc/:doc_id", routes.DeleteDocument(documentService))
-	s.fiberApp.Get("/preview-doc-page/:doc_id", routes.PreviewDocumentPage(documentService))
-	s.fiberApp.Get("/preview-doc/:doc_id", routes.PreviewDocument(documentService))
+	v1.Get("/projects/:project_id/documents", api.ListDocuments(projectService, membershipService))
+	v1.Post("/projects/:project_id/documents", api.UploadDocument(documentService, membershipService))
+	v1.Post("/projects/:project_id/documents/lock", api.LockDocuments(documentService, membershipService))
+	v1.Post("/projects/:project_id/uploads", api.StartUpload(documentService, membershipService))
+	v1.Get("/projects/:project_id/uploads/:upload_id", api.ResumeUpload(documentService, membershipService))
+	v1.Post("/projects/:project_id/uploads/:upload_id/complete", api.CompleteUpload(documentService, membershipService))
+	v1.Delete("/projects/:project_id/uploads/:upload_id", api.CancelUpload(documentService, membershipService))
+	v1.Get("/documents/:doc_id", api.GetDocument(documentService, membershipService))
+	v1.Get("/documents/:doc_id/download", api.DownloadDocument(documentService, membershipService))
+	v1.Delete("/documents/:doc_id", api.DeleteDocument(documentService, membershipService))
 
 	// comment routes
-	s.fiberApp.Get("/doc-comments/:doc_id", routes.GetDocCommentSection(commentService))
-	s.fiberApp.Post("/doc-comment/:doc_id", routes.AddDocComment(commentService))
-	s.fiberApp.Delete("/doc-comment/:comment_id", routes.DeleteComment(commentService))
+	v1.Get("/documents/:doc_id/comments", api.ListComments(commentService, documentService, membershipService))
+	v1.Post("/documents/:doc_id/comments", api.CreateComment(commentService, documentService, membershipService))
+	v1.Patch("/comments/:comment_id", api.EditComment(commentService, documentService, membershipService))
+	v1.Delete("/comments/:comment_id", api.DeleteComment(commentService, documentService, membershipService))
+	v1.Get("/comments/:comment_id/revisions", api.ListRevisions(commentService, documentService, membershipService))
+	v1.Post("/comments/:comment_id/replies", api.CreateReply(commentService, documentService, membershipService))
+	v1.Post("/comments/:comment_id/resolve", api.ResolveThread(commentService, documentService, membershipService))
+	v1.Post("/comments/:comment_id/reopen", api.ReopenThread(commentService, documentService, membershipService))
+
+	// anything else under /api/v1 gets a JSON 404
+	v1.Use(api.NotFound())
 }
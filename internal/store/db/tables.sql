@@ -1,4 +1,4 @@
-DROP TABLE IF EXISTS memberships_organizations, doc_comments, documents, memberships, organizations, users;
+DROP TABLE IF EXISTS blobs, uploads, data_keys, two_factor, sessions, login_attempts, document_versions, notifications, comment_revisions, api_tokens, webhook_deliveries, webhooks, digest_subscriptions, project_activity, memberships_organizations, doc_comments, documents, memberships, organizations, users;
 DROP TYPE IF EXISTS invite_status;
 
 CREATE TABLE "users" (
@@ -14,7 +14,8 @@ CREATE TABLE "organizations" (
     "owner_id" UUID REFERENCES users(id) ON DELETE CASCADE,
     "created_at" TIMESTAMP,
     "updated_at" TIMESTAMP,
-    "name" VARCHAR(50)
+    "name" VARCHAR(50),
+    "require_two_factor" BOOLEAN NOT NULL DEFAULT false
 );
 
 CREATE TYPE invite_status AS ENUM ('pending', 'accepted', 'rejected', 'revoked');
@@ -35,7 +36,13 @@ CREATE TABLE "documents" (
     "file_type" VARCHAR(50),
     "date" TIMESTAMP,
     "color" VARCHAR(50),
-    "status" VARCHAR(50)
+    "status" VARCHAR(50),
+    -- detected from the file when it's uploaded
+    "content_type" VARCHAR(100),
+    -- pending until the virus scanner has checked the file, then clean or quarantined
+    "scan_status" VARCHAR(20),
+    -- the hex SHA-256 of the file, which it's stored under
+    "checksum" VARCHAR(64)
 );
 
 CREATE TABLE "doc_comments" (
@@ -43,7 +50,23 @@ CREATE TABLE "doc_comments" (
     "document_id" UUID,
     "user_id" UUID,
     "created_at" TIMESTAMP,
-    "comment" VARCHAR(250)
+    "comment" VARCHAR(250),
+    -- replies point at the comment that started the thread, only threads are resolved
+    "parent_id" UUID REFERENCES doc_comments(id) ON DELETE CASCADE,
+    "resolved_at" TIMESTAMP,
+    "resolved_by" UUID REFERENCES users(id) ON DELETE SET NULL,
+    -- the page and region a comment is anchored to, the region as fractions of the page
+    "anchor_page" INTEGER,
+    "anchor_x" DOUBLE PRECISION,
+    "anchor_y" DOUBLE PRECISION,
+    "anchor_width" DOUBLE PRECISION,
+    "anchor_height" DOUBLE PRECISION,
+    "anchor_text" VARCHAR(500),
+    "edited_at" TIMESTAMP,
+    -- the members mentioned in the comment
+    "mentions" UUID[],
+    -- the version a thread was written on when it was carried forward from it
+    "carried_from_version" INTEGER
 );
 
 CREATE TABLE "memberships_organizations" (
@@ -52,10 +75,173 @@ CREATE TABLE "memberships_organizations" (
     PRIMARY KEY ("membership_id", "organization_id")
 );
 
+-- what happened on each project, for the email digests
+CREATE TABLE "project_activity" (
+    "id" UUID PRIMARY KEY,
+    "organization_id" UUID REFERENCES organizations(id) ON DELETE CASCADE,
+    "user_id" UUID REFERENCES users(id) ON DELETE CASCADE,
+    "kind" VARCHAR(50),
+    "detail" TEXT,
+    "created_at" TIMESTAMP
+);
+
+-- users without a row get the default frequency
+CREATE TABLE "digest_subscriptions" (
+    "user_id" UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    "frequency" VARCHAR(20),
+    "unsubscribe_token" VARCHAR(64) UNIQUE,
+    "last_sent_at" TIMESTAMP
+);
+
+-- the URLs project events are posted to
+CREATE TABLE "webhooks" (
+    "id" UUID PRIMARY KEY,
+    "organization_id" UUID REFERENCES organizations(id) ON DELETE CASCADE,
+    "url" TEXT,
+    "secret" TEXT,
+    "event_types" TEXT[],
+    "created_by" UUID REFERENCES users(id) ON DELETE CASCADE,
+    "created_at" TIMESTAMP
+);
+
+-- one row per event sent to a webhook, retried until it succeeds or runs out of attempts
+CREATE TABLE "webhook_deliveries" (
+    "id" UUID PRIMARY KEY,
+    "webhook_id" UUID REFERENCES webhooks(id) ON DELETE CASCADE,
+    "event_type" VARCHAR(50),
+    "payload" JSONB,
+    "status" VARCHAR(20),
+    "attempts" INTEGER NOT NULL DEFAULT 0,
+    "next_attempt_at" TIMESTAMP,
+    "response_code" INTEGER NOT NULL DEFAULT 0,
+    "last_error" TEXT NOT NULL DEFAULT '',
+    "created_at" TIMESTAMP,
+    "delivered_at" TIMESTAMP
+);
+
+-- personal tokens for the JSON API, only the hash of the token is kept
+CREATE TABLE "api_tokens" (
+    "id" UUID PRIMARY KEY,
+    "user_id" UUID REFERENCES users(id) ON DELETE CASCADE,
+    "name" VARCHAR(100),
+    "token_hash" VARCHAR(64) UNIQUE,
+    "scope" VARCHAR(20),
+    "project_ids" UUID[],
+    "expires_at" TIMESTAMP,
+    "last_used_at" TIMESTAMP,
+    "created_at" TIMESTAMP
+);
+
+-- the earlier text of edited comments, oldest first
+CREATE TABLE "comment_revisions" (
+    "id" UUID PRIMARY KEY,
+    "comment_id" UUID REFERENCES doc_comments(id) ON DELETE CASCADE,
+    "comment" VARCHAR(250),
+    "created_at" TIMESTAMP
+);
+
+-- the document and comment are kept as plain ids, the notification outlives them
+CREATE TABLE "notifications" (
+    "id" UUID PRIMARY KEY,
+    "user_id" UUID REFERENCES users(id) ON DELETE CASCADE,
+    "actor_id" UUID REFERENCES users(id) ON DELETE CASCADE,
+    "kind" VARCHAR(50),
+    "organization_id" UUID REFERENCES organizations(id) ON DELETE CASCADE,
+    "document_id" UUID,
+    "comment_id" UUID,
+    "excerpt" VARCHAR(150),
+    "created_at" TIMESTAMP,
+    "read_at" TIMESTAMP,
+    "emailed_at" TIMESTAMP
+);
+
+-- the numbered versions of each file type, the documents they replaced are kept as superseded
+CREATE TABLE "document_versions" (
+    "document_id" UUID PRIMARY KEY REFERENCES documents(id) ON DELETE CASCADE,
+    "organization_id" UUID REFERENCES organizations(id) ON DELETE CASCADE,
+    "file_type" VARCHAR(50),
+    "version" INTEGER,
+    "file_name" VARCHAR(100),
+    "user_id" UUID REFERENCES users(id),
+    "created_at" TIMESTAMP,
+    UNIQUE ("organization_id", "file_type", "version")
+);
+
+-- failed logins by account and by IP, the key is the kind and subject together
+CREATE TABLE "login_attempts" (
+    "key" TEXT PRIMARY KEY,
+    "kind" VARCHAR(20),
+    "subject" TEXT,
+    "failures" INTEGER NOT NULL DEFAULT 0,
+    "last_failure" TIMESTAMP,
+    "locked_until" TIMESTAMP
+);
+
+-- one row per login, the session token carries the id
+CREATE TABLE "sessions" (
+    "id" UUID PRIMARY KEY,
+    "user_id" UUID REFERENCES users(id) ON DELETE CASCADE,
+    "user_agent" TEXT,
+    "ip" VARCHAR(45),
+    "created_at" TIMESTAMP,
+    "last_seen_at" TIMESTAMP,
+    "expires_at" TIMESTAMP,
+    "revoked_at" TIMESTAMP
+);
+
+-- the TOTP secret is enabled once the first code is confirmed, the recovery codes are kept hashed
+CREATE TABLE "two_factor" (
+    "user_id" UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    "secret" VARCHAR(64),
+    "enabled_at" TIMESTAMP,
+    "last_used_step" BIGINT NOT NULL DEFAULT 0,
+    "recovery_hashes" TEXT[],
+    "created_at" TIMESTAMP
+);
+
+-- each project's files are encrypted with its own key, stored wrapped by the master key
+CREATE TABLE "data_keys" (
+    "project_id" UUID PRIMARY KEY REFERENCES organizations(id) ON DELETE CASCADE,
+    "master_key_id" VARCHAR(100),
+    "wrapped_key" BYTEA,
+    "created_at" TIMESTAMP,
+    "rotated_at" TIMESTAMP
+);
+
+-- uploads sent straight to the bucket in parts, removed once stored as a document or abandoned
+CREATE TABLE "uploads" (
+    "id" UUID PRIMARY KEY,
+    "project_id" UUID REFERENCES organizations(id) ON DELETE CASCADE,
+    "user_id" UUID REFERENCES users(id) ON DELETE CASCADE,
+    "file_name" TEXT,
+    "file_type" VARCHAR(50),
+    "size" BIGINT,
+    "store_id" TEXT,
+    "created_at" TIMESTAMP
+);
+
+-- how many documents of a project use each stored file, the file is deleted when none do
+CREATE TABLE "blobs" (
+    "project_id" UUID REFERENCES organizations(id) ON DELETE CASCADE,
+    "checksum" VARCHAR(64),
+    "size" BIGINT,
+    "ref_count" INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY ("project_id", "checksum")
+);
+
 ALTER TABLE "memberships" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id");
 ALTER TABLE "documents" ADD FOREIGN KEY ("organization_id") REFERENCES "organizations" ("id");
 ALTER TABLE "doc_comments" ADD FOREIGN KEY ("document_id") REFERENCES "documents" ("id");
 ALTER TABLE "doc_comments" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id");
 ALTER TABLE "documents" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id");
 CREATE UNIQUE INDEX unique_org_file_status ON documents (organization_id, file_type) WHERE status IN ('locked');
-
+CREATE INDEX project_activity_created ON project_activity (organization_id, created_at);
+CREATE INDEX webhook_deliveries_due ON webhook_deliveries (status, next_attempt_at);
+CREATE INDEX webhook_deliveries_webhook ON webhook_deliveries (webhook_id, created_at);
+CREATE INDEX doc_comments_parent ON doc_comments (parent_id);
+CREATE INDEX comment_revisions_comment ON comment_revisions (comment_id, created_at);
+CREATE INDEX notifications_user ON notifications (user_id, created_at);
+CREATE INDEX login_attempts_locked ON login_attempts (locked_until);
+CREATE INDEX sessions_user ON sessions (user_id, expires_at);
+CREATE INDEX documents_pending_scan ON documents (date) WHERE scan_status = 'pending';
+CREATE INDEX uploads_created ON uploads (created_at);
@@ -0,0 +1,8 @@
+// sends the CSRF token from its cookie with every htmx request, the server rejects
+// state-changing requests without it, see internal/application/middleware/csrf
+document.addEventListener("htmx:configRequest", (event) => {
+  const match = document.cookie.match(/(?:^|;\s*)filmpackager_csrf=([^;]*)/);
+  if (match) {
+    event.detail.headers["X-CSRF-Token"] = decodeURIComponent(match[1]);
+  }
+});
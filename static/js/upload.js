@@ -0,0 +1,115 @@
+// stages files by sending them straight to the bucket in parts, see /api/v1/projects/{id}/uploads.
+// If the connection drops, picking the same file again carries on from the parts already sent
+(() => {
+  const csrfToken = () => {
+    const match = document.cookie.match(/(?:^|;\s*)filmpackager_csrf=([^;]*)/);
+    return match ? decodeURIComponent(match[1]) : "";
+  };
+
+  async function api(method, url, body) {
+    const res = await fetch(url, {
+      method,
+      credentials: "same-origin",
+      headers: { "Content-Type": "application/json", "X-CSRF-Token": csrfToken() },
+      body: body ? JSON.stringify(body) : undefined,
+    });
+    const json = res.status === 204 ? {} : await res.json();
+    if (!res.ok) {
+      throw new Error(json.error ? json.error.message : "the upload failed");
+    }
+    return json.data;
+  }
+
+  // PUTs one part to the bucket, reporting how much of it has gone
+  function putPart(url, blob, onProgress) {
+    return new Promise((resolve, reject) => {
+      const xhr = new XMLHttpRequest();
+      xhr.open("PUT", url);
+      xhr.upload.onprogress = (event) => onProgress(event.loaded);
+      xhr.onload = () =>
+        xhr.status < 300
+          ? resolve()
+          : reject(new Error("part of the file didn't upload, stage it again to carry on"));
+      xhr.onerror = () => reject(new Error("the connection dropped, stage the file again to carry on"));
+      xhr.send(blob);
+    });
+  }
+
+  // the same file for the same project and type picks up its unfinished upload
+  const resumeKey = (projectID, fileType, file) =>
+    `upload:${projectID}:${fileType}:${file.name}:${file.size}:${file.lastModified}`;
+
+  async function session(base, key, file, fileType) {
+    const saved = localStorage.getItem(key);
+    if (saved) {
+      try {
+        return await api("GET", `${base}/${saved}`);
+      } catch (e) {
+        // finished, cancelled or abandoned, so start again
+        localStorage.removeItem(key);
+      }
+    }
+
+    const started = await api("POST", base, { file_name: file.name, file_type: fileType, size: file.size });
+    localStorage.setItem(key, started.id);
+    return started;
+  }
+
+  async function upload(form, progress) {
+    const projectID = form.dataset.project;
+    const file = form.querySelector("input[type=file]").files[0];
+    const fileType = form.querySelector("select[name=file-type]").value;
+    if (!file) {
+      throw new Error("pick a file to stage");
+    }
+
+    const base = `/api/v1/projects/${projectID}/uploads`;
+    const key = resumeKey(projectID, fileType, file);
+    const s = await session(base, key, file, fileType);
+
+    // the last part is the only one shorter than part_size
+    const partSize = (n) => Math.min(s.part_size, file.size - (n - 1) * s.part_size);
+    let sent = s.done.reduce((total, n) => total + partSize(n), 0);
+
+    progress.max = file.size;
+    progress.value = sent;
+    progress.hidden = false;
+
+    for (const part of s.parts) {
+      const start = (part.number - 1) * s.part_size;
+      const blob = file.slice(start, start + s.part_size);
+      await putPart(part.url, blob, (loaded) => (progress.value = sent + loaded));
+      sent += blob.size;
+    }
+
+    try {
+      await api("POST", `${base}/${s.id}/complete`);
+    } finally {
+      localStorage.removeItem(key);
+    }
+
+    form.reset();
+    htmx.ajax("GET", `/staged-list/${projectID}/`, { target: "#staged-list", swap: "innerHTML" });
+  }
+
+  document.addEventListener("submit", (event) => {
+    const form = event.target;
+    if (form.id !== "doc-input") {
+      return;
+    }
+    event.preventDefault();
+
+    const button = form.querySelector("button[type=submit]");
+    const progress = document.getElementById("upload-progress");
+    const error = document.getElementById("upload-error");
+    error.textContent = "";
+    button.disabled = true;
+
+    upload(form, progress)
+      .catch((e) => (error.textContent = `Error: ${e.message}`))
+      .finally(() => {
+        button.disabled = false;
+        progress.hidden = true;
+      });
+  });
+})();